@@ -7,5 +7,5 @@ import (
 )
 
 func main() {
-	skm.Main(os.Args)
+	os.Exit(skm.Main(os.Args)) //nolint:forbidigo // the exit code is set by the command that ran
 }
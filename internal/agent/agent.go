@@ -0,0 +1,117 @@
+// Package agent caches the pinUvAuthTokens of security keys across skm invocations, so the PIN is entered once
+// for a series of commands. A Server holds the tokens in memory locked into RAM for a short time, and commands
+// talk to it over a Unix socket only the user can access.
+package agent
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/skm/internal/authenticator"
+)
+
+const (
+	// EnvSocket is the environment variable that sets the path of the agent socket.
+	EnvSocket = "SKM_AGENT_SOCK"
+	// DefaultTTL is how long a token is cached by default.
+	DefaultTTL = 5 * time.Minute
+)
+
+// SocketPath returns the path of the agent socket: SKM_AGENT_SOCK if set, otherwise skm/agent.sock in
+// $XDG_RUNTIME_DIR, or in the user cache directory if it isn't set.
+func SocketPath() (string, error) {
+	if path := os.Getenv(EnvSocket); path != "" {
+		return path, nil
+	}
+
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		var err error
+		dir, err = os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find a directory for the agent socket, set %s: %w", EnvSocket, err)
+		}
+	}
+	return filepath.Join(dir, "skm", "agent.sock"), nil
+}
+
+// Key identifies the security key desc in the agent: its vendor, product and serial number if it has one, as
+// they survive the key being replugged, or else its path.
+func Key(desc fido2.DeviceDescriptor) string {
+	if desc.SerialNumber == "" {
+		return "path:" + desc.Path
+	}
+	return fmt.Sprintf("usb:%04x:%04x:%s", desc.VendorID, desc.ProductID, desc.SerialNumber)
+}
+
+// Cacheable reports whether a token with permissions for rpID is cached. Valid must be able to check it with a
+// command that needs no touch: getCredsMetadata or enumerateCredentials for the credential management
+// permission, enumerateEnrollments for bio enrollment, and a silent getAssertion for the getAssertion
+// permission, which is bound to an RP ID.
+func Cacheable(permissions ctap2.Permission, rpID string) bool {
+	return permissions&(ctap2.PermissionCredentialManagement|ctap2.PermissionBioEnrollment) != 0 ||
+		(rpID != "" && permissions&ctap2.PermissionGetAssertion != 0)
+}
+
+// Permissions returns the permissions to request a token for rpID with, so that it can be cached: a token with
+// the makeCredential permission, which Valid cannot check without creating a credential, is also given the
+// getAssertion permission.
+func Permissions(permissions ctap2.Permission, rpID string) ctap2.Permission {
+	if rpID != "" && permissions&ctap2.PermissionMakeCredential != 0 {
+		permissions |= ctap2.PermissionGetAssertion
+	}
+	return permissions
+}
+
+// Valid reports whether dev still accepts token, issued with permissions for rpID, which it forgets when it is
+// unplugged or issues another token, e.g. to a browser. The token is checked with a command allowed by
+// permissions that needs no touch, and that changes nothing on dev but the signature counter for getAssertion.
+func Valid(dev authenticator.Device, token []byte, permissions ctap2.Permission, rpID string) bool {
+	var err error
+	switch {
+	case permissions&ctap2.PermissionCredentialManagement != 0 && rpID != "":
+		// A token bound to an RP ID only manages the credentials of that RP.
+		rpIDHash := sha256.Sum256([]byte(rpID))
+		for _, err = range dev.EnumerateCredentials(token, rpIDHash[:]) {
+			break
+		}
+	case permissions&ctap2.PermissionCredentialManagement != 0:
+		_, err = dev.GetCredsMetadata(token)
+	case permissions&ctap2.PermissionBioEnrollment != 0:
+		_, err = dev.EnumerateEnrollments(token)
+		// Authenticators report CTAP2_ERR_INVALID_OPTION when no fingerprint is enrolled.
+		if hasStatus(err, ctaphid.StatusCTAP2ErrInvalidOption) {
+			return true
+		}
+	case permissions&ctap2.PermissionGetAssertion != 0 && rpID != "":
+		// Without user presence, the assertion needs no touch. It is still signed, so keys increase the signature
+		// counter of the credential, or their global counter, as for any other assertion.
+		clientData := make([]byte, 32)
+		if _, err := rand.Read(clientData); err != nil {
+			return false
+		}
+		assertions := dev.GetAssertion(token, rpID, clientData, nil, nil, map[ctap2.Option]bool{
+			ctap2.OptionUserPresence: false,
+		})
+		for _, err = range assertions {
+			break
+		}
+	default:
+		return false
+	}
+	// A token that is accepted for an RP without credentials gets CTAP2_ERR_NO_CREDENTIALS.
+	return err == nil || hasStatus(err, ctaphid.StatusCTAP2ErrNoCredentials)
+}
+
+func hasStatus(err error, status ctaphid.StatusCode) bool {
+	var ctapErr *ctaphid.CTAPError
+	return errors.As(err, &ctapErr) && ctapErr.StatusCode == status
+}
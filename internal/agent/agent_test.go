@@ -0,0 +1,336 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/virtual"
+)
+
+const (
+	cm  = ctap2.PermissionCredentialManagement
+	lbw = ctap2.PermissionLargeBlobWrite
+	be  = ctap2.PermissionBioEnrollment
+	mc  = ctap2.PermissionMakeCredential
+	ga  = ctap2.PermissionGetAssertion
+)
+
+// startServer runs s on a socket set in SKM_AGENT_SOCK until the test ends.
+func startServer(t *testing.T, s *Server) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	t.Setenv(EnvSocket, path)
+
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(ctx, l)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Serve() error = %v, want %v", err, context.Canceled)
+		}
+	})
+	return path
+}
+
+func TestCache(t *testing.T) {
+	startServer(t, &Server{})
+	ctx := context.Background()
+	token := bytes.Repeat([]byte{0xab}, 32)
+
+	if err := Put(ctx, "usb:1050:0407:0001", cm|lbw, "", token); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := Put(ctx, "path:/dev/hidraw1", cm, "example.com", token); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		permissions ctap2.Permission
+		rpID        string
+		want        bool
+	}{
+		{name: "same permissions", key: "usb:1050:0407:0001", permissions: cm | lbw, want: true},
+		{name: "fewer permissions", key: "usb:1050:0407:0001", permissions: cm, want: true},
+		{name: "any RP ID", key: "usb:1050:0407:0001", permissions: cm, rpID: "example.com", want: true},
+		{name: "missing permission", key: "usb:1050:0407:0001", permissions: cm | be},
+		{name: "bound RP ID", key: "path:/dev/hidraw1", permissions: cm, rpID: "example.com", want: true},
+		{name: "other RP ID", key: "path:/dev/hidraw1", permissions: cm, rpID: "example.org"},
+		{name: "no RP ID", key: "path:/dev/hidraw1", permissions: cm},
+		{name: "other key", key: "usb:1050:0407:0002", permissions: cm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Get(ctx, tt.key, tt.permissions, tt.rpID)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if tt.want && !bytes.Equal(got, token) {
+				t.Errorf("Get() = %x, want %x", got, token)
+			}
+			if !tt.want && got != nil {
+				t.Errorf("Get() = %x, want nil", got)
+			}
+		})
+	}
+
+	if err := Forget(ctx, "usb:1050:0407:0001"); err != nil {
+		t.Fatalf("Forget() error = %v", err)
+	}
+	if got, _ := Get(ctx, "usb:1050:0407:0001", cm, ""); got != nil {
+		t.Errorf("Get() after Forget() = %x, want nil", got)
+	}
+
+	if err := Clear(ctx); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	status, err := GetStatus(ctx)
+	if err != nil {
+		t.Fatalf("GetStatus() error = %v", err)
+	}
+	if len(status.Entries) != 0 {
+		t.Errorf("entries after Clear() = %+v, want none", status.Entries)
+	}
+}
+
+func TestLock(t *testing.T) {
+	startServer(t, &Server{})
+	ctx := context.Background()
+	token := []byte("token")
+
+	if err := Put(ctx, "key", cm, "", token); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := Lock(ctx); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	if got, _ := Get(ctx, "key", cm, ""); got != nil {
+		t.Errorf("Get() while locked = %q, want nil", got)
+	}
+	if err := Put(ctx, "key", cm, "", token); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Errorf("Put() while locked error = %v, want the agent is locked", err)
+	}
+	if status, err := GetStatus(ctx); err != nil || !status.Locked || len(status.Entries) != 0 {
+		t.Errorf("GetStatus() = %+v, %v, want locked without entries", status, err)
+	}
+
+	if err := Unlock(ctx); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if err := Put(ctx, "key", cm, "", token); err != nil {
+		t.Fatalf("Put() after Unlock() error = %v", err)
+	}
+	if got, _ := Get(ctx, "key", cm, ""); !bytes.Equal(got, token) {
+		t.Errorf("Get() after Unlock() = %q, want %q", got, token)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s := &Server{TTL: 50 * time.Millisecond}
+	startServer(t, s)
+	ctx := context.Background()
+
+	if err := Put(ctx, "key", cm, "", []byte("token")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if got, _ := Get(ctx, "key", cm, ""); got != nil {
+		t.Errorf("Get() after the TTL = %q, want nil", got)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) != 0 || !bytes.Equal(s.vault.mem, make([]byte, len(s.vault.mem))) {
+		t.Error("the expired token was not wiped")
+	}
+}
+
+func TestDropRemovedKeys(t *testing.T) {
+	yubikey := fido2.DeviceDescriptor{Path: "/dev/hidraw0", VendorID: 0x1050, ProductID: 0x0407, SerialNumber: "1"}
+	solo := fido2.DeviceDescriptor{Path: "/dev/hidraw1"}
+
+	var mu sync.Mutex
+	devs := []fido2.DeviceDescriptor{yubikey, solo}
+	startServer(t, &Server{Enumerate: func() ([]fido2.DeviceDescriptor, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return devs, nil
+	}})
+	ctx := context.Background()
+
+	for _, desc := range []fido2.DeviceDescriptor{yubikey, solo} {
+		if err := Put(ctx, Key(desc), cm, "", []byte("token")); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	// Let the agent enumerate the keys before one is removed.
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	devs = []fido2.DeviceDescriptor{solo}
+	mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := Get(ctx, Key(yubikey), cm, "")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the token of the removed key is still cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got, _ := Get(ctx, Key(solo), cm, ""); got == nil {
+		t.Error("the token of the key still present was dropped")
+	}
+}
+
+func TestNotRunning(t *testing.T) {
+	t.Setenv(EnvSocket, filepath.Join(t.TempDir(), "agent.sock"))
+
+	if _, err := Get(context.Background(), "key", cm, ""); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Get() error = %v, want %v", err, ErrNotRunning)
+	}
+}
+
+func TestListen(t *testing.T) {
+	path := startServer(t, &Server{})
+
+	if _, err := Listen(path); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("Listen() error = %v, want an agent is already running", err)
+	}
+
+	// The socket of an agent that is gone is replaced.
+	stale := filepath.Join(t.TempDir(), "stale.sock")
+	l, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false) //nolint:forcetypeassert // a unix listener
+	_ = l.Close()
+
+	l, err = Listen(stale)
+	if err != nil {
+		t.Fatalf("Listen() over a stale socket error = %v", err)
+	}
+	_ = l.Close()
+}
+
+func TestListenPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+
+	// An existing directory that others can access is made private.
+	dir := filepath.Join(t.TempDir(), "skm")
+	if err := os.Mkdir(dir, 0o755); err != nil { //nolint:gosec // the mode Listen has to fix
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+	path := filepath.Join(dir, "agent.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm() != 0o700 {
+		t.Errorf("directory mode = %v, %v, want 0700", fi.Mode().Perm(), err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode = %v, %v, want 0600", fi.Mode().Perm(), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("directory entries = %v, want only the socket", entries)
+	}
+
+	_ = l.Close()
+	if _, err := os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket after Close() error = %v, want it removed", err)
+	}
+
+	// A directory that isn't the user's is refused.
+	if os.Getuid() != 0 {
+		if _, err := Listen("/agent.sock"); err == nil {
+			t.Error("Listen() in a directory of another user error = nil")
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	a, err := virtual.Open(filepath.Join(t.TempDir(), "key.json"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() {
+		_ = a.Close()
+	}()
+	if err := a.SetPIN("1234"); err != nil {
+		t.Fatalf("SetPIN() error = %v", err)
+	}
+
+	tests := []struct {
+		permissions ctap2.Permission
+		rpID        string
+	}{
+		{permissions: cm},
+		{permissions: cm | lbw},
+		{permissions: be},
+		{permissions: cm, rpID: "example.com"},
+		{permissions: ga, rpID: "example.com"},
+		{permissions: Permissions(mc, "example.com"), rpID: "example.com"},
+	}
+
+	for _, tt := range tests {
+		if !Cacheable(tt.permissions, tt.rpID) {
+			t.Errorf("Cacheable(%v, %q) = false", tt.permissions, tt.rpID)
+		}
+
+		token, err := a.GetPinUvAuthTokenUsingPIN("1234", tt.permissions, tt.rpID)
+		if err != nil {
+			t.Fatalf("GetPinUvAuthTokenUsingPIN() error = %v", err)
+		}
+		if !Valid(a, token, tt.permissions, tt.rpID) {
+			t.Errorf("Valid(%v, %q) = false for the current token", tt.permissions, tt.rpID)
+		}
+
+		// A new token replaces the previous one.
+		if _, err := a.GetPinUvAuthTokenUsingPIN("1234", tt.permissions, tt.rpID); err != nil {
+			t.Fatalf("GetPinUvAuthTokenUsingPIN() error = %v", err)
+		}
+		if Valid(a, token, tt.permissions, tt.rpID) {
+			t.Errorf("Valid(%v, %q) = true for a replaced token", tt.permissions, tt.rpID)
+		}
+	}
+
+	if Cacheable(mc, "example.com") {
+		t.Error("Cacheable(mc) = true, want false as the token cannot be checked")
+	}
+}
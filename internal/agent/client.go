@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+)
+
+// ErrNotRunning is returned when no agent is listening on the socket.
+var ErrNotRunning = errors.New("no agent is running")
+
+// Get returns the token cached for the security key identified by key, if it was issued with permissions and
+// for rpID, or nil if there is none.
+func Get(ctx context.Context, key string, permissions ctap2.Permission, rpID string) ([]byte, error) {
+	resp, err := call(ctx, &request{Op: opGet, Key: key, Permissions: permissions, RPID: rpID})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Token, nil
+}
+
+// Put caches token, issued with permissions for rpID, as the token of the security key identified by key.
+func Put(ctx context.Context, key string, permissions ctap2.Permission, rpID string, token []byte) error {
+	_, err := call(ctx, &request{Op: opPut, Key: key, Permissions: permissions, RPID: rpID, Token: token})
+	return err
+}
+
+// Forget drops the token cached for the security key identified by key, e.g. after its PIN changed.
+func Forget(ctx context.Context, key string) error {
+	_, err := call(ctx, &request{Op: opForget, Key: key})
+	return err
+}
+
+// Clear drops all the cached tokens.
+func Clear(ctx context.Context) error {
+	_, err := call(ctx, &request{Op: opClear})
+	return err
+}
+
+// Lock drops all the cached tokens and makes the agent refuse new ones until Unlock.
+func Lock(ctx context.Context) error {
+	_, err := call(ctx, &request{Op: opLock})
+	return err
+}
+
+// Unlock makes a locked agent cache tokens again.
+func Unlock(ctx context.Context) error {
+	_, err := call(ctx, &request{Op: opUnlock})
+	return err
+}
+
+// GetStatus returns the state of the agent.
+func GetStatus(ctx context.Context) (*Status, error) {
+	resp, err := call(ctx, &request{Op: opStatus})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status == nil {
+		return nil, errors.New("the agent did not report its status")
+	}
+	return resp.Status, nil
+}
+
+// call sends req to the agent and returns its response.
+func call(ctx context.Context, req *request) (*response, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ioTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("%w at %s", ErrNotRunning, path)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send the request to the agent: %w", err)
+	}
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("invalid response from the agent: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("agent: %s", resp.Error)
+	}
+	return &resp, nil
+}
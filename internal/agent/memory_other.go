@@ -0,0 +1,10 @@
+//go:build !unix
+
+package agent
+
+import "errors"
+
+// lockedMemory is not supported on this platform, where the tokens are kept in ordinary memory.
+func lockedMemory(int) ([]byte, error) {
+	return nil, errors.New("locking memory is not supported on this platform")
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package agent
+
+import "golang.org/x/sys/unix"
+
+// lockedMemory maps size bytes of anonymous memory and locks them into RAM.
+func lockedMemory(size int) ([]byte, error) {
+	mem, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Mlock(mem); err != nil {
+		_ = unix.Munmap(mem)
+		return nil, err
+	}
+	return mem, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package agent
+
+import "os"
+
+// checkOwner does nothing on this platform, which has no user IDs.
+func checkOwner(string, os.FileInfo) error {
+	return nil
+}
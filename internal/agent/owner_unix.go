@@ -0,0 +1,43 @@
+//go:build unix
+
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// checkOwner returns an error unless the file at path, described by fi, belongs to the user.
+func checkOwner(path string, fi os.FileInfo) error {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s belongs to another user, set %s to a path in a directory of yours", path, EnvSocket)
+	}
+	return nil
+}
+
+// peerIsUser reports whether the peer of conn runs as the user, according to the uid uid returns for the
+// socket.
+func peerIsUser(conn net.Conn, uid func(fd int) (uint32, error)) bool {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return false
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return false
+	}
+
+	var (
+		peer    uint32
+		peerErr error
+	)
+	if err := raw.Control(func(fd uintptr) {
+		peer, peerErr = uid(int(fd)) //nolint:gosec // a file descriptor fits in an int
+	}); err != nil || peerErr != nil {
+		return false
+	}
+	return int(peer) == os.Getuid()
+}
@@ -0,0 +1,18 @@
+package agent
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerAllowed reports whether the client at the other end of conn runs as the user, from its LOCAL_PEERCRED.
+func peerAllowed(conn net.Conn) bool {
+	return peerIsUser(conn, func(fd int) (uint32, error) {
+		cred, err := unix.GetsockoptXucred(fd, unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+		if err != nil {
+			return 0, err
+		}
+		return cred.Uid, nil
+	})
+}
@@ -0,0 +1,376 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+)
+
+const (
+	// ioTimeout bounds a connection to the agent, from the request to the response.
+	ioTimeout = 5 * time.Second
+	// watchInterval is how often the security keys are enumerated to find those removed, when the platform
+	// doesn't report it.
+	watchInterval = time.Second
+)
+
+// Operations of the agent protocol. A connection carries a single request and its response, each a line of
+// JSON.
+const (
+	opGet    = "get"
+	opPut    = "put"
+	opForget = "forget"
+	opClear  = "clear"
+	opLock   = "lock"
+	opUnlock = "unlock"
+	opStatus = "status"
+)
+
+type request struct {
+	Op          string           `json:"op"`
+	Key         string           `json:"key,omitempty"`
+	Permissions ctap2.Permission `json:"permissions,omitempty"`
+	RPID        string           `json:"rpId,omitempty"`
+	Token       []byte           `json:"token,omitempty"`
+}
+
+type response struct {
+	Token  []byte  `json:"token,omitempty"`
+	Status *Status `json:"status,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Status is the state of an agent.
+type Status struct {
+	// Locked is set while the agent refuses to cache tokens, see Lock.
+	Locked bool `json:"locked"`
+	// MemoryLocked reports whether the tokens are kept in memory locked into RAM.
+	MemoryLocked bool `json:"memoryLocked"`
+	// TTL is how long a token is cached.
+	TTL time.Duration `json:"ttl"`
+	// Entries describe the cached tokens, ordered by key.
+	Entries []Entry `json:"entries"`
+}
+
+// Entry describes a cached token, without its value.
+type Entry struct {
+	// Key identifies the security key, see Key.
+	Key string `json:"key"`
+	// Permissions are the permissions the token was issued with.
+	Permissions ctap2.Permission `json:"permissions"`
+	// RPID is the RP ID the token is bound to, if any.
+	RPID string `json:"rpId,omitempty"`
+	// Expires is when the token is dropped.
+	Expires time.Time `json:"expires"`
+}
+
+// Server caches a token per security key for the clients connecting to its socket. It hands a token out for
+// a request whose permissions it was issued with, and whose RP ID it is bound to, if any.
+type Server struct {
+	// TTL is how long a token is cached, DefaultTTL if zero.
+	TTL time.Duration
+	// Enumerate lists the connected security keys, so that the tokens of those removed are dropped. Nil
+	// disables it.
+	Enumerate func() ([]fido2.DeviceDescriptor, error)
+
+	mu      sync.Mutex
+	vault   *vault
+	entries map[string]*entry
+	locked  bool
+}
+
+type entry struct {
+	Entry
+	slot  int
+	size  int
+	timer *time.Timer
+}
+
+// Listen creates the agent socket at path, in a directory only the user can access, and makes it accessible to
+// the user only. The socket of an agent that is no longer running is replaced.
+func Listen(path string) (net.Listener, error) {
+	dir := filepath.Dir(path)
+	if err := privateDir(dir); err != nil {
+		return nil, err
+	}
+
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("an agent is already running at %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	// The socket is created in a new directory only the user can access, and moved in place once only the user
+	// can access it, so it is never reachable with the permissions of the umask.
+	tmp, err := os.MkdirTemp(dir, ".agent")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	tmpPath := filepath.Join(tmp, "s")
+	l, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	ul := l.(*net.UnixListener) //nolint:forcetypeassert // a unix socket always has a unix listener
+	ul.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmpPath, 0o600); err != nil {
+		_ = ul.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = ul.Close()
+		return nil, err
+	}
+	return &listener{UnixListener: ul, path: path}, nil
+}
+
+// privateDir creates dir if needed, and makes sure that it belongs to the user and only the user can access it.
+func privateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if err := checkOwner(dir, fi); err != nil {
+		return err
+	}
+	if fi.Mode().Perm() != 0o700 {
+		return os.Chmod(dir, 0o700)
+	}
+	return nil
+}
+
+// listener is the listener of the agent socket, which removes the socket it was moved to when it is closed.
+type listener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *listener) Close() error {
+	err := l.UnixListener.Close()
+	_ = os.Remove(l.path)
+	return err
+}
+
+// MemoryLocked reports whether the tokens are kept in memory locked into RAM. Locking fails when it exceeds
+// RLIMIT_MEMLOCK, and isn't supported on Windows.
+func (s *Server) MemoryLocked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.init()
+	return s.vault.locked
+}
+
+// Serve answers the clients connecting to l until ctx is done, then closes l and wipes the cached tokens.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	s.mu.Lock()
+	s.init()
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		s.mu.Lock()
+		s.clear()
+		s.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	context.AfterFunc(ctx, func() {
+		_ = l.Close()
+	})
+	if s.Enumerate != nil {
+		wg.Go(func() {
+			s.watch(ctx)
+		})
+	}
+
+	for {
+		conn, err := l.Accept()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		wg.Go(func() {
+			s.handle(conn)
+		})
+	}
+}
+
+// watch drops the tokens of the security keys that are removed.
+func (s *Server) watch(ctx context.Context) {
+	for e, err := range authenticator.Watch(ctx, s.Enumerate, watchInterval) {
+		if err == nil && e.Type == authenticator.EventDetach {
+			s.mu.Lock()
+			s.drop(Key(e.Device))
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	if !peerAllowed(conn) {
+		return
+	}
+	_ = conn.SetDeadline(time.Now().Add(ioTimeout))
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	resp := s.do(&req)
+	clear(req.Token)
+
+	_ = json.NewEncoder(conn).Encode(resp)
+	clear(resp.Token)
+}
+
+func (s *Server) do(req *request) *response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Op {
+	case opGet:
+		return &response{Token: s.get(req)}
+	case opPut:
+		if err := s.put(req); err != nil {
+			return &response{Error: err.Error()}
+		}
+	case opForget:
+		s.drop(req.Key)
+	case opClear:
+		s.clear()
+	case opLock:
+		s.clear()
+		s.locked = true
+	case opUnlock:
+		s.locked = false
+	case opStatus:
+		return &response{Status: s.status()}
+	default:
+		return &response{Error: fmt.Sprintf("unknown operation %q", req.Op)}
+	}
+	return &response{}
+}
+
+// get returns a copy of the token cached for the request, or nil if there is none.
+func (s *Server) get(req *request) []byte {
+	e, ok := s.entries[req.Key]
+	switch {
+	case !ok || s.locked || time.Now().After(e.Expires):
+		return nil
+	case e.Permissions&req.Permissions != req.Permissions:
+		return nil
+	case e.RPID != "" && e.RPID != req.RPID:
+		return nil
+	}
+	return s.vault.load(e.slot, e.size)
+}
+
+// put caches the token of the request in place of the one of its security key, if any.
+func (s *Server) put(req *request) error {
+	switch {
+	case s.locked:
+		return errors.New("the agent is locked")
+	case req.Key == "" || len(req.Token) == 0:
+		return errors.New("a key and a token are required")
+	}
+
+	s.drop(req.Key)
+	slot, err := s.vault.store(req.Token)
+	if err != nil {
+		return err
+	}
+
+	key, ttl := req.Key, s.ttl()
+	e := &entry{
+		Entry: Entry{Key: key, Permissions: req.Permissions, RPID: req.RPID, Expires: time.Now().Add(ttl)},
+		slot:  slot,
+		size:  len(req.Token),
+	}
+	e.timer = time.AfterFunc(ttl, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.entries[key] == e {
+			s.drop(key)
+		}
+	})
+	s.entries[key] = e
+	return nil
+}
+
+// drop wipes the token cached for key, if any.
+func (s *Server) drop(key string) {
+	e, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	e.timer.Stop()
+	s.vault.wipe(e.slot)
+	delete(s.entries, key)
+}
+
+// clear wipes all the cached tokens.
+func (s *Server) clear() {
+	for key := range s.entries {
+		s.drop(key)
+	}
+}
+
+func (s *Server) status() *Status {
+	status := &Status{Locked: s.locked, MemoryLocked: s.vault.locked, TTL: s.ttl(), Entries: []Entry{}}
+	for _, key := range slices.Sorted(maps.Keys(s.entries)) {
+		status.Entries = append(status.Entries, s.entries[key].Entry)
+	}
+	return status
+}
+
+func (s *Server) ttl() time.Duration {
+	if s.TTL <= 0 {
+		return DefaultTTL
+	}
+	return s.TTL
+}
+
+// init allocates the vault on first use. It must be called with s.mu held.
+func (s *Server) init() {
+	if s.vault == nil {
+		s.vault = newVault()
+		s.entries = make(map[string]*entry)
+	}
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"errors"
+	"os"
+)
+
+// slotSize is the room for a token in the vault. Tokens are 32 bytes with PIN/UV auth protocols 1 and 2.
+const slotSize = 64
+
+var errVaultFull = errors.New("the agent has no room left for tokens")
+
+// vault stores tokens in fixed-size slots of memory that is locked into RAM, so they are never written to swap,
+// and is wiped when a token is removed.
+type vault struct {
+	mem    []byte
+	used   []bool
+	locked bool
+}
+
+// newVault returns a vault of one memory page. If the memory cannot be locked, e.g. because of
+// RLIMIT_MEMLOCK, the vault uses ordinary memory and isn't locked.
+func newVault() *vault {
+	size := os.Getpagesize()
+	mem, err := lockedMemory(size)
+	locked := err == nil
+	if !locked {
+		mem = make([]byte, size)
+	}
+	return &vault{mem: mem, used: make([]bool, size/slotSize), locked: locked}
+}
+
+// store copies token into a free slot and returns it.
+func (v *vault) store(token []byte) (int, error) {
+	if len(token) > slotSize {
+		return 0, errors.New("token too long")
+	}
+	for slot, used := range v.used {
+		if !used {
+			v.used[slot] = true
+			copy(v.slot(slot), token)
+			return slot, nil
+		}
+	}
+	return 0, errVaultFull
+}
+
+// load returns a copy of the first n bytes of slot.
+func (v *vault) load(slot, n int) []byte {
+	return append([]byte(nil), v.slot(slot)[:n]...)
+}
+
+// wipe zeroes slot and frees it.
+func (v *vault) wipe(slot int) {
+	clear(v.slot(slot))
+	v.used[slot] = false
+}
+
+func (v *vault) slot(slot int) []byte {
+	return v.mem[slot*slotSize : (slot+1)*slotSize]
+}
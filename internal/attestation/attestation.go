@@ -0,0 +1,341 @@
+// Package attestation verifies the attestation statement a security key returns with a new credential, which
+// proves the model of the key when it is signed by a certificate chaining up to a root of its vendor.
+package attestation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/uuid"
+	"github.com/ldclabs/cose/iana"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+	"github.com/mohammadv184/skm/internal/credential"
+)
+
+// Type is the attestation type of a statement, which tells what it proves.
+type Type string
+
+const (
+	// TypeBasic means the statement is signed by an attestation certificate the vendor shares between a batch of
+	// keys of the same model.
+	TypeBasic Type = "basic"
+	// TypeAttCA means the statement is signed by an attestation identity key certified by a CA, as TPMs do.
+	TypeAttCA Type = "attca"
+	// TypeSelf means the statement is signed by the credential private key, which proves nothing about the key.
+	TypeSelf Type = "self"
+	// TypeNone means the key gave no attestation.
+	TypeNone Type = "none"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	// StatusPass means the check succeeded.
+	StatusPass Status = "pass"
+	// StatusFail means the check failed, so the key is not proven genuine.
+	StatusFail Status = "fail"
+	// StatusSkip means the check does not apply to the statement.
+	StatusSkip Status = "skip"
+)
+
+// Names of the checks, in the order they are made.
+const (
+	// CheckFormat checks that the statement is well formed for its format.
+	CheckFormat = "format"
+	// CheckSignature checks the signature of the statement over the new credential and the challenge.
+	CheckSignature = "signature"
+	// CheckCertificate checks that the attestation certificate meets the requirements of the format.
+	CheckCertificate = "certificate"
+	// CheckAAGUID checks that the AAGUID certified by the attestation certificate is the one of the key.
+	CheckAAGUID = "aaguid"
+	// CheckChain checks that the attestation certificate chains up to a trusted root.
+	CheckChain = "chain"
+)
+
+// oidAAGUID is the certificate extension that holds the AAGUID of the model an attestation certificate certifies.
+var oidAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// Check is the outcome of a single check of a statement.
+type Check struct {
+	// Name is the name of the check, one of the Check constants.
+	Name string
+	// Status is the outcome of the check.
+	Status Status
+	// Message explains the outcome.
+	Message string
+}
+
+// Result is the outcome of the verification of an attestation statement.
+type Result struct {
+	// Format is the attestation statement format, e.g. packed.
+	Format string
+	// Type is the attestation type of the statement, if its format is supported.
+	Type Type
+	// AAGUID is the AAGUID of the key, from the authenticator data.
+	AAGUID uuid.UUID
+	// CertificateAAGUID is the AAGUID certified by the attestation certificate, if it has one.
+	CertificateAAGUID *uuid.UUID
+	// Certificates are the attestation certificate followed by its intermediates, as given by the key.
+	Certificates []*x509.Certificate
+	// Root is the trusted root the attestation certificate chains up to, or nil if it doesn't.
+	Root *x509.Certificate
+	// Checks are the checks made, in order.
+	Checks []Check
+}
+
+// Genuine reports whether the statement proves the key is genuine: every check passed or did not apply, and the
+// attestation certificate chains up to a trusted root.
+func (r *Result) Genuine() bool {
+	for _, c := range r.Checks {
+		if c.Status == StatusFail {
+			return false
+		}
+	}
+	return r.Root != nil
+}
+
+func (r *Result) add(name string, status Status, message string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Message: message})
+}
+
+// check adds a check that passed with message if err is nil, and that failed with err otherwise.
+func (r *Result) check(name string, err error, message string) {
+	if err != nil {
+		r.add(name, StatusFail, err.Error())
+		return
+	}
+	r.add(name, StatusPass, message)
+}
+
+// Verify verifies the attestation statement of resp, returned by makeCredential for clientDataHash, and that its
+// certificate chains up to one of roots at time now. roots may be nil if no root is trusted for the model of the
+// key. The checks that fail are reported in the result rather than as an error.
+func Verify(
+	resp *ctap2.AuthenticatorMakeCredentialResponse,
+	clientDataHash []byte,
+	roots *x509.CertPool,
+	now time.Time,
+) *Result {
+	r := &Result{Format: string(resp.Format)}
+	if resp.AuthData == nil || resp.AuthData.AttestedCredentialData == nil {
+		r.add(CheckFormat, StatusFail, "the authenticator data has no attested credential")
+		return r
+	}
+	r.AAGUID = resp.AuthData.AttestedCredentialData.AAGUID
+
+	var err error
+	switch resp.Format {
+	case webauthn.AttestationStatementFormatIdentifierPacked:
+		err = r.verifyPacked(resp, clientDataHash)
+	case webauthn.AttestationStatementFormatIdentifierFIDOU2F:
+		err = r.verifyFIDOU2F(resp, clientDataHash)
+	case webauthn.AttestationStatementFormatIdentifierTPM:
+		err = r.verifyTPM(resp, clientDataHash)
+	case webauthn.AttestationStatementFormatIdentifierNone:
+		r.Type = TypeNone
+		if len(resp.AttestationStatement) != 0 {
+			err = errors.New("the none attestation statement is not empty")
+		}
+	default:
+		err = fmt.Errorf("unsupported attestation statement format %q", resp.Format)
+	}
+	if err != nil {
+		r.Checks = append([]Check{{Name: CheckFormat, Status: StatusFail, Message: err.Error()}}, r.Checks...)
+		return r
+	}
+	r.Checks = append([]Check{{
+		Name:    CheckFormat,
+		Status:  StatusPass,
+		Message: fmt.Sprintf("%s statement with %s attestation", r.Format, r.Type),
+	}}, r.Checks...)
+
+	r.verifyAAGUID()
+	r.verifyChain(roots, now)
+	return r
+}
+
+// decodeStatement decodes the attestation statement of resp into v, one of the statement formats of webauthn.
+func decodeStatement(resp *ctap2.AuthenticatorMakeCredentialResponse, v any) error {
+	b, err := cbor.Marshal(resp.AttestationStatement)
+	if err != nil {
+		return err
+	}
+	if err := cbor.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("invalid %s attestation statement: %w", resp.Format, err)
+	}
+	return nil
+}
+
+// parseChain parses the attestation certificate and its intermediates of a statement.
+func parseChain(x5c [][]byte) ([]*x509.Certificate, error) {
+	if len(x5c) == 0 {
+		return nil, errors.New("the attestation statement has no certificate")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(x5c))
+	for _, der := range x5c {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid attestation certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// credentialKey returns the public key and the COSE algorithm of the new credential of resp.
+func credentialKey(resp *ctap2.AuthenticatorMakeCredentialResponse) (crypto.PublicKey, int64, error) {
+	k := resp.AuthData.AttestedCredentialData.CredentialPublicKey
+	pk, err := credential.DecodePublicKey(k)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid credential public key: %w", err)
+	}
+	return pk.Key, int64(k.Alg()), nil
+}
+
+// verifyAAGUID checks that the AAGUID certified by the attestation certificate, if any, is the one of the key.
+func (r *Result) verifyAAGUID() {
+	if len(r.Certificates) == 0 {
+		r.add(CheckAAGUID, StatusSkip, "no attestation certificate")
+		return
+	}
+
+	for _, ext := range r.Certificates[0].Extensions {
+		if !ext.Id.Equal(oidAAGUID) {
+			continue
+		}
+
+		var b []byte
+		if _, err := asn1.Unmarshal(ext.Value, &b); err != nil || len(b) != 16 {
+			r.add(CheckAAGUID, StatusFail, "the AAGUID extension of the attestation certificate is invalid")
+			return
+		}
+		if ext.Critical {
+			r.add(CheckAAGUID, StatusFail, "the AAGUID extension of the attestation certificate is critical")
+			return
+		}
+
+		aaguid := uuid.UUID(b)
+		r.CertificateAAGUID = &aaguid
+		if aaguid != r.AAGUID {
+			r.add(CheckAAGUID, StatusFail, fmt.Sprintf("the attestation certificate is for %s, the key is %s",
+				aaguid, r.AAGUID))
+			return
+		}
+		r.add(CheckAAGUID, StatusPass, "the attestation certificate is for "+aaguid.String())
+		return
+	}
+	r.add(CheckAAGUID, StatusSkip, "the attestation certificate has no AAGUID extension")
+}
+
+// verifyChain checks that the attestation certificate chains up to one of roots through its intermediates.
+func (r *Result) verifyChain(roots *x509.CertPool, now time.Time) {
+	switch {
+	case len(r.Certificates) == 0:
+		r.add(CheckChain, StatusFail, fmt.Sprintf("%s attestation cannot prove the model of the key", r.Type))
+		return
+	case roots == nil:
+		r.add(CheckChain, StatusFail, "no trusted root for the model of the key, import the MDS BLOB or give one")
+		return
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range r.Certificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := r.Certificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		r.add(CheckChain, StatusFail, err.Error())
+		return
+	}
+
+	chain := chains[0]
+	r.Root = chain[len(chain)-1]
+	r.add(CheckChain, StatusPass, "issued by "+r.Root.Subject.String())
+}
+
+// algorithm is how a COSE signature algorithm signs: with which type of key, over which digest.
+type algorithm struct {
+	key  string
+	hash crypto.Hash
+	pss  bool
+}
+
+var algorithms = map[int64]algorithm{
+	iana.AlgorithmES256: {key: "ECDSA", hash: crypto.SHA256},
+	iana.AlgorithmES384: {key: "ECDSA", hash: crypto.SHA384},
+	iana.AlgorithmES512: {key: "ECDSA", hash: crypto.SHA512},
+	iana.AlgorithmRS256: {key: "RSA", hash: crypto.SHA256},
+	iana.AlgorithmRS384: {key: "RSA", hash: crypto.SHA384},
+	iana.AlgorithmRS512: {key: "RSA", hash: crypto.SHA512},
+	iana.AlgorithmRS1:   {key: "RSA", hash: crypto.SHA1},
+	iana.AlgorithmPS256: {key: "RSA", hash: crypto.SHA256, pss: true},
+	iana.AlgorithmPS384: {key: "RSA", hash: crypto.SHA384, pss: true},
+	iana.AlgorithmPS512: {key: "RSA", hash: crypto.SHA512, pss: true},
+	iana.AlgorithmEdDSA: {key: "Ed25519"},
+}
+
+var errSignature = errors.New("the attestation signature is invalid")
+
+// verifySignature verifies the signature sig of data, made by the key pub with the COSE algorithm alg.
+func verifySignature(pub crypto.PublicKey, alg int64, data, sig []byte) error {
+	a, ok := algorithms[alg]
+	if !ok {
+		return fmt.Errorf("unsupported signature algorithm %s", credential.AlgorithmName(int(alg)))
+	}
+
+	var key string
+	switch pub.(type) {
+	case *ecdsa.PublicKey:
+		key = "ECDSA"
+	case *rsa.PublicKey:
+		key = "RSA"
+	case ed25519.PublicKey:
+		key = "Ed25519"
+	default:
+		return fmt.Errorf("unsupported public key %T", pub)
+	}
+	if key != a.key {
+		return fmt.Errorf("an %s key cannot make %s signatures", key, credential.AlgorithmName(int(alg)))
+	}
+
+	var digest []byte
+	if a.hash != 0 {
+		h := a.hash.New()
+		h.Write(data)
+		digest = h.Sum(nil)
+	}
+
+	var valid bool
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(pub, digest, sig)
+	case *rsa.PublicKey:
+		if a.pss {
+			valid = rsa.VerifyPSS(pub, a.hash, digest, sig, nil) == nil
+		} else {
+			valid = rsa.VerifyPKCS1v15(pub, a.hash, digest, sig) == nil
+		}
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(pub, data, sig)
+	}
+	if !valid {
+		return errSignature
+	}
+	return nil
+}
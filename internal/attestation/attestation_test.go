@@ -0,0 +1,324 @@
+package attestation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/uuid"
+	"github.com/ldclabs/cose/iana"
+	cosecdsa "github.com/ldclabs/cose/key/ecdsa"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+	"github.com/mohammadv184/skm/internal/attestation/attestationtest"
+)
+
+var (
+	aaguid = uuid.MustParse("ee882879-721c-4913-9775-3dfcce97072a")
+	now    = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	return k
+}
+
+// newCert returns the certificate of tmpl, valid for a year around now.
+func newCert(t *testing.T, tmpl, parent *x509.Certificate, pub crypto.PublicKey, priv crypto.Signer) *x509.Certificate {
+	t.Helper()
+
+	tmpl.SerialNumber = big.NewInt(1)
+	tmpl.NotBefore, tmpl.NotAfter = now.AddDate(-1, 0, 0), now.AddDate(1, 0, 0)
+	return attestationtest.NewCertificate(t, tmpl, parent, pub, priv)
+}
+
+// aaguidExtension returns the extension that certifies id.
+func aaguidExtension(t *testing.T, id uuid.UUID) pkix.Extension {
+	t.Helper()
+
+	v, err := asn1.Marshal(id[:])
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	return pkix.Extension{Id: oidAAGUID, Value: v}
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	t.Helper()
+
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("SignASN1() error = %v", err)
+	}
+	return sig
+}
+
+// fixture is a credential made by a test authenticator, and the CA that issues its attestation certificates.
+type fixture struct {
+	credKey        *ecdsa.PrivateKey
+	authData       []byte
+	clientDataHash []byte
+	caKey          *ecdsa.PrivateKey
+	ca             *x509.Certificate
+	roots          *x509.CertPool
+}
+
+func newFixture(t *testing.T, id uuid.UUID) *fixture {
+	t.Helper()
+
+	f := &fixture{credKey: newKey(t), caKey: newKey(t), clientDataHash: make([]byte, 32)}
+	_, _ = rand.Read(f.clientDataHash)
+
+	coseKey, err := cosecdsa.KeyFromPublic(&f.credKey.PublicKey)
+	if err != nil {
+		t.Fatalf("KeyFromPublic() error = %v", err)
+	}
+	pub, err := cbor.Marshal(coseKey)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	rpIDHash := sha256.Sum256([]byte("example.invalid"))
+	credID := []byte("credential")
+	f.authData = slices.Concat(
+		rpIDHash[:],
+		[]byte{0x41, 0, 0, 0, 0},
+		id[:],
+		binary.BigEndian.AppendUint16(nil, uint16(len(credID))),
+		credID,
+		pub,
+	)
+
+	f.ca = newCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Attestation Root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, f.caKey.Public(), f.caKey)
+	f.roots = x509.NewCertPool()
+	f.roots.AddCert(f.ca)
+	return f
+}
+
+// packedCert issues an attestation certificate that meets the packed requirements and certifies id.
+func (f *fixture) packedCert(t *testing.T, key *ecdsa.PrivateKey, id uuid.UUID) *x509.Certificate {
+	t.Helper()
+
+	return newCert(t, &x509.Certificate{
+		Subject: pkix.Name{
+			Country:            []string{"SE"},
+			Organization:       []string{"Test Vendor"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Test Key Attestation",
+		},
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{aaguidExtension(t, id)},
+	}, f.ca, key.Public(), f.caKey)
+}
+
+func (f *fixture) response(
+	t *testing.T,
+	format string,
+	stmt map[string]any,
+) *ctap2.AuthenticatorMakeCredentialResponse {
+	t.Helper()
+
+	authData, err := ctap2.ParseMakeCredentialAuthData(f.authData)
+	if err != nil {
+		t.Fatalf("ParseMakeCredentialAuthData() error = %v", err)
+	}
+	return &ctap2.AuthenticatorMakeCredentialResponse{
+		Format:               webauthn.AttestationStatementFormatIdentifier(format),
+		AuthDataRaw:          f.authData,
+		AuthData:             authData,
+		AttestationStatement: stmt,
+	}
+}
+
+func (f *fixture) packed(t *testing.T, certAAGUID uuid.UUID) *ctap2.AuthenticatorMakeCredentialResponse {
+	t.Helper()
+
+	key := newKey(t)
+	cert := f.packedCert(t, key, certAAGUID)
+	return f.response(t, "packed", map[string]any{
+		"alg": int64(iana.AlgorithmES256),
+		"sig": sign(t, key, slices.Concat(f.authData, f.clientDataHash)),
+		"x5c": [][]byte{cert.Raw},
+	})
+}
+
+func tpm2b(b []byte) []byte {
+	return slices.Concat(binary.BigEndian.AppendUint16(nil, uint16(len(b))), b)
+}
+
+func (f *fixture) tpm(t *testing.T) *ctap2.AuthenticatorMakeCredentialResponse {
+	t.Helper()
+
+	pub := f.credKey.PublicKey
+	pubArea := slices.Concat(
+		[]byte{0x00, 0x23, 0x00, 0x0b, 0x00, 0x06, 0x04, 0x72},
+		tpm2b(nil),
+		[]byte{0x00, 0x10, 0x00, 0x10, 0x00, 0x03, 0x00, 0x10},
+		tpm2b(pub.X.FillBytes(make([]byte, 32))),
+		tpm2b(pub.Y.FillBytes(make([]byte, 32))),
+	)
+	extraData := sha256.Sum256(slices.Concat(f.authData, f.clientDataHash))
+	name := sha256.Sum256(pubArea)
+	certInfo := slices.Concat(
+		[]byte{0xff, 0x54, 0x43, 0x47, 0x80, 0x17},
+		tpm2b(nil),
+		tpm2b(extraData[:]),
+		make([]byte, 17+8),
+		tpm2b(slices.Concat([]byte{0x00, 0x0b}, name[:])),
+		tpm2b(nil),
+	)
+
+	aik := newKey(t)
+	cert := newCert(t, &x509.Certificate{
+		UnknownExtKeyUsage:    []asn1.ObjectIdentifier{oidAIKCertificate},
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{aaguidExtension(t, aaguid)},
+	}, f.ca, aik.Public(), f.caKey)
+
+	return f.response(t, "tpm", map[string]any{
+		"ver":      "2.0",
+		"alg":      int64(iana.AlgorithmES256),
+		"x5c":      [][]byte{cert.Raw},
+		"sig":      sign(t, aik, certInfo),
+		"certInfo": certInfo,
+		"pubArea":  pubArea,
+	})
+}
+
+func TestVerify(t *testing.T) {
+	f := newFixture(t, aaguid)
+	other := newFixture(t, aaguid)
+
+	u2f := newFixture(t, uuid.Nil)
+	u2fKey := newKey(t)
+	u2fCert := newCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "U2F"}}, u2f.ca, u2fKey.Public(), u2f.caKey)
+	credPub := u2f.credKey.PublicKey
+	u2fResp := u2f.response(t, "fido-u2f", nil)
+	u2fResp.AttestationStatement = map[string]any{
+		"x5c": [][]byte{u2fCert.Raw},
+		"sig": sign(t, u2fKey, slices.Concat(
+			[]byte{0x00},
+			u2fResp.AuthData.RPIDHash,
+			u2f.clientDataHash,
+			[]byte("credential"),
+			[]byte{0x04},
+			credPub.X.FillBytes(make([]byte, 32)),
+			credPub.Y.FillBytes(make([]byte, 32)),
+		)),
+	}
+
+	tampered := f.packed(t, aaguid)
+	sig := slices.Clone(tampered.AttestationStatement["sig"].([]byte)) //nolint:forcetypeassert // set above
+	sig[len(sig)-1] ^= 0xff
+	tampered.AttestationStatement["sig"] = sig
+
+	badTPM := f.tpm(t)
+	badTPM.AttestationStatement["pubArea"] = other.tpm(t).AttestationStatement["pubArea"]
+
+	tests := []struct {
+		name        string
+		resp        *ctap2.AuthenticatorMakeCredentialResponse
+		roots       *x509.CertPool
+		fx          *fixture
+		wantType    Type
+		wantGenuine bool
+		wantFail    string
+		wantMessage string
+	}{
+		{name: "packed", resp: f.packed(t, aaguid), roots: f.roots, wantType: TypeBasic, wantGenuine: true},
+		{
+			name: "packed without roots", resp: f.packed(t, aaguid), wantType: TypeBasic,
+			wantFail: CheckChain, wantMessage: "no trusted root",
+		},
+		{
+			name: "packed from another CA", resp: f.packed(t, aaguid), roots: other.roots, wantType: TypeBasic,
+			wantFail: CheckChain, wantMessage: "unknown authority",
+		},
+		{
+			name: "packed for another model", resp: f.packed(t, uuid.New()), roots: f.roots, wantType: TypeBasic,
+			wantFail: CheckAAGUID, wantMessage: "the key is " + aaguid.String(),
+		},
+		{
+			name: "packed with a tampered signature", resp: tampered, roots: f.roots, wantType: TypeBasic,
+			wantFail: CheckSignature, wantMessage: "invalid",
+		},
+		{
+			name: "self", roots: f.roots, wantType: TypeSelf,
+			resp: f.response(t, "packed", map[string]any{
+				"alg": int64(iana.AlgorithmES256),
+				"sig": sign(t, f.credKey, slices.Concat(f.authData, f.clientDataHash)),
+			}),
+			wantFail: CheckChain, wantMessage: "self attestation cannot prove",
+		},
+		{name: "fido-u2f", resp: u2fResp, roots: u2f.roots, fx: u2f, wantType: TypeBasic, wantGenuine: true},
+		{name: "tpm", resp: f.tpm(t), roots: f.roots, wantType: TypeAttCA, wantGenuine: true},
+		{
+			name: "tpm for another key", resp: badTPM, roots: f.roots, wantType: TypeAttCA,
+			wantFail: CheckFormat, wantMessage: "not the credential public key",
+		},
+		{
+			name: "none", resp: f.response(t, "none", nil), roots: f.roots, wantType: TypeNone,
+			wantFail: CheckChain, wantMessage: "none attestation cannot prove",
+		},
+		{
+			name: "unsupported format", resp: f.response(t, "apple", nil), roots: f.roots,
+			wantFail: CheckFormat, wantMessage: `unsupported attestation statement format "apple"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fx := f
+			if tt.fx != nil {
+				fx = tt.fx
+			}
+
+			r := Verify(tt.resp, fx.clientDataHash, tt.roots, now)
+			if r.Type != tt.wantType {
+				t.Errorf("Type = %q, want %q", r.Type, tt.wantType)
+			}
+			if r.Genuine() != tt.wantGenuine {
+				t.Errorf("Genuine() = %v, want %v, checks %+v", r.Genuine(), tt.wantGenuine, r.Checks)
+			}
+
+			failed := false
+			for _, c := range r.Checks {
+				failed = failed || c.Name == tt.wantFail
+				if c.Status == StatusFail && c.Name != tt.wantFail {
+					t.Errorf("check %s failed: %s", c.Name, c.Message)
+				}
+				if c.Name == tt.wantFail && (c.Status != StatusFail || !strings.Contains(c.Message, tt.wantMessage)) {
+					t.Errorf("check %s = %s %q, want to fail with %q", c.Name, c.Status, c.Message, tt.wantMessage)
+				}
+			}
+			if tt.wantFail != "" && !failed {
+				t.Errorf("no %s check in %+v", tt.wantFail, r.Checks)
+			}
+			if tt.wantGenuine && (r.Root == nil || !r.Root.Equal(fx.ca)) {
+				t.Errorf("Root = %v, want the test CA", r.Root)
+			}
+		})
+	}
+}
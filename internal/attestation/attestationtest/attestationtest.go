@@ -0,0 +1,33 @@
+// Package attestationtest provides helpers to issue the certificates of attestation and MDS BLOB tests.
+package attestationtest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+)
+
+// NewCertificate returns the certificate of tmpl for pub, issued by parent with priv, or self-signed if parent
+// is nil.
+func NewCertificate(
+	tb testing.TB,
+	tmpl, parent *x509.Certificate,
+	pub crypto.PublicKey,
+	priv crypto.Signer,
+) *x509.Certificate {
+	tb.Helper()
+
+	if parent == nil {
+		parent = tmpl
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, priv)
+	if err != nil {
+		tb.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		tb.Fatalf("ParseCertificate() error = %v", err)
+	}
+	return cert
+}
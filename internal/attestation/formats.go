@@ -0,0 +1,108 @@
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+)
+
+// verifyPacked verifies a packed statement, signed by an attestation certificate or by the credential itself.
+func (r *Result) verifyPacked(resp *ctap2.AuthenticatorMakeCredentialResponse, clientDataHash []byte) error {
+	var stmt webauthn.PackedAttestationStatementFormat
+	if err := decodeStatement(resp, &stmt); err != nil {
+		return err
+	}
+	signed := slices.Concat(resp.AuthDataRaw, clientDataHash)
+
+	if stmt.X509Chain == nil {
+		r.Type = TypeSelf
+		pub, alg, err := credentialKey(resp)
+		if err != nil {
+			return err
+		}
+		if int64(stmt.Algorithm) != alg {
+			return fmt.Errorf("the self attestation algorithm %d is not the credential's %d", stmt.Algorithm, alg)
+		}
+
+		r.check(CheckSignature, verifySignature(pub, alg, signed, stmt.Signature), "signed by the credential")
+		r.add(CheckCertificate, StatusSkip, "self attestation has no certificate")
+		return nil
+	}
+
+	certs, err := parseChain(stmt.X509Chain)
+	if err != nil {
+		return err
+	}
+	r.Type, r.Certificates = TypeBasic, certs
+
+	r.check(CheckSignature, verifySignature(certs[0].PublicKey, int64(stmt.Algorithm), signed, stmt.Signature),
+		"signed by the attestation certificate")
+	r.check(CheckCertificate, checkPackedCertificate(certs[0]), "meets the packed attestation requirements")
+	return nil
+}
+
+// checkPackedCertificate checks the requirements of the packed format on the attestation certificate.
+func checkPackedCertificate(cert *x509.Certificate) error {
+	subject := cert.Subject
+	switch {
+	case cert.Version != 3:
+		return fmt.Errorf("the attestation certificate is version %d, not 3", cert.Version)
+	case len(subject.Country) == 0 || len(subject.Organization) == 0 || subject.CommonName == "":
+		return errors.New("the attestation certificate subject lacks its country, organization or common name")
+	case !slices.Contains(subject.OrganizationalUnit, "Authenticator Attestation"):
+		return errors.New("the attestation certificate subject OU is not Authenticator Attestation")
+	case cert.IsCA:
+		return errors.New("the attestation certificate is a CA")
+	}
+	return nil
+}
+
+// verifyFIDOU2F verifies a fido-u2f statement, made by a U2F key for a credential of the legacy format.
+func (r *Result) verifyFIDOU2F(resp *ctap2.AuthenticatorMakeCredentialResponse, clientDataHash []byte) error {
+	var stmt webauthn.FIDOU2FAttestationStatementFormat
+	if err := decodeStatement(resp, &stmt); err != nil {
+		return err
+	}
+	if len(stmt.X509Chain) != 1 {
+		return fmt.Errorf("the fido-u2f statement has %d certificates, want 1", len(stmt.X509Chain))
+	}
+	certs, err := parseChain(stmt.X509Chain)
+	if err != nil {
+		return err
+	}
+	r.Type, r.Certificates = TypeBasic, certs
+
+	pub, _, err := credentialKey(resp)
+	if err != nil {
+		return err
+	}
+	credKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok || credKey.Curve != elliptic.P256() {
+		return errors.New("the fido-u2f credential public key is not a P-256 key")
+	}
+
+	point := slices.Concat([]byte{0x04}, credKey.X.FillBytes(make([]byte, 32)), credKey.Y.FillBytes(make([]byte, 32)))
+	signed := slices.Concat(
+		[]byte{0x00},
+		resp.AuthData.RPIDHash,
+		clientDataHash,
+		resp.AuthData.AttestedCredentialData.CredentialID,
+		point,
+	)
+	r.check(CheckSignature, verifySignature(certs[0].PublicKey, iana.AlgorithmES256, signed, stmt.Signature),
+		"signed by the attestation certificate")
+
+	var certErr error
+	if key, ok := certs[0].PublicKey.(*ecdsa.PublicKey); !ok || key.Curve != elliptic.P256() {
+		certErr = errors.New("the attestation certificate key is not a P-256 key")
+	}
+	r.check(CheckCertificate, certErr, "meets the fido-u2f attestation requirements")
+	return nil
+}
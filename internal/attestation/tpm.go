@@ -0,0 +1,226 @@
+package attestation
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+)
+
+// TPM 2.0 constants used by the tpm attestation statement format.
+const (
+	tpmGeneratedValue  = 0xff544347
+	tpmSTAttestCertify = 0x8017
+
+	tpmAlgRSA  = 0x0001
+	tpmAlgECC  = 0x0023
+	tpmAlgNull = 0x0010
+)
+
+// tpmHashes are the hash algorithms of TPM names.
+var tpmHashes = map[uint16]crypto.Hash{
+	0x0004: crypto.SHA1,
+	0x000b: crypto.SHA256,
+	0x000c: crypto.SHA384,
+	0x000d: crypto.SHA512,
+}
+
+// tpmCurves are the elliptic curves of TPM keys.
+var tpmCurves = map[uint16]elliptic.Curve{
+	0x0003: elliptic.P256(),
+	0x0004: elliptic.P384(),
+	0x0005: elliptic.P521(),
+}
+
+// oidAIKCertificate is the extended key usage of TPM attestation identity key certificates.
+var oidAIKCertificate = asn1.ObjectIdentifier{2, 23, 133, 8, 3}
+
+// verifyTPM verifies a tpm statement: the TPM certifies with an attestation identity key that it holds the
+// credential key described by pubArea, in the attestation certInfo.
+func (r *Result) verifyTPM(resp *ctap2.AuthenticatorMakeCredentialResponse, clientDataHash []byte) error {
+	var stmt webauthn.TPMAttestationStatementFormat
+	if err := decodeStatement(resp, &stmt); err != nil {
+		return err
+	}
+	if stmt.Version != "2.0" {
+		return fmt.Errorf("unsupported TPM version %q", stmt.Version)
+	}
+	a, ok := algorithms[int64(stmt.Algorithm)]
+	if !ok || a.hash == 0 {
+		return fmt.Errorf("unsupported TPM signature algorithm %d", stmt.Algorithm)
+	}
+	certs, err := parseChain(stmt.X509Chain)
+	if err != nil {
+		return err
+	}
+	r.Type, r.Certificates = TypeAttCA, certs
+
+	pub, _, err := credentialKey(resp)
+	if err != nil {
+		return err
+	}
+	nameAlg, err := checkPubArea(stmt.PubArea, pub)
+	if err != nil {
+		return err
+	}
+
+	h := a.hash.New()
+	h.Write(slices.Concat(resp.AuthDataRaw, clientDataHash))
+	if err := checkCertInfo(stmt.CertInfo, h.Sum(nil), nameAlg, stmt.PubArea); err != nil {
+		return err
+	}
+
+	r.check(CheckSignature, verifySignature(certs[0].PublicKey, int64(stmt.Algorithm), stmt.CertInfo, stmt.Signature),
+		"signed by the attestation identity key")
+	r.check(CheckCertificate, checkAIKCertificate(certs[0]), "meets the tpm attestation requirements")
+	return nil
+}
+
+// checkPubArea checks that the TPMT_PUBLIC pubArea describes the credential key pub, and returns its name
+// algorithm.
+func checkPubArea(pubArea []byte, pub crypto.PublicKey) (uint16, error) {
+	t := &tpmReader{b: pubArea}
+	typ, nameAlg := t.u16(), t.u16()
+	t.u32()   // objectAttributes
+	t.tpm2b() // authPolicy
+	if alg := t.u16(); alg != tpmAlgNull {
+		t.u16() // keyBits
+		t.u16() // mode
+	}
+	if scheme := t.u16(); scheme != tpmAlgNull {
+		t.u16() // hashAlg
+	}
+
+	var match bool
+	switch typ {
+	case tpmAlgRSA:
+		t.u16() // keyBits
+		exponent, modulus := t.u32(), t.tpm2b()
+		if exponent == 0 {
+			exponent = 65537
+		}
+		key, ok := pub.(*rsa.PublicKey)
+		match = ok && key.E == int(exponent) && key.N.Cmp(new(big.Int).SetBytes(modulus)) == 0
+	case tpmAlgECC:
+		curve := tpmCurves[t.u16()]
+		if kdf := t.u16(); kdf != tpmAlgNull {
+			t.u16() // hashAlg
+		}
+		x, y := t.tpm2b(), t.tpm2b()
+		key, ok := pub.(*ecdsa.PublicKey)
+		match = ok && curve != nil && key.Curve == curve &&
+			key.X.Cmp(new(big.Int).SetBytes(x)) == 0 && key.Y.Cmp(new(big.Int).SetBytes(y)) == 0
+	default:
+		return 0, fmt.Errorf("unsupported TPM key type %#04x", typ)
+	}
+
+	switch {
+	case t.err != nil:
+		return 0, fmt.Errorf("invalid TPM pubArea: %w", t.err)
+	case !match:
+		return 0, errors.New("the TPM pubArea is not the credential public key")
+	}
+	return nameAlg, nil
+}
+
+// checkCertInfo checks that the TPMS_ATTEST certInfo certifies the key of pubArea over extraData.
+func checkCertInfo(certInfo, extraData []byte, nameAlg uint16, pubArea []byte) error {
+	t := &tpmReader{b: certInfo}
+	magic, typ := t.u32(), t.u16()
+	t.tpm2b() // qualifiedSigner
+	data := t.tpm2b()
+	t.read(17) // clockInfo
+	t.u64()    // firmwareVersion
+	name := t.tpm2b()
+	t.tpm2b() // qualifiedName
+
+	hash, ok := tpmHashes[nameAlg]
+	switch {
+	case t.err != nil:
+		return fmt.Errorf("invalid TPM certInfo: %w", t.err)
+	case magic != tpmGeneratedValue || typ != tpmSTAttestCertify:
+		return errors.New("the TPM certInfo is not a certification made by the TPM")
+	case !bytes.Equal(data, extraData):
+		return errors.New("the TPM certInfo is not for this credential and challenge")
+	case !ok:
+		return fmt.Errorf("unsupported TPM name algorithm %#04x", nameAlg)
+	}
+
+	h := hash.New()
+	h.Write(pubArea)
+	if !bytes.Equal(name, slices.Concat(binary.BigEndian.AppendUint16(nil, nameAlg), h.Sum(nil))) {
+		return errors.New("the TPM certInfo does not certify the pubArea")
+	}
+	return nil
+}
+
+// checkAIKCertificate checks the requirements of the tpm format on the attestation identity key certificate.
+func checkAIKCertificate(cert *x509.Certificate) error {
+	switch {
+	case cert.Version != 3:
+		return fmt.Errorf("the attestation certificate is version %d, not 3", cert.Version)
+	case len(cert.Subject.Names) != 0:
+		return errors.New("the attestation certificate subject is not empty")
+	case !slices.ContainsFunc(cert.UnknownExtKeyUsage, oidAIKCertificate.Equal):
+		return errors.New("the attestation certificate is not for an attestation identity key")
+	case cert.IsCA:
+		return errors.New("the attestation certificate is a CA")
+	}
+	return nil
+}
+
+// tpmReader reads the big-endian TPM structures, and keeps the first error.
+type tpmReader struct {
+	b   []byte
+	err error
+}
+
+func (t *tpmReader) read(n int) []byte {
+	if t.err != nil {
+		return nil
+	}
+	if len(t.b) < n {
+		t.err = errors.New("truncated structure")
+		return nil
+	}
+	b := t.b[:n]
+	t.b = t.b[n:]
+	return b
+}
+
+func (t *tpmReader) u16() uint16 {
+	if b := t.read(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (t *tpmReader) u32() uint32 {
+	if b := t.read(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (t *tpmReader) u64() uint64 {
+	if b := t.read(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// tpm2b reads a TPM2B structure: a 16-bit size followed by as many bytes.
+func (t *tpmReader) tpm2b() []byte {
+	return t.read(int(t.u16()))
+}
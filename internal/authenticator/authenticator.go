@@ -0,0 +1,203 @@
+// Package authenticator opens security keys, either connected over HID or emulated by a virtual authenticator.
+package authenticator
+
+import (
+	"context"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+	"github.com/mohammadv184/skm/internal/virtual"
+)
+
+const (
+	// VirtualPrefix is the device path prefix that selects the virtual authenticator stored in the file
+	// after it, e.g. virtual:/tmp/key.json. The file is created on first use.
+	VirtualPrefix = "virtual:"
+	// VirtualDevicesEnv names the environment variable listing virtual authenticator files, separated by the
+	// OS path list separator, that are enumerated alongside the connected security keys.
+	VirtualDevicesEnv = "SKM_VIRTUAL_DEVICES"
+)
+
+// Device is an opened security key. It is implemented by *fido2.Device and *virtual.Authenticator.
+type Device interface {
+	Info() *ctap2.AuthenticatorGetInfoResponse
+	Close() error
+	Reset() error
+	Selection(ctx context.Context) error
+
+	GetPINRetries() (uint, bool, error)
+	GetUVRetries() (uint, error)
+	SetPIN(pin string) error
+	ChangePIN(currentPin string, newPin string) error
+	GetPinUvAuthTokenUsingPIN(pin string, permissions ctap2.Permission, rpID string) ([]byte, error)
+	GetPinUvAuthTokenUsingUV(permissions ctap2.Permission, rpID string) ([]byte, error)
+
+	MakeCredential(
+		pinUvAuthToken []byte,
+		clientData []byte,
+		rp webauthn.PublicKeyCredentialRpEntity,
+		user webauthn.PublicKeyCredentialUserEntity,
+		pubKeyCredParams []webauthn.PublicKeyCredentialParameters,
+		excludeList []webauthn.PublicKeyCredentialDescriptor,
+		extInputs *webauthn.CreateAuthenticationExtensionsClientInputs,
+		options map[ctap2.Option]bool,
+		enterpriseAttestation uint,
+		attestationFormatsPreference []webauthn.AttestationStatementFormatIdentifier,
+	) (*ctap2.AuthenticatorMakeCredentialResponse, error)
+	GetAssertion(
+		pinUvAuthToken []byte,
+		rpID string,
+		clientData []byte,
+		allowList []webauthn.PublicKeyCredentialDescriptor,
+		extInputs *webauthn.GetAuthenticationExtensionsClientInputs,
+		options map[ctap2.Option]bool,
+	) iter.Seq2[*ctap2.AuthenticatorGetAssertionResponse, error]
+
+	GetCredsMetadata(pinUvAuthToken []byte) (*ctap2.AuthenticatorCredentialManagementResponse, error)
+	EnumerateRPs(pinUvAuthToken []byte) iter.Seq2[*ctap2.AuthenticatorCredentialManagementResponse, error]
+	EnumerateCredentials(
+		pinUvAuthToken []byte,
+		rpIDHash []byte,
+	) iter.Seq2[*ctap2.AuthenticatorCredentialManagementResponse, error]
+	DeleteCredential(pinUvAuthToken []byte, credentialID webauthn.PublicKeyCredentialDescriptor) error
+	UpdateUserInformation(
+		pinUvAuthToken []byte,
+		credentialID webauthn.PublicKeyCredentialDescriptor,
+		user webauthn.PublicKeyCredentialUserEntity,
+	) error
+
+	GetBioModality() (*ctap2.AuthenticatorBioEnrollmentResponse, error)
+	GetFingerprintSensorInfo() (*ctap2.AuthenticatorBioEnrollmentResponse, error)
+	BeginEnroll(pinUvAuthToken []byte, timeoutMilliseconds uint) (*ctap2.AuthenticatorBioEnrollmentResponse, error)
+	EnrollCaptureNextSample(
+		pinUvAuthToken []byte,
+		templateID []byte,
+		timeoutMilliseconds uint,
+	) (*ctap2.AuthenticatorBioEnrollmentResponse, error)
+	CancelCurrentEnrollment() error
+	EnumerateEnrollments(pinUvAuthToken []byte) (*ctap2.AuthenticatorBioEnrollmentResponse, error)
+	SetFriendlyName(pinUvAuthToken []byte, templateID []byte, friendlyName string) error
+	RemoveEnrollment(pinUvAuthToken []byte, templateID []byte) error
+
+	GetLargeBlobs() ([]*ctap2.LargeBlob, error)
+	SetLargeBlobs(pinUvAuthToken []byte, blobs []*ctap2.LargeBlob) error
+
+	EnableEnterpriseAttestation(pinUvAuthToken []byte) error
+	ToggleAlwaysUV(pinUvAuthToken []byte) error
+	SetMinPINLength(
+		pinUvAuthToken []byte,
+		newMinPINLength uint,
+		minPinLengthRPIDs []string,
+		forceChangePin bool,
+		pinComplexityPolicy bool,
+	) error
+}
+
+var (
+	_ Device = (*fido2.Device)(nil)
+	_ Device = (*virtual.Authenticator)(nil)
+)
+
+// Backend enumerates and opens security keys. Commands find their Backend in their context, so tests can
+// replace the connected security keys with fakes.
+type Backend interface {
+	// Enumerate returns the available security keys.
+	Enumerate() ([]fido2.DeviceDescriptor, error)
+	// OpenPath opens the security key at path.
+	OpenPath(path string) (Device, error)
+}
+
+// System is the Backend of the security keys connected to this machine and the virtual authenticators listed
+// in VirtualDevicesEnv.
+var System Backend = systemBackend{}
+
+type backendKey struct{}
+
+// NewContext returns a copy of ctx that carries b.
+func NewContext(ctx context.Context, b Backend) context.Context {
+	return context.WithValue(ctx, backendKey{}, b)
+}
+
+// FromContext returns the Backend carried by ctx, or System if there is none.
+func FromContext(ctx context.Context) Backend {
+	if ctx != nil {
+		if b, ok := ctx.Value(backendKey{}).(Backend); ok {
+			return b
+		}
+	}
+	return System
+}
+
+// IsVirtual reports whether path selects a virtual authenticator.
+func IsVirtual(path string) bool {
+	return strings.HasPrefix(path, VirtualPrefix)
+}
+
+// Open opens the security key described by desc with the Backend of ctx.
+func Open(ctx context.Context, desc fido2.DeviceDescriptor) (Device, error) {
+	return OpenPath(ctx, desc.Path)
+}
+
+// OpenPath opens the security key at path with the Backend of ctx. A wrong PIN is reported as an
+// InvalidPINError.
+func OpenPath(ctx context.Context, path string) (Device, error) {
+	dev, err := FromContext(ctx).OpenPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return pinRetriesDevice{dev}, nil
+}
+
+// Enumerate returns the security keys of the Backend of ctx.
+func Enumerate(ctx context.Context) ([]fido2.DeviceDescriptor, error) {
+	return FromContext(ctx).Enumerate()
+}
+
+// Describe returns the descriptor of the virtual authenticator at path, which must be prefixed with
+// VirtualPrefix.
+func Describe(path string) (fido2.DeviceDescriptor, error) {
+	return virtual.Describe(VirtualPrefix, strings.TrimPrefix(path, VirtualPrefix))
+}
+
+type systemBackend struct{}
+
+// OpenPath opens the security key at path, which is either a platform-specific HID path or a path prefixed
+// with VirtualPrefix.
+func (systemBackend) OpenPath(path string) (Device, error) {
+	if IsVirtual(path) {
+		return virtual.Open(strings.TrimPrefix(path, VirtualPrefix))
+	}
+	return fido2.OpenPath(path)
+}
+
+// Enumerate returns the connected security keys followed by the virtual authenticators listed in
+// VirtualDevicesEnv. If any virtual authenticator is listed, a failure to enumerate HID devices, e.g. on a
+// machine without USB, is ignored.
+func (systemBackend) Enumerate() ([]fido2.DeviceDescriptor, error) {
+	var paths []string
+	for _, p := range filepath.SplitList(os.Getenv(VirtualDevicesEnv)) {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+
+	devs, err := fido2.Enumerate()
+	if err != nil && len(paths) == 0 {
+		return nil, err
+	}
+
+	for _, p := range paths {
+		desc, err := Describe(VirtualPrefix + strings.TrimPrefix(p, VirtualPrefix))
+		if err != nil {
+			return nil, err
+		}
+		devs = append(devs, desc)
+	}
+
+	return devs, nil
+}
@@ -0,0 +1,51 @@
+package authenticator
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mohammadv184/skm/internal/virtual"
+)
+
+func TestEnumerateVirtualDevices(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "a.json"), filepath.Join(dir, "b.json")}
+	t.Setenv(VirtualDevicesEnv, strings.Join(files, string(filepath.ListSeparator)))
+
+	devs, err := Enumerate(context.Background())
+	if err != nil {
+		t.Fatalf("Enumerate() error = %v", err)
+	}
+
+	var virtuals []string
+	for _, d := range devs {
+		if IsVirtual(d.Path) {
+			virtuals = append(virtuals, d.Path)
+			if d.Product != virtual.Product {
+				t.Errorf("Product = %q, want %q", d.Product, virtual.Product)
+			}
+		}
+	}
+	if len(virtuals) != len(files) {
+		t.Fatalf("got virtual devices %v, want %d", virtuals, len(files))
+	}
+
+	for i, path := range virtuals {
+		if want := VirtualPrefix + files[i]; path != want {
+			t.Errorf("Path = %q, want %q", path, want)
+		}
+
+		dev, err := OpenPath(context.Background(), path)
+		if err != nil {
+			t.Fatalf("OpenPath(%q) error = %v", path, err)
+		}
+		if d, ok := dev.(pinRetriesDevice); !ok {
+			t.Errorf("OpenPath(%q) = %T, want pinRetriesDevice", path, dev)
+		} else if _, ok := d.Device.(*virtual.Authenticator); !ok {
+			t.Errorf("OpenPath(%q) opened %T, want *virtual.Authenticator", path, d.Device)
+		}
+		_ = dev.Close()
+	}
+}
@@ -0,0 +1,162 @@
+// Package authenticatortest provides a fake authenticator.Backend for testing commands without security keys.
+package authenticatortest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/virtual"
+)
+
+// Backend is an authenticator.Backend whose security keys are virtual authenticators stored in a temporary
+// directory, listed under the paths they were added with. Security keys may be added while a command runs.
+type Backend struct {
+	// EnumerateErr is returned by Enumerate if set.
+	EnumerateErr error
+	// OpenErr is returned by OpenPath if set.
+	OpenErr error
+	// Wrap, if set, wraps every opened security key, e.g. to make a single method fail.
+	Wrap func(authenticator.Device) authenticator.Device
+
+	tb    testing.TB
+	dir   string
+	mu    sync.Mutex
+	devs  []fido2.DeviceDescriptor
+	files map[string]string
+	// unplugged holds the files of the unplugged security keys by their former paths.
+	unplugged map[string]string
+}
+
+var _ authenticator.Backend = (*Backend)(nil)
+
+// NewBackend returns a Backend without security keys.
+func NewBackend(tb testing.TB) *Backend {
+	tb.Helper()
+
+	return &Backend{
+		tb:        tb,
+		dir:       tb.TempDir(),
+		files:     make(map[string]string),
+		unplugged: make(map[string]string),
+	}
+}
+
+// Add adds a new virtual authenticator listed as desc and returns it, so it can be set up before running a
+// command. If desc has no path, /dev/hidrawN is used.
+func (b *Backend) Add(desc fido2.DeviceDescriptor) *virtual.Authenticator {
+	b.tb.Helper()
+
+	b.mu.Lock()
+	if desc.Path == "" {
+		desc.Path = fmt.Sprintf("/dev/hidraw%d", len(b.devs))
+	}
+	if _, ok := b.files[desc.Path]; ok {
+		b.mu.Unlock()
+		b.tb.Fatalf("authenticatortest: %s added twice", desc.Path)
+	}
+
+	b.files[desc.Path] = filepath.Join(b.dir, fmt.Sprintf("%d.json", len(b.devs)))
+	b.devs = append(b.devs, desc)
+	b.mu.Unlock()
+
+	return b.Virtual(desc.Path)
+}
+
+// Virtual opens the virtual authenticator listed at path with its current state. It is closed when the test
+// ends.
+func (b *Backend) Virtual(path string) *virtual.Authenticator {
+	b.tb.Helper()
+
+	b.mu.Lock()
+	file, ok := b.files[path]
+	b.mu.Unlock()
+	if !ok {
+		b.tb.Fatalf("authenticatortest: no security key at %s", path)
+	}
+
+	a, err := virtual.Open(file)
+	if err != nil {
+		b.tb.Fatalf("authenticatortest: %v", err)
+	}
+	b.tb.Cleanup(func() {
+		_ = a.Close()
+	})
+
+	return a
+}
+
+// Unplug stops listing the security key at path, which keeps its state until it is replugged.
+func (b *Backend) Unplug(path string) {
+	b.tb.Helper()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := slices.IndexFunc(b.devs, func(d fido2.DeviceDescriptor) bool {
+		return d.Path == path
+	})
+	if i < 0 {
+		b.tb.Errorf("authenticatortest: no security key at %s", path)
+		return
+	}
+	b.devs = slices.Delete(b.devs, i, i+1)
+	b.unplugged[path] = b.files[path]
+	delete(b.files, path)
+}
+
+// Replug lists the security key unplugged from path again as desc, whose path may differ.
+func (b *Backend) Replug(path string, desc fido2.DeviceDescriptor) {
+	b.tb.Helper()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	file, ok := b.unplugged[path]
+	if !ok {
+		b.tb.Errorf("authenticatortest: no security key unplugged from %s", path)
+		return
+	}
+	delete(b.unplugged, path)
+	b.files[desc.Path] = file
+	b.devs = append(b.devs, desc)
+}
+
+// Enumerate returns the added security keys in the order they were added.
+func (b *Backend) Enumerate() ([]fido2.DeviceDescriptor, error) {
+	if b.EnumerateErr != nil {
+		return nil, b.EnumerateErr
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]fido2.DeviceDescriptor(nil), b.devs...), nil
+}
+
+// OpenPath opens the security key listed at path.
+func (b *Backend) OpenPath(path string) (authenticator.Device, error) {
+	if b.OpenErr != nil {
+		return nil, b.OpenErr
+	}
+
+	b.mu.Lock()
+	file, ok := b.files[path]
+	b.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("open %s: %w", path, os.ErrNotExist)
+	}
+
+	a, err := virtual.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	if b.Wrap != nil {
+		return b.Wrap(a), nil
+	}
+	return a, nil
+}
@@ -0,0 +1,60 @@
+package authenticator
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+)
+
+// hotplugNotifications returns a channel that receives a value whenever a hidraw device is added or removed,
+// as announced by the kernel uevents multicast over netlink, and a function that stops the notifications.
+// If the netlink socket cannot be opened, e.g. in a sandbox, the channel is nil and Watch only polls.
+func hotplugNotifications() (<-chan struct{}, func()) {
+	fd, err := syscall.Socket(
+		syscall.AF_NETLINK,
+		syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK,
+		syscall.NETLINK_KOBJECT_UEVENT,
+	)
+	if err != nil {
+		return nil, func() {}
+	}
+	// Group 1 receives the kernel uevents, as opposed to those rebroadcast by udev.
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: 1}); err != nil {
+		_ = syscall.Close(fd)
+		return nil, func() {}
+	}
+
+	// A non-blocking descriptor makes a pollable File, whose Read is interrupted by Close.
+	f := os.NewFile(uintptr(fd), "uevent")
+	changes := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, os.Getpagesize())
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			if isHIDRawUevent(buf[:n]) {
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return changes, func() {
+		_ = f.Close()
+	}
+}
+
+// isHIDRawUevent reports whether the uevent msg, a header followed by NUL-separated KEY=value pairs, is about
+// a hidraw device.
+func isHIDRawUevent(msg []byte) bool {
+	for field := range bytes.SplitSeq(msg, []byte{0}) {
+		if bytes.Equal(field, []byte("SUBSYSTEM=hidraw")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package authenticator
+
+// hotplugNotifications returns a nil channel: hot-plug notifications are only supported on Linux, elsewhere
+// Watch polls.
+func hotplugNotifications() (<-chan struct{}, func()) {
+	return nil, func() {}
+}
@@ -0,0 +1,57 @@
+package authenticator
+
+import (
+	"errors"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+)
+
+// InvalidPINError is a PIN rejected by a security key with CTAP2_ERR_PIN_INVALID, with the number of PIN
+// retries left.
+type InvalidPINError struct {
+	Retries uint
+	Err     error
+}
+
+// Error returns the message of the wrapped error.
+func (e *InvalidPINError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *InvalidPINError) Unwrap() error {
+	return e.Err
+}
+
+// pinRetriesDevice is a Device whose CTAP2_ERR_PIN_INVALID errors are InvalidPINErrors.
+type pinRetriesDevice struct {
+	Device
+}
+
+func (d pinRetriesDevice) GetPinUvAuthTokenUsingPIN(
+	pin string,
+	permissions ctap2.Permission,
+	rpID string,
+) ([]byte, error) {
+	token, err := d.Device.GetPinUvAuthTokenUsingPIN(pin, permissions, rpID)
+	return token, d.withRetries(err)
+}
+
+func (d pinRetriesDevice) ChangePIN(currentPin string, newPin string) error {
+	return d.withRetries(d.Device.ChangePIN(currentPin, newPin))
+}
+
+// withRetries turns a CTAP2_ERR_PIN_INVALID error into an InvalidPINError, if the retries can be read.
+func (d pinRetriesDevice) withRetries(err error) error {
+	var ctapErr *ctaphid.CTAPError
+	if !errors.As(err, &ctapErr) || ctapErr.StatusCode != ctaphid.StatusCTAP2ErrPinInvalid {
+		return err
+	}
+
+	retries, _, rerr := d.GetPINRetries()
+	if rerr != nil {
+		return err
+	}
+	return &InvalidPINError{Retries: retries, Err: err}
+}
@@ -0,0 +1,47 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+)
+
+func TestInvalidPINError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "key.json")
+	t.Setenv(VirtualDevicesEnv, file)
+
+	dev, err := OpenPath(context.Background(), VirtualPrefix+file)
+	if err != nil {
+		t.Fatalf("OpenPath() error = %v", err)
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	if err := dev.SetPIN("1234"); err != nil {
+		t.Fatalf("SetPIN() error = %v", err)
+	}
+
+	_, err = dev.GetPinUvAuthTokenUsingPIN("0000", ctap2.PermissionCredentialManagement, "")
+
+	var pinErr *InvalidPINError
+	if !errors.As(err, &pinErr) {
+		t.Fatalf("GetPinUvAuthTokenUsingPIN() error = %v, want an InvalidPINError", err)
+	}
+	if pinErr.Retries != 7 {
+		t.Errorf("Retries = %d, want 7", pinErr.Retries)
+	}
+	var ctapErr *ctaphid.CTAPError
+	if !errors.As(err, &ctapErr) || ctapErr.StatusCode != ctaphid.StatusCTAP2ErrPinInvalid {
+		t.Errorf("GetPinUvAuthTokenUsingPIN() error = %v, want %s", err, ctaphid.StatusCTAP2ErrPinInvalid)
+	}
+
+	err = dev.ChangePIN("0000", "5678")
+	if !errors.As(err, &pinErr) || pinErr.Retries != 6 {
+		t.Errorf("ChangePIN() error = %v, want an InvalidPINError with 6 retries", err)
+	}
+}
@@ -0,0 +1,125 @@
+package authenticator
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+)
+
+// EventType is the kind of a hot-plug Event.
+type EventType string
+
+const (
+	// EventAttach reports a security key that was plugged in.
+	EventAttach EventType = "attach"
+	// EventDetach reports a security key that was removed.
+	EventDetach EventType = "detach"
+)
+
+// Event is a security key being attached or detached.
+type Event struct {
+	Type   EventType
+	Time   time.Time
+	Device fido2.DeviceDescriptor
+}
+
+// Watch enumerates the security keys with enumerate whenever the kernel reports a HID device being added or
+// removed, which is only supported on Linux, and at least every interval, and yields an Event for every key
+// that appeared or disappeared since the previous enumeration. The keys present when Watch starts are
+// reported as attached. Failed enumerations are yielded as errors and watching goes on until the consumer
+// stops or ctx is done.
+func Watch(
+	ctx context.Context,
+	enumerate func() ([]fido2.DeviceDescriptor, error),
+	interval time.Duration,
+) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		var attached []fido2.DeviceDescriptor
+		poll(ctx, enumerate, interval, func(devs []fido2.DeviceDescriptor, err error) bool {
+			if err != nil {
+				return yield(Event{}, err)
+			}
+
+			for _, e := range diffDevices(attached, devs, time.Now()) {
+				if !yield(e, nil) {
+					return false
+				}
+			}
+			attached = devs
+			return true
+		})
+	}
+}
+
+// WaitFor enumerates the security keys with enumerate when Watch would, until found reports that the wanted
+// keys are there, and returns them. Failed enumerations are retried. It returns the error of ctx if it is done
+// first.
+func WaitFor(
+	ctx context.Context,
+	enumerate func() ([]fido2.DeviceDescriptor, error),
+	interval time.Duration,
+	found func(devs []fido2.DeviceDescriptor) bool,
+) ([]fido2.DeviceDescriptor, error) {
+	var (
+		result []fido2.DeviceDescriptor
+		ok     bool
+	)
+	poll(ctx, enumerate, interval, func(devs []fido2.DeviceDescriptor, err error) bool {
+		ok = err == nil && found(devs)
+		result = devs
+		return !ok
+	})
+
+	if !ok {
+		return nil, ctx.Err()
+	}
+	return result, nil
+}
+
+// poll calls visit with the result of enumerate right away, then whenever a hidraw device is added or removed
+// and at least every interval, until visit returns false or ctx is done.
+func poll(
+	ctx context.Context,
+	enumerate func() ([]fido2.DeviceDescriptor, error),
+	interval time.Duration,
+	visit func(devs []fido2.DeviceDescriptor, err error) bool,
+) {
+	changes, stop := hotplugNotifications()
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if !visit(enumerate()) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-changes:
+		}
+	}
+}
+
+// diffDevices returns the events turning the old list of security keys into the new one: the removed keys
+// are detached first, then the added ones attached. A key whose descriptor changed is both.
+func diffDevices(old, cur []fido2.DeviceDescriptor, now time.Time) []Event {
+	var events []Event
+	for _, d := range old {
+		if !slices.Contains(cur, d) {
+			events = append(events, Event{Type: EventDetach, Time: now, Device: d})
+		}
+	}
+	for _, d := range cur {
+		if !slices.Contains(old, d) {
+			events = append(events, Event{Type: EventAttach, Time: now, Device: d})
+		}
+	}
+	return events
+}
@@ -0,0 +1,135 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+)
+
+func TestWatch(t *testing.T) {
+	yubikey := fido2.DeviceDescriptor{Path: "/dev/hidraw0", Product: "YubiKey 5"}
+	solo := fido2.DeviceDescriptor{Path: "/dev/hidraw1", Product: "Solo 2"}
+
+	// Every enumeration returns the next snapshot, then the last one forever.
+	errEnumerate := errors.New("hid: enumeration failed")
+	snapshots := []struct {
+		devs []fido2.DeviceDescriptor
+		err  error
+	}{
+		{devs: []fido2.DeviceDescriptor{yubikey}},
+		{devs: []fido2.DeviceDescriptor{yubikey}},
+		{devs: []fido2.DeviceDescriptor{yubikey, solo}},
+		{err: errEnumerate},
+		{devs: []fido2.DeviceDescriptor{solo}},
+	}
+	var mu sync.Mutex
+	enumerate := func() ([]fido2.DeviceDescriptor, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		s := snapshots[0]
+		if len(snapshots) > 1 {
+			snapshots = snapshots[1:]
+		}
+		return s.devs, s.err
+	}
+
+	type event struct {
+		typ  EventType
+		path string
+		err  error
+	}
+	want := []event{
+		{typ: EventAttach, path: yubikey.Path},
+		{typ: EventAttach, path: solo.Path},
+		{err: errEnumerate},
+		{typ: EventDetach, path: yubikey.Path},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var got []event
+	start := time.Now()
+	for e, err := range Watch(ctx, enumerate, time.Millisecond) {
+		if err == nil && (e.Time.Before(start) || e.Time.After(time.Now())) {
+			t.Errorf("event time %v, want between %v and now", e.Time, start)
+		}
+		got = append(got, event{typ: e.Type, path: e.Device.Path, err: err})
+		if len(got) == len(want) {
+			break
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got events %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].typ != want[i].typ || got[i].path != want[i].path || !errors.Is(got[i].err, want[i].err) {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWatchStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	enumerate := func() ([]fido2.DeviceDescriptor, error) {
+		cancel()
+		return nil, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e, err := range Watch(ctx, enumerate, time.Hour) {
+			t.Errorf("got event %+v, %v, want none", e, err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch didn't stop when its context was cancelled")
+	}
+}
+
+func TestWaitFor(t *testing.T) {
+	var calls int
+	enumerate := func() ([]fido2.DeviceDescriptor, error) {
+		calls++
+		switch calls {
+		case 1:
+			return nil, nil
+		case 2:
+			return nil, errors.New("hid: enumeration failed")
+		default:
+			return []fido2.DeviceDescriptor{{Path: "/dev/hidraw0"}}, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	devs, err := WaitFor(ctx, enumerate, time.Millisecond, func(devs []fido2.DeviceDescriptor) bool {
+		return len(devs) > 0
+	})
+	if err != nil {
+		t.Fatalf("WaitFor() error = %v", err)
+	}
+	if len(devs) != 1 || calls != 3 {
+		t.Errorf("WaitFor() = %v after %d enumerations, want 1 security key after 3", devs, calls)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = WaitFor(ctx, enumerate, time.Millisecond, func([]fido2.DeviceDescriptor) bool {
+		return false
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitFor() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
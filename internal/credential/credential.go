@@ -0,0 +1,151 @@
+// Package credential collects the discoverable (resident) credentials stored on a security key.
+package credential
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+	"github.com/mohammadv184/skm/internal/authenticator"
+)
+
+// Credential is a discoverable credential as reported by authenticatorCredentialManagement.
+// Its RP and RPIDHash fields are always set to the relying party it belongs to.
+type Credential = ctap2.AuthenticatorCredentialManagementResponse
+
+// RelyingParty groups the credentials stored for a single relying party.
+type RelyingParty struct {
+	// RP is the relying party entity.
+	RP webauthn.PublicKeyCredentialRpEntity
+	// RPIDHash is the SHA-256 hash of the relying party ID.
+	RPIDHash []byte
+	// Credentials are all the credentials stored for the relying party.
+	Credentials []*Credential
+}
+
+// DisplayName returns the relying party name, or its ID if it has no name.
+func (rp *RelyingParty) DisplayName() string {
+	if rp.RP.Name != "" {
+		return rp.RP.Name
+	}
+	return rp.RP.ID
+}
+
+// Collect enumerates every credential stored on dev, grouped by relying party in the order the device reports them.
+// The pinUvAuthToken must have the credential management permission.
+func Collect(dev authenticator.Device, pinUvAuthToken []byte) ([]*RelyingParty, error) {
+	var rps []*RelyingParty
+
+	for rp, err := range dev.EnumerateRPs(pinUvAuthToken) {
+		if isNoCredentials(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rps = append(rps, &RelyingParty{
+			RP:       rp.RP,
+			RPIDHash: rp.RPIDHash,
+		})
+	}
+
+	for _, rp := range rps {
+		for c, err := range dev.EnumerateCredentials(pinUvAuthToken, rp.RPIDHash) {
+			if err != nil {
+				return nil, err
+			}
+
+			c.RP = rp.RP
+			c.RPIDHash = rp.RPIDHash
+			rp.Credentials = append(rp.Credentials, c)
+		}
+	}
+
+	return rps, nil
+}
+
+// isNoCredentials reports whether err is the CTAP2_ERR_NO_CREDENTIALS status returned by devices that
+// have no discoverable credentials when enumeration begins.
+func isNoCredentials(err error) bool {
+	var ctapErr *ctaphid.CTAPError
+	return errors.As(err, &ctapErr) && ctapErr.StatusCode == ctaphid.StatusCTAP2ErrNoCredentials
+}
+
+// All returns the credentials of all relying parties as a flat list, keeping them grouped by relying party.
+func All(rps []*RelyingParty) []*Credential {
+	var creds []*Credential
+	for _, rp := range rps {
+		creds = append(creds, rp.Credentials...)
+	}
+	return creds
+}
+
+// FindByID returns the credential with the given base64url encoded ID.
+func FindByID(rps []*RelyingParty, id string) (*Credential, error) {
+	decodedID, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(id, "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode credential ID: %w", err)
+	}
+
+	for _, c := range All(rps) {
+		if bytes.Equal(c.CredentialID.ID, decodedID) {
+			return c, nil
+		}
+	}
+
+	return nil, fmt.Errorf("credential not found with ID: %s", id)
+}
+
+// ProtectionName returns the name of a credProtect policy level.
+func ProtectionName(credProtect uint) string {
+	switch credProtect {
+	case 0:
+		return "none"
+	case 1:
+		return "userVerificationOptional"
+	case 2:
+		return "userVerificationOptionalWithCredentialIDList"
+	case 3:
+		return "userVerificationRequired"
+	default:
+		return "unknown (" + strconv.FormatUint(uint64(credProtect), 10) + ")"
+	}
+}
+
+// algorithmNames maps COSE algorithm identifiers commonly used by authenticators to their names.
+var algorithmNames = map[int]string{
+	-7:   "ES256",
+	-8:   "EdDSA",
+	-9:   "ESP256",
+	-35:  "ES384",
+	-36:  "ES512",
+	-37:  "PS256",
+	-47:  "ES256K",
+	-257: "RS256",
+	-258: "RS384",
+	-259: "RS512",
+}
+
+// AlgorithmName returns the name of a COSE algorithm, or its identifier if the algorithm is unknown.
+func AlgorithmName(alg int) string {
+	if name, ok := algorithmNames[alg]; ok {
+		return name
+	}
+	return strconv.Itoa(alg)
+}
+
+// PublicKeyAlgorithm returns the name of the algorithm of the credential's public key, or an empty string
+// if the device didn't report a public key.
+func PublicKeyAlgorithm(c *Credential) string {
+	if c.PublicKey == nil {
+		return ""
+	}
+	return AlgorithmName(int(c.PublicKey.Alg()))
+}
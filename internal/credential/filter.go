@@ -0,0 +1,93 @@
+package credential
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// Filter selects credentials by relying party ID and user name. Patterns are globs unless Regex is set,
+// in which case they are regular expressions matching anywhere in the value unless anchored.
+type Filter struct {
+	// RP is the pattern matched against the relying party ID.
+	RP string
+	// User is the pattern matched against the user name.
+	User string
+	// Regex makes RP and User regular expressions instead of globs.
+	Regex bool
+}
+
+// IsSet reports whether any pattern is set.
+func (f Filter) IsSet() bool {
+	return f.RP != "" || f.User != ""
+}
+
+// Apply returns the credentials of rps that match the filter, grouped by relying party.
+// Relying parties without matching credentials are omitted.
+func (f Filter) Apply(rps []*RelyingParty) ([]*RelyingParty, error) {
+	matchRP, err := f.matcher(f.RP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RP pattern %q: %w", f.RP, err)
+	}
+	matchUser, err := f.matcher(f.User)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user pattern %q: %w", f.User, err)
+	}
+
+	var matched []*Credential
+	for _, rp := range rps {
+		if !matchRP(rp.RP.ID) {
+			continue
+		}
+		for _, c := range rp.Credentials {
+			if matchUser(c.User.Name) {
+				matched = append(matched, c)
+			}
+		}
+	}
+
+	return Group(matched), nil
+}
+
+func (f Filter) matcher(pattern string) (func(string) bool, error) {
+	if pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	if f.Regex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return func(s string) bool {
+		ok, _ := path.Match(pattern, s)
+		return ok
+	}, nil
+}
+
+// Group groups credentials by relying party, keeping the order in which relying parties first appear.
+func Group(creds []*Credential) []*RelyingParty {
+	var rps []*RelyingParty
+	byHash := make(map[string]*RelyingParty)
+
+	for _, c := range creds {
+		rp, ok := byHash[string(c.RPIDHash)]
+		if !ok {
+			rp = &RelyingParty{
+				RP:       c.RP,
+				RPIDHash: c.RPIDHash,
+			}
+			byHash[string(c.RPIDHash)] = rp
+			rps = append(rps, rp)
+		}
+		rp.Credentials = append(rp.Credentials, c)
+	}
+
+	return rps
+}
@@ -0,0 +1,105 @@
+package credential
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+)
+
+// PublicKey is a credential public key decoded from its COSE representation.
+type PublicKey struct {
+	// Algorithm is the name of the COSE algorithm of the key.
+	Algorithm string
+	// Curve is the name of the elliptic curve of the key, or empty for RSA keys.
+	Curve string
+	// PEM is the PEM encoded PKIX (SubjectPublicKeyInfo) public key.
+	PEM string
+	// JWK is the public key as a JSON Web Key.
+	JWK map[string]string
+	// Key is the public key itself: an *ecdsa.PublicKey, an ed25519.PublicKey or an *rsa.PublicKey.
+	Key crypto.PublicKey
+}
+
+// DecodePublicKey decodes a COSE public key into its PEM and JWK representations.
+// EC2 (P-256, P-384, P-521), OKP (Ed25519) and RSA keys are supported.
+func DecodePublicKey(k key.Key) (*PublicKey, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	pk := &PublicKey{
+		Algorithm: AlgorithmName(int(k.Alg())),
+		JWK:       map[string]string{},
+	}
+	if k.Has(iana.KeyParameterAlg) {
+		pk.JWK["alg"] = pk.Algorithm
+	}
+
+	var pub crypto.PublicKey
+	switch k.Kty() {
+	case iana.KeyTypeEC2:
+		crv, _ := k.GetInt(iana.EC2KeyParameterCrv)
+		x, _ := k.GetBytes(iana.EC2KeyParameterX)
+		y, _ := k.GetBytes(iana.EC2KeyParameterY)
+
+		var curve elliptic.Curve
+		switch crv {
+		case iana.EllipticCurveP_256:
+			curve, pk.Curve = elliptic.P256(), "P-256"
+		case iana.EllipticCurveP_384:
+			curve, pk.Curve = elliptic.P384(), "P-384"
+		case iana.EllipticCurveP_521:
+			curve, pk.Curve = elliptic.P521(), "P-521"
+		default:
+			return nil, fmt.Errorf("unsupported EC2 curve %d", crv)
+		}
+		if len(x) == 0 || len(y) == 0 {
+			return nil, errors.New("EC2 public key is missing its coordinates")
+		}
+
+		pub = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		pk.JWK["kty"], pk.JWK["crv"], pk.JWK["x"], pk.JWK["y"] = "EC", pk.Curve, b64(x), b64(y)
+	case iana.KeyTypeOKP:
+		crv, _ := k.GetInt(iana.OKPKeyParameterCrv)
+		x, _ := k.GetBytes(iana.OKPKeyParameterX)
+		if crv != iana.EllipticCurveEd25519 {
+			return nil, fmt.Errorf("unsupported OKP curve %d", crv)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key size")
+		}
+
+		pk.Curve = "Ed25519"
+		pub = ed25519.PublicKey(x)
+		pk.JWK["kty"], pk.JWK["crv"], pk.JWK["x"] = "OKP", pk.Curve, b64(x)
+	case iana.KeyTypeRSA:
+		n, _ := k.GetBytes(iana.RSAKeyParameterN)
+		e, _ := k.GetBytes(iana.RSAKeyParameterE)
+		if len(n) == 0 || len(e) == 0 {
+			return nil, errors.New("RSA public key is missing its modulus or exponent")
+		}
+
+		pub = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		pk.JWK["kty"], pk.JWK["n"], pk.JWK["e"] = "RSA", b64(n), b64(e)
+	default:
+		return nil, fmt.Errorf("unsupported COSE key type %d", k.Kty())
+	}
+
+	pk.Key = pub
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	pk.PEM = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	return pk, nil
+}
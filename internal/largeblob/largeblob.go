@@ -0,0 +1,155 @@
+// Package largeblob encrypts, decrypts and matches the per-credential entries of a security key's
+// serialized large-blob array as specified by CTAP 2.1.
+package largeblob
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/credential"
+)
+
+// ErrNoLargeBlobKey is returned when a credential has no largeBlobKey and so cannot own a large blob.
+var ErrNoLargeBlobKey = errors.New("credential has no largeBlobKey")
+
+// nonceSize is the AES-GCM nonce size used by large blob entries.
+const nonceSize = 12
+
+// Entry is an entry of the large-blob array together with the credential that owns it, if any.
+type Entry struct {
+	// Index is the position of the entry in the large-blob array.
+	Index int
+	// Blob is the encrypted entry as stored on the device.
+	Blob *ctap2.LargeBlob
+	// Credential is the resident credential whose largeBlobKey decrypts the entry, or nil if the entry is orphaned.
+	Credential *credential.Credential
+	// Data is the decrypted and decompressed data of the entry, or nil if the entry is orphaned.
+	Data []byte
+}
+
+// Orphaned reports whether no resident credential can decrypt the entry.
+func (e *Entry) Orphaned() bool {
+	return e.Credential == nil
+}
+
+// Match decrypts every entry of blobs with the largeBlobKey of each credential in creds. Entries that no
+// credential can decrypt are returned as orphaned.
+func Match(blobs []*ctap2.LargeBlob, creds []*credential.Credential) []*Entry {
+	entries := make([]*Entry, len(blobs))
+	for i, blob := range blobs {
+		entries[i] = &Entry{Index: i, Blob: blob}
+		for _, c := range creds {
+			if len(c.LargeBlobKey) == 0 {
+				continue
+			}
+
+			data, err := Decrypt(c.LargeBlobKey, blob)
+			if err != nil {
+				continue
+			}
+
+			entries[i].Credential = c
+			entries[i].Data = data
+			break
+		}
+	}
+	return entries
+}
+
+// Find returns the index of the entry of blobs owned by cred, or -1 if it has none.
+func Find(blobs []*ctap2.LargeBlob, cred *credential.Credential) int {
+	if len(cred.LargeBlobKey) == 0 {
+		return -1
+	}
+
+	for i, blob := range blobs {
+		if _, err := Decrypt(cred.LargeBlobKey, blob); err == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// Decrypt decrypts and decompresses a large-blob entry with the given largeBlobKey.
+func Decrypt(key []byte, blob *ctap2.LargeBlob) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob.Nonce) != nonceSize {
+		return nil, fmt.Errorf("invalid nonce size %d", len(blob.Nonce))
+	}
+
+	compressed, err := aead.Open(nil, blob.Nonce, blob.Ciphertext, associatedData(blob.OrigSize))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress large blob: %w", err)
+	}
+	if uint(len(data)) != blob.OrigSize {
+		return nil, fmt.Errorf("large blob size %d does not match its recorded size %d", len(data), blob.OrigSize)
+	}
+
+	return data, nil
+}
+
+// Encrypt compresses and encrypts data into a large-blob entry with the given largeBlobKey.
+func Encrypt(key []byte, data []byte) (*ctap2.LargeBlob, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed bytes.Buffer
+	w, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	origSize := uint(len(data))
+	return &ctap2.LargeBlob{
+		Ciphertext: aead.Seal(nil, nonce, compressed.Bytes(), associatedData(origSize)),
+		Nonce:      nonce,
+		OrigSize:   origSize,
+	}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, ErrNoLargeBlobKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// associatedData returns the AES-GCM associated data of an entry: "blob" followed by the
+// uncompressed size as a little-endian uint64.
+func associatedData(origSize uint) []byte {
+	return binary.LittleEndian.AppendUint64([]byte("blob"), uint64(origSize))
+}
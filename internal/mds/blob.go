@@ -0,0 +1,201 @@
+package mds
+
+import (
+	"crypto/x509"
+	_ "embed"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// rootPEM is GlobalSign Root CA - R3, the root of the certificates the FIDO Alliance signs MDS BLOBs with.
+//
+//go:embed globalsign_root_r3.pem
+var rootPEM []byte
+
+// signerDomain is the domain of the certificate that signs the BLOB, so that a certificate issued by the root to
+// anyone else is refused.
+const signerDomain = "fidoalliance.org"
+
+// Roots returns the root of the certificates the FIDO Alliance signs MDS BLOBs with.
+func Roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(rootPEM)
+	return pool
+}
+
+// jwsHeader is the header of the JWT an MDS BLOB is.
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	// X5C is the signing certificate followed by its intermediates, DER encoded.
+	X5C [][]byte `json:"x5c"`
+}
+
+// blobPayload is the part of the payload of an MDS BLOB that skm uses.
+type blobPayload struct {
+	No         int    `json:"no"`
+	NextUpdate string `json:"nextUpdate"`
+	Entries    []struct {
+		AAGUID            string `json:"aaguid"`
+		MetadataStatement struct {
+			Description                 string   `json:"description"`
+			Icon                        string   `json:"icon"`
+			AttestationRootCertificates [][]byte `json:"attestationRootCertificates"`
+		} `json:"metadataStatement"`
+		StatusReports []StatusReport `json:"statusReports"`
+	} `json:"entries"`
+}
+
+// Parse verifies the signature of the MDS BLOB blob, a JWT signed with a certificate of the FIDO Alliance that
+// chains up to one of roots at time now, and returns its metadata. The revocation of the certificates isn't
+// checked, as the BLOB is imported offline.
+func Parse(blob []byte, roots *x509.CertPool, now time.Time) (*Metadata, error) {
+	parts := strings.Split(strings.TrimSpace(string(blob)), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("the MDS BLOB is not a JWT")
+	}
+
+	var header jwsHeader
+	if err := decodePart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid MDS BLOB header: %w", err)
+	}
+	signer, err := verifyChain(header.X5C, roots, now)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid MDS BLOB signature: %w", err)
+	}
+	if err := verifySignature(signer, header.Alg, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, fmt.Errorf("invalid MDS BLOB signature: %w", err)
+	}
+
+	var payload blobPayload
+	if err := decodePart(parts[1], &payload); err != nil {
+		return nil, fmt.Errorf("invalid MDS BLOB payload: %w", err)
+	}
+	return payload.metadata(), nil
+}
+
+func decodePart(part string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// verifyChain verifies that the first certificate of x5c, which must belong to the FIDO Alliance, chains up to
+// one of roots through the others, and returns it.
+func verifyChain(x5c [][]byte, roots *x509.CertPool, now time.Time) (*x509.Certificate, error) {
+	if len(x5c) == 0 {
+		return nil, errors.New("the MDS BLOB has no certificate chain")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(x5c))
+	for _, der := range x5c {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate in the MDS BLOB: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	signer := certs[0]
+	if _, err := signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, fmt.Errorf("the MDS BLOB is not signed by the FIDO Alliance: %w", err)
+	}
+
+	names := append([]string{signer.Subject.CommonName}, signer.DNSNames...)
+	if !slices.ContainsFunc(names, func(name string) bool {
+		return name == signerDomain || strings.HasSuffix(name, "."+signerDomain)
+	}) {
+		return nil, fmt.Errorf("the MDS BLOB is signed by %s, not the FIDO Alliance", signer.Subject.CommonName)
+	}
+	return signer, nil
+}
+
+// verifySignature verifies the JWS signature sig of signed with the algorithm alg.
+func verifySignature(signer *x509.Certificate, alg string, signed, sig []byte) error {
+	var algorithm x509.SignatureAlgorithm
+	switch alg {
+	case "RS256":
+		algorithm = x509.SHA256WithRSA
+	case "PS256":
+		algorithm = x509.SHA256WithRSAPSS
+	case "ES256":
+		// JWS encodes the ECDSA signature as r || s, x509 as an ASN.1 sequence.
+		if len(sig) != 64 {
+			return fmt.Errorf("ES256 signature of %d bytes, want 64", len(sig))
+		}
+		der, err := asn1.Marshal(struct{ R, S *big.Int }{
+			new(big.Int).SetBytes(sig[:32]),
+			new(big.Int).SetBytes(sig[32:]),
+		})
+		if err != nil {
+			return err
+		}
+		algorithm, sig = x509.ECDSAWithSHA256, der
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	return signer.CheckSignature(algorithm, signed, sig)
+}
+
+// metadata returns the models of the entries with an AAGUID, which U2F and UAF authenticators don't have.
+func (p *blobPayload) metadata() *Metadata {
+	m := &Metadata{Serial: p.No, NextUpdate: p.NextUpdate, Models: []Model{}}
+	for _, e := range p.Entries {
+		aaguid, err := uuid.Parse(e.AAGUID)
+		if err != nil {
+			continue
+		}
+
+		reports := slices.Clone(e.StatusReports)
+		slices.SortStableFunc(reports, func(a, b StatusReport) int {
+			return strings.Compare(a.EffectiveDate, b.EffectiveDate)
+		})
+		m.Models = append(m.Models, Model{
+			AAGUID:           aaguid,
+			Name:             e.MetadataStatement.Description,
+			Icon:             e.MetadataStatement.Icon,
+			Certification:    certification(reports),
+			StatusReports:    reports,
+			AttestationRoots: e.MetadataStatement.AttestationRootCertificates,
+		})
+	}
+	return m
+}
+
+// certification returns the latest FIDO certification level of reports, unless it was revoked since.
+func certification(reports []StatusReport) string {
+	var level string
+	for _, r := range reports {
+		switch {
+		case strings.HasPrefix(r.Status, "FIDO_CERTIFIED"):
+			level = r.Status
+		case r.Status == "REVOKED" || r.Status == "NOT_FIDO_CERTIFIED":
+			level = ""
+		}
+	}
+	return level
+}
@@ -0,0 +1,233 @@
+// Package mds identifies security key models by their AAGUID, from a table built into skm and from a FIDO
+// Metadata Service (MDS3) BLOB imported by the user, whose signature is verified against the FIDO Alliance root.
+package mds
+
+import (
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EnvFile is the environment variable that sets the file the imported metadata is kept in.
+const EnvFile = "SKM_MDS_FILE"
+
+// builtinModels is the table of well-known models built into skm.
+//
+//go:embed models.json
+var builtinModels []byte
+
+// builtinIcons are the icons of the built-in models, generic pictures of their form factor.
+//
+//go:embed icons/*.svg
+var builtinIcons embed.FS
+
+// securityStatuses are the authenticator statuses that report a security issue.
+var securityStatuses = []string{
+	"REVOKED",
+	"USER_VERIFICATION_BYPASS",
+	"ATTESTATION_KEY_COMPROMISE",
+	"USER_KEY_REMOTE_COMPROMISE",
+	"USER_KEY_PHYSICAL_COMPROMISE",
+}
+
+// StatusReport is a status the FIDO Alliance reported for a model, such as a certification or a security
+// issue.
+type StatusReport struct {
+	// Status is the authenticator status, e.g. FIDO_CERTIFIED_L1 or USER_VERIFICATION_BYPASS.
+	Status string `json:"status"`
+	// EffectiveDate is the date, as YYYY-MM-DD, the status was reported, if known.
+	EffectiveDate string `json:"effectiveDate,omitempty"`
+	// URL points to more information about the status, if any.
+	URL string `json:"url,omitempty"`
+}
+
+// SecurityIssue reports whether the status is a known security issue of the model.
+func (r StatusReport) SecurityIssue() bool {
+	return slices.Contains(securityStatuses, r.Status)
+}
+
+// Model describes a security key model.
+type Model struct {
+	AAGUID uuid.UUID `json:"aaguid"`
+	// Vendor is the maker of the model, if known.
+	Vendor string `json:"vendor,omitempty"`
+	// Name is the name of the model.
+	Name string `json:"name"`
+	// Icon is the icon of the model as a data: URL. The built-in models have a generic picture of their form
+	// factor, which an imported MDS BLOB replaces with the icon of the vendor.
+	Icon string `json:"icon,omitempty"`
+	// Certification is the latest FIDO certification level of the model, e.g. FIDO_CERTIFIED_L2, if any.
+	Certification string `json:"certification,omitempty"`
+	// StatusReports are the statuses reported for the model, oldest first.
+	StatusReports []StatusReport `json:"statusReports,omitempty"`
+	// AttestationRoots are the DER encoded root certificates its attestation certificates chain up to.
+	AttestationRoots [][]byte `json:"attestationRootCertificates,omitempty"`
+}
+
+// String returns the name of the model, prefixed with its vendor unless the name already includes it.
+func (m *Model) String() string {
+	if m.Vendor == "" || strings.Contains(m.Name, m.Vendor) {
+		return m.Name
+	}
+	return m.Vendor + " " + m.Name
+}
+
+// SecurityIssues returns the status reports of the known security issues of the model.
+func (m *Model) SecurityIssues() []StatusReport {
+	var issues []StatusReport
+	for _, r := range m.StatusReports {
+		if r.SecurityIssue() {
+			issues = append(issues, r)
+		}
+	}
+	return issues
+}
+
+// Metadata is the content of an imported MDS BLOB.
+type Metadata struct {
+	// Serial is the serial number of the BLOB, which increases with every release.
+	Serial int `json:"no"`
+	// NextUpdate is the date, as YYYY-MM-DD, by which a newer BLOB is published.
+	NextUpdate string `json:"nextUpdate"`
+	// Models are the models the BLOB describes by their AAGUID.
+	Models []Model `json:"models"`
+}
+
+// Stale reports whether a newer BLOB should have been published by now.
+func (m *Metadata) Stale(now time.Time) bool {
+	next, err := time.Parse(time.DateOnly, m.NextUpdate)
+	return err == nil && now.After(next.AddDate(0, 0, 1))
+}
+
+// DB is the table of the known security key models.
+type DB struct {
+	// Metadata is the imported metadata, or nil if none was imported.
+	Metadata *Metadata
+
+	models map[uuid.UUID]*Model
+}
+
+// Load returns the built-in models, completed with the metadata imported with Import, if any.
+func Load() (*DB, error) {
+	// The built-in table only names the models and refers to their icon: their certification and attestation
+	// roots come from the MDS BLOB.
+	var builtin []struct {
+		AAGUID uuid.UUID `json:"aaguid"`
+		Vendor string    `json:"vendor"`
+		Name   string    `json:"name"`
+		Icon   string    `json:"icon"`
+	}
+	if err := json.Unmarshal(builtinModels, &builtin); err != nil {
+		return nil, fmt.Errorf("invalid built-in models: %w", err)
+	}
+
+	db := &DB{models: make(map[uuid.UUID]*Model, len(builtin))}
+	for _, m := range builtin {
+		icon, err := builtinIcons.ReadFile("icons/" + m.Icon)
+		if err != nil {
+			return nil, fmt.Errorf("invalid built-in model %s: %w", m.AAGUID, err)
+		}
+		db.models[m.AAGUID] = &Model{
+			AAGUID: m.AAGUID,
+			Vendor: m.Vendor,
+			Name:   m.Name,
+			Icon:   "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(icon),
+		}
+	}
+
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path) //nolint:gosec // the path is chosen by the user
+	if errors.Is(err, fs.ErrNotExist) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	db.Metadata = &Metadata{}
+	if err := json.Unmarshal(b, db.Metadata); err != nil {
+		return nil, fmt.Errorf("invalid metadata in %s, import the MDS BLOB again: %w", path, err)
+	}
+	for i := range db.Metadata.Models {
+		m := &db.Metadata.Models[i]
+		if known, ok := db.models[m.AAGUID]; ok {
+			if m.Vendor == "" {
+				m.Vendor = known.Vendor
+			}
+			if m.Icon == "" {
+				m.Icon = known.Icon
+			}
+		}
+		db.models[m.AAGUID] = m
+	}
+	return db, nil
+}
+
+// Lookup returns the model whose AAGUID is aaguid, or nil if it is unknown or db is nil.
+func (db *DB) Lookup(aaguid uuid.UUID) *Model {
+	if db == nil {
+		return nil
+	}
+	return db.models[aaguid]
+}
+
+// Path returns the file the imported metadata is kept in: SKM_MDS_FILE if set, otherwise skm/mds.json in the
+// user configuration directory.
+func Path() (string, error) {
+	if path := os.Getenv(EnvFile); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find a directory for the metadata, set %s: %w", EnvFile, err)
+	}
+	return filepath.Join(dir, "skm", "mds.json"), nil
+}
+
+// Save writes m to the file Load reads it from.
+func Save(m *Metadata) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Import verifies the signature of the MDS BLOB blob against the FIDO Alliance root bundled with skm, and saves
+// its metadata for Load.
+func Import(blob []byte, now time.Time) (*Metadata, error) {
+	m, err := Parse(blob, Roots(), now)
+	if err != nil {
+		return nil, err
+	}
+	if err := Save(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
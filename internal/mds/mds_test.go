@@ -0,0 +1,288 @@
+package mds
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mohammadv184/skm/internal/attestation/attestationtest"
+)
+
+var (
+	yubikey5 = uuid.MustParse("ee882879-721c-4913-9775-3dfcce97072a")
+	unknown  = uuid.MustParse("00000000-1111-2222-3333-444444444444")
+	now      = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// testPKI is a root, and a certificate it issued to sign MDS BLOBs with.
+type testPKI struct {
+	roots  *x509.CertPool
+	x5c    [][]byte
+	signer crypto.Signer
+}
+
+// newPKI creates a root and an intermediate CA that issue a certificate for signerName, with an RSA key if rsaKey
+// is set, and an ECDSA P-256 key otherwise.
+func newPKI(t *testing.T, signerName string, rsaKey bool) *testPKI {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		NotBefore:             now.AddDate(-1, 0, 0),
+		NotAfter:              now.AddDate(1, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	root := attestationtest.NewCertificate(t, ca, ca, caKey.Public(), caKey)
+
+	ca.SerialNumber, ca.Subject = big.NewInt(2), pkix.Name{CommonName: "Test Intermediate"}
+	intermediate := attestationtest.NewCertificate(t, ca, root, caKey.Public(), caKey)
+
+	var signer crypto.Signer
+	if rsaKey {
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	leaf := attestationtest.NewCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: signerName},
+		DNSNames:     []string{signerName},
+		NotBefore:    now.AddDate(-1, 0, 0),
+		NotAfter:     now.AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, intermediate, signer.Public(), caKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return &testPKI{roots: roots, x5c: [][]byte{leaf.Raw, intermediate.Raw}, signer: signer}
+}
+
+// sign returns the BLOB of payload signed with alg.
+func (p *testPKI) sign(t *testing.T, alg string, payload any) []byte {
+	t.Helper()
+
+	encode := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := encode(map[string]any{"alg": alg, "typ": "JWT", "x5c": p.x5c}) + "." + encode(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	var err error
+	switch key := p.signer.(type) {
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest[:])
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	default:
+		sig, err = p.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return []byte(signed + "." + base64.RawURLEncoding.EncodeToString(sig))
+}
+
+var testPayload = map[string]any{
+	"no":         7,
+	"nextUpdate": "2026-11-01",
+	"entries": []map[string]any{
+		{
+			"aaguid": yubikey5.String(),
+			"metadataStatement": map[string]any{
+				"description":                 "YubiKey 5 Series",
+				"attestationRootCertificates": [][]byte{{0x30, 0x00}},
+			},
+			"statusReports": []map[string]any{
+				{"status": "FIDO_CERTIFIED_L2", "effectiveDate": "2021-03-01"},
+				{"status": "FIDO_CERTIFIED_L1", "effectiveDate": "2019-01-01"},
+			},
+		},
+		{
+			"aaguid":            unknown.String(),
+			"metadataStatement": map[string]any{"description": "Broken Key"},
+			"statusReports": []map[string]any{
+				{"status": "FIDO_CERTIFIED", "effectiveDate": "2020-01-01"},
+				{"status": "USER_VERIFICATION_BYPASS", "effectiveDate": "2022-01-01", "url": "https://example.com"},
+				{"status": "REVOKED", "effectiveDate": "2022-02-01"},
+			},
+		},
+		{
+			"aaid":              "4e4e#4005",
+			"metadataStatement": map[string]any{"description": "UAF Authenticator"},
+		},
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		alg    string
+		rsaKey bool
+	}{
+		{alg: "RS256", rsaKey: true},
+		{alg: "ES256"},
+	} {
+		t.Run(tt.alg, func(t *testing.T) {
+			pki := newPKI(t, "mds3.fidoalliance.org", tt.rsaKey)
+
+			m, err := Parse(pki.sign(t, tt.alg, testPayload), pki.roots, now)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if m.Serial != 7 || m.NextUpdate != "2026-11-01" || len(m.Models) != 2 {
+				t.Fatalf("Parse() = %+v, want BLOB #7 with 2 models", m)
+			}
+
+			yk := m.Models[0]
+			if yk.AAGUID != yubikey5 || yk.Name != "YubiKey 5 Series" || yk.Certification != "FIDO_CERTIFIED_L2" {
+				t.Errorf("model = %+v, want a FIDO_CERTIFIED_L2 YubiKey 5", yk)
+			}
+			if yk.StatusReports[0].Status != "FIDO_CERTIFIED_L1" {
+				t.Errorf("status reports = %+v, want the oldest first", yk.StatusReports)
+			}
+			if len(yk.AttestationRoots) != 1 {
+				t.Errorf("attestation roots = %x, want 1", yk.AttestationRoots)
+			}
+
+			broken := m.Models[1]
+			if broken.Certification != "" {
+				t.Errorf("certification = %q, want none after REVOKED", broken.Certification)
+			}
+			issues := broken.SecurityIssues()
+			if len(issues) != 2 || issues[0].Status != "USER_VERIFICATION_BYPASS" || issues[1].Status != "REVOKED" {
+				t.Errorf("SecurityIssues() = %+v, want USER_VERIFICATION_BYPASS and REVOKED", issues)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	pki := newPKI(t, "mds3.fidoalliance.org", false)
+	blob := pki.sign(t, "ES256", testPayload)
+	other := newPKI(t, "mds.example.com", false)
+
+	parts := strings.Split(string(blob), ".")
+	tampered := strings.Join([]string{
+		parts[0],
+		base64.RawURLEncoding.EncodeToString([]byte(`{"no":8,"entries":[]}`)),
+		parts[2],
+	}, ".")
+
+	tests := []struct {
+		name    string
+		blob    []byte
+		roots   *x509.CertPool
+		now     time.Time
+		wantErr string
+	}{
+		{name: "not a JWT", blob: []byte("blob"), wantErr: "not a JWT"},
+		{name: "tampered payload", blob: []byte(tampered), wantErr: "invalid MDS BLOB signature"},
+		{name: "expired", now: now.AddDate(2, 0, 0), wantErr: "not signed by the FIDO Alliance"},
+		{name: "other root", roots: other.roots, wantErr: "not signed by the FIDO Alliance"},
+		{name: "other signer", blob: other.sign(t, "ES256", testPayload), roots: other.roots,
+			wantErr: "signed by mds.example.com, not the FIDO Alliance"},
+		{name: "unsupported algorithm", blob: pki.sign(t, "HS256", testPayload), wantErr: `unsupported algorithm "HS256"`},
+		{name: "bundled root", roots: Roots(), wantErr: "not signed by the FIDO Alliance"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.blob == nil {
+				tt.blob = blob
+			}
+			if tt.roots == nil {
+				tt.roots = pki.roots
+			}
+			if tt.now.IsZero() {
+				tt.now = now
+			}
+
+			_, err := Parse(tt.blob, tt.roots, tt.now)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv(EnvFile, filepath.Join(t.TempDir(), "skm", "mds.json"))
+
+	db, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if db.Metadata != nil {
+		t.Errorf("Metadata = %+v, want nil before an import", db.Metadata)
+	}
+	if m := db.Lookup(yubikey5); m == nil || m.String() != "Yubico YubiKey 5 Series" {
+		t.Errorf("Lookup(%s) = %v, want the built-in Yubico YubiKey 5 Series", yubikey5, m)
+	}
+	if m := db.Lookup(unknown); m != nil {
+		t.Errorf("Lookup(%s) = %v, want nil", unknown, m)
+	}
+	names := make(map[string]uuid.UUID)
+	for _, m := range db.models {
+		if !strings.HasPrefix(m.Icon, "data:image/svg+xml;base64,") {
+			t.Errorf("built-in model %s has icon %q, want an SVG data: URL", m.AAGUID, m.Icon)
+		}
+		if other, ok := names[m.String()]; ok {
+			t.Errorf("built-in models %s and %s are both named %s", other, m.AAGUID, m)
+		}
+		names[m.String()] = m.AAGUID
+	}
+
+	if err := Save(&Metadata{Serial: 7, NextUpdate: "2026-11-01", Models: []Model{
+		{AAGUID: yubikey5, Name: "YubiKey 5 Series", Certification: "FIDO_CERTIFIED_L2"},
+		{AAGUID: unknown, Name: "Broken Key"},
+	}}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	db, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if db.Metadata == nil || db.Metadata.Serial != 7 {
+		t.Errorf("Metadata = %+v, want BLOB #7", db.Metadata)
+	}
+	if m := db.Lookup(yubikey5); m == nil || m.Certification != "FIDO_CERTIFIED_L2" || m.Vendor != "Yubico" {
+		t.Errorf("Lookup(%s) = %+v, want the imported model with the built-in vendor", yubikey5, m)
+	}
+	if m := db.Lookup(unknown); m == nil || m.String() != "Broken Key" {
+		t.Errorf("Lookup(%s) = %v, want the imported Broken Key", unknown, m)
+	}
+	if !db.Metadata.Stale(now.AddDate(0, 2, 0)) || db.Metadata.Stale(now) {
+		t.Error("Stale() should only report the metadata past its next update")
+	}
+	if (*DB)(nil).Lookup(yubikey5) != nil {
+		t.Error("Lookup() on a nil DB should return nil")
+	}
+}
@@ -0,0 +1,206 @@
+package policy
+
+import (
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+)
+
+// Status is the outcome of an audit rule.
+type Status string
+
+const (
+	// StatusPass means the security key complies with the rule.
+	StatusPass Status = "pass"
+	// StatusFail means the security key does not comply with the rule.
+	StatusFail Status = "fail"
+	// StatusError means the rule could not be checked.
+	StatusError Status = "error"
+)
+
+// RuleResult is the outcome of checking a security key against a single rule of a policy.
+type RuleResult struct {
+	// Rule is the name of the rule.
+	Rule string
+	// Expected is the value required by the policy.
+	Expected string
+	// Actual is the value reported by the security key.
+	Actual string
+	// Status is the outcome of the rule.
+	Status Status
+	// Message explains a failure or error.
+	Message string
+}
+
+// DeviceState is the state of a security key checked by an audit.
+type DeviceState struct {
+	// Info is the GetInfo response of the security key.
+	Info *ctap2.AuthenticatorGetInfoResponse
+	// PINRetries is the number of PIN retries left, or nil if it could not be read.
+	PINRetries *uint
+	// RPIDs are the RP IDs of the resident credentials, or nil if they were not enumerated.
+	RPIDs []string
+	// RPIDsErr is the reason the resident credentials could not be enumerated.
+	RPIDsErr error
+}
+
+// Audit checks a security key against every rule set in the policy, in a fixed order.
+func Audit(p *Policy, state *DeviceState) []RuleResult {
+	var results []RuleResult
+	info := state.Info
+
+	check := func(rule, expected, actual string, ok bool, message string) {
+		r := RuleResult{Rule: rule, Expected: expected, Actual: actual, Status: StatusPass}
+		if !ok {
+			r.Status = StatusFail
+			r.Message = message
+		}
+		results = append(results, r)
+	}
+
+	if p.PIN.Required != nil {
+		pinSet := info.Options[ctap2.OptionClientPIN]
+		check("pinSet", setState(*p.PIN.Required), setState(pinSet), pinSet == *p.PIN.Required, "PIN state differs")
+	}
+
+	if p.PIN.MinLength != nil {
+		check(
+			"minPinLength",
+			">= "+strconv.FormatUint(uint64(*p.PIN.MinLength), 10),
+			strconv.FormatUint(uint64(info.MinPinLength), 10),
+			info.MinPinLength >= *p.PIN.MinLength,
+			"minimum PIN length is too low",
+		)
+	}
+
+	if p.AlwaysUV != nil {
+		enabled, ok := info.Options[ctap2.OptionAlwaysUv]
+		actual := enabledState(enabled)
+		if !ok {
+			actual = "unsupported"
+		}
+		check("alwaysUv", enabledState(*p.AlwaysUV), actual, enabled == *p.AlwaysUV, "Always UV state differs")
+	}
+
+	if p.EnterpriseAttestation != nil {
+		enabled, ok := info.Options[ctap2.OptionEnterpriseAttestation]
+		actual := enabledState(enabled)
+		if !ok {
+			actual = "unsupported"
+		}
+		check(
+			"enterpriseAttestation",
+			enabledState(*p.EnterpriseAttestation),
+			actual,
+			enabled == *p.EnterpriseAttestation,
+			"enterprise attestation state differs",
+		)
+	}
+
+	if len(p.AllowedAAGUIDs) > 0 {
+		check(
+			"allowedAaguids",
+			strings.Join(p.AllowedAAGUIDs, ", "),
+			info.AAGUID.String(),
+			p.AAGUIDAllowed(info.AAGUID),
+			"security key model is not allowed",
+		)
+	}
+
+	if len(p.RequiredExtensions) > 0 {
+		var missing []string
+		for _, ext := range p.RequiredExtensions {
+			if !slices.Contains(info.Extensions, webauthn.ExtensionIdentifier(ext)) {
+				missing = append(missing, ext)
+			}
+		}
+
+		actual := "all supported"
+		if len(missing) > 0 {
+			actual = "missing " + strings.Join(missing, ", ")
+		}
+		check(
+			"requiredExtensions",
+			strings.Join(p.RequiredExtensions, ", "),
+			actual,
+			len(missing) == 0,
+			"required extensions are not supported",
+		)
+	}
+
+	if p.MinFirmwareVersion != nil {
+		check(
+			"minFirmwareVersion",
+			">= "+strconv.FormatUint(uint64(*p.MinFirmwareVersion), 10),
+			strconv.FormatUint(uint64(info.FirmwareVersion), 10),
+			info.FirmwareVersion >= *p.MinFirmwareVersion,
+			"firmware is too old",
+		)
+	}
+
+	if p.MinPINRetries != nil {
+		expected := ">= " + strconv.FormatUint(uint64(*p.MinPINRetries), 10)
+		if state.PINRetries == nil {
+			results = append(results, RuleResult{
+				Rule:     "minPinRetries",
+				Expected: expected,
+				Actual:   "unknown",
+				Status:   StatusError,
+				Message:  "PIN retries could not be read",
+			})
+		} else {
+			check(
+				"minPinRetries",
+				expected,
+				strconv.FormatUint(uint64(*state.PINRetries), 10),
+				*state.PINRetries >= *p.MinPINRetries,
+				"too few PIN retries left",
+			)
+		}
+	}
+
+	if len(p.ForbiddenRPIDs) > 0 {
+		expected := "none of " + strings.Join(p.ForbiddenRPIDs, ", ")
+		if state.RPIDs == nil && state.RPIDsErr != nil {
+			results = append(results, RuleResult{
+				Rule:     "forbiddenRpIds",
+				Expected: expected,
+				Actual:   "unknown",
+				Status:   StatusError,
+				Message:  "credentials could not be enumerated: " + state.RPIDsErr.Error(),
+			})
+		} else {
+			var found []string
+			for _, id := range state.RPIDs {
+				if slices.ContainsFunc(p.ForbiddenRPIDs, func(pattern string) bool {
+					ok, _ := path.Match(pattern, id)
+					return ok
+				}) {
+					found = append(found, id)
+				}
+			}
+
+			actual := "none"
+			if len(found) > 0 {
+				actual = strings.Join(found, ", ")
+			}
+			check("forbiddenRpIds", expected, actual, len(found) == 0, "credentials exist for forbidden RP IDs")
+		}
+	}
+
+	return results
+}
+
+// Passed reports whether every rule passed.
+func Passed(results []RuleResult) bool {
+	for _, r := range results {
+		if r.Status != StatusPass {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,255 @@
+package policy
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+)
+
+// Action is a change made to a security key to reconcile it with a policy.
+type Action string
+
+const (
+	// ActionNone means the setting already matches the policy.
+	ActionNone Action = "none"
+	// ActionSetPIN sets the first PIN.
+	ActionSetPIN Action = "set-pin"
+	// ActionChangePIN changes the existing PIN.
+	ActionChangePIN Action = "change-pin"
+	// ActionSetMinPINLength raises the minimum PIN length.
+	ActionSetMinPINLength Action = "set-min-pin-length"
+	// ActionForcePINChange forces a PIN change before the PIN is used again.
+	ActionForcePINChange Action = "force-pin-change"
+	// ActionSetMinPINLengthRPIDs sets the RP IDs allowed to read the minimum PIN length.
+	ActionSetMinPINLengthRPIDs Action = "set-min-pin-length-rp-ids"
+	// ActionToggleAlwaysUV toggles the Always UV option.
+	ActionToggleAlwaysUV Action = "toggle-always-uv"
+	// ActionEnableEnterpriseAttestation enables enterprise attestation.
+	ActionEnableEnterpriseAttestation Action = "enable-enterprise-attestation"
+)
+
+// Step is a single setting of a plan.
+type Step struct {
+	// Setting is the name of the setting.
+	Setting string
+	// Current is the current value of the setting.
+	Current string
+	// Desired is the value required by the policy.
+	Desired string
+	// Action is the change needed to reach the desired value.
+	Action Action
+	// Problem explains why the setting cannot be reconciled, or is empty.
+	Problem string
+}
+
+// Plan is the list of changes needed to bring a security key to the state described by a policy.
+type Plan struct {
+	Steps []Step
+}
+
+// NewPlan compares the state of a security key described by info with the policy.
+func NewPlan(p *Policy, info *ctap2.AuthenticatorGetInfoResponse) *Plan {
+	plan := &Plan{}
+
+	if len(p.AllowedAAGUIDs) > 0 {
+		step := Step{Setting: "aaguid", Current: info.AAGUID.String(), Desired: strings.Join(p.AllowedAAGUIDs, ", ")}
+		if !p.AAGUIDAllowed(info.AAGUID) {
+			step.Problem = "security key model is not in allowedAaguids"
+		}
+		plan.add(step)
+	}
+
+	if p.MinFirmwareVersion != nil {
+		step := Step{
+			Setting: "firmwareVersion",
+			Current: strconv.FormatUint(uint64(info.FirmwareVersion), 10),
+			Desired: ">= " + strconv.FormatUint(uint64(*p.MinFirmwareVersion), 10),
+		}
+		if info.FirmwareVersion < *p.MinFirmwareVersion {
+			step.Problem = "firmware is older than minFirmwareVersion"
+		}
+		plan.add(step)
+	}
+
+	pinSet, pinSupported := info.Options[ctap2.OptionClientPIN]
+	if p.PIN.Required != nil {
+		step := Step{Setting: "pin", Current: setState(pinSet), Desired: setState(*p.PIN.Required)}
+		switch {
+		case !pinSupported:
+			step.Problem = "security key does not support a PIN"
+		case *p.PIN.Required && !pinSet:
+			step.Action = ActionSetPIN
+		case *p.PIN.Required && p.PIN.Rotate:
+			step.Desired = "rotated"
+			step.Action = ActionChangePIN
+		case !*p.PIN.Required && pinSet:
+			step.Problem = "a PIN can only be removed with a factory reset"
+		}
+		plan.add(step)
+	}
+
+	if p.PIN.MinLength != nil {
+		step := Step{
+			Setting: "minPinLength",
+			Current: strconv.FormatUint(uint64(info.MinPinLength), 10),
+			Desired: strconv.FormatUint(uint64(*p.PIN.MinLength), 10),
+		}
+		switch {
+		case !info.Options[ctap2.OptionSetMinPINLength]:
+			step.Problem = "security key does not support setting the minimum PIN length"
+		case *p.PIN.MinLength > info.MinPinLength:
+			step.Action = ActionSetMinPINLength
+		case *p.PIN.MinLength < info.MinPinLength:
+			step.Problem = "the minimum PIN length can only be lowered with a factory reset"
+		}
+		plan.add(step)
+	}
+
+	if len(p.PIN.MinLengthRPIDs) > 0 {
+		// The RP IDs cannot be read back, so they are always set.
+		step := Step{
+			Setting: "minPinLengthRpIds",
+			Current: "unknown",
+			Desired: strings.Join(p.PIN.MinLengthRPIDs, ", "),
+			Action:  ActionSetMinPINLengthRPIDs,
+		}
+		switch {
+		case !info.Options[ctap2.OptionSetMinPINLength]:
+			step.Problem = "security key does not support setting the minimum PIN length"
+		case uint(len(p.PIN.MinLengthRPIDs)) > info.MaxRPIDsForSetMinPINLength:
+			step.Problem = "security key accepts at most " +
+				strconv.FormatUint(uint64(info.MaxRPIDsForSetMinPINLength), 10) + " RP IDs"
+		}
+		plan.add(step)
+	}
+
+	if p.PIN.ForceChange != nil {
+		step := Step{
+			Setting: "forcePinChange",
+			Current: strconv.FormatBool(info.ForcePinChange),
+			Desired: strconv.FormatBool(*p.PIN.ForceChange),
+		}
+		switch {
+		case *p.PIN.ForceChange && !info.ForcePinChange && !info.Options[ctap2.OptionSetMinPINLength]:
+			step.Problem = "security key does not support forcing a PIN change"
+		case *p.PIN.ForceChange && !info.ForcePinChange:
+			step.Action = ActionForcePINChange
+		case !*p.PIN.ForceChange && info.ForcePinChange && !plan.Has(ActionChangePIN):
+			step.Problem = "a forced PIN change is only cleared by changing the PIN"
+		}
+		plan.add(step)
+	}
+
+	if p.AlwaysUV != nil {
+		enabled, ok := info.Options[ctap2.OptionAlwaysUv]
+		step := Step{Setting: "alwaysUv", Current: enabledState(enabled), Desired: enabledState(*p.AlwaysUV)}
+		switch {
+		case !ok:
+			step.Current = "unsupported"
+			if *p.AlwaysUV {
+				step.Problem = "security key does not support Always UV"
+			}
+		case enabled != *p.AlwaysUV:
+			step.Action = ActionToggleAlwaysUV
+		}
+		plan.add(step)
+	}
+
+	if p.EnterpriseAttestation != nil {
+		enabled, ok := info.Options[ctap2.OptionEnterpriseAttestation]
+		step := Step{
+			Setting: "enterpriseAttestation",
+			Current: enabledState(enabled),
+			Desired: enabledState(*p.EnterpriseAttestation),
+		}
+		switch {
+		case !ok:
+			step.Current = "unsupported"
+			if *p.EnterpriseAttestation {
+				step.Problem = "security key does not support enterprise attestation"
+			}
+		case *p.EnterpriseAttestation && !enabled:
+			step.Action = ActionEnableEnterpriseAttestation
+		case !*p.EnterpriseAttestation && enabled:
+			step.Problem = "enterprise attestation can only be disabled with a factory reset"
+		}
+		plan.add(step)
+	}
+
+	plan.requirePIN(pinSet)
+	return plan
+}
+
+// requirePIN turns the configuration changes of the plan into problems if the security key has no PIN and the plan
+// sets none, since they are authorized with a PIN.
+func (p *Plan) requirePIN(pinSet bool) {
+	if pinSet || p.Has(ActionSetPIN) {
+		return
+	}
+	for i, s := range p.Steps {
+		switch s.Action {
+		case ActionNone, ActionSetPIN, ActionChangePIN:
+			continue
+		}
+		p.Steps[i].Action = ActionNone
+		p.Steps[i].Problem = "a PIN is required to change this setting, set pin.required: true"
+	}
+}
+
+func (p *Plan) add(step Step) {
+	if step.Action == "" || step.Problem != "" {
+		step.Action = ActionNone
+	}
+	p.Steps = append(p.Steps, step)
+}
+
+// Has reports whether the plan contains the action.
+func (p *Plan) Has(action Action) bool {
+	for _, s := range p.Steps {
+		if s.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Changes returns the number of steps that change the security key.
+func (p *Plan) Changes() int {
+	n := 0
+	for _, s := range p.Steps {
+		if s.Action != ActionNone {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns an error describing the settings that cannot be reconciled, or nil if there are none.
+func (p *Plan) Err() error {
+	var problems []string
+	for _, s := range p.Steps {
+		if s.Problem != "" {
+			problems = append(problems, s.Setting+": "+s.Problem)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+func setState(set bool) string {
+	if set {
+		return "set"
+	}
+	return "not set"
+}
+
+func enabledState(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
@@ -0,0 +1,139 @@
+// Package policy loads the desired state of security keys from a policy file and plans the changes needed to
+// reconcile a device with it.
+package policy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the policy file version supported by this package.
+const Version = 1
+
+// Policy is the desired state of a security key. Settings that are not set are left as they are.
+type Policy struct {
+	// Version is the policy file version, which must be Version.
+	Version int `yaml:"version"`
+	// PIN is the desired PIN state.
+	PIN PINPolicy `yaml:"pin"`
+	// AlwaysUV is whether the Always UV option must be enabled.
+	AlwaysUV *bool `yaml:"alwaysUv"`
+	// EnterpriseAttestation is whether enterprise attestation must be enabled.
+	EnterpriseAttestation *bool `yaml:"enterpriseAttestation"`
+	// AllowedAAGUIDs restricts the policy to security keys of the listed models.
+	AllowedAAGUIDs []string `yaml:"allowedAaguids"`
+	// MinFirmwareVersion is the minimum firmware version reported by the security key.
+	MinFirmwareVersion *uint `yaml:"minFirmwareVersion"`
+	// RequiredExtensions are the extensions the security key must support. It is only checked by audits.
+	RequiredExtensions []string `yaml:"requiredExtensions"`
+	// MinPINRetries is the minimum number of PIN retries left. It is only checked by audits.
+	MinPINRetries *uint `yaml:"minPinRetries"`
+	// ForbiddenRPIDs are glob patterns of RP IDs that must have no resident credentials on the security key.
+	// It is only checked by audits.
+	ForbiddenRPIDs []string `yaml:"forbiddenRpIds"`
+
+	// path and digest identify the file the policy was loaded from.
+	path   string
+	digest string
+}
+
+// PINPolicy is the desired PIN state of a security key.
+type PINPolicy struct {
+	// Required is whether a PIN must be set.
+	Required *bool `yaml:"required"`
+	// Rotate is whether an existing PIN must be changed to the new PIN.
+	Rotate bool `yaml:"rotate"`
+	// MinLength is the minimum PIN length.
+	MinLength *uint `yaml:"minLength"`
+	// ForceChange is whether the PIN must be changed before it is used again.
+	ForceChange *bool `yaml:"forceChange"`
+	// MinLengthRPIDs are the RP IDs allowed to read the minimum PIN length through the minPinLength extension.
+	MinLengthRPIDs []string `yaml:"minLengthRpIds"`
+}
+
+// Load reads and validates a policy file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy %s: %w", path, err)
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+
+	sum := sha256.Sum256(data)
+	p.path = path
+	p.digest = hex.EncodeToString(sum[:])
+
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	if p.Version != Version {
+		return fmt.Errorf("unsupported version %d, expected %d", p.Version, Version)
+	}
+
+	for _, id := range p.AllowedAAGUIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("invalid AAGUID %q in allowedAaguids", id)
+		}
+	}
+
+	for _, pattern := range p.ForbiddenRPIDs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q in forbiddenRpIds: %w", pattern, err)
+		}
+	}
+
+	// The PIN is only planned when pin.required is set, so rotating it without would be silently dropped.
+	if p.PIN.Rotate && (p.PIN.Required == nil || !*p.PIN.Required) {
+		return errors.New("pin.rotate requires pin.required: true")
+	}
+
+	return nil
+}
+
+// Path returns the path of the policy file.
+func (p *Policy) Path() string {
+	return p.path
+}
+
+// Digest returns the hex encoded SHA-256 digest of the policy file.
+func (p *Policy) Digest() string {
+	return p.digest
+}
+
+// NeedsNewPIN reports whether applying the policy may set or change the PIN.
+func (p *Policy) NeedsNewPIN() bool {
+	return p.PIN.Rotate || (p.PIN.Required != nil && *p.PIN.Required)
+}
+
+// AAGUIDAllowed reports whether the AAGUID is allowed by the policy.
+func (p *Policy) AAGUIDAllowed(aaguid uuid.UUID) bool {
+	if len(p.AllowedAAGUIDs) == 0 {
+		return true
+	}
+
+	for _, id := range p.AllowedAAGUIDs {
+		if allowed, err := uuid.Parse(id); err == nil && allowed == aaguid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package skm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/agent"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/spf13/cobra"
+)
+
+// agentOptions holds the flags of the agent command.
+type agentOptions struct {
+	ttl time.Duration
+}
+
+// newAgentCommand returns the agent command and its subcommands.
+func newAgentCommand() *cobra.Command {
+	var opts agentOptions
+	cmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Cache PIN tokens so the PIN is entered once for a series of commands",
+		Long: `Run an agent that caches the pinUvAuthToken of every security key a command gets with its PIN, so that
+the next commands on the same key don't ask for the PIN again. A token is cached for the permissions and the RP
+ID it was issued with (credential management, large blob or bio enrollment, or getAssertion and makeCredential
+for a single RP), and only until the TTL runs out, the key is removed, or the key stops accepting it, e.g. after
+a browser used it.
+The tokens are kept in memory locked into RAM, and commands reach the agent over a Unix socket only the user can
+access: $SKM_AGENT_SOCK, or skm/agent.sock in $XDG_RUNTIME_DIR or the user cache directory. The agent runs until
+the command is interrupted; every command uses it when it is running.`,
+		Example: `  skm agent &
+  skm agent --ttl 15m
+  skm agent lock`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return agentHandler(cmd, &opts)
+		},
+	}
+
+	cmd.Flags().DurationVar(&opts.ttl, "ttl", agent.DefaultTTL, "How long a token is cached")
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show the tokens cached by the agent",
+			Args:  cobra.NoArgs,
+			RunE:  agentStatusHandler,
+		},
+		&cobra.Command{
+			Use:   "clear",
+			Short: "Drop all the tokens cached by the agent",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return agentRequest(cmd, agent.Clear, "Cached tokens cleared.")
+			},
+		},
+		&cobra.Command{
+			Use:   "lock",
+			Short: "Drop all the cached tokens and stop caching until 'skm agent unlock'",
+			Long: `Drop all the tokens cached by the agent and make it refuse new ones until 'skm agent unlock', e.g. from a
+screen locker hook.`,
+			Args: cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return agentRequest(cmd, agent.Lock, "Agent locked.")
+			},
+		},
+		&cobra.Command{
+			Use:   "unlock",
+			Short: "Make a locked agent cache tokens again",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return agentRequest(cmd, agent.Unlock, "Agent unlocked.")
+			},
+		},
+	)
+	return cmd
+}
+
+func agentHandler(cmd *cobra.Command, opts *agentOptions) error {
+	if opts.ttl <= 0 {
+		return errors.New("--ttl must be positive")
+	}
+
+	path, err := agent.SocketPath()
+	if err != nil {
+		return err
+	}
+	l, err := agent.Listen(path)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := &agent.Server{
+		TTL: opts.ttl,
+		Enumerate: func() ([]fido2.DeviceDescriptor, error) {
+			return authenticator.Enumerate(ctx)
+		},
+	}
+	if !s.MemoryLocked() {
+		cmd.PrintErrln("Warning: the tokens cannot be locked into memory and may be written to swap.")
+	}
+
+	cmd.Printf("Agent listening on %s, caching tokens for %s. Press Ctrl+C to stop it.\n", path, opts.ttl)
+	if err := s.Serve(ctx, l); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	cmd.Println("Agent stopped, cached tokens wiped.")
+	return nil
+}
+
+func agentStatusHandler(cmd *cobra.Command, _ []string) error {
+	status, err := agent.GetStatus(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	state := "unlocked"
+	if status.Locked {
+		state = "locked"
+	}
+	memory := "locked into RAM"
+	if !status.MemoryLocked {
+		memory = "not locked, tokens may be written to swap"
+	}
+
+	cmd.Printf("State:  %s\nMemory: %s\nTTL:    %s\n", state, memory, status.TTL)
+	if len(status.Entries) == 0 {
+		cmd.Println("No tokens cached.")
+		return nil
+	}
+
+	cmd.Println("Cached tokens:")
+	for _, e := range status.Entries {
+		scope := permissionNames(e.Permissions)
+		if e.RPID != "" {
+			scope += " for " + e.RPID
+		}
+		cmd.Printf("  %s  %s, expires in %s\n", e.Key, scope, time.Until(e.Expires).Round(time.Second))
+	}
+	return nil
+}
+
+// agentRequest sends a request without a result to the agent and prints done.
+func agentRequest(cmd *cobra.Command, request func(ctx context.Context) error, done string) error {
+	if err := request(cmd.Context()); err != nil {
+		return err
+	}
+	cmd.Println(done)
+	return nil
+}
+
+// permissionNames lists the permissions set in p.
+func permissionNames(p ctap2.Permission) string {
+	var names []string
+	for bit := ctap2.Permission(1); bit != 0; bit <<= 1 {
+		if p&bit != 0 {
+			names = append(names, bit.String())
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,113 @@
+package skm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/agent"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/authenticator/authenticatortest"
+)
+
+// keepOpen is a security key that stays open across commands, like one that stays plugged in, so it keeps its
+// pinUvAuthToken.
+type keepOpen struct {
+	authenticator.Device
+}
+
+func (keepOpen) Close() error {
+	return nil
+}
+
+// startAgent runs an agent for the security keys of b until the test ends.
+func startAgent(t *testing.T, b *authenticatortest.Backend) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	t.Setenv(agent.EnvSocket, path)
+	l, err := agent.Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- (&agent.Server{Enumerate: b.Enumerate}).Serve(ctx, l)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Serve() error = %v", err)
+		}
+	})
+}
+
+func TestAgent(t *testing.T) {
+	b := newBackend(t, true)
+	a := b.Virtual(testDevicePath)
+	b.Wrap = func(d authenticator.Device) authenticator.Device {
+		_ = d.Close()
+		return keepOpen{a}
+	}
+	startAgent(t, b)
+
+	runCommand(t, b, "agent", "status").check(t, []string{"State:  unlocked", "No tokens cached."}, "")
+	runCommand(t, b, "creds", "list", "--pin", testPIN).check(t, []string{"No credentials found"}, "")
+	runCommand(t, b, "agent", "status").check(t, []string{"usb:0000:0000:0001  Credential Management"}, "")
+
+	// The cached token is used instead of the PIN, which isn't given.
+	runCommand(t, b, "creds", "list").check(t, []string{"No credentials found"}, "")
+	runCommand(t, b, "blob", "list").check(t, nil, "")
+
+	// A token issued to another client replaces the cached one, so the PIN is used again.
+	if _, err := a.GetPinUvAuthTokenUsingPIN(testPIN, ctap2.PermissionCredentialManagement, ""); err != nil {
+		t.Fatalf("GetPinUvAuthTokenUsingPIN() error = %v", err)
+	}
+	runCommand(t, b, "creds", "list", "--pin", "0000").check(t, nil, "the PIN is incorrect")
+	runCommand(t, b, "creds", "list", "--pin", testPIN).check(t, nil, "")
+
+	runCommand(t, b, "agent", "lock").check(t, []string{"Agent locked."}, "")
+	runCommand(t, b, "creds", "list", "--pin", "0000").check(t, nil, "the PIN is incorrect")
+	runCommand(t, b, "agent", "status").check(t, []string{"State:  locked", "No tokens cached."}, "")
+	runCommand(t, b, "agent", "unlock").check(t, []string{"Agent unlocked."}, "")
+
+	runCommand(t, b, "creds", "list", "--pin", testPIN).check(t, nil, "")
+	runCommand(t, b, "agent", "clear").check(t, []string{"Cached tokens cleared."}, "")
+	runCommand(t, b, "agent", "status").check(t, []string{"No tokens cached."}, "")
+}
+
+func TestAgentDropsRemovedKeys(t *testing.T) {
+	b := newBackend(t, true)
+	startAgent(t, b)
+
+	runCommand(t, b, "creds", "list", "--pin", testPIN).check(t, nil, "")
+	runCommand(t, b, "agent", "status").check(t, []string{"usb:0000:0000:0001"}, "")
+	b.Unplug(testDevicePath)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := agent.GetStatus(context.Background())
+		if err != nil {
+			t.Fatalf("GetStatus() error = %v", err)
+		}
+		if len(status.Entries) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the token of the removed key is still cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAgentNotRunning(t *testing.T) {
+	b := newBackend(t, true)
+
+	runCommand(t, b, "agent", "status").check(t, nil, "no agent is running")
+	runCommand(t, b, "creds", "list", "--pin", testPIN).check(t, []string{"No credentials found"}, "")
+}
@@ -0,0 +1,98 @@
+package skm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mohammadv184/skm/internal/virtual"
+	"github.com/mohammadv184/skm/internal/virtual/uhid"
+	"github.com/spf13/cobra"
+)
+
+// attachOptions holds the flags of the attach command.
+type attachOptions struct {
+	name       string
+	touchDelay time.Duration
+}
+
+// newAttachCommand returns the attach command.
+func newAttachCommand() *cobra.Command {
+	var opts attachOptions
+	cmd := &cobra.Command{
+		Use:   "attach FILE",
+		Short: "Attach a virtual authenticator as a HID security key",
+		Long: `Create a /dev/hidraw* node backed by the virtual authenticator whose state is kept in FILE, so that
+FIDO2 clients such as browsers, OpenSSH and fido2-token can use it as if it were plugged in. The key stays
+attached until the command is interrupted.
+This requires Linux and write access to /dev/uhid, usually root.`,
+		Example: `  sudo skm attach /tmp/key.json
+  sudo skm attach /tmp/key.json --touch-delay 2s`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return attachHandler(cmd, args, &opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.name, "name", virtual.Product, "Product name of the HID device")
+	cmd.Flags().DurationVar(&opts.touchDelay, "touch-delay", 0,
+		"How long the simulated user takes to touch the key")
+	return cmd
+}
+
+func attachHandler(cmd *cobra.Command, args []string, opts *attachOptions) error {
+	a, err := virtual.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = a.Close()
+	}()
+
+	dev, err := uhid.Create(opts.name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	path, err := dev.Path()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cmd.Printf("Virtual security key attached at %s. Press Ctrl+C to detach it.\n", path)
+
+	s := uhid.Server{Authenticator: a, Presence: touchAfter(opts.touchDelay)}
+	if err := s.Serve(ctx, dev); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	cmd.Println("Virtual security key detached.")
+	return nil
+}
+
+// touchAfter returns a Presence that grants user presence after delay, unless the request is cancelled first.
+func touchAfter(delay time.Duration) virtual.Presence {
+	if delay <= 0 {
+		return nil
+	}
+	return func(ctx context.Context) error {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package skm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/credential"
+	"github.com/mohammadv184/skm/internal/policy"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/exitcode"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/output"
+	"github.com/mohammadv184/skm/internal/ui/views"
+	"github.com/spf13/cobra"
+)
+
+// auditExitNonCompliant is the exit code used when a security key does not comply with the policy.
+const auditExitNonCompliant = 2
+
+// auditOptions holds the flags of the audit command.
+type auditOptions struct {
+	device device.Selector
+	policy string
+	pin    pinflag.Source
+	junit  string
+
+	// pinRejected is set once a security key rejects the PIN, so that it isn't tried on the others.
+	pinRejected bool
+}
+
+// newAuditCommand returns the audit command.
+func newAuditCommand() *cobra.Command {
+	var opts auditOptions
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Check security keys against a policy file",
+		Long: `Check every connected security key against a policy file without changing anything, and report pass or fail
+for each rule. The policy format is shared with 'skm provision' and adds audit-only rules:
+
+  requiredExtensions: [credProtect, hmac-secret]
+  minPinRetries: 5
+  forbiddenRpIds: ["*.test.example.com"]
+
+Checking forbiddenRpIds enumerates the resident credentials and requires the PIN of each security key. The PIN is
+asked for once, and once a security key rejects it, it is not tried on the others.
+Results are shown as a table, or as a document with --output, and can also be written as JUnit XML with --junit,
+to a file or, without --output, to stdout.
+The command exits with status 2 if any security key does not comply.`,
+		Example: `  skm audit --policy policy.yaml
+  skm audit --policy policy.yaml --output json
+  skm audit --policy policy.yaml --junit audit.xml
+  skm audit --policy policy.yaml --serial 12345678 --pin 123456`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return auditHandler(cmd, &opts)
+		},
+		SilenceUsage: true,
+	}
+
+	opts.device.RegisterFlags(cmd)
+	output.RegisterFlag(cmd)
+	cmd.Flags().StringVar(&opts.policy, "policy", "", "Path to the policy file (YAML)")
+	opts.pin.RegisterFlags(cmd, "PIN of the security keys, used to check forbiddenRpIds")
+	cmd.Flags().StringVar(&opts.junit, "junit", "", `Write the results as JUnit XML to the file ("-" for stdout)`)
+	_ = cmd.MarkFlagRequired("policy")
+	_ = cmd.MarkFlagFilename("policy", "yaml", "yml")
+	return cmd
+}
+
+func auditHandler(cmd *cobra.Command, opts *auditOptions) error {
+	format, err := output.FromCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	if opts.junit == "-" && format != output.FormatText {
+		return exitcode.New(
+			exitcode.Usage,
+			fmt.Errorf("--junit - writes to stdout and cannot be used with --output %s", format),
+		)
+	}
+
+	pol, err := policy.Load(opts.policy)
+	if err != nil {
+		return err
+	}
+
+	// An empty list would report that every security key complies, although none was checked.
+	devs, err := opts.device.ResolveAll(true)
+	if err != nil {
+		return err
+	}
+
+	report := output.NewAuditReport(pol)
+	for _, desc := range devs {
+		info, results, err := auditOne(cmd, pol, &desc, opts)
+		report.AddDevice(&desc, info, results, err)
+	}
+
+	switch {
+	case opts.junit == "-":
+		err = report.WriteJUnit(cmd.OutOrStdout())
+	case format != output.FormatText:
+		err = output.Write(cmd.OutOrStdout(), format, report)
+	default:
+		cmd.Println(views.NewAuditView(report).Render())
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.junit != "" && opts.junit != "-" {
+		if err := writeJUnitFile(opts.junit, report); err != nil {
+			return err
+		}
+	}
+
+	if total, passed := report.Summary(); passed < total {
+		return exitcode.New(
+			auditExitNonCompliant,
+			fmt.Errorf("%d of %d security keys do not comply with the policy", total-passed, total),
+		)
+	}
+	return nil
+}
+
+// auditOne reads the state of a security key and checks it against the policy.
+func auditOne(
+	cmd *cobra.Command,
+	pol *policy.Policy,
+	desc *fido2.DeviceDescriptor,
+	opts *auditOptions,
+) (*ctap2.AuthenticatorGetInfoResponse, []policy.RuleResult, error) {
+	dev, err := authenticator.Open(cmd.Context(), *desc)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	state := &policy.DeviceState{Info: dev.Info()}
+
+	if retries, _, err := dev.GetPINRetries(); err == nil {
+		state.PINRetries = &retries
+	}
+
+	if len(pol.ForbiddenRPIDs) > 0 {
+		state.RPIDs, state.RPIDsErr = auditRPIDs(dev, desc, opts)
+	}
+
+	return state.Info, policy.Audit(pol, state), nil
+}
+
+// auditRPIDs returns the RP IDs of the resident credentials stored on dev, the security key desc.
+func auditRPIDs(dev authenticator.Device, desc *fido2.DeviceDescriptor, opts *auditOptions) ([]string, error) {
+	if !dev.Info().Options[ctap2.OptionClientPIN] {
+		// Resident credentials can only be enumerated with a PIN.
+		return nil, errors.New("no PIN is set")
+	}
+	if opts.pinRejected {
+		// Trying the PIN again would spend a PIN retry of every security key.
+		return nil, errors.New("not checked, the PIN was rejected by another security key")
+	}
+
+	token, err := opts.pin.Token(dev, *desc, ctap2.PermissionCredentialManagement, "")
+	if err != nil {
+		var ctapErr *ctaphid.CTAPError
+		if errors.As(err, &ctapErr) && ctapErr.StatusCode == ctaphid.StatusCTAP2ErrPinInvalid {
+			opts.pinRejected = true
+		}
+		return nil, err
+	}
+
+	rps, err := credential.Collect(dev, token)
+	if err != nil {
+		return nil, err
+	}
+
+	rpIDs := make([]string, 0, len(rps))
+	for _, rp := range rps {
+		rpIDs = append(rpIDs, rp.RP.ID)
+	}
+	return rpIDs, nil
+}
+
+func writeJUnitFile(path string, report *output.AuditReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := report.WriteJUnit(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
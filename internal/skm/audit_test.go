@@ -0,0 +1,100 @@
+package skm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/skm/exitcode"
+)
+
+func TestAudit(t *testing.T) {
+	compliant := writePolicy(t, "version: 1\nminPinRetries: 1\n")
+	nonCompliant := writePolicy(t, "version: 1\nrequiredExtensions: [bogus]\n")
+	rotateOnly := writePolicy(t, "version: 1\npin:\n  rotate: true\n")
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantOut  []string
+		wantErr  string
+		wantCode int
+	}{
+		{
+			name:    "compliant",
+			args:    []string{"audit", "--policy", compliant},
+			wantOut: []string{"1 of 1 security keys comply with the policy"},
+		},
+		{
+			name:     "non-compliant",
+			args:     []string{"audit", "--policy", nonCompliant},
+			wantOut:  []string{"0 of 1 security keys comply with the policy"},
+			wantErr:  "1 of 1 security keys do not comply",
+			wantCode: auditExitNonCompliant,
+		},
+		{
+			name:    "rotate without required",
+			args:    []string{"audit", "--policy", rotateOnly},
+			wantErr: "pin.rotate requires pin.required: true",
+		},
+		{
+			name:     "no matching security key",
+			args:     []string{"audit", "--policy", compliant, "--serial", "nope"},
+			wantErr:  "no security key matches",
+			wantCode: exitcode.NoDevice,
+		},
+		{
+			name:     "JUnit to stdout with a document",
+			args:     []string{"audit", "--policy", compliant, "--junit", "-", "--output", "json"},
+			wantErr:  "--junit - writes to stdout and cannot be used with --output json",
+			wantCode: exitcode.Usage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := runCommand(t, newBackend(t, true), tt.args...)
+			r.check(t, tt.wantOut, tt.wantErr)
+
+			var exitErr *exitcode.Error
+			if tt.wantCode != 0 && (!errors.As(r.err, &exitErr) || exitErr.Code != tt.wantCode) {
+				t.Errorf("error = %v, want exit code %d", r.err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAuditRejectedPIN(t *testing.T) {
+	b := newBackend(t, true)
+	second := b.Add(fido2.DeviceDescriptor{Path: "/dev/hidraw1", Product: "Test Key", SerialNumber: "0002"})
+	if err := second.SetPIN(testPIN); err != nil {
+		t.Fatalf("SetPIN() error = %v", err)
+	}
+
+	forbidden := writePolicy(t, "version: 1\nforbiddenRpIds: [example.com]\n")
+	r := runCommand(t, b, "audit", "--policy", forbidden, "--pin", "0000", "--output", "json")
+	r.check(t, []string{"not checked, the PIN was rejected by another security key"}, "2 of 2 security keys do not comply")
+
+	for path, want := range map[string]uint{testDevicePath: 7, "/dev/hidraw1": 8} {
+		retries, _, err := b.Virtual(path).GetPINRetries()
+		if err != nil {
+			t.Fatalf("GetPINRetries() error = %v", err)
+		}
+		if retries != want {
+			t.Errorf("PIN retries of %s = %d, want %d", path, retries, want)
+		}
+	}
+}
+
+// writePolicy writes a policy file with content and returns its path.
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
@@ -0,0 +1,69 @@
+package bio
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+)
+
+// bioToken returns a pinUvAuthToken of dev, the security key desc, with the bio enrollment permission, prompting
+// for the PIN if it isn't given and no token is cached.
+func bioToken(dev authenticator.Device, desc fido2.DeviceDescriptor, source *pinflag.Source) ([]byte, error) {
+	return source.Token(dev, desc, ctap2.PermissionBioEnrollment, "")
+}
+
+// enrollments returns the fingerprints enrolled on dev. Authenticators report CTAP2_ERR_INVALID_OPTION
+// when nothing is enrolled, which is returned as an empty list.
+func enrollments(dev authenticator.Device, pinUvAuthToken []byte) ([]ctap2.TemplateInfo, error) {
+	resp, err := dev.EnumerateEnrollments(pinUvAuthToken)
+	var ctapErr *ctaphid.CTAPError
+	if errors.As(err, &ctapErr) && ctapErr.StatusCode == ctaphid.StatusCTAP2ErrInvalidOption {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.TemplateInfos, nil
+}
+
+// selectEnrollment returns the enrollment identified by ref, either its hex encoded template ID or its friendly
+// name, or prompts for one if ref is empty.
+func selectEnrollment(infos []ctap2.TemplateInfo, ref string) (*ctap2.TemplateInfo, error) {
+	if len(infos) == 0 {
+		return nil, errors.New("no fingerprints are enrolled on this device")
+	}
+
+	if ref == "" {
+		return prompts.NewEnrollmentSelectPrompt().WithEnrollments(infos...).Run()
+	}
+
+	for i, info := range infos {
+		if strings.EqualFold(hex.EncodeToString(info.TemplateID), ref) {
+			return &infos[i], nil
+		}
+	}
+
+	var matched *ctap2.TemplateInfo
+	for i, info := range infos {
+		if info.TemplateFriendlyName == ref {
+			if matched != nil {
+				return nil, fmt.Errorf("more than one fingerprint is named %q, use its template ID", ref)
+			}
+			matched = &infos[i]
+		}
+	}
+	if matched == nil {
+		return nil, fmt.Errorf("fingerprint not found: %s", ref)
+	}
+
+	return matched, nil
+}
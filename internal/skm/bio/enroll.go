@@ -0,0 +1,126 @@
+package bio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/spf13/cobra"
+)
+
+// enrollOptions holds the flags of the bio enroll command.
+type enrollOptions struct {
+	device        device.Selector
+	pin           pinflag.Source
+	name          string
+	sampleTimeout time.Duration
+}
+
+// newEnrollCommand returns the bio enroll command.
+func newEnrollCommand() *cobra.Command {
+	var opts enrollOptions
+	cmd := &cobra.Command{
+		Use:     "enroll",
+		Aliases: []string{"add"},
+		Short:   "Enroll a new fingerprint",
+		Long: `Enroll a new fingerprint on a biometric security key. The sensor must be touched several times; the remaining
+samples and the quality of the last sample are shown while enrolling. The new fingerprint can be given a friendly
+name with --name, otherwise the name is prompted for once the enrollment is complete.`,
+		Example: `  skm bio enroll
+  skm bio enroll --device-path /dev/hidraw0 --pin 123456 --name "Right index"`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return enrollHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	cmd.Flags().StringVarP(&opts.name, "name", "n", "", "Friendly name of the new fingerprint")
+	cmd.Flags().DurationVar(
+		&opts.sampleTimeout, "sample-timeout", 0,
+		"Time to wait for each sample (default: the security key's own timeout)",
+	)
+	return cmd
+}
+
+func enrollHandler(cmd *cobra.Command, opts *enrollOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	sensor, err := dev.GetFingerprintSensorInfo()
+	if err != nil {
+		return err
+	}
+
+	if opts.name != "" {
+		if err := validateName(sensor, opts.name); err != nil {
+			return err
+		}
+	}
+
+	token, err := bioToken(dev, *selectedDev, &opts.pin)
+	if err != nil {
+		return err
+	}
+
+	timeout := uint(opts.sampleTimeout.Milliseconds())
+	capture := func(templateID []byte) (*ctap2.AuthenticatorBioEnrollmentResponse, error) {
+		if templateID == nil {
+			return dev.BeginEnroll(token, timeout)
+		}
+		return dev.EnrollCaptureNextSample(token, templateID, timeout)
+	}
+
+	templateID, err := prompts.NewBioEnrollPrompt(capture).
+		WithTotalSamples(sensor.MaxCaptureSamplesRequiredForEnroll).
+		Run()
+	if err != nil {
+		// The prompt begins the enrollment and returns once no capture is in flight.
+		_ = dev.CancelCurrentEnrollment()
+		return err
+	}
+
+	name := opts.name
+	if name == "" {
+		name, err = prompts.NewTextPrompt("Enter a name for the new fingerprint (leave empty to skip)").
+			WithPlaceholder("e.g. Right index").
+			WithValidation(func(name string) error {
+				return validateName(sensor, name)
+			}).
+			Run()
+		if err != nil {
+			return err
+		}
+	}
+
+	if name != "" {
+		if err := dev.SetFriendlyName(token, templateID, name); err != nil {
+			return err
+		}
+	}
+
+	cmd.Println("Fingerprint enrolled successfully.")
+	return nil
+}
+
+// validateName checks that a friendly name fits in the maximum length reported by the sensor.
+func validateName(sensor *ctap2.AuthenticatorBioEnrollmentResponse, name string) error {
+	if sensor.MaxTemplateFriendlyName > 0 && uint(len(name)) > sensor.MaxTemplateFriendlyName {
+		return fmt.Errorf("name is too long: the security key allows at most %d bytes", sensor.MaxTemplateFriendlyName)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package bio
+
+import (
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/ui/views"
+	"github.com/spf13/cobra"
+)
+
+// infoOptions holds the flags of the bio info command.
+type infoOptions struct {
+	device device.Selector
+}
+
+// newInfoCommand returns the bio info command.
+func newInfoCommand() *cobra.Command {
+	var opts infoOptions
+	cmd := &cobra.Command{
+		Use:     "info",
+		Aliases: []string{"sensor"},
+		Short:   "Show biometric sensor information",
+		Long: `Show the biometric modality of a security key and, for fingerprint sensors, the sensor kind, the number of
+samples required to enroll a fingerprint, and the maximum length of fingerprint names. No PIN is required.`,
+		Example: `  skm bio info
+  skm bio info --device-path /dev/hidraw0`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return infoHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	return cmd
+}
+
+func infoHandler(cmd *cobra.Command, opts *infoOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	modality, err := dev.GetBioModality()
+	if err != nil {
+		return err
+	}
+
+	sensor, err := dev.GetFingerprintSensorInfo()
+	if err != nil {
+		return err
+	}
+
+	cmd.Println(views.NewBioSensorView(modality.Modality, sensor).Render())
+	return nil
+}
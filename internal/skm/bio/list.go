@@ -0,0 +1,68 @@
+package bio
+
+import (
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/views"
+	"github.com/spf13/cobra"
+)
+
+// listOptions holds the flags of the bio list command.
+type listOptions struct {
+	device device.Selector
+	pin    pinflag.Source
+}
+
+// newListCommand returns the bio list command.
+func newListCommand() *cobra.Command {
+	var opts listOptions
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List enrolled fingerprints",
+		Long:    "List the fingerprints enrolled on a biometric security key with their friendly names and template IDs.",
+		Example: `  skm bio list
+  skm bio list --device-path /dev/hidraw0 --pin 123456`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return listHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	return cmd
+}
+
+func listHandler(cmd *cobra.Command, opts *listOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	token, err := bioToken(dev, *selectedDev, &opts.pin)
+	if err != nil {
+		return err
+	}
+
+	infos, err := enrollments(dev, token)
+	if err != nil {
+		return err
+	}
+
+	if len(infos) == 0 {
+		cmd.Println("No fingerprints are enrolled on this device.")
+		return nil
+	}
+
+	cmd.Println(views.NewBioEnrollmentListView().WithEnrollments(infos...).Render())
+	return nil
+}
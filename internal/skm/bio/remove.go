@@ -0,0 +1,99 @@
+package bio
+
+import (
+	"encoding/hex"
+
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/spf13/cobra"
+)
+
+// removeOptions holds the flags of the bio remove command.
+type removeOptions struct {
+	device      device.Selector
+	pin         pinflag.Source
+	fingerprint string
+	yes         bool
+}
+
+// newRemoveCommand returns the bio remove command.
+func newRemoveCommand() *cobra.Command {
+	var opts removeOptions
+	cmd := &cobra.Command{
+		Use:     "remove",
+		Aliases: []string{"rm", "delete", "del"},
+		Short:   "Remove an enrolled fingerprint",
+		Long:    "Permanently remove a fingerprint enrolled on a biometric security key. This action is irreversible.",
+		Example: `  skm bio remove
+  skm bio remove --device-path /dev/hidraw0 --pin 123456 --fingerprint "Right index" --yes`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return removeHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	cmd.Flags().
+		StringVarP(&opts.fingerprint, "fingerprint", "f", "", "Template ID (hex) or name of the fingerprint to remove")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Skip the confirmation prompt")
+	return cmd
+}
+
+func removeHandler(cmd *cobra.Command, opts *removeOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	token, err := bioToken(dev, *selectedDev, &opts.pin)
+	if err != nil {
+		return err
+	}
+
+	infos, err := enrollments(dev, token)
+	if err != nil {
+		return err
+	}
+
+	selected, err := selectEnrollment(infos, opts.fingerprint)
+	if err != nil {
+		return err
+	}
+
+	if !opts.yes {
+		name := selected.TemplateFriendlyName
+		if name == "" {
+			name = hex.EncodeToString(selected.TemplateID)
+		}
+
+		confirmed, err := prompts.NewConfirmPrompt(
+			"Remove fingerprint "+name+"?",
+			"The fingerprint can no longer be used to unlock the security key.",
+		).Run()
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			cmd.Println("Aborted.")
+			return nil
+		}
+	}
+
+	err = dev.RemoveEnrollment(token, selected.TemplateID)
+	if err != nil {
+		return err
+	}
+
+	cmd.Println("Fingerprint removed successfully.")
+	return nil
+}
@@ -0,0 +1,97 @@
+package bio
+
+import (
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/spf13/cobra"
+)
+
+// renameOptions holds the flags of the bio rename command.
+type renameOptions struct {
+	device      device.Selector
+	pin         pinflag.Source
+	fingerprint string
+	name        string
+}
+
+// newRenameCommand returns the bio rename command.
+func newRenameCommand() *cobra.Command {
+	var opts renameOptions
+	cmd := &cobra.Command{
+		Use:   "rename",
+		Short: "Rename an enrolled fingerprint",
+		Long:  "Change the friendly name of a fingerprint enrolled on a biometric security key.",
+		Example: `  skm bio rename
+  skm bio rename --fingerprint "Right index" --name "Right thumb"
+  skm bio rename --device-path /dev/hidraw0 --pin 123456 --fingerprint 0a1b --name "Left index"`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return renameHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	cmd.Flags().
+		StringVarP(&opts.fingerprint, "fingerprint", "f", "", "Template ID (hex) or name of the fingerprint to rename")
+	cmd.Flags().StringVarP(&opts.name, "name", "n", "", "New friendly name of the fingerprint")
+	return cmd
+}
+
+func renameHandler(cmd *cobra.Command, opts *renameOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	sensor, err := dev.GetFingerprintSensorInfo()
+	if err != nil {
+		return err
+	}
+
+	token, err := bioToken(dev, *selectedDev, &opts.pin)
+	if err != nil {
+		return err
+	}
+
+	infos, err := enrollments(dev, token)
+	if err != nil {
+		return err
+	}
+
+	selected, err := selectEnrollment(infos, opts.fingerprint)
+	if err != nil {
+		return err
+	}
+
+	name := opts.name
+	if name == "" {
+		name, err = prompts.NewTextPrompt("Enter the new name of the fingerprint").
+			WithValue(selected.TemplateFriendlyName).
+			Run()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := validateName(sensor, name); err != nil {
+		return err
+	}
+
+	err = dev.SetFriendlyName(token, selected.TemplateID, name)
+	if err != nil {
+		return err
+	}
+
+	cmd.Println("Fingerprint renamed successfully.")
+	return nil
+}
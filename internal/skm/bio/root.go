@@ -0,0 +1,30 @@
+package bio
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// NewCommand returns the bio command and its subcommands.
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "bio",
+		Aliases: []string{"fingerprint", "fp"},
+		Short:   "Manage biometric enrollments",
+		Long: `Commands for managing the fingerprints enrolled on biometric security keys (e.g. YubiKey Bio, Feitian BioPass)
+using CTAP 2.1 authenticatorBioEnrollment. This includes listing, enrolling, renaming, and removing fingerprints,
+and showing information about the fingerprint sensor.`,
+		Example: `  skm bio list
+  skm bio enroll --name "Right index"
+  skm bio rename
+  skm bio remove
+  skm bio info`,
+	}
+	cmd.AddCommand(
+		newListCommand(),
+		newEnrollCommand(),
+		newRenameCommand(),
+		newRemoveCommand(),
+		newInfoCommand(),
+	)
+	return cmd
+}
@@ -0,0 +1,103 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/credential"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+)
+
+// trailerSize is the size of the truncated SHA-256 hash that ends a serialized large-blob array.
+const trailerSize = 16
+
+// blobState is the large-blob array of a device together with its resident credentials.
+type blobState struct {
+	token []byte
+	rps   []*credential.RelyingParty
+	blobs []*ctap2.LargeBlob
+}
+
+// readState reads the resident credentials and the large-blob array of dev, the security key desc, prompting for
+// the PIN if it isn't given and no token is cached. With write set, the token also has the large-blob write
+// permission.
+func readState(
+	dev authenticator.Device,
+	desc fido2.DeviceDescriptor,
+	source *pinflag.Source,
+	write bool,
+) (*blobState, error) {
+	if largeBlobs, ok := dev.Info().Options[ctap2.OptionLargeBlobs]; !ok || !largeBlobs {
+		return nil, errors.New("this security key does not support large blobs")
+	}
+
+	permissions := ctap2.PermissionCredentialManagement
+	if write {
+		permissions |= ctap2.PermissionLargeBlobWrite
+	}
+
+	token, err := source.Token(dev, desc, permissions, "")
+	if err != nil {
+		return nil, err
+	}
+
+	rps, err := credential.Collect(dev, token)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := dev.GetLargeBlobs()
+	if errors.Is(err, fido2.ErrLargeBlobsIntegrityCheck) {
+		return nil, fmt.Errorf("the large-blob array is corrupted, its SHA-256 trailer does not match: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &blobState{token: token, rps: rps, blobs: blobs}, nil
+}
+
+// selectCredential returns the credential with the given ID, or prompts for one among the credentials that
+// have a largeBlobKey if id is empty.
+func (s *blobState) selectCredential(id string) (*credential.Credential, error) {
+	if id != "" {
+		c, err := credential.FindByID(s.rps, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(c.LargeBlobKey) == 0 {
+			return nil, errors.New("credential was not created with a largeBlobKey and cannot store a large blob")
+		}
+		return c, nil
+	}
+
+	var withKey []*credential.Credential
+	for _, c := range credential.All(s.rps) {
+		if len(c.LargeBlobKey) > 0 {
+			withKey = append(withKey, c)
+		}
+	}
+	if len(withKey) == 0 {
+		return nil, errors.New("no resident credential on this device has a largeBlobKey")
+	}
+
+	return prompts.NewCredentialSelectPrompt().WithRelyingParties(credential.Group(withKey)...).Run()
+}
+
+// serializedSize returns the size of the serialized large-blob array including its trailer.
+func serializedSize(blobs []*ctap2.LargeBlob) uint {
+	if blobs == nil {
+		blobs = []*ctap2.LargeBlob{}
+	}
+
+	encoded, err := cbor.Marshal(blobs)
+	if err != nil {
+		return 0
+	}
+	return uint(len(encoded)) + trailerSize
+}
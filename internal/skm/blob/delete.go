@@ -0,0 +1,134 @@
+package blob
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/credential"
+	"github.com/mohammadv184/skm/internal/largeblob"
+	"github.com/mohammadv184/skm/internal/skm/completion"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/spf13/cobra"
+)
+
+// deleteOptions holds the flags of the blob delete command.
+type deleteOptions struct {
+	device       device.Selector
+	pin          pinflag.Source
+	credentialID string
+	orphans      bool
+	yes          bool
+}
+
+// newDeleteCommand returns the blob delete command.
+func newDeleteCommand() *cobra.Command {
+	var opts deleteOptions
+	cmd := &cobra.Command{
+		Use:     "delete",
+		Aliases: []string{"rm", "del", "remove"},
+		Short:   "Delete large blobs from a security key",
+		Long: `Remove the large blob of a resident credential, or with --orphans every entry that no resident credential
+can decrypt, to reclaim large-blob storage. This action is irreversible.`,
+		Example: `  skm blob delete
+  skm blob delete --device-path /dev/hidraw0 --pin 123456 --credential-id base64-id
+  skm blob delete --orphans --yes`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return deleteHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	cmd.Flags().StringVarP(
+		&opts.credentialID, "credential-id", "i", "",
+		"ID of the credential whose blob to delete (base64 encoded)",
+	)
+	_ = cmd.RegisterFlagCompletionFunc("credential-id", completion.CompleteCredentialID)
+	cmd.Flags().BoolVar(&opts.orphans, "orphans", false, "Delete every orphaned entry")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Skip the confirmation prompt")
+	cmd.MarkFlagsMutuallyExclusive("credential-id", "orphans")
+	return cmd
+}
+
+func deleteHandler(cmd *cobra.Command, opts *deleteOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	state, err := readState(dev, *selectedDev, &opts.pin, true)
+	if err != nil {
+		return err
+	}
+
+	var (
+		keep  []*ctap2.LargeBlob
+		title string
+	)
+	if opts.orphans {
+		for _, e := range largeblob.Match(state.blobs, credential.All(state.rps)) {
+			if !e.Orphaned() {
+				keep = append(keep, e.Blob)
+			}
+		}
+
+		removed := len(state.blobs) - len(keep)
+		if removed == 0 {
+			cmd.Println("No orphaned large blobs found.")
+			return nil
+		}
+		title = "Delete " + strconv.Itoa(removed) + " orphaned large blob entries?"
+	} else {
+		selectedCred, err := state.selectCredential(opts.credentialID)
+		if err != nil {
+			return err
+		}
+
+		idx := largeblob.Find(state.blobs, selectedCred)
+		if idx < 0 {
+			return errors.New("no large blob is stored for this credential")
+		}
+
+		for i, blob := range state.blobs {
+			if i != idx {
+				keep = append(keep, blob)
+			}
+		}
+		title = "Delete the large blob of " + selectedCred.User.Name + " on " + selectedCred.RP.ID + "?"
+	}
+
+	if !opts.yes {
+		confirmed, err := prompts.NewConfirmPrompt(title, "The data cannot be recovered.").Run()
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			cmd.Println("Aborted.")
+			return nil
+		}
+	}
+
+	if keep == nil {
+		keep = []*ctap2.LargeBlob{}
+	}
+
+	err = dev.SetLargeBlobs(state.token, keep)
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("Deleted %d large blob entries.\n", len(state.blobs)-len(keep))
+	return nil
+}
@@ -0,0 +1,93 @@
+package blob
+
+import (
+	"errors"
+	"os"
+
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/largeblob"
+	"github.com/mohammadv184/skm/internal/skm/completion"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/spf13/cobra"
+)
+
+// getOptions holds the flags of the blob get command.
+type getOptions struct {
+	device       device.Selector
+	pin          pinflag.Source
+	credentialID string
+	file         string
+}
+
+// newGetCommand returns the blob get command.
+func newGetCommand() *cobra.Command {
+	var opts getOptions
+	cmd := &cobra.Command{
+		Use:     "get",
+		Aliases: []string{"read"},
+		Short:   "Read the large blob of a credential",
+		Long: `Decrypt the large blob of a resident credential and write it to a file, or to standard output if no file
+is given.`,
+		Example: `  skm blob get --credential-id base64-id > blob.bin
+  skm blob get --device-path /dev/hidraw0 --pin 123456 --credential-id base64-id --file blob.bin`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return getHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	cmd.Flags().
+		StringVarP(&opts.credentialID, "credential-id", "i", "", "ID of the credential that owns the blob (base64 encoded)")
+	_ = cmd.RegisterFlagCompletionFunc("credential-id", completion.CompleteCredentialID)
+	cmd.Flags().StringVarP(&opts.file, "file", "f", "", "File to write the blob to (default: standard output)")
+	return cmd
+}
+
+func getHandler(cmd *cobra.Command, opts *getOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	state, err := readState(dev, *selectedDev, &opts.pin, false)
+	if err != nil {
+		return err
+	}
+
+	selectedCred, err := state.selectCredential(opts.credentialID)
+	if err != nil {
+		return err
+	}
+
+	idx := largeblob.Find(state.blobs, selectedCred)
+	if idx < 0 {
+		return errors.New("no large blob is stored for this credential")
+	}
+
+	data, err := largeblob.Decrypt(selectedCred.LargeBlobKey, state.blobs[idx])
+	if err != nil {
+		return err
+	}
+
+	if opts.file == "" || opts.file == "-" {
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(opts.file, data, 0o600); err != nil {
+		return err
+	}
+
+	cmd.PrintErrf("Wrote %d bytes to %s.\n", len(data), opts.file)
+	return nil
+}
@@ -0,0 +1,71 @@
+package blob
+
+import (
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/credential"
+	"github.com/mohammadv184/skm/internal/largeblob"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/views"
+	"github.com/spf13/cobra"
+)
+
+// listOptions holds the flags of the blob list command.
+type listOptions struct {
+	device device.Selector
+	pin    pinflag.Source
+}
+
+// newListCommand returns the blob list command.
+func newListCommand() *cobra.Command {
+	var opts listOptions
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List large blobs stored on a security key",
+		Long: `List the entries of the large-blob array of a security key with the credential that owns each of them,
+their size, and how much of the device's large-blob storage is used. Entries that no resident credential can
+decrypt are reported as orphaned.`,
+		Example: `  skm blob list
+  skm blob list --device-path /dev/hidraw0 --pin 123456`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return listHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	return cmd
+}
+
+func listHandler(cmd *cobra.Command, opts *listOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	state, err := readState(dev, *selectedDev, &opts.pin, false)
+	if err != nil {
+		return err
+	}
+
+	if len(state.blobs) == 0 {
+		cmd.Println("No large blobs are stored on this device.")
+		return nil
+	}
+
+	entries := largeblob.Match(state.blobs, credential.All(state.rps))
+	cmd.Println(views.NewLargeBlobListView().
+		WithEntries(entries...).
+		WithUsage(serializedSize(state.blobs), dev.Info().MaxSerializedLargeBlobArray).
+		Render())
+	return nil
+}
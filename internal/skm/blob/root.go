@@ -0,0 +1,28 @@
+package blob
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// NewCommand returns the blob command and its subcommands.
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "blob",
+		Aliases: []string{"blobs", "large-blob"},
+		Short:   "Manage large blobs stored on security keys",
+		Long: `Commands for managing the per-credential large blobs stored on security keys that support the CTAP 2.1
+largeBlobs option. Each blob is encrypted with the largeBlobKey of the resident credential it belongs to, so a PIN
+is required to read them. Entries that no resident credential can decrypt are reported as orphaned.`,
+		Example: `  skm blob list
+  skm blob get --credential-id base64-id --file blob.bin
+  skm blob set --credential-id base64-id --file blob.bin
+  skm blob delete --orphans`,
+	}
+	cmd.AddCommand(
+		newListCommand(),
+		newGetCommand(),
+		newSetCommand(),
+		newDeleteCommand(),
+	)
+	return cmd
+}
@@ -0,0 +1,127 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/largeblob"
+	"github.com/mohammadv184/skm/internal/skm/completion"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/spf13/cobra"
+)
+
+// setOptions holds the flags of the blob set command.
+type setOptions struct {
+	device       device.Selector
+	pin          pinflag.Source
+	credentialID string
+	file         string
+	data         string
+}
+
+// newSetCommand returns the blob set command.
+func newSetCommand() *cobra.Command {
+	var opts setOptions
+	cmd := &cobra.Command{
+		Use:     "set",
+		Aliases: []string{"write", "put"},
+		Short:   "Store a large blob for a credential",
+		Long: `Encrypt data with the largeBlobKey of a resident credential and store it in the large-blob array of the
+security key, replacing the credential's existing blob if it has one. The data is read from a file, from --data, or
+from standard input when the file is "-".`,
+		Example: `  skm blob set --credential-id base64-id --file blob.bin
+  skm blob set --credential-id base64-id --data "hello"
+  cat blob.bin | skm blob set --device-path /dev/hidraw0 --pin 123456 --credential-id base64-id --file -`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return setHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	cmd.Flags().
+		StringVarP(&opts.credentialID, "credential-id", "i", "", "ID of the credential that owns the blob (base64 encoded)")
+	_ = cmd.RegisterFlagCompletionFunc("credential-id", completion.CompleteCredentialID)
+	cmd.Flags().StringVarP(&opts.file, "file", "f", "", `File to read the blob from ("-" for standard input)`)
+	cmd.Flags().StringVar(&opts.data, "data", "", "Blob data given on the command line")
+	cmd.MarkFlagsMutuallyExclusive("file", "data")
+	cmd.MarkFlagsOneRequired("file", "data")
+	return cmd
+}
+
+func setHandler(cmd *cobra.Command, opts *setOptions) error {
+	data, err := readBlobData(cmd, opts)
+	if err != nil {
+		return err
+	}
+
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	state, err := readState(dev, *selectedDev, &opts.pin, true)
+	if err != nil {
+		return err
+	}
+
+	selectedCred, err := state.selectCredential(opts.credentialID)
+	if err != nil {
+		return err
+	}
+
+	entry, err := largeblob.Encrypt(selectedCred.LargeBlobKey, data)
+	if err != nil {
+		return err
+	}
+
+	blobs := slices.Clone(state.blobs)
+	replaced := false
+	if idx := largeblob.Find(blobs, selectedCred); idx >= 0 {
+		blobs[idx] = entry
+		replaced = true
+	} else {
+		blobs = append(blobs, entry)
+	}
+
+	err = dev.SetLargeBlobs(state.token, blobs)
+	if errors.Is(err, fido2.ErrLargeBlobsTooBig) {
+		return fmt.Errorf("not enough large-blob storage, remove other blobs or orphaned entries first: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if replaced {
+		cmd.Printf("Large blob replaced (%d bytes).\n", len(data))
+	} else {
+		cmd.Printf("Large blob stored (%d bytes).\n", len(data))
+	}
+	return nil
+}
+
+// readBlobData returns the blob data given with --data or --file.
+func readBlobData(cmd *cobra.Command, opts *setOptions) ([]byte, error) {
+	switch opts.file {
+	case "":
+		return []byte(opts.data), nil
+	case "-":
+		return io.ReadAll(cmd.InOrStdin())
+	default:
+		return os.ReadFile(opts.file)
+	}
+}
@@ -4,15 +4,17 @@ package completion
 import (
 	"encoding/base64"
 	"fmt"
+	"os"
 
-	"github.com/mohammadv184/go-fido2"
 	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/credential"
 	"github.com/spf13/cobra"
 )
 
 // CompleteDevicePath provides shell completion for FIDO2 device paths.
-func CompleteDevicePath(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
-	devs, err := fido2.Enumerate()
+func CompleteDevicePath(cmd *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	devs, err := authenticator.Enumerate(cmd.Context())
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
 	}
@@ -25,16 +27,37 @@ func CompleteDevicePath(_ *cobra.Command, _ []string, _ string) ([]string, cobra
 	return paths, cobra.ShellCompDirectiveNoFileComp
 }
 
+// CompleteSerialNumber provides shell completion for FIDO2 device serial numbers.
+func CompleteSerialNumber(cmd *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	devs, err := authenticator.Enumerate(cmd.Context())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	serials := make([]string, 0, len(devs))
+	for _, dev := range devs {
+		if dev.SerialNumber == "" {
+			continue
+		}
+		serials = append(serials, fmt.Sprintf("%s\t%s", dev.SerialNumber, dev.Product))
+	}
+
+	return serials, cobra.ShellCompDirectiveNoFileComp
+}
+
 // CompleteCredentialID provides shell completion for credential IDs stored on a device.
 func CompleteCredentialID(cmd *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
 	devicePath, _ := cmd.Flags().GetString("device-path")
 	pin, _ := cmd.Flags().GetString("pin")
+	if pin == "" {
+		pin = os.Getenv("SKM_PIN")
+	}
 
 	if devicePath == "" || pin == "" {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	dev, err := fido2.OpenPath(devicePath)
+	dev, err := authenticator.OpenPath(cmd.Context(), devicePath)
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
 	}
@@ -47,23 +70,16 @@ func CompleteCredentialID(cmd *cobra.Command, _ []string, _ string) ([]string, c
 		return nil, cobra.ShellCompDirectiveError
 	}
 
+	rps, err := credential.Collect(dev, token)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
 	var completions []string
-	for rp, err := range dev.EnumerateRPs(token) {
-		if err != nil {
-			continue
-		}
-		for c, err := range dev.EnumerateCredentials(token, rp.RPIDHash) {
-			if err != nil {
-				continue
-			}
+	for _, rp := range rps {
+		for _, c := range rp.Credentials {
 			id := base64.RawURLEncoding.EncodeToString(c.CredentialID.ID)
-
-			rpName := rp.RP.Name
-			if rpName == "" {
-				rpName = rp.RP.ID
-			}
-
-			completions = append(completions, fmt.Sprintf("%s\t%s (%s)", id, c.User.Name, rpName))
+			completions = append(completions, fmt.Sprintf("%s\t%s (%s)", id, c.User.Name, rp.DisplayName()))
 		}
 	}
 
@@ -4,68 +4,58 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/mohammadv184/go-fido2"
 	"github.com/mohammadv184/go-fido2/protocol/ctap2"
-	"github.com/mohammadv184/skm/internal/skm/completion"
-	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
 	"github.com/spf13/cobra"
 )
 
-var alwaysUVCMD = cobra.Command{
-	Use:   "always-uv",
-	Short: "Toggle Always User Verification (UV)",
-	Long:  "Toggle the 'Always UV' option on a security key. If enabled, the device will always require user verification (e.g. PIN or Biometrics) for all operations.",
-	Example: `  skm config always-uv
-  skm config always-uv --device-path /dev/hidraw0 --pin 123456`,
-	RunE: alwaysUVHandler,
+// alwaysUVOptions holds the flags of the config always-uv command.
+type alwaysUVOptions struct {
+	device  device.Selector
+	pin     pinflag.Source
+	enable  bool
+	disable bool
+	status  bool
 }
 
-var (
-	alwaysUVDevicePath string
-	alwaysUVPin        string
-)
+// newAlwaysUVCommand returns the config always-uv command.
+func newAlwaysUVCommand() *cobra.Command {
+	var opts alwaysUVOptions
+	cmd := &cobra.Command{
+		Use:   "always-uv",
+		Short: "Enable, disable or show Always User Verification (UV)",
+		Long: `Manage the 'Always UV' option on a security key. If enabled, the device will always require user verification
+(e.g. PIN or Biometrics) for all operations.
+
+With --enable or --disable the option is only changed if needed, so the command can safely be run again, and the
+final state is read back from the device and printed. Without a mode flag, or with --status, the current state is
+shown and nothing is changed.`,
+		Example: `  skm config always-uv
+  skm config always-uv --enable
+  skm config always-uv --device-path /dev/hidraw0 --pin 123456 --disable`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return alwaysUVHandler(cmd, &opts)
+		},
+	}
 
-func init() {
-	alwaysUVCMD.Flags().StringVarP(&alwaysUVDevicePath, "device-path", "d", "", "Path to the security key device")
-	_ = alwaysUVCMD.RegisterFlagCompletionFunc("device-path", completion.CompleteDevicePath)
-	alwaysUVCMD.Flags().StringVarP(&alwaysUVPin, "pin", "p", "", "PIN for the security key")
-	rootCMD.AddCommand(&alwaysUVCMD)
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	cmd.Flags().BoolVar(&opts.enable, "enable", false, "Enable Always UV if it is disabled")
+	cmd.Flags().BoolVar(&opts.disable, "disable", false, "Disable Always UV if it is enabled")
+	cmd.Flags().BoolVar(&opts.status, "status", false, "Show whether Always UV is enabled (default)")
+	cmd.MarkFlagsMutuallyExclusive("enable", "disable", "status")
+	return cmd
 }
 
-func alwaysUVHandler(cmd *cobra.Command, _ []string) error {
-	var selectedDev *fido2.DeviceDescriptor
-
-	if alwaysUVDevicePath != "" {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-		for _, dev := range devs {
-			if dev.Path == alwaysUVDevicePath {
-				selectedDev = &dev
-				break
-			}
-		}
-		if selectedDev == nil {
-			return fmt.Errorf("device not found at path: %s", alwaysUVDevicePath)
-		}
-	} else {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-
-		if len(devs) == 0 {
-			return errors.New("no security keys found")
-		}
-
-		selectedDev, err = prompts.NewDeviceSelectPrompt().WithDevices(devs...).Run()
-		if err != nil {
-			return err
-		}
+func alwaysUVHandler(cmd *cobra.Command, opts *alwaysUVOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
 	}
 
-	dev, err := fido2.Open(*selectedDev)
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
 	if err != nil {
 		return err
 	}
@@ -73,16 +63,22 @@ func alwaysUVHandler(cmd *cobra.Command, _ []string) error {
 		_ = dev.Close()
 	}()
 
-	pin := alwaysUVPin
-	if pin == "" {
-		retries, _, _ := dev.GetPINRetries()
-		pin, err = prompts.NewPinPrompt().WithRetries(retries).Run()
-		if err != nil {
-			return err
-		}
+	enabled, ok := dev.Info().Options[ctap2.OptionAlwaysUv]
+	if !ok {
+		return errors.New("this security key does not support Always UV")
 	}
 
-	token, err := dev.GetPinUvAuthTokenUsingPIN(pin, ctap2.PermissionAuthenticatorConfiguration, "")
+	if opts.status || (!opts.enable && !opts.disable) {
+		cmd.Println("Always UV is " + alwaysUVState(enabled) + ".")
+		return nil
+	}
+
+	if (opts.enable && enabled) || (opts.disable && !enabled) {
+		cmd.Println("Always UV is already " + alwaysUVState(enabled) + ", nothing to do.")
+		return nil
+	}
+
+	token, err := opts.pin.Token(dev, *selectedDev, ctap2.PermissionAuthenticatorConfiguration, "")
 	if err != nil {
 		return err
 	}
@@ -92,6 +88,28 @@ func alwaysUVHandler(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	cmd.Println("Always UV toggled successfully.")
+	// The device caches its GetInfo response, so reopen it to read the new state.
+	_ = dev.Close()
+	confirmDev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return fmt.Errorf("failed to confirm the Always UV state: %w", err)
+	}
+	defer func() {
+		_ = confirmDev.Close()
+	}()
+
+	final := confirmDev.Info().Options[ctap2.OptionAlwaysUv]
+	if final == enabled {
+		return errors.New("the security key still reports Always UV as " + alwaysUVState(final) + " after toggling it")
+	}
+
+	cmd.Println("Always UV is now " + alwaysUVState(final) + ".")
 	return nil
 }
+
+func alwaysUVState(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
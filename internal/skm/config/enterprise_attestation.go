@@ -1,72 +1,45 @@
 package config
 
 import (
-	"errors"
-	"fmt"
-
-	"github.com/mohammadv184/go-fido2"
 	"github.com/mohammadv184/go-fido2/protocol/ctap2"
-	"github.com/mohammadv184/skm/internal/skm/completion"
-	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
 	"github.com/spf13/cobra"
 )
 
-var enterpriseAttestationCMD = cobra.Command{
-	Use:   "enterprise-attestation",
-	Short: "Enable Enterprise Attestation",
-	Long:  "Enable Enterprise Attestation on a security key, if supported. This is often required in enterprise environments for device-specific attestation.",
-	Example: `  skm config enterprise-attestation
-  skm config enterprise-attestation --device-path /dev/hidraw0 --pin 123456`,
-	RunE: enterpriseAttestationHandler,
+// enterpriseAttestationOptions holds the flags of the config enterprise-attestation command.
+type enterpriseAttestationOptions struct {
+	device device.Selector
+	pin    pinflag.Source
 }
 
-var (
-	enterpriseAttestationDevicePath string
-	enterpriseAttestationPin        string
-)
+// newEnterpriseAttestationCommand returns the config enterprise-attestation command.
+func newEnterpriseAttestationCommand() *cobra.Command {
+	var opts enterpriseAttestationOptions
+	cmd := &cobra.Command{
+		Use:   "enterprise-attestation",
+		Short: "Enable Enterprise Attestation",
+		Long:  "Enable Enterprise Attestation on a security key, if supported. This is often required in enterprise environments for device-specific attestation.",
+		Example: `  skm config enterprise-attestation
+  skm config enterprise-attestation --device-path /dev/hidraw0 --pin 123456`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return enterpriseAttestationHandler(cmd, &opts)
+		},
+	}
 
-func init() {
-	enterpriseAttestationCMD.Flags().
-		StringVarP(&enterpriseAttestationDevicePath, "device-path", "d", "", "Path to the security key device")
-	_ = enterpriseAttestationCMD.RegisterFlagCompletionFunc("device-path", completion.CompleteDevicePath)
-	enterpriseAttestationCMD.Flags().StringVarP(&enterpriseAttestationPin, "pin", "p", "", "PIN for the security key")
-	rootCMD.AddCommand(&enterpriseAttestationCMD)
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	return cmd
 }
 
-func enterpriseAttestationHandler(cmd *cobra.Command, _ []string) error {
-	var selectedDev *fido2.DeviceDescriptor
-
-	if enterpriseAttestationDevicePath != "" {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-		for _, dev := range devs {
-			if dev.Path == enterpriseAttestationDevicePath {
-				selectedDev = &dev
-				break
-			}
-		}
-		if selectedDev == nil {
-			return fmt.Errorf("device not found at path: %s", enterpriseAttestationDevicePath)
-		}
-	} else {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-
-		if len(devs) == 0 {
-			return errors.New("no security keys found")
-		}
-
-		selectedDev, err = prompts.NewDeviceSelectPrompt().WithDevices(devs...).Run()
-		if err != nil {
-			return err
-		}
+func enterpriseAttestationHandler(cmd *cobra.Command, opts *enterpriseAttestationOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
 	}
 
-	dev, err := fido2.Open(*selectedDev)
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
 	if err != nil {
 		return err
 	}
@@ -74,16 +47,7 @@ func enterpriseAttestationHandler(cmd *cobra.Command, _ []string) error {
 		_ = dev.Close()
 	}()
 
-	pin := enterpriseAttestationPin
-	if pin == "" {
-		retries, _, _ := dev.GetPINRetries()
-		pin, err = prompts.NewPinPrompt().WithRetries(retries).Run()
-		if err != nil {
-			return err
-		}
-	}
-
-	token, err := dev.GetPinUvAuthTokenUsingPIN(pin, ctap2.PermissionAuthenticatorConfiguration, "")
+	token, err := opts.pin.Token(dev, *selectedDev, ctap2.PermissionAuthenticatorConfiguration, "")
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/spf13/cobra"
+)
+
+// minPINLengthOptions holds the flags of the config min-pin-length command.
+type minPINLengthOptions struct {
+	device      device.Selector
+	pin         pinflag.Source
+	length      uint
+	forceChange bool
+	rpiDs       []string
+}
+
+// newMinPINLengthCommand returns the config min-pin-length command.
+func newMinPINLengthCommand() *cobra.Command {
+	var opts minPINLengthOptions
+	cmd := &cobra.Command{
+		Use:   "min-pin-length",
+		Short: "Set the minimum PIN length",
+		Long: `Raise the minimum PIN length of a security key, force a PIN change on next use, or set the RP IDs that may
+read the minimum PIN length through the minPinLength extension. The minimum PIN length can only be increased;
+lowering it requires a factory reset.
+
+Without any of --length, --force-change or --rp-id, the current settings are shown.`,
+		Example: `  skm config min-pin-length
+  skm config min-pin-length --length 8
+  skm config min-pin-length --length 8 --force-change
+  skm config min-pin-length --device-path /dev/hidraw0 --pin 123456 --rp-id example.com --rp-id login.example.com`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return minPINLengthHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	cmd.Flags().UintVarP(&opts.length, "length", "l", 0, "New minimum PIN length")
+	cmd.Flags().
+		BoolVar(&opts.forceChange, "force-change", false, "Require the PIN to be changed before it is used again")
+	cmd.Flags().StringSliceVar(
+		&opts.rpiDs, "rp-id", nil,
+		"RP ID allowed to read the minimum PIN length through the minPinLength extension (repeatable)",
+	)
+	return cmd
+}
+
+func minPINLengthHandler(cmd *cobra.Command, opts *minPINLengthOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	info := dev.Info()
+	if setMin, ok := info.Options[ctap2.OptionSetMinPINLength]; !ok || !setMin {
+		return errors.New("this security key does not support setting the minimum PIN length")
+	}
+
+	if opts.length == 0 && !opts.forceChange && len(opts.rpiDs) == 0 {
+		cmd.Println("Minimum PIN length: " + strconv.FormatUint(uint64(info.MinPinLength), 10))
+		cmd.Println("Force PIN change:   " + strconv.FormatBool(info.ForcePinChange))
+		cmd.Println("Max RP IDs:         " + strconv.FormatUint(uint64(info.MaxRPIDsForSetMinPINLength), 10))
+		return nil
+	}
+
+	if opts.length != 0 && opts.length < info.MinPinLength {
+		return fmt.Errorf(
+			"the minimum PIN length can only be increased, the current minimum is %d",
+			info.MinPinLength,
+		)
+	}
+	if uint(len(opts.rpiDs)) > info.MaxRPIDsForSetMinPINLength {
+		return fmt.Errorf(
+			"this security key accepts at most %d RP IDs, got %d",
+			info.MaxRPIDsForSetMinPINLength,
+			len(opts.rpiDs),
+		)
+	}
+
+	token, err := opts.pin.Token(dev, *selectedDev, ctap2.PermissionAuthenticatorConfiguration, "")
+	if err != nil {
+		return err
+	}
+
+	err = dev.SetMinPINLength(token, opts.length, opts.rpiDs, opts.forceChange, false)
+	if err != nil {
+		return err
+	}
+
+	if opts.length != 0 {
+		cmd.Printf("Minimum PIN length set to %d.\n", opts.length)
+	}
+	if len(opts.rpiDs) > 0 {
+		cmd.Println("RP IDs allowed to read the minimum PIN length: " + strings.Join(opts.rpiDs, ", "))
+	}
+
+	// The device caches its GetInfo response, so reopen it to read whether a PIN change is now forced.
+	_ = dev.Close()
+	confirmDev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return fmt.Errorf("failed to read whether a PIN change is forced: %w", err)
+	}
+	defer func() {
+		_ = confirmDev.Close()
+	}()
+
+	if confirmDev.Info().ForcePinChange {
+		cmd.Println("The PIN must be changed with 'skm pin change' before it can be used again.")
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package skm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/authenticator/authenticatortest"
+	"github.com/mohammadv184/skm/internal/virtual"
+)
+
+func TestConfigAlwaysUV(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		unsupported bool
+		args        []string
+		wantOut     []string
+		wantErr     string
+		wantEnabled bool
+	}{
+		{
+			name:    "status",
+			args:    []string{"--status"},
+			wantOut: []string{"Always UV is disabled."},
+		},
+		{
+			name:    "status by default",
+			args:    []string{"--pin", testPIN},
+			wantOut: []string{"Always UV is disabled."},
+		},
+		{
+			name:        "enable",
+			args:        []string{"--pin", testPIN, "--enable"},
+			wantOut:     []string{"Always UV is now enabled."},
+			wantEnabled: true,
+		},
+		{
+			name:        "enable when enabled",
+			enabled:     true,
+			args:        []string{"--enable"},
+			wantOut:     []string{"Always UV is already enabled, nothing to do."},
+			wantEnabled: true,
+		},
+		{
+			name:    "disable",
+			enabled: true,
+			args:    []string{"--pin", testPIN, "--disable"},
+			wantOut: []string{"Always UV is now disabled."},
+		},
+		{
+			name:    "wrong PIN",
+			args:    []string{"--pin", "0000", "--enable"},
+			wantErr: "CTAP2_ERR_PIN_INVALID",
+		},
+		{
+			name:    "conflicting modes",
+			args:    []string{"--enable", "--disable"},
+			wantErr: "if any flags in the group [enable disable status] are set none of the others can be",
+		},
+		{
+			name:        "unsupported",
+			unsupported: true,
+			args:        []string{"--status"},
+			wantErr:     "this security key does not support Always UV",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t, true)
+			a := b.Virtual(testDevicePath)
+			if tt.enabled {
+				toggleAlwaysUV(t, a)
+			}
+			if tt.unsupported {
+				state := a.State()
+				state.Features.AlwaysUV = false
+				if err := state.Save(a.Path()); err != nil {
+					t.Fatalf("Save() error = %v", err)
+				}
+			}
+
+			args := append([]string{"config", "always-uv"}, tt.args...)
+			runCommand(t, b, args...).check(t, tt.wantOut, tt.wantErr)
+
+			if tt.unsupported {
+				return
+			}
+			if enabled := b.Virtual(testDevicePath).Info().Options[ctap2.OptionAlwaysUv]; enabled != tt.wantEnabled {
+				t.Errorf("alwaysUv = %v, want %v", enabled, tt.wantEnabled)
+			}
+		})
+	}
+}
+
+// unplugOnToggle is a security key that cannot be reopened once Always UV has been toggled.
+type unplugOnToggle struct {
+	authenticator.Device
+	b *authenticatortest.Backend
+}
+
+func (d unplugOnToggle) ToggleAlwaysUV(pinUvAuthToken []byte) error {
+	if err := d.Device.ToggleAlwaysUV(pinUvAuthToken); err != nil {
+		return err
+	}
+	d.b.OpenErr = errors.New("unplugged")
+	return nil
+}
+
+func TestConfigAlwaysUVReopenFails(t *testing.T) {
+	b := newBackend(t, true)
+	b.Wrap = func(d authenticator.Device) authenticator.Device {
+		return unplugOnToggle{Device: d, b: b}
+	}
+
+	runCommand(t, b, "config", "always-uv", "--pin", testPIN, "--enable").
+		check(t, nil, "failed to confirm the Always UV state: unplugged")
+}
+
+func TestConfigEnterpriseAttestation(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantOut []string
+		wantErr string
+		wantEP  bool
+	}{
+		{
+			name:    "enable",
+			args:    []string{"--pin", testPIN},
+			wantOut: []string{"Enterprise Attestation enabled successfully."},
+			wantEP:  true,
+		},
+		{
+			name:    "wrong PIN",
+			args:    []string{"--pin", "0000"},
+			wantErr: "CTAP2_ERR_PIN_INVALID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t, true)
+
+			args := append([]string{"config", "enterprise-attestation"}, tt.args...)
+			runCommand(t, b, args...).check(t, tt.wantOut, tt.wantErr)
+
+			if ep := b.Virtual(testDevicePath).Info().Options[ctap2.OptionEnterpriseAttestation]; ep != tt.wantEP {
+				t.Errorf("ep = %v, want %v", ep, tt.wantEP)
+			}
+		})
+	}
+}
+
+func TestConfigMinPINLength(t *testing.T) {
+	tests := []struct {
+		name string
+		// pin replaces testPIN on the security key if set.
+		pin             string
+		args            []string
+		wantOut         []string
+		wantErr         string
+		wantMin         uint
+		wantForceChange bool
+	}{
+		{
+			name:    "show",
+			wantOut: []string{"Minimum PIN length: 4", "Force PIN change:   false", "Max RP IDs:         4"},
+			wantMin: 4,
+		},
+		{
+			name: "raise",
+			args: []string{"--pin", testPIN, "--length", "6"},
+			wantOut: []string{
+				"Minimum PIN length set to 6.",
+				"The PIN must be changed with 'skm pin change' before it can be used again.",
+			},
+			wantMin:         6,
+			wantForceChange: true,
+		},
+		{
+			name:    "raise up to the PIN length",
+			pin:     "123456",
+			args:    []string{"--pin", "123456", "--length", "6"},
+			wantOut: []string{"Minimum PIN length set to 6."},
+			wantMin: 6,
+		},
+		{
+			name:    "RP IDs",
+			args:    []string{"--pin", testPIN, "--rp-id", "example.com", "--rp-id", "login.example.com"},
+			wantOut: []string{"RP IDs allowed to read the minimum PIN length: example.com, login.example.com"},
+			wantMin: 4,
+		},
+		{
+			name:    "lower",
+			args:    []string{"--pin", testPIN, "--length", "3"},
+			wantErr: "the minimum PIN length can only be increased, the current minimum is 4",
+			wantMin: 4,
+		},
+		{
+			name:    "too many RP IDs",
+			args:    []string{"--pin", testPIN, "--rp-id", "a,b,c,d,e"},
+			wantErr: "this security key accepts at most 4 RP IDs, got 5",
+			wantMin: 4,
+		},
+		{
+			name:    "wrong PIN",
+			args:    []string{"--pin", "0000", "--length", "6"},
+			wantErr: "CTAP2_ERR_PIN_INVALID",
+			wantMin: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t, true)
+			if tt.pin != "" {
+				if err := b.Virtual(testDevicePath).ChangePIN(testPIN, tt.pin); err != nil {
+					t.Fatalf("ChangePIN() error = %v", err)
+				}
+			}
+
+			args := append([]string{"config", "min-pin-length"}, tt.args...)
+			r := runCommand(t, b, args...)
+			r.check(t, tt.wantOut, tt.wantErr)
+			if forced := strings.Contains(r.stdout, "The PIN must be changed"); forced != tt.wantForceChange {
+				t.Errorf("PIN change required in output = %v, want %v", forced, tt.wantForceChange)
+			}
+
+			info := b.Virtual(testDevicePath).Info()
+			if info.MinPinLength != tt.wantMin || info.ForcePinChange != tt.wantForceChange {
+				t.Errorf(
+					"minPinLength, forcePinChange = %d, %v, want %d, %v",
+					info.MinPinLength, info.ForcePinChange, tt.wantMin, tt.wantForceChange,
+				)
+			}
+		})
+	}
+}
+
+func toggleAlwaysUV(t *testing.T, a *virtual.Authenticator) {
+	t.Helper()
+
+	token, err := a.GetPinUvAuthTokenUsingPIN(testPIN, ctap2.PermissionAuthenticatorConfiguration, "")
+	if err != nil {
+		t.Fatalf("GetPinUvAuthTokenUsingPIN() error = %v", err)
+	}
+	if err := a.ToggleAlwaysUV(token); err != nil {
+		t.Fatalf("ToggleAlwaysUV() error = %v", err)
+	}
+}
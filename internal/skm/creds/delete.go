@@ -1,78 +1,81 @@
 package creds
 
 import (
-	"bytes"
-	"encoding/base64"
 	"errors"
 	"fmt"
+	"strconv"
 
-	"github.com/mohammadv184/go-fido2"
 	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/credential"
 	"github.com/mohammadv184/skm/internal/skm/completion"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
 	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/mohammadv184/skm/internal/ui/views"
 	"github.com/spf13/cobra"
 )
 
-var deleteCMD = cobra.Command{
-	Use:     "delete",
-	Aliases: []string{"rm", "del", "remove"},
-	Short:   "Delete a credential stored on a security key",
-	Long:    `Permanently remove a specific discoverable (resident) credential from a selected security key. This action is irreversible.`,
-	Example: `  skm creds delete
-  skm creds delete --device-path /dev/hidraw0 --pin 123456 --credential-id base64-id`,
-	RunE: deleteHandler,
+// deleteOptions holds the flags of the creds delete command.
+type deleteOptions struct {
+	device        device.Selector
+	pin           pinflag.Source
+	credentialIDs []string
+	filter        credential.Filter
+	allForRP      string
+	dryRun        bool
+	yes           bool
 }
 
-var (
-	deleteDevicePath   string
-	deletePin          string
-	deleteCredentialID string
-)
+// newDeleteCommand returns the creds delete command.
+func newDeleteCommand() *cobra.Command {
+	var opts deleteOptions
+	cmd := &cobra.Command{
+		Use:     "delete",
+		Aliases: []string{"rm", "del", "remove"},
+		Short:   "Delete credentials stored on a security key",
+		Long: `Permanently remove discoverable (resident) credentials from a selected security key. This action is irreversible.
+
+Credentials can be selected by ID, by filtering on the relying party ID and user name (globs by default, regular
+expressions with --regex), or interactively, where space toggles a credential. Everything that will be removed is
+listed in a single confirmation, and the whole batch is deleted with one PIN entry.`,
+		Example: `  skm creds delete
+  skm creds delete --device-path /dev/hidraw0 --pin 123456 --credential-id base64-id
+  skm creds delete --all-for-rp test.example.com --dry-run
+  skm creds delete --rp '*.example.com' --user 'test-*' --yes
+  skm creds delete --rp '^(dev|staging)\.' --regex`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return deleteHandler(cmd, &opts)
+		},
+	}
 
-func init() {
-	deleteCMD.Flags().StringVarP(&deleteDevicePath, "device-path", "d", "", "Path to the security key device")
-	_ = deleteCMD.RegisterFlagCompletionFunc("device-path", completion.CompleteDevicePath)
-	deleteCMD.Flags().StringVarP(&deletePin, "pin", "p", "", "PIN for the security key")
-	deleteCMD.Flags().
-		StringVarP(&deleteCredentialID, "credential-id", "i", "", "ID of the credential to delete (base64 encoded)")
-	_ = deleteCMD.RegisterFlagCompletionFunc("credential-id", completion.CompleteCredentialID)
-	rootCMD.AddCommand(&deleteCMD)
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	cmd.Flags().StringSliceVarP(
+		&opts.credentialIDs, "credential-id", "i", nil,
+		"ID of a credential to delete (base64 encoded, repeatable)",
+	)
+	_ = cmd.RegisterFlagCompletionFunc("credential-id", completion.CompleteCredentialID)
+	cmd.Flags().StringVar(&opts.filter.RP, "rp", "", "Delete credentials whose RP ID matches the pattern")
+	cmd.Flags().StringVar(&opts.filter.User, "user", "", "Delete credentials whose user name matches the pattern")
+	cmd.Flags().BoolVar(&opts.filter.Regex, "regex", false, "Treat --rp and --user as regular expressions")
+	cmd.Flags().StringVar(&opts.allForRP, "all-for-rp", "", "Delete every credential of the given RP ID")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "List the credentials that would be deleted and exit")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Skip the confirmation prompt")
+	cmd.MarkFlagsMutuallyExclusive("credential-id", "all-for-rp")
+	cmd.MarkFlagsMutuallyExclusive("credential-id", "rp")
+	cmd.MarkFlagsMutuallyExclusive("credential-id", "user")
+	cmd.MarkFlagsMutuallyExclusive("all-for-rp", "rp")
+	return cmd
 }
 
-func deleteHandler(cmd *cobra.Command, _ []string) error {
-	var selectedDev *fido2.DeviceDescriptor
-
-	if deleteDevicePath != "" {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-		for _, dev := range devs {
-			if dev.Path == deleteDevicePath {
-				selectedDev = &dev
-				break
-			}
-		}
-		if selectedDev == nil {
-			return fmt.Errorf("device not found at path: %s", deleteDevicePath)
-		}
-	} else {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-
-		if len(devs) == 0 {
-			return errors.New("no security keys found")
-		}
-
-		selectedDev, err = prompts.NewDeviceSelectPrompt().WithDevices(devs...).Run()
-		if err != nil {
-			return err
-		}
+func deleteHandler(cmd *cobra.Command, opts *deleteOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
 	}
 
-	dev, err := fido2.Open(*selectedDev)
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
 	if err != nil {
 		return err
 	}
@@ -80,74 +83,107 @@ func deleteHandler(cmd *cobra.Command, _ []string) error {
 		_ = dev.Close()
 	}()
 
-	pin := deletePin
-	if pin == "" {
-		retries, _, _ := dev.GetPINRetries()
-		pin, err = prompts.NewPinPrompt().WithRetries(retries).Run()
-		if err != nil {
-			return err
-		}
+	// A single token is used for enumeration and for every deletion in the batch.
+	token, err := opts.pin.Token(dev, *selectedDev, ctap2.PermissionCredentialManagement, "")
+	if err != nil {
+		return err
 	}
 
-	token, err := dev.GetPinUvAuthTokenUsingPIN(pin, ctap2.PermissionCredentialManagement, "")
+	rps, err := credential.Collect(dev, token)
 	if err != nil {
 		return err
 	}
 
-	allCreds := make([]*ctap2.AuthenticatorCredentialManagementResponse, 0)
-
-	for rp, err := range dev.EnumerateRPs(token) {
-		if err != nil {
-			return err
-		}
-
-		allCreds = append(allCreds, rp)
+	if len(rps) == 0 {
+		cmd.Println("No credentials found on this device.")
+		return nil
 	}
 
-	for i, cred := range allCreds {
-		for c, err := range dev.EnumerateCredentials(token, cred.RPIDHash) {
-			if err != nil {
-				return err
-			}
+	selectedCreds, err := selectCredentialsToDelete(rps, opts)
+	if err != nil {
+		return err
+	}
 
-			c.RP = cred.RP
-			allCreds[i] = c
-		}
+	if len(selectedCreds) == 0 {
+		cmd.Println("No credentials match the given filters.")
+		return nil
 	}
 
-	if len(allCreds) == 0 {
-		cmd.Println("No credentials found on this device.")
+	cmd.Println(views.NewCredentialListView().WithRelyingParties(credential.Group(selectedCreds)...).Render())
+
+	if opts.dryRun {
+		cmd.Println(pluralCredentials(len(selectedCreds)) + " would be deleted (dry run).")
 		return nil
 	}
 
-	var selectedCred *ctap2.AuthenticatorCredentialManagementResponse
-	if deleteCredentialID != "" {
-		decodedID, err := base64.RawURLEncoding.DecodeString(deleteCredentialID)
+	if !opts.yes {
+		confirmed, err := prompts.NewConfirmPrompt(
+			"Delete "+pluralCredentials(len(selectedCreds))+"?",
+			"The credentials listed above will be permanently removed from the security key.",
+		).Run()
 		if err != nil {
-			return fmt.Errorf("failed to decode credential ID: %w", err)
+			return err
 		}
-		for _, c := range allCreds {
-			if bytes.Equal(c.CredentialID.ID, decodedID) {
-				selectedCred = c
-				break
-			}
+		if !confirmed {
+			cmd.Println("Aborted.")
+			return nil
 		}
-		if selectedCred == nil {
-			return fmt.Errorf("credential not found with ID: %s", deleteCredentialID)
+	}
+
+	var errs []error
+	deleted := 0
+	for _, c := range selectedCreds {
+		if err := dev.DeleteCredential(token, c.CredentialID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete credential %s of %s: %w", c.User.Name, c.RP.ID, err))
+			continue
 		}
+		deleted++
+	}
+
+	if len(selectedCreds) == 1 && deleted == 1 {
+		cmd.Println("Credential deleted successfully.")
 	} else {
-		var err error
-		selectedCred, err = prompts.NewCredentialSelectPrompt().WithCredentials(allCreds...).Run()
-		if err != nil {
-			return err
-		}
+		cmd.Printf("Deleted %d of %s.\n", deleted, pluralCredentials(len(selectedCreds)))
 	}
 
-	err = dev.DeleteCredential(token, selectedCred.CredentialID)
-	if err != nil {
-		return err
+	return errors.Join(errs...)
+}
+
+// selectCredentialsToDelete returns the credentials selected by the flags, or prompts for them if no
+// selection flag is set.
+func selectCredentialsToDelete(rps []*credential.RelyingParty, opts *deleteOptions) ([]*credential.Credential, error) {
+	switch {
+	case len(opts.credentialIDs) > 0:
+		creds := make([]*credential.Credential, 0, len(opts.credentialIDs))
+		for _, id := range opts.credentialIDs {
+			c, err := credential.FindByID(rps, id)
+			if err != nil {
+				return nil, err
+			}
+			creds = append(creds, c)
+		}
+		return creds, nil
+	case opts.allForRP != "":
+		for _, rp := range rps {
+			if rp.RP.ID == opts.allForRP {
+				matched, err := credential.Filter{User: opts.filter.User, Regex: opts.filter.Regex}.Apply(
+					[]*credential.RelyingParty{rp},
+				)
+				return credential.All(matched), err
+			}
+		}
+		return nil, fmt.Errorf("no credentials found for RP ID: %s", opts.allForRP)
+	case opts.filter.IsSet():
+		matched, err := opts.filter.Apply(rps)
+		return credential.All(matched), err
+	default:
+		return prompts.NewCredentialSelectPrompt().WithMultiSelect().WithRelyingParties(rps...).RunMulti()
 	}
+}
 
-	cmd.Println("Credential deleted successfully.")
-	return nil
+func pluralCredentials(n int) string {
+	if n == 1 {
+		return "1 credential"
+	}
+	return strconv.Itoa(n) + " credentials"
 }
@@ -1,73 +1,57 @@
 package creds
 
 import (
-	"errors"
-	"fmt"
-
-	"github.com/mohammadv184/go-fido2"
 	"github.com/mohammadv184/go-fido2/protocol/ctap2"
-	"github.com/mohammadv184/skm/internal/skm/completion"
-	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/credential"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/output"
 	"github.com/mohammadv184/skm/internal/ui/views"
 	"github.com/spf13/cobra"
 )
 
-var listCMD = cobra.Command{
-	Use:     "list",
-	Aliases: []string{"ls"},
-	Short:   "List credentials stored on a security key",
-	Long:    `Retrieve and display a list of all discoverable (resident) credentials stored on a selected security key. You will be prompted to select a device and enter its PIN.`,
-	Example: `  skm creds list
-  skm creds list --device-path /dev/hidraw0 --pin 123456`,
-	RunE: listHandler,
+// listOptions holds the flags of the creds list command.
+type listOptions struct {
+	device device.Selector
+	pin    pinflag.Source
 }
 
-var (
-	listDevicePath string
-	listPin        string
-)
+// newListCommand returns the creds list command.
+func newListCommand() *cobra.Command {
+	var opts listOptions
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List credentials stored on a security key",
+		Long:    `Retrieve and display a list of all discoverable (resident) credentials stored on a selected security key. You will be prompted to select a device and enter its PIN.`,
+		Example: `  skm creds list
+  skm creds list --device-path /dev/hidraw0 --pin 123456
+  pass show security-key/pin | skm creds list --pin-stdin
+  skm creds list --output csv`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return listHandler(cmd, &opts)
+		},
+	}
 
-func init() {
-	listCMD.Flags().StringVarP(&listDevicePath, "device-path", "d", "", "Path to the security key device")
-	_ = listCMD.RegisterFlagCompletionFunc("device-path", completion.CompleteDevicePath)
-	listCMD.Flags().StringVarP(&listPin, "pin", "p", "", "PIN for the security key")
-	rootCMD.AddCommand(&listCMD)
+	opts.device.RegisterFlags(cmd)
+	output.RegisterFlag(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	return cmd
 }
 
-func listHandler(cmd *cobra.Command, _ []string) error {
-	var selectedDev *fido2.DeviceDescriptor
-
-	if listDevicePath != "" {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-		for _, dev := range devs {
-			if dev.Path == listDevicePath {
-				selectedDev = &dev
-				break
-			}
-		}
-		if selectedDev == nil {
-			return fmt.Errorf("device not found at path: %s", listDevicePath)
-		}
-	} else {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-
-		if len(devs) == 0 {
-			return errors.New("no security keys found")
-		}
+func listHandler(cmd *cobra.Command, opts *listOptions) error {
+	format, err := output.FromCommand(cmd)
+	if err != nil {
+		return err
+	}
 
-		selectedDev, err = prompts.NewDeviceSelectPrompt().WithDevices(devs...).Run()
-		if err != nil {
-			return err
-		}
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
 	}
 
-	dev, err := fido2.Open(*selectedDev)
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
 	if err != nil {
 		return err
 	}
@@ -75,39 +59,19 @@ func listHandler(cmd *cobra.Command, _ []string) error {
 		_ = dev.Close()
 	}()
 
-	pin := listPin
-	if pin == "" {
-		retries, _, _ := dev.GetPINRetries()
-		pin, err = prompts.NewPinPrompt().WithRetries(retries).Run()
-		if err != nil {
-			return err
-		}
-	}
-
-	token, err := dev.GetPinUvAuthTokenUsingPIN(pin, ctap2.PermissionCredentialManagement, "")
+	token, err := opts.pin.Token(dev, *selectedDev, ctap2.PermissionCredentialManagement, "")
 	if err != nil {
 		return err
 	}
 
-	allCreds := make([]*ctap2.AuthenticatorCredentialManagementResponse, 0)
-
-	for rp, err := range dev.EnumerateRPs(token) {
-		if err != nil {
-			return err
-		}
-
-		allCreds = append(allCreds, rp)
+	rps, err := credential.Collect(dev, token)
+	if err != nil {
+		return err
 	}
+	allCreds := credential.All(rps)
 
-	for i, cred := range allCreds {
-		for c, err := range dev.EnumerateCredentials(token, cred.RPIDHash) {
-			if err != nil {
-				return err
-			}
-
-			c.RP = cred.RP
-			allCreds[i] = c
-		}
+	if format != output.FormatText {
+		return output.Write(cmd.OutOrStdout(), format, output.NewCredentialList(output.NewDevice(selectedDev), allCreds...))
 	}
 
 	if len(allCreds) == 0 {
@@ -115,7 +79,7 @@ func listHandler(cmd *cobra.Command, _ []string) error {
 		return nil
 	}
 
-	v := views.NewCredentialListView().WithCredentials(allCreds...)
+	v := views.NewCredentialListView().WithRelyingParties(rps...)
 	cmd.Println(v.Render())
 
 	return nil
@@ -0,0 +1,172 @@
+package creds
+
+import (
+	"crypto/rand"
+	"slices"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/credential"
+	"github.com/mohammadv184/skm/internal/skm/completion"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/output"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/mohammadv184/skm/internal/ui/views"
+	"github.com/spf13/cobra"
+)
+
+// showOptions holds the flags of the creds show command.
+type showOptions struct {
+	device        device.Selector
+	pin           pinflag.Source
+	credentialID  string
+	checkCredBlob bool
+}
+
+// newShowCommand returns the creds show command.
+func newShowCommand() *cobra.Command {
+	var opts showOptions
+	cmd := &cobra.Command{
+		Use:     "show",
+		Aliases: []string{"get", "inspect"},
+		Short:   "Show details of a credential stored on a security key",
+		Long: `Display all details of a single discoverable (resident) credential, including the user ID, the full
+credential ID, its credProtect level, and its public key exported as PEM and JWK.
+
+Whether the credential has a credBlob is only reported by an assertion, so it is checked only with
+--check-cred-blob, which requires touching the security key.`,
+		Example: `  skm creds show
+  skm creds show --device-path /dev/hidraw0 --pin 123456 --credential-id base64-id
+  skm creds show --credential-id base64-id --check-cred-blob --output json`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return showHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	output.RegisterFlag(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	cmd.Flags().
+		StringVarP(&opts.credentialID, "credential-id", "i", "", "ID of the credential to show (base64 encoded)")
+	_ = cmd.RegisterFlagCompletionFunc("credential-id", completion.CompleteCredentialID)
+	cmd.Flags().
+		BoolVar(&opts.checkCredBlob, "check-cred-blob", false, "Check whether the credential has a credBlob (requires touch)")
+	return cmd
+}
+
+func showHandler(cmd *cobra.Command, opts *showOptions) error {
+	format, err := output.FromCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	token, err := opts.pin.Token(dev, *selectedDev, ctap2.PermissionCredentialManagement, "")
+	if err != nil {
+		return err
+	}
+
+	rps, err := credential.Collect(dev, token)
+	if err != nil {
+		return err
+	}
+
+	if len(rps) == 0 {
+		cmd.Println("No credentials found on this device.")
+		return nil
+	}
+
+	var selectedCred *credential.Credential
+	if opts.credentialID != "" {
+		selectedCred, err = credential.FindByID(rps, opts.credentialID)
+	} else {
+		selectedCred, err = prompts.NewCredentialSelectPrompt().WithRelyingParties(rps...).Run()
+	}
+	if err != nil {
+		return err
+	}
+
+	var credBlob *bool
+	credBlobState := "not checked (use --check-cred-blob)"
+	if opts.checkCredBlob {
+		if !slices.Contains(dev.Info().Extensions, webauthn.ExtensionIdentifierCredentialBlob) {
+			credBlobState = "unsupported by the device"
+		} else {
+			cmd.PrintErrln("Touch your security key to check for a credBlob.")
+			present, err := hasCredBlob(dev, *selectedDev, selectedCred, opts)
+			if err != nil {
+				return err
+			}
+
+			credBlob = &present
+			credBlobState = "absent"
+			if present {
+				credBlobState = "present"
+			}
+		}
+	}
+
+	if format != output.FormatText {
+		return output.Write(
+			cmd.OutOrStdout(),
+			format,
+			output.NewCredentialDetail(output.NewDevice(selectedDev), selectedCred, credBlob),
+		)
+	}
+
+	cmd.Println(views.NewCredentialView(selectedCred).WithCredBlob(credBlobState).Render())
+	return nil
+}
+
+// hasCredBlob reports whether cred has a non-empty credBlob by requesting an assertion with the credBlob extension.
+func hasCredBlob(
+	dev authenticator.Device,
+	desc fido2.DeviceDescriptor,
+	cred *credential.Credential,
+	opts *showOptions,
+) (bool, error) {
+	token, err := opts.pin.Token(dev, desc, ctap2.PermissionGetAssertion, cred.RP.ID)
+	if err != nil {
+		return false, err
+	}
+
+	clientData := make([]byte, 32)
+	if _, err := rand.Read(clientData); err != nil {
+		return false, err
+	}
+
+	for assertion, err := range dev.GetAssertion(
+		token,
+		cred.RP.ID,
+		clientData,
+		[]webauthn.PublicKeyCredentialDescriptor{cred.CredentialID},
+		&webauthn.GetAuthenticationExtensionsClientInputs{
+			GetCredentialBlobInputs: &webauthn.GetCredentialBlobInputs{GetCredBlob: true},
+		},
+		nil,
+	) {
+		if err != nil {
+			return false, err
+		}
+
+		outputs := assertion.ExtensionOutputs.GetCredentialBlobOutputs
+		return outputs != nil && len(outputs.GetCredBlob) > 0, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,149 @@
+package creds
+
+import (
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/credential"
+	"github.com/mohammadv184/skm/internal/skm/completion"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/mohammadv184/skm/internal/ui/views"
+	"github.com/spf13/cobra"
+)
+
+// updateUserOptions holds the flags of the creds update-user command.
+type updateUserOptions struct {
+	device       device.Selector
+	pin          pinflag.Source
+	credentialID string
+	name         string
+	displayName  string
+	yes          bool
+}
+
+// newUpdateUserCommand returns the creds update-user command.
+func newUpdateUserCommand() *cobra.Command {
+	var opts updateUserOptions
+	cmd := &cobra.Command{
+		Use:     "update-user",
+		Aliases: []string{"rename", "update"},
+		Short:   "Update the user information of a credential stored on a security key",
+		Long: `Change the user name and display name of an existing discoverable (resident) credential, for example after
+a user has been renamed. The user ID and the credential itself are left unchanged, so the credential keeps working
+with the relying party.
+
+If neither --name nor --display-name is given, the new values are prompted for. Pass an empty value to clear a field.`,
+		Example: `  skm creds update-user
+  skm creds update-user --credential-id base64-id --name alice@example.com --display-name "Alice Smith"
+  skm creds update-user --credential-id base64-id --display-name "" --yes`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return updateUserHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "PIN for the security key")
+	cmd.Flags().
+		StringVarP(&opts.credentialID, "credential-id", "i", "", "ID of the credential to update (base64 encoded)")
+	_ = cmd.RegisterFlagCompletionFunc("credential-id", completion.CompleteCredentialID)
+	cmd.Flags().StringVarP(&opts.name, "name", "n", "", "New user name")
+	cmd.Flags().StringVar(&opts.displayName, "display-name", "", "New user display name")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Skip the confirmation prompt")
+	return cmd
+}
+
+func updateUserHandler(cmd *cobra.Command, opts *updateUserOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	token, err := opts.pin.Token(dev, *selectedDev, ctap2.PermissionCredentialManagement, "")
+	if err != nil {
+		return err
+	}
+
+	rps, err := credential.Collect(dev, token)
+	if err != nil {
+		return err
+	}
+
+	if len(rps) == 0 {
+		cmd.Println("No credentials found on this device.")
+		return nil
+	}
+
+	var selectedCred *credential.Credential
+	if opts.credentialID != "" {
+		selectedCred, err = credential.FindByID(rps, opts.credentialID)
+	} else {
+		selectedCred, err = prompts.NewCredentialSelectPrompt().WithRelyingParties(rps...).Run()
+	}
+	if err != nil {
+		return err
+	}
+
+	before := selectedCred.User
+	after := before
+
+	nameSet := cmd.Flags().Changed("name")
+	displayNameSet := cmd.Flags().Changed("display-name")
+	switch {
+	case nameSet || displayNameSet:
+		if nameSet {
+			after.Name = opts.name
+		}
+		if displayNameSet {
+			after.DisplayName = opts.displayName
+		}
+	default:
+		after.Name, err = prompts.NewTextPrompt("Enter the new user name").WithValue(before.Name).Run()
+		if err != nil {
+			return err
+		}
+		after.DisplayName, err = prompts.NewTextPrompt("Enter the new display name").
+			WithValue(before.DisplayName).
+			Run()
+		if err != nil {
+			return err
+		}
+	}
+
+	if after.Name == before.Name && after.DisplayName == before.DisplayName {
+		cmd.Println("User information is unchanged.")
+		return nil
+	}
+
+	cmd.Println(views.NewUserDiffView(before, after).Render())
+
+	if !opts.yes {
+		confirmed, err := prompts.NewConfirmPrompt(
+			"Update the user information of this credential?",
+			"RP: "+selectedCred.RP.ID,
+		).Run()
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			cmd.Println("Aborted.")
+			return nil
+		}
+	}
+
+	err = dev.UpdateUserInformation(token, selectedCred.CredentialID, after)
+	if err != nil {
+		return err
+	}
+
+	cmd.Println("User information updated successfully.")
+	return nil
+}
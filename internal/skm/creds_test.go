@@ -0,0 +1,164 @@
+package skm
+
+import (
+	"encoding/base64"
+	"slices"
+	"testing"
+
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+	"github.com/mohammadv184/skm/internal/authenticator/authenticatortest"
+	"github.com/mohammadv184/skm/internal/virtual"
+)
+
+// newCredsBackend returns a backend with a single security key holding two credentials of example.com and one
+// of github.com. The IDs of the credentials are returned base64url encoded, in that order.
+func newCredsBackend(t *testing.T) (*authenticatortest.Backend, []string) {
+	t.Helper()
+
+	b := newBackend(t, true)
+	a := b.Virtual(testDevicePath)
+
+	creds := []struct {
+		rp   string
+		user string
+	}{
+		{"example.com", "alice"},
+		{"example.com", "bob"},
+		{"github.com", "carol"},
+	}
+	ids := make([]string, 0, len(creds))
+	for i, c := range creds {
+		id, err := a.AddCredential(
+			webauthn.PublicKeyCredentialRpEntity{ID: c.rp, Name: c.rp},
+			webauthn.PublicKeyCredentialUserEntity{ID: []byte{byte(i)}, Name: c.user, DisplayName: c.user},
+			virtual.CredentialOptions{},
+		)
+		if err != nil {
+			t.Fatalf("AddCredential() error = %v", err)
+		}
+		ids = append(ids, base64.RawURLEncoding.EncodeToString(id))
+	}
+
+	return b, ids
+}
+
+func TestCredsList(t *testing.T) {
+	tests := []struct {
+		name    string
+		empty   bool
+		args    []string
+		wantOut []string
+		wantErr string
+	}{
+		{
+			name:    "table",
+			args:    []string{"--pin", testPIN},
+			wantOut: []string{"example.com", "alice", "bob", "github.com", "carol"},
+		},
+		{
+			name:    "json",
+			args:    []string{"--pin", testPIN, "-o", "json"},
+			wantOut: []string{`"kind": "CredentialList"`, `"rpId": "github.com"`, `"userName": "carol"`},
+		},
+		{
+			name:    "empty",
+			empty:   true,
+			args:    []string{"--pin", testPIN},
+			wantOut: []string{"No credentials found on this device."},
+		},
+		{
+			name:    "wrong PIN",
+			args:    []string{"--pin", "0000"},
+			wantErr: "CTAP2_ERR_PIN_INVALID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b *authenticatortest.Backend
+			if tt.empty {
+				b = newBackend(t, true)
+			} else {
+				b, _ = newCredsBackend(t)
+			}
+
+			runCommand(t, b, append([]string{"creds", "list"}, tt.args...)...).check(t, tt.wantOut, tt.wantErr)
+		})
+	}
+}
+
+func TestCredsDelete(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      func(ids []string) []string
+		wantOut   []string
+		wantErr   string
+		wantUsers []string
+	}{
+		{
+			name: "by ID",
+			args: func(ids []string) []string {
+				return []string{"--credential-id", ids[1], "--yes"}
+			},
+			wantOut:   []string{"Credential deleted successfully."},
+			wantUsers: []string{"alice", "carol"},
+		},
+		{
+			name: "all for RP",
+			args: func([]string) []string {
+				return []string{"--all-for-rp", "example.com", "--yes"}
+			},
+			wantOut:   []string{"Deleted 2 of 2 credentials."},
+			wantUsers: []string{"carol"},
+		},
+		{
+			name: "filter dry run",
+			args: func([]string) []string {
+				return []string{"--rp", "*.com", "--user", "c*", "--dry-run"}
+			},
+			wantOut:   []string{"carol", "1 credential would be deleted (dry run)."},
+			wantUsers: []string{"alice", "bob", "carol"},
+		},
+		{
+			name: "filter without match",
+			args: func([]string) []string {
+				return []string{"--user", "mallory", "--yes"}
+			},
+			wantOut:   []string{"No credentials match the given filters."},
+			wantUsers: []string{"alice", "bob", "carol"},
+		},
+		{
+			name: "unknown RP",
+			args: func([]string) []string {
+				return []string{"--all-for-rp", "gitlab.com", "--yes"}
+			},
+			wantErr:   "no credentials found for RP ID: gitlab.com",
+			wantUsers: []string{"alice", "bob", "carol"},
+		},
+		{
+			name: "conflicting selectors",
+			args: func(ids []string) []string {
+				return []string{"--credential-id", ids[0], "--all-for-rp", "example.com"}
+			},
+			wantErr:   "if any flags in the group [credential-id all-for-rp] are set none of the others can be",
+			wantUsers: []string{"alice", "bob", "carol"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, ids := newCredsBackend(t)
+
+			args := append([]string{"creds", "delete", "--pin", testPIN}, tt.args(ids)...)
+			runCommand(t, b, args...).check(t, tt.wantOut, tt.wantErr)
+
+			var users []string
+			for _, c := range b.Virtual(testDevicePath).State().Credentials {
+				users = append(users, c.User.Name)
+			}
+			if !slices.Equal(users, tt.wantUsers) {
+				t.Errorf("remaining users = %v, want %v", users, tt.wantUsers)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Package device resolves the security key a command should operate on from the device selector flags.
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/google/uuid"
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/completion"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// ErrNoDevices is returned when no security keys are connected.
+	ErrNoDevices = errors.New("no security keys found")
+	// ErrNoMatch is returned when no connected security key matches the selectors.
+	ErrNoMatch = errors.New("no security key matches the given selectors")
+	// ErrIndexOutOfRange is returned when --device-index is past the connected security keys.
+	ErrIndexOutOfRange = errors.New("device index out of range")
+	// ErrAmbiguous is returned when several security keys match and no prompt can be shown.
+	ErrAmbiguous = errors.New(
+		"multiple security keys found, select one with --device-path, --serial, --aaguid, --product or --device-index",
+	)
+)
+
+// Selector holds the flags used to select a security key.
+type Selector struct {
+	// Path is the platform-specific device path.
+	Path string
+	// Serial is the device serial number.
+	Serial string
+	// AAGUID is the authenticator AAGUID.
+	AAGUID string
+	// Product is a glob pattern matched case-insensitively against the product name.
+	Product string
+	// Index is the position of the device as listed by 'skm list', starting at 0.
+	Index int
+
+	cmd    *cobra.Command
+	flags  *pflag.FlagSet
+	waited bool
+}
+
+// RegisterFlags registers the device selector flags on cmd.
+func (s *Selector) RegisterFlags(cmd *cobra.Command) {
+	s.cmd = cmd
+	s.flags = cmd.Flags()
+
+	cmd.Flags().StringVarP(
+		&s.Path, "device-path", "d", "",
+		"Path to the security key device, or virtual:FILE for a virtual authenticator",
+	)
+	_ = cmd.RegisterFlagCompletionFunc("device-path", completion.CompleteDevicePath)
+	cmd.Flags().StringVar(&s.Serial, "serial", "", "Serial number of the security key")
+	_ = cmd.RegisterFlagCompletionFunc("serial", completion.CompleteSerialNumber)
+	cmd.Flags().StringVar(&s.AAGUID, "aaguid", "", "AAGUID of the security key")
+	cmd.Flags().StringVar(&s.Product, "product", "", "Product name of the security key (glob pattern, e.g. 'YubiKey*')")
+	cmd.Flags().IntVar(&s.Index, "device-index", 0, "Position of the security key as listed by 'skm list', starting at 0")
+}
+
+// IsSet reports whether any selector flag was given.
+func (s *Selector) IsSet() bool {
+	return s.Path != "" || s.Serial != "" || s.AAGUID != "" || s.Product != "" || s.indexSet()
+}
+
+// Match returns all connected security keys that match the selectors.
+// It returns all connected security keys if no selector is set.
+func (s *Selector) Match() ([]fido2.DeviceDescriptor, error) {
+	// A virtual authenticator is selected by its path alone and needs no enumeration.
+	if authenticator.IsVirtual(s.Path) {
+		desc, err := authenticator.Describe(s.Path)
+		if err != nil {
+			return nil, err
+		}
+		return s.filter([]fido2.DeviceDescriptor{desc})
+	}
+
+	devs, err := authenticator.Enumerate(s.context())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.filter(devs)
+}
+
+// Resolve returns exactly one security key. If several keys match the selectors, the user is asked to pick one.
+// With --wait, it waits for a matching key if none is connected.
+func (s *Selector) Resolve() (*fido2.DeviceDescriptor, error) {
+	devs, err := s.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(devs) == 0 && s.IsSet():
+		return nil, ErrNoMatch
+	case len(devs) == 0:
+		return nil, ErrNoDevices
+	case len(devs) == 1:
+		return &devs[0], nil
+	}
+
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return nil, ErrAmbiguous
+	}
+
+	return prompts.NewDeviceSelectPrompt().WithDevices(devs...).Run()
+}
+
+// ResolveAll returns every security key that matches the selectors if all is set, otherwise it behaves like
+// Resolve and returns a single security key.
+func (s *Selector) ResolveAll(all bool) ([]fido2.DeviceDescriptor, error) {
+	if !all {
+		dev, err := s.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		return []fido2.DeviceDescriptor{*dev}, nil
+	}
+
+	devs, err := s.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(devs) == 0 && s.IsSet():
+		return nil, ErrNoMatch
+	case len(devs) == 0:
+		return nil, ErrNoDevices
+	}
+
+	return devs, nil
+}
+
+func (s *Selector) filter(devs []fido2.DeviceDescriptor) ([]fido2.DeviceDescriptor, error) {
+	if s.indexSet() {
+		if s.Index < 0 || s.Index >= len(devs) {
+			return nil, fmt.Errorf("%w: %d, %d security keys found", ErrIndexOutOfRange, s.Index, len(devs))
+		}
+		devs = devs[s.Index : s.Index+1]
+	}
+
+	var aaguid uuid.UUID
+	if s.AAGUID != "" {
+		var err error
+		aaguid, err = uuid.Parse(s.AAGUID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AAGUID %q: %w", s.AAGUID, err)
+		}
+	}
+
+	if s.Product != "" {
+		if _, err := path.Match(s.Product, ""); err != nil {
+			return nil, fmt.Errorf("invalid product pattern %q: %w", s.Product, err)
+		}
+	}
+
+	matched := make([]fido2.DeviceDescriptor, 0, len(devs))
+	for _, dev := range devs {
+		if s.Path != "" && dev.Path != s.Path {
+			continue
+		}
+		if s.Serial != "" && dev.SerialNumber != s.Serial {
+			continue
+		}
+		if s.Product != "" {
+			if ok, _ := path.Match(strings.ToLower(s.Product), strings.ToLower(dev.Product)); !ok {
+				continue
+			}
+		}
+		if s.AAGUID != "" && !hasAAGUID(s.context(), dev, aaguid) {
+			continue
+		}
+
+		matched = append(matched, dev)
+	}
+
+	return matched, nil
+}
+
+// context returns the context of the command the flags are registered on, which carries its Backend.
+func (s *Selector) context() context.Context {
+	if s.cmd == nil || s.cmd.Context() == nil {
+		return context.Background()
+	}
+	return s.cmd.Context()
+}
+
+func (s *Selector) indexSet() bool {
+	return s.flags != nil && s.flags.Changed("device-index")
+}
+
+// hasAAGUID reports whether the device reports the given AAGUID. Devices that cannot be opened never match.
+func hasAAGUID(ctx context.Context, desc fido2.DeviceDescriptor, aaguid uuid.UUID) bool {
+	dev, err := authenticator.Open(ctx, desc)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	return dev.Info().AAGUID == aaguid
+}
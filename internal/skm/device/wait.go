@@ -0,0 +1,116 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/spf13/cobra"
+)
+
+const (
+	waitFlagName = "wait"
+	// waitInterval is how often the security keys are polled while waiting, in addition to the hot-plug events.
+	waitInterval = 250 * time.Millisecond
+)
+
+// RegisterWaitFlag registers the persistent --wait[=TIMEOUT] flag on cmd, which makes the commands wait for a
+// matching security key to be plugged in instead of failing when none is connected.
+func RegisterWaitFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().Duration(
+		waitFlagName,
+		0,
+		"Wait up to the given time, or forever if none, for a matching security key to be plugged in",
+	)
+	cmd.PersistentFlags().Lookup(waitFlagName).NoOptDefVal = "0s"
+}
+
+// waitTimeout returns whether the --wait flag is set on the command the selector flags are registered on, and
+// its timeout, 0 for none.
+func (s *Selector) waitTimeout() (bool, time.Duration) {
+	if s.cmd == nil {
+		return false, 0
+	}
+	flag := s.cmd.Flags().Lookup(waitFlagName)
+	if flag == nil || !flag.Changed {
+		return false, 0
+	}
+
+	timeout, err := time.ParseDuration(flag.Value.String())
+	if err != nil {
+		return false, 0
+	}
+	return true, timeout
+}
+
+// Wait returns all connected security keys that match the selectors, like Match. If none matches and --wait is
+// set, it waits for a matching security key to be plugged in, showing a spinner on a terminal.
+func (s *Selector) Wait() ([]fido2.DeviceDescriptor, error) {
+	s.waited = false
+
+	devs, err := s.Match()
+	switch {
+	case err == nil && len(devs) > 0:
+		return devs, nil
+	case err != nil && !errors.Is(err, ErrIndexOutOfRange):
+		// Invalid selectors, e.g. a malformed AAGUID, don't get any better by waiting.
+		return nil, err
+	}
+
+	wait, timeout := s.waitTimeout()
+	if !wait {
+		return nil, err
+	}
+
+	ctx := s.context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	message := "Insert your security key…"
+	if s.IsSet() {
+		message = "Insert a security key matching the selectors…"
+	}
+
+	waitForMatch := func(ctx context.Context) error {
+		devs, err = s.waitForMatch(ctx)
+		return err
+	}
+	if term.IsTerminal(os.Stdin.Fd()) && term.IsTerminal(os.Stderr.Fd()) {
+		err = prompts.NewSpinnerPrompt(message).Run(ctx, waitForMatch)
+	} else {
+		s.cmd.PrintErrln(message)
+		err = waitForMatch(ctx)
+	}
+
+	s.waited = err == nil
+	if errors.Is(err, context.DeadlineExceeded) {
+		if s.IsSet() {
+			return nil, fmt.Errorf("%w after waiting %s", ErrNoMatch, timeout)
+		}
+		return nil, fmt.Errorf("%w after waiting %s", ErrNoDevices, timeout)
+	}
+	return devs, err
+}
+
+// Waited reports whether the security keys returned by the last call to Wait, Resolve or ResolveAll were plugged
+// in while waiting for them.
+func (s *Selector) Waited() bool {
+	return s.waited
+}
+
+// waitForMatch checks the selectors whenever a security key is plugged in, until one matches or ctx is done.
+// Failures, e.g. of --device-index while too few security keys are connected, are retried.
+func (s *Selector) waitForMatch(ctx context.Context) ([]fido2.DeviceDescriptor, error) {
+	return authenticator.WaitFor(ctx, s.Match, waitInterval, func(devs []fido2.DeviceDescriptor) bool {
+		return len(devs) > 0
+	})
+}
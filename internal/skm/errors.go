@@ -0,0 +1,145 @@
+package skm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/go-fido2/transport/hid"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/exitcode"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+)
+
+// statusError is how a CTAP2 status returned by a security key is reported.
+type statusError struct {
+	code int
+	hint string
+}
+
+// statusErrors maps the CTAP2 statuses that call for an action of the user to their exit code and a hint.
+var statusErrors = map[ctaphid.StatusCode]statusError{
+	ctaphid.StatusCTAP2ErrPinInvalid: {exitcode.PINInvalid, "the PIN is incorrect"},
+	ctaphid.StatusCTAP2ErrUVInvalid: {
+		exitcode.PINInvalid,
+		"user verification failed, e.g. the fingerprint was not recognized",
+	},
+	ctaphid.StatusCTAP2ErrPinBlocked: {
+		exitcode.PINBlocked,
+		"the PIN is blocked after too many wrong attempts, the security key can only be used again after 'skm reset', " +
+			"which deletes all its credentials",
+	},
+	ctaphid.StatusCTAP2ErrPinAuthBlocked: {
+		exitcode.PINBlocked,
+		"too many wrong PINs in a row, unplug the security key and plug it back in before trying again",
+	},
+	ctaphid.StatusCTAP2ErrUVBlocked: {
+		exitcode.PINBlocked,
+		"built-in user verification is blocked after too many failures, use the PIN to unblock it",
+	},
+	ctaphid.StatusCTAP2ErrPinNotSet: {
+		exitcode.AuthRequired,
+		"no PIN is set on the security key, set one with 'skm pin set'",
+	},
+	ctaphid.StatusCTAP2ErrPUATRequired: {exitcode.AuthRequired, "the security key requires a PIN for this operation"},
+	ctaphid.StatusCTAP2ErrPinPolicyViolation: {
+		exitcode.Usage,
+		"the new PIN does not meet the PIN policy of the security key, e.g. its minimum length",
+	},
+	ctaphid.StatusCTAP2ErrUnsupportedOption:    {exitcode.Unsupported, "the security key does not support this option"},
+	ctaphid.StatusCTAP2ErrUnsupportedAlgorithm: {exitcode.Unsupported, "the security key does not support this algorithm"},
+	ctaphid.StatusCTAP1ErrInvalidCommand:       {exitcode.Unsupported, "the security key does not support this command"},
+	ctaphid.StatusCTAP2ErrOperationDenied:      {exitcode.Denied, "the operation was declined on the security key"},
+	ctaphid.StatusCTAP2ErrNotAllowed: {
+		exitcode.Denied,
+		"the security key does not allow this operation in its current state",
+	},
+	ctaphid.StatusCTAP2ErrKeepaliveCancel: {exitcode.Denied, "the operation was canceled"},
+	ctaphid.StatusCTAP2ErrUserActionTimeout: {
+		exitcode.Timeout,
+		"the security key was not touched in time, touch it as soon as it blinks",
+	},
+	ctaphid.StatusCTAP2ErrActionTimeout: {exitcode.Timeout, "the security key was not touched in time"},
+	ctaphid.StatusCTAP1ErrTimeout:       {exitcode.Timeout, "the security key did not respond in time"},
+	ctaphid.StatusCTAP1ErrChannelBusy:   {exitcode.DeviceBusy, "the security key is busy with another application"},
+	ctaphid.StatusCTAP2ErrKeyStoreFull: {
+		exitcode.StorageFull,
+		"the security key has no room left for credentials, free some with 'skm creds delete'",
+	},
+	ctaphid.StatusCTAP2ErrLargeBlobStorageFull: {
+		exitcode.StorageFull,
+		"the large blob storage of the security key is full, free some with 'skm blob delete'",
+	},
+}
+
+// sentinelErrors maps the errors of skm and its libraries to their exit code and a hint, which is empty for
+// errors whose message says it all.
+var sentinelErrors = []struct {
+	err  error
+	code int
+	hint string
+}{
+	{prompts.ErrCanceled, exitcode.Canceled, ""},
+	{context.Canceled, exitcode.Canceled, "canceled"},
+	{device.ErrNoDevices, exitcode.NoDevice, ""},
+	{device.ErrNoMatch, exitcode.NoDevice, ""},
+	{device.ErrIndexOutOfRange, exitcode.NoDevice, ""},
+	{device.ErrAmbiguous, exitcode.NoDevice, ""},
+	{
+		hid.ErrDeviceLocked,
+		exitcode.DeviceBusy,
+		"the security key is in use by another application, close it and try again",
+	},
+	{ctaphid.ErrDeviceBusy, exitcode.DeviceBusy, "the security key is busy with another application"},
+	{ctaphid.ErrTimeout, exitcode.Timeout, "the security key did not respond in time"},
+	{fido2.ErrPinNotSet, exitcode.AuthRequired, "no PIN is set on the security key, set one with 'skm pin set'"},
+	{fido2.ErrPinUvAuthTokenRequired, exitcode.AuthRequired, "the security key requires a PIN for this operation"},
+	{fido2.ErrNotSupported, exitcode.Unsupported, ""},
+	{fido2.ErrUvNotConfigured, exitcode.Unsupported, ""},
+	{fido2.ErrLargeBlobsTooBig, exitcode.StorageFull, "the large blob storage of the security key is full"},
+}
+
+// classify turns the error of a command into an exitcode.Error with a message telling the user what to do about
+// it. Errors that already carry an exit code are returned as is.
+func classify(err error) *exitcode.Error {
+	var exitErr *exitcode.Error
+	if errors.As(err, &exitErr) {
+		return exitErr
+	}
+
+	var ctapErr *ctaphid.CTAPError
+	if errors.As(err, &ctapErr) {
+		se, ok := statusErrors[ctapErr.StatusCode]
+		if !ok {
+			return exitcode.New(exitcode.Failure, err)
+		}
+
+		hint := se.hint
+		var pinErr *authenticator.InvalidPINError
+		if errors.As(err, &pinErr) {
+			hint = fmt.Sprintf("%s, %d %s left", hint, pinErr.Retries, plural(pinErr.Retries, "retry", "retries"))
+		}
+		return exitcode.New(se.code, fmt.Errorf("%s (%s): %w", hint, ctapErr.StatusCode, err))
+	}
+
+	for _, s := range sentinelErrors {
+		if errors.Is(err, s.err) {
+			if s.hint == "" {
+				return exitcode.New(s.code, err)
+			}
+			return exitcode.New(s.code, fmt.Errorf("%s: %w", s.hint, err))
+		}
+	}
+
+	return exitcode.New(exitcode.Failure, err)
+}
+
+func plural(n uint, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,184 @@
+package skm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/go-fido2/transport/hid"
+	"github.com/mohammadv184/skm/internal/authenticator/authenticatortest"
+	"github.com/mohammadv184/skm/internal/skm/exitcode"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+)
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		noDevices  bool
+		openErr    error
+		args       []string
+		wantCode   int
+		wantStderr string
+	}{
+		{
+			name: "success",
+			args: []string{"list"},
+		},
+		{
+			name:       "wrong PIN",
+			args:       []string{"config", "always-uv", "--pin", "0000", "--enable"},
+			wantCode:   exitcode.PINInvalid,
+			wantStderr: "Error: the PIN is incorrect, 7 retries left (CTAP2_ERR_PIN_INVALID)",
+		},
+		{
+			name:       "no security keys",
+			noDevices:  true,
+			args:       []string{"info"},
+			wantCode:   exitcode.NoDevice,
+			wantStderr: "Error: no security keys found",
+		},
+		{
+			name:       "security key in use",
+			openErr:    hid.ErrDeviceLocked,
+			args:       []string{"config", "always-uv", "--status"},
+			wantCode:   exitcode.DeviceBusy,
+			wantStderr: "Error: the security key is in use by another application, close it and try again",
+		},
+		{
+			name:       "unknown flag",
+			args:       []string{"list", "--bogus"},
+			wantCode:   exitcode.Usage,
+			wantStderr: "Error: unknown flag: --bogus\nRun 'skm list --help' for usage",
+		},
+		{
+			name:       "output format of a command without documents",
+			args:       []string{"pin", "retries", "--output", "json"},
+			wantCode:   exitcode.Usage,
+			wantStderr: "Error: unknown flag: --output",
+		},
+		{
+			name:       "unknown command",
+			args:       []string{"bogus"},
+			wantCode:   exitcode.Usage,
+			wantStderr: "Run 'skm --help' for usage",
+		},
+		{
+			name:       "conflicting flags",
+			args:       []string{"config", "always-uv", "--enable", "--disable"},
+			wantCode:   exitcode.Usage,
+			wantStderr: "Run 'skm config always-uv --help' for usage",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := authenticatortest.NewBackend(t)
+			if !tt.noDevices {
+				b = newBackend(t, true)
+			}
+			b.OpenErr = tt.openErr
+
+			r := runCommand(t, b, tt.args...)
+
+			var code int
+			if r.err != nil {
+				var exitErr *exitcode.Error
+				if !errors.As(r.err, &exitErr) {
+					t.Fatalf("error = %v, want an *exitcode.Error", r.err)
+				}
+				code = exitErr.Code
+			}
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (error %v)", code, tt.wantCode, r.err)
+			}
+			if !strings.Contains(r.stderr, tt.wantStderr) {
+				t.Errorf("stderr does not contain %q:\n%s", tt.wantStderr, r.stderr)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	ctapErr := func(status ctaphid.StatusCode) error {
+		return fmt.Errorf("failed to reset: %w", &ctaphid.CTAPError{Command: ctaphid.CmdCBOR, StatusCode: status})
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantMsg  string
+	}{
+		{
+			name:     "PIN blocked",
+			err:      ctapErr(ctaphid.StatusCTAP2ErrPinBlocked),
+			wantCode: exitcode.PINBlocked,
+			wantMsg:  "after 'skm reset'",
+		},
+		{
+			name:     "PIN auth blocked",
+			err:      ctapErr(ctaphid.StatusCTAP2ErrPinAuthBlocked),
+			wantCode: exitcode.PINBlocked,
+			wantMsg:  "unplug the security key and plug it back in",
+		},
+		{
+			name:     "PIN not set",
+			err:      ctapErr(ctaphid.StatusCTAP2ErrPinNotSet),
+			wantCode: exitcode.AuthRequired,
+			wantMsg:  "set one with 'skm pin set' (CTAP2_ERR_PIN_NOT_SET)",
+		},
+		{
+			name:     "key store full",
+			err:      ctapErr(ctaphid.StatusCTAP2ErrKeyStoreFull),
+			wantCode: exitcode.StorageFull,
+			wantMsg:  "'skm creds delete'",
+		},
+		{
+			name:     "touch timeout",
+			err:      ctapErr(ctaphid.StatusCTAP2ErrUserActionTimeout),
+			wantCode: exitcode.Timeout,
+			wantMsg:  "not touched in time",
+		},
+		{
+			name:     "unknown status",
+			err:      ctapErr(ctaphid.StatusCTAP2ErrInvalidCBOR),
+			wantCode: exitcode.Failure,
+			wantMsg:  "failed to reset: CTAPHID_CBOR failed (CTAP2_ERR_INVALID_CBOR)",
+		},
+		{
+			name:     "canceled prompt",
+			err:      prompts.ErrCanceled,
+			wantCode: exitcode.Canceled,
+			wantMsg:  "canceled",
+		},
+		{
+			name:     "exit code kept",
+			err:      fmt.Errorf("audit: %w", exitcode.New(2, errors.New("1 of 2 security keys do not comply"))),
+			wantCode: 2,
+			wantMsg:  "1 of 2 security keys do not comply",
+		},
+		{
+			name:     "other error",
+			err:      errors.New("open policy.yaml: no such file or directory"),
+			wantCode: exitcode.Failure,
+			wantMsg:  "open policy.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classify(tt.err)
+			if got.Code != tt.wantCode {
+				t.Errorf("classify(%v) code = %d, want %d", tt.err, got.Code, tt.wantCode)
+			}
+			if !strings.Contains(got.Error(), tt.wantMsg) {
+				t.Errorf("classify(%v) = %q, want it to contain %q", tt.err, got.Error(), tt.wantMsg)
+			}
+			if !errors.Is(got, tt.err) && !errors.Is(tt.err, got) {
+				t.Errorf("classify(%v) = %v, want it to wrap the error", tt.err, got)
+			}
+		})
+	}
+}
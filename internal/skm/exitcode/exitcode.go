@@ -0,0 +1,63 @@
+// Package exitcode provides errors that carry a process exit code for skm, and the exit codes themselves.
+//
+// The exit codes are stable: scripts may rely on them. Codes 2 to 9 are reserved for the outcome of the
+// reporting commands, such as 'skm audit' and 'skm pin retries', which document their own.
+package exitcode
+
+// Exit codes shared by all commands.
+const (
+	// OK means the command succeeded.
+	OK = 0
+	// Failure is any error that has no more specific exit code.
+	Failure = 1
+	// Usage means invalid flags or arguments, including a new PIN rejected by the PIN policy.
+	Usage = 10
+	// NoDevice means no security key is connected or matches the selectors, or several do and none was picked.
+	NoDevice = 11
+	// DeviceBusy means the security key is in use by another application.
+	DeviceBusy = 12
+	// PINInvalid means the PIN, or the built-in user verification, was wrong.
+	PINInvalid = 13
+	// PINBlocked means the PIN or the built-in user verification is blocked, until the security key is
+	// replugged or reset.
+	PINBlocked = 14
+	// AuthRequired means the operation requires a PIN that is not set or was not given.
+	AuthRequired = 15
+	// Unsupported means the security key does not support the operation.
+	Unsupported = 16
+	// Denied means the security key refused or canceled the operation.
+	Denied = 17
+	// Timeout means the security key was not touched, or did not respond, in time.
+	Timeout = 18
+	// StorageFull means the security key has no room left for credentials or large blobs.
+	StorageFull = 19
+	// Canceled means the user canceled the command, e.g. with Ctrl+C.
+	Canceled = 130
+)
+
+// Error is an error that carries the exit code the process should terminate with.
+type Error struct {
+	Code int
+	Err  error
+}
+
+// New creates a new Error with the given exit code.
+func New(code int, err error) *Error {
+	return &Error{
+		Code: code,
+		Err:  err,
+	}
+}
+
+// Error returns the message of the wrapped error.
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
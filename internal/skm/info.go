@@ -1,93 +1,98 @@
 package skm
 
 import (
-	"fmt"
 	"strings"
 
-	"github.com/mohammadv184/go-fido2"
-	"github.com/mohammadv184/skm/internal/skm/completion"
-	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/ui/output"
 	"github.com/mohammadv184/skm/internal/ui/views"
 	"github.com/spf13/cobra"
 )
 
-var infoCMD = cobra.Command{
-	Use:   "info",
-	Short: "Show information about a security key",
-	Long:  `Display detailed technical information about a selected security key, including AAGUID, supported versions, extensions, and protocol options.`,
-	Example: `  skm info
-  skm info --all
-  skm info --device-path /dev/hidraw0`,
-	RunE: infoHandler,
+// infoOptions holds the flags of the info command.
+type infoOptions struct {
+	device device.Selector
+	all    bool
 }
 
-var (
-	infoDevicePath string
-	infoAll        bool
-)
+// newInfoCommand returns the info command.
+func newInfoCommand() *cobra.Command {
+	var opts infoOptions
+	cmd := &cobra.Command{
+		Use:   "info",
+		Short: "Show information about a security key",
+		Long:  `Display detailed technical information about a selected security key, including AAGUID, model, supported versions, extensions, and protocol options.`,
+		Example: `  skm info
+  skm info --all
+  skm info --device-path /dev/hidraw0
+  skm info --product 'YubiKey*' --all
+  skm info --all --output yaml`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return infoHandler(cmd, &opts)
+		},
+	}
 
-func init() {
-	infoCMD.Flags().StringVarP(&infoDevicePath, "device-path", "d", "", "Path to the security key device")
-	_ = infoCMD.RegisterFlagCompletionFunc("device-path", completion.CompleteDevicePath)
-	infoCMD.Flags().BoolVarP(&infoAll, "all", "a", false, "Show information for all connected security keys")
-	rootCMD.AddCommand(&infoCMD)
+	opts.device.RegisterFlags(cmd)
+	output.RegisterFlag(cmd)
+	cmd.Flags().BoolVarP(&opts.all, "all", "a", false, "Show information for all matching security keys")
+	return cmd
 }
 
-func infoHandler(cmd *cobra.Command, _ []string) error {
-	devs, err := fido2.Enumerate()
+func infoHandler(cmd *cobra.Command, opts *infoOptions) error {
+	format, err := output.FromCommand(cmd)
 	if err != nil {
 		return err
 	}
 
-	if len(devs) == 0 {
-		cmd.Println("No security keys found.")
-		return nil
+	selectedDevs, err := opts.device.ResolveAll(opts.all)
+	if err != nil {
+		return err
 	}
 
-	var selectedDevs []fido2.DeviceDescriptor
-
-	if infoAll {
-		selectedDevs = devs
-	} else if infoDevicePath != "" {
-		for _, dev := range devs {
-			if dev.Path == infoDevicePath {
-				selectedDevs = append(selectedDevs, dev)
-				break
-			}
-		}
-		if len(selectedDevs) == 0 {
-			return fmt.Errorf("device not found at path: %s", infoDevicePath)
-		}
-	} else {
-		cmd.Println("Select a security key to view its information:")
-
-		selected, err := prompts.NewDeviceSelectPrompt().WithDevices(devs...).Run()
-		if err != nil {
-			return err
-		}
-		selectedDevs = append(selectedDevs, *selected)
-	}
+	db := loadModels(cmd)
+	infos := make([]output.DeviceInfo, 0, len(selectedDevs))
 
 	for i, sd := range selectedDevs {
-		if i > 0 {
+		if i > 0 && format == output.FormatText {
 			cmd.Println("\n" + strings.Repeat("-", 40) + "\n")
 		}
 
-		dev, err := fido2.Open(sd)
+		dev, err := authenticator.Open(cmd.Context(), sd)
 		if err != nil {
-			cmd.Printf("Error opening device %s: %v\n", sd.Path, err)
+			cmd.PrintErrf("Error opening device %s: %v\n", sd.Path, err)
 			continue
 		}
 
 		info := dev.Info()
 
-		pinRetries, _, _ := dev.GetPINRetries()
+		var retries output.Retries
+		pinRetries, powerCycle, err := dev.GetPINRetries()
+		if err == nil {
+			retries.PIN = &pinRetries
+			retries.PowerCycleRequired = powerCycle
+		}
 		uvRetries, err := dev.GetUVRetries()
 		hasUV := err == nil
+		if hasUV {
+			retries.UV = &uvRetries
+		}
+		_ = dev.Close()
+		model := db.Lookup(info.AAGUID)
 
-		v := views.NewDeviceInfoView(&sd, info).WithRetries(pinRetries, uvRetries, hasUV)
+		if format != output.FormatText {
+			di := output.NewDeviceInfo(&sd, info, retries)
+			di.Device.Model = output.NewModel(model)
+			infos = append(infos, di)
+			continue
+		}
+
+		v := views.NewDeviceInfoView(&sd, info).WithRetries(pinRetries, uvRetries, hasUV).WithModel(model)
 		cmd.Println(v.Render())
-		_ = dev.Close()
+	}
+
+	if format != output.FormatText {
+		return output.Write(cmd.OutOrStdout(), format, output.NewDeviceInfoList(infos...))
 	}
 
 	return nil
@@ -0,0 +1,83 @@
+package skm
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/authenticator/authenticatortest"
+)
+
+func TestInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		devices int
+		withPIN bool
+		openErr error
+		args    []string
+		wantOut []string
+		wantErr string
+	}{
+		{
+			name:    "single security key",
+			devices: 1,
+			withPIN: true,
+			wantOut: []string{"Security Key Information", "Test Key 0", "/dev/hidraw0", "PIN Retries:", "8"},
+		},
+		{
+			name:    "all as yaml",
+			devices: 2,
+			args:    []string{"--all", "-o", "yaml"},
+			wantOut: []string{"schemaVersion: 1", "path: /dev/hidraw0", "path: /dev/hidraw1", "product: Test Key 1"},
+		},
+		{
+			name:    "selected by index",
+			devices: 2,
+			args:    []string{"--device-index", "1", "-o", "json"},
+			wantOut: []string{`"path": "/dev/hidraw1"`},
+		},
+		{
+			name:    "index out of range",
+			devices: 1,
+			args:    []string{"--device-index", "3"},
+			wantErr: "device index out of range: 3, 1 security keys found",
+		},
+		{
+			name:    "ambiguous",
+			devices: 2,
+			wantErr: "multiple security keys found",
+		},
+		{
+			name:    "no security keys",
+			wantErr: "no security keys found",
+		},
+		{
+			name:    "open failure",
+			devices: 1,
+			openErr: errors.New("device busy"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := authenticatortest.NewBackend(t)
+			b.OpenErr = tt.openErr
+			for i := range tt.devices {
+				a := b.Add(fido2.DeviceDescriptor{Product: "Test Key " + strconv.Itoa(i)})
+				if tt.withPIN {
+					if err := a.SetPIN(testPIN); err != nil {
+						t.Fatalf("SetPIN() error = %v", err)
+					}
+				}
+			}
+
+			res := runCommand(t, b, append([]string{"info"}, tt.args...)...)
+			res.check(t, tt.wantOut, tt.wantErr)
+			if tt.openErr != nil && !strings.Contains(res.stderr, "Error opening device /dev/hidraw0: device busy") {
+				t.Errorf("stderr = %q, want the open error", res.stderr)
+			}
+		})
+	}
+}
@@ -1,36 +1,142 @@
 package skm
 
 import (
-	"github.com/mohammadv184/go-fido2"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/ui/output"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
 	"github.com/mohammadv184/skm/internal/ui/views"
 	"github.com/spf13/cobra"
 )
 
-var listCMD = cobra.Command{
-	Use:     "list",
-	Short:   "List connected security keys",
-	Long:    `Enumerate and display all FIDO2 security keys currently connected to the system. It shows the device path, product name, manufacturer, and serial number.`,
-	Example: `  skm list`,
-	RunE:    listHandler,
+// listOptions holds the flags of the list command.
+type listOptions struct {
+	device     device.Selector
+	watch      bool
+	jsonEvents bool
+	interval   time.Duration
 }
 
-func init() {
-	rootCMD.AddCommand(&listCMD)
+// newListCommand returns the list command.
+func newListCommand() *cobra.Command {
+	var opts listOptions
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List connected security keys",
+		Long: `Enumerate and display all FIDO2 security keys currently connected to the system. It shows the device path, product name, manufacturer, and serial number.
+The model of every security key that can be opened is looked up by its AAGUID, see 'skm mds'.
+The device selector flags can be used to narrow down the list.
+With --watch, the list is kept up to date as security keys are plugged in and removed, and every change is
+logged with its time. With --json-events, every change is printed instead as a line of JSON (NDJSON), starting
+with the security keys already connected. Changes are detected from kernel events on Linux, and by polling
+elsewhere.`,
+		Example: `  skm list
+  skm list --product 'YubiKey*'
+  skm list --output json
+  skm list --watch
+  skm list --json-events`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return listHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	output.RegisterFlag(cmd)
+	cmd.Flags().BoolVarP(&opts.watch, "watch", "w", false,
+		"Keep watching for security keys being plugged in and removed")
+	cmd.Flags().BoolVar(&opts.jsonEvents, "json-events", false,
+		"Watch for security keys and print every attach and detach event as a line of JSON")
+	cmd.Flags().DurationVar(&opts.interval, "interval", time.Second,
+		"How often to poll for security keys when watching")
+	return cmd
 }
 
-func listHandler(cmd *cobra.Command, _ []string) error {
-	devs, err := fido2.Enumerate()
+func listHandler(cmd *cobra.Command, opts *listOptions) error {
+	format, err := output.FromCommand(cmd)
 	if err != nil {
 		return err
 	}
 
+	if opts.watch || opts.jsonEvents {
+		return watchDevices(cmd, format, opts)
+	}
+
+	devs, err := opts.device.Wait()
+	if err != nil {
+		return err
+	}
+
+	if len(devs) == 0 && opts.device.IsSet() {
+		return device.ErrNoMatch
+	}
+
+	models := deviceModels(cmd, devs)
+
+	if format != output.FormatText {
+		l := output.NewDeviceList(devs...)
+		for i := range l.Devices {
+			l.Devices[i].Model = output.NewModel(models[l.Devices[i].Path])
+		}
+		return output.Write(cmd.OutOrStdout(), format, l)
+	}
+
 	if len(devs) == 0 {
 		cmd.Println("No security keys found.")
 		return nil
 	}
 
-	t := views.NewDevicesListView().WithDevices(devs...)
+	t := views.NewDevicesListView().WithDevices(devs...).WithModels(models)
 
 	cmd.Println(t.Render())
 	return nil
 }
+
+// watchDevices reports the security keys being plugged in and removed until interrupted.
+func watchDevices(cmd *cobra.Command, format output.Format, opts *listOptions) error {
+	if !opts.jsonEvents && format != output.FormatText {
+		return errors.New("--watch only supports text output, use --json-events for machine-readable events")
+	}
+	if opts.interval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	events := authenticator.Watch(ctx, opts.device.Match, opts.interval)
+
+	if !opts.jsonEvents && isTerminal(cmd.OutOrStdout()) {
+		return prompts.NewDeviceWatchPrompt(events).Run()
+	}
+
+	for e, err := range events {
+		if err != nil {
+			cmd.PrintErrf("Error enumerating security keys: %v\n", err)
+			continue
+		}
+
+		if opts.jsonEvents {
+			if err := output.NewDeviceEvent(&e).WriteLine(cmd.OutOrStdout()); err != nil {
+				return err
+			}
+			continue
+		}
+		cmd.Printf("%s  %-8s  %s  %s\n", e.Time.Format(time.TimeOnly), e.Type, e.Device.Path, e.Device.Product)
+	}
+
+	return nil
+}
+
+// isTerminal reports whether w is a terminal, on which the live views can be shown.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(f.Fd())
+}
@@ -0,0 +1,135 @@
+package skm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/authenticator/authenticatortest"
+)
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		name    string
+		devices []fido2.DeviceDescriptor
+		err     error
+		args    []string
+		wantOut []string
+		wantErr string
+	}{
+		{
+			name:    "no security keys",
+			wantOut: []string{"No security keys found."},
+		},
+		{
+			name: "table",
+			devices: []fido2.DeviceDescriptor{
+				{Product: "YubiKey 5", Manufacturer: "Yubico", SerialNumber: "111"},
+				{Product: "Solo 2", Manufacturer: "SoloKeys", SerialNumber: "222"},
+			},
+			wantOut: []string{"PATH", "/dev/hidraw0", "YubiKey 5", "/dev/hidraw1", "Solo 2", "SoloKeys", "222"},
+		},
+		{
+			name: "product filter",
+			devices: []fido2.DeviceDescriptor{
+				{Product: "YubiKey 5"},
+				{Product: "Solo 2"},
+			},
+			args:    []string{"--product", "solo*", "--output", "csv"},
+			wantOut: []string{"product\n/dev/hidraw1,0,0,,,Solo 2\n"},
+		},
+		{
+			name:    "json",
+			devices: []fido2.DeviceDescriptor{{Product: "YubiKey 5", SerialNumber: "111"}},
+			args:    []string{"-o", "json"},
+			wantOut: []string{`"schemaVersion": 1`, `"path": "/dev/hidraw0"`, `"serialNumber": "111"`},
+		},
+		{
+			name:    "no match",
+			devices: []fido2.DeviceDescriptor{{Product: "YubiKey 5"}},
+			args:    []string{"--serial", "999"},
+			wantErr: "no security key matches the given selectors",
+		},
+		{
+			name:    "invalid output format",
+			args:    []string{"--output", "xml"},
+			wantErr: `unsupported output format "xml"`,
+		},
+		{
+			name:    "enumeration failure",
+			err:     errors.New("hid: enumeration failed"),
+			wantErr: "hid: enumeration failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := authenticatortest.NewBackend(t)
+			b.EnumerateErr = tt.err
+			for _, d := range tt.devices {
+				b.Add(d)
+			}
+
+			runCommand(t, b, append([]string{"list"}, tt.args...)...).check(t, tt.wantOut, tt.wantErr)
+		})
+	}
+}
+
+func TestListWatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantOut []string
+		notOut  string
+		wantErr string
+	}{
+		{
+			name: "json events",
+			args: []string{"--json-events"},
+			wantOut: []string{
+				`{"schemaVersion":1,"kind":"DeviceEvent","type":"attach","timestamp":"`,
+				`"device":{"path":"/dev/hidraw0","vendorId":0,"productId":0,"serialNumber":"111",`,
+				`"device":{"path":"/dev/hidraw1",`,
+			},
+		},
+		{
+			name:    "text events",
+			args:    []string{"--watch", "--product", "solo*"},
+			wantOut: []string{"attach    /dev/hidraw1  Solo 2\n"},
+			notOut:  "YubiKey",
+		},
+		{
+			name:    "machine-readable output",
+			args:    []string{"--watch", "-o", "json"},
+			wantErr: "--watch only supports text output",
+		},
+		{
+			name:    "invalid interval",
+			args:    []string{"--watch", "--interval", "0s"},
+			wantErr: "--interval must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := authenticatortest.NewBackend(t)
+			b.Add(fido2.DeviceDescriptor{Product: "YubiKey 5", SerialNumber: "111"})
+			b.Add(fido2.DeviceDescriptor{Product: "Solo 2", SerialNumber: "222"})
+
+			// Watching goes on until the context is done.
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			args := append([]string{"list", "--interval", "10ms"}, tt.args...)
+			r := runCommandContext(t, ctx, b, args...)
+			r.check(t, tt.wantOut, tt.wantErr)
+
+			if tt.notOut != "" && strings.Contains(r.stdout, tt.notOut) {
+				t.Errorf("output contains %q:\n%s", tt.notOut, r.stdout)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package skm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mohammadv184/skm/internal/mds"
+)
+
+func TestModels(t *testing.T) {
+	b := newBackend(t, false)
+	a := b.Virtual(testDevicePath)
+
+	t.Setenv(mds.EnvFile, filepath.Join(t.TempDir(), "mds.json"))
+	if err := mds.Save(&mds.Metadata{
+		Serial:     42,
+		NextUpdate: "2099-01-01",
+		Models: []mds.Model{{
+			AAGUID:        a.Info().AAGUID,
+			Vendor:        "skm",
+			Name:          "Test Key Pro",
+			Certification: "FIDO_CERTIFIED_L1",
+			StatusReports: []mds.StatusReport{
+				{Status: "FIDO_CERTIFIED_L1", EffectiveDate: "2020-01-01"},
+				{Status: "USER_VERIFICATION_BYPASS", EffectiveDate: "2021-06-01"},
+			},
+		}},
+	}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantOut []string
+	}{
+		{
+			name: "info",
+			args: []string{"info"},
+			wantOut: []string{
+				"Model:", "skm Test Key Pro", "Certification:", "FIDO_CERTIFIED_L1",
+				"USER_VERIFICATION_BYPASS since 2021-06-01",
+			},
+		},
+		{
+			name:    "info as json",
+			args:    []string{"info", "-o", "json"},
+			wantOut: []string{`"name": "Test Key Pro"`, `"status": "USER_VERIFICATION_BYPASS"`, `"securityIssue": true`},
+		},
+		{
+			name:    "list",
+			args:    []string{"list"},
+			wantOut: []string{"MODEL", "skm Test Key Pro"},
+		},
+		{
+			name:    "list as yaml",
+			args:    []string{"list", "-o", "yaml"},
+			wantOut: []string{"certification: FIDO_CERTIFIED_L1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runCommand(t, b, tt.args...).check(t, tt.wantOut, "")
+		})
+	}
+}
+
+func TestMDSImport(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(mds.EnvFile, filepath.Join(dir, "mds.json"))
+
+	blob := filepath.Join(dir, "blob.jwt")
+	if err := os.WriteFile(blob, []byte("not a blob"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "invalid BLOB", args: []string{blob}, wantErr: "the MDS BLOB is not a JWT"},
+		{name: "missing file", args: []string{filepath.Join(dir, "missing.jwt")}, wantErr: "no such file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := runCommand(t, newBackend(t, false), append([]string{"mds", "import"}, tt.args...)...)
+			res.check(t, nil, tt.wantErr)
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "mds.json")); err == nil || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("Stat() error = %v, want the metadata not saved", err)
+	}
+}
@@ -2,71 +2,49 @@ package pin
 
 import (
 	"errors"
-	"fmt"
 
-	"github.com/mohammadv184/go-fido2"
-	"github.com/mohammadv184/skm/internal/skm/completion"
+	"github.com/mohammadv184/skm/internal/agent"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
 	"github.com/mohammadv184/skm/internal/ui/prompts"
 	"github.com/spf13/cobra"
 )
 
-var changeCMD = cobra.Command{
-	Use:   "change",
-	Short: "Change an existing PIN on a security key",
-	Long:  "Change an existing PIN on a security key. You will be prompted for your current PIN and then your new PIN.",
-	Example: `  skm pin change
-  skm pin change --device-path /dev/hidraw0 --pin 123456 --new-pin 654321`,
-	RunE: changeHandler,
+// changeOptions holds the flags of the pin change command.
+type changeOptions struct {
+	device device.Selector
+	pin    pinflag.Source
+	newPin pinflag.Source
 }
 
-var (
-	changeDevicePath string
-	changePin        string
-	changeNewPin     string
-)
+// newChangeCommand returns the pin change command.
+func newChangeCommand() *cobra.Command {
+	var opts changeOptions
+	cmd := &cobra.Command{
+		Use:   "change",
+		Short: "Change an existing PIN on a security key",
+		Long:  "Change an existing PIN on a security key. You will be prompted for your current PIN and then your new PIN.",
+		Example: `  skm pin change
+  skm pin change --device-path /dev/hidraw0 --pin 123456 --new-pin 654321`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return changeHandler(cmd, &opts)
+		},
+	}
 
-func init() {
-	changeCMD.Flags().StringVarP(&changeDevicePath, "device-path", "d", "", "Path to the security key device")
-	_ = changeCMD.RegisterFlagCompletionFunc("device-path", completion.CompleteDevicePath)
-	changeCMD.Flags().StringVarP(&changePin, "pin", "p", "", "Current PIN for the security key")
-	changeCMD.Flags().StringVarP(&changeNewPin, "new-pin", "n", "", "New PIN for the security key")
-	rootCMD.AddCommand(&changeCMD)
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "Current PIN for the security key")
+	opts.newPin.RegisterNewFlags(cmd, "New PIN for the security key")
+	return cmd
 }
 
-func changeHandler(cmd *cobra.Command, _ []string) error {
-	var selectedDev *fido2.DeviceDescriptor
-
-	if changeDevicePath != "" {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-		for _, dev := range devs {
-			if dev.Path == changeDevicePath {
-				selectedDev = &dev
-				break
-			}
-		}
-		if selectedDev == nil {
-			return fmt.Errorf("device not found at path: %s", changeDevicePath)
-		}
-	} else {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-
-		if len(devs) == 0 {
-			return errors.New("no security keys found")
-		}
-
-		selectedDev, err = prompts.NewDeviceSelectPrompt().WithDevices(devs...).Run()
-		if err != nil {
-			return err
-		}
+func changeHandler(cmd *cobra.Command, opts *changeOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
 	}
 
-	dev, err := fido2.Open(*selectedDev)
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
 	if err != nil {
 		return err
 	}
@@ -79,10 +57,14 @@ func changeHandler(cmd *cobra.Command, _ []string) error {
 		return errors.New("PIN is not set, use 'skm pin set' to set it")
 	}
 
-	currentPIN := changePin
+	currentPIN, err := opts.pin.Value()
+	if err != nil {
+		return err
+	}
 	if currentPIN == "" {
 		retries, _, _ := dev.GetPINRetries()
 		currentPIN, err = prompts.NewPinPrompt().
+			WithIO(cmd.InOrStdin(), cmd.ErrOrStderr()).
 			WithTitle("Enter Current PIN").
 			WithRetries(retries).
 			Run()
@@ -91,21 +73,22 @@ func changeHandler(cmd *cobra.Command, _ []string) error {
 		}
 	}
 
-	newPIN := changeNewPin
+	validate := Validator(info)
+	newPIN, err := opts.newPin.Value()
+	if err != nil {
+		return err
+	}
 	if newPIN == "" {
 		newPIN, err = prompts.NewPinPrompt().
+			WithIO(cmd.InOrStdin(), cmd.ErrOrStderr()).
 			WithTitle("Enter New PIN").
-			WithValidation(func(s string) error {
-				if len(s) < 4 {
-					return errors.New("PIN must be at least 4 characters long")
-				}
-				return nil
-			}).Run()
+			WithValidation(validate).Run()
 		if err != nil {
 			return err
 		}
 
 		_, err = prompts.NewPinPrompt().
+			WithIO(cmd.InOrStdin(), cmd.ErrOrStderr()).
 			WithTitle("Confirm New PIN").
 			WithValidation(func(s string) error {
 				if s != newPIN {
@@ -116,16 +99,16 @@ func changeHandler(cmd *cobra.Command, _ []string) error {
 		if err != nil {
 			return err
 		}
-	} else {
-		if len(newPIN) < 4 {
-			return errors.New("PIN must be at least 4 characters long")
-		}
+	} else if err := validate(newPIN); err != nil {
+		return err
 	}
 
 	err = dev.ChangePIN(currentPIN, newPIN)
 	if err != nil {
 		return err
 	}
+	// Changing the PIN invalidates the token of the key.
+	_ = agent.Forget(cmd.Context(), agent.Key(*selectedDev))
 
 	cmd.Println("PIN changed successfully.")
 	return nil
@@ -0,0 +1,148 @@
+package pin
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/exitcode"
+	"github.com/mohammadv184/skm/internal/ui/views"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// retriesExitLow is the exit code used when a key is low on retries or needs a power cycle.
+	retriesExitLow = 2
+	// retriesExitBlocked is the exit code used when a key has a blocked PIN.
+	retriesExitBlocked = 3
+	// retriesExitUnknown is the exit code used when the PIN retries of a key cannot be read.
+	retriesExitUnknown = 4
+)
+
+// retriesOptions holds the flags of the pin retries command.
+type retriesOptions struct {
+	device        device.Selector
+	all           bool
+	warnThreshold uint
+}
+
+// newRetriesCommand returns the pin retries command.
+func newRetriesCommand() *cobra.Command {
+	var opts retriesOptions
+	cmd := &cobra.Command{
+		Use:   "retries",
+		Short: "Show PIN and UV retries of a security key",
+		Long: `Display the remaining PIN and UV (e.g. fingerprint) retries of a security key, whether a power cycle
+is required before the PIN can be tried again, and whether the PIN is blocked.
+
+The command exits with 0 if all selected keys are healthy, 2 if any key has no more retries left than
+the warning threshold or requires a power cycle, 3 if any key has a blocked PIN, and 4 if the PIN retries of
+any key cannot be read. Keys without a PIN, or that do not support one, have no retries to run out of and are
+reported as "not set" or "unsupported" with 0.`,
+		Example: `  skm pin retries
+  skm pin retries --all
+  skm pin retries --device-path /dev/hidraw0 --warn-threshold 2`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return retriesHandler(cmd, &opts)
+		},
+	}
+
+	opts.device.RegisterFlags(cmd)
+	cmd.Flags().BoolVarP(&opts.all, "all", "a", false, "Show retries for all matching security keys")
+	cmd.Flags().
+		UintVarP(&opts.warnThreshold, "warn-threshold", "w", 3, "Report keys with this many retries or fewer as low")
+	return cmd
+}
+
+func retriesHandler(cmd *cobra.Command, opts *retriesOptions) error {
+	selectedDevs, err := opts.device.ResolveAll(opts.all)
+	if err != nil {
+		return err
+	}
+
+	v := views.NewPinRetriesView()
+
+	var lowCount, blockedCount, unknownCount int
+	for _, sd := range selectedDevs {
+		dev, err := authenticator.Open(cmd.Context(), sd)
+		if err != nil {
+			return fmt.Errorf("failed to open device %s: %w", sd.Path, err)
+		}
+
+		pinRetries, uvRetries, powerCycle, status := retriesState(dev, opts.warnThreshold)
+		_ = dev.Close()
+
+		switch status {
+		case "blocked":
+			blockedCount++
+		case "low":
+			lowCount++
+		case "unknown":
+			unknownCount++
+		}
+
+		v.WithDevice(&sd, pinRetries, uvRetries, powerCycle, status)
+	}
+
+	cmd.Println(v.Render())
+
+	switch {
+	case blockedCount > 0:
+		return exitcode.New(
+			retriesExitBlocked,
+			fmt.Errorf("%d of %d security keys have a blocked PIN", blockedCount, len(selectedDevs)),
+		)
+	case lowCount > 0:
+		return exitcode.New(
+			retriesExitLow,
+			fmt.Errorf("%d of %d security keys are low on retries", lowCount, len(selectedDevs)),
+		)
+	case unknownCount > 0:
+		return exitcode.New(
+			retriesExitUnknown,
+			fmt.Errorf("the PIN retries of %d of %d security keys cannot be read", unknownCount, len(selectedDevs)),
+		)
+	}
+
+	return nil
+}
+
+// retriesState reads the retry counters of dev and classifies them as "ok", "low" (at most warnThreshold),
+// "blocked", "not set", "unsupported" or "unknown". Counters that cannot be read are reported as "n/a".
+func retriesState(
+	dev authenticator.Device,
+	warnThreshold uint,
+) (pinRetries, uvRetries string, powerCycle bool, status string) {
+	pinRetries, uvRetries, status = "n/a", "n/a", "ok"
+
+	pinRetryCount, powerCycle, err := dev.GetPINRetries()
+	switch {
+	case errors.Is(err, fido2.ErrPinNotSet):
+		status = "not set"
+	case errors.Is(err, fido2.ErrNotSupported):
+		status = "unsupported"
+	case err != nil:
+		status = "unknown"
+	default:
+		pinRetries = strconv.FormatUint(uint64(pinRetryCount), 10)
+		switch {
+		case pinRetryCount == 0:
+			status = "blocked"
+		case pinRetryCount <= warnThreshold || powerCycle:
+			status = "low"
+		}
+	}
+
+	if uvRetryCount, err := dev.GetUVRetries(); err == nil {
+		uvRetries = strconv.FormatUint(uint64(uvRetryCount), 10)
+		if uvRetryCount <= warnThreshold && status == "ok" {
+			status = "low"
+		}
+	}
+
+	return pinRetries, uvRetries, powerCycle, status
+}
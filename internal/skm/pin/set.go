@@ -2,69 +2,46 @@ package pin
 
 import (
 	"errors"
-	"fmt"
 
-	"github.com/mohammadv184/go-fido2"
-	"github.com/mohammadv184/skm/internal/skm/completion"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
 	"github.com/mohammadv184/skm/internal/ui/prompts"
 	"github.com/spf13/cobra"
 )
 
-var setCMD = cobra.Command{
-	Use:   "set",
-	Short: "Set a new PIN on a security key",
-	Long:  "Set a new PIN on a security key that currently doesn't have one.",
-	Example: `  skm pin set
-  skm pin set --device-path /dev/hidraw0 --pin 123456`,
-	RunE: setHandler,
+// setOptions holds the flags of the pin set command.
+type setOptions struct {
+	device device.Selector
+	pin    pinflag.Source
 }
 
-var (
-	setDevicePath string
-	setPin        string
-)
+// newSetCommand returns the pin set command.
+func newSetCommand() *cobra.Command {
+	var opts setOptions
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "Set a new PIN on a security key",
+		Long:  "Set a new PIN on a security key that currently doesn't have one.",
+		Example: `  skm pin set
+  skm pin set --device-path /dev/hidraw0 --pin 123456`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return setHandler(cmd, &opts)
+		},
+	}
 
-func init() {
-	setCMD.Flags().StringVarP(&setDevicePath, "device-path", "d", "", "Path to the security key device")
-	_ = setCMD.RegisterFlagCompletionFunc("device-path", completion.CompleteDevicePath)
-	setCMD.Flags().StringVarP(&setPin, "pin", "p", "", "New PIN for the security key")
-	rootCMD.AddCommand(&setCMD)
+	opts.device.RegisterFlags(cmd)
+	opts.pin.RegisterFlags(cmd, "New PIN for the security key")
+	return cmd
 }
 
-func setHandler(cmd *cobra.Command, _ []string) error {
-	var selectedDev *fido2.DeviceDescriptor
-
-	if setDevicePath != "" {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-		for _, dev := range devs {
-			if dev.Path == setDevicePath {
-				selectedDev = &dev
-				break
-			}
-		}
-		if selectedDev == nil {
-			return fmt.Errorf("device not found at path: %s", setDevicePath)
-		}
-	} else {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-
-		if len(devs) == 0 {
-			return errors.New("no security keys found")
-		}
-
-		selectedDev, err = prompts.NewDeviceSelectPrompt().WithDevices(devs...).Run()
-		if err != nil {
-			return err
-		}
+func setHandler(cmd *cobra.Command, opts *setOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
 	}
 
-	dev, err := fido2.Open(*selectedDev)
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
 	if err != nil {
 		return err
 	}
@@ -77,21 +54,22 @@ func setHandler(cmd *cobra.Command, _ []string) error {
 		return errors.New("PIN is already set, use 'skm pin change' to update it")
 	}
 
-	newPIN := setPin
+	validate := Validator(info)
+	newPIN, err := opts.pin.Value()
+	if err != nil {
+		return err
+	}
 	if newPIN == "" {
 		newPIN, err = prompts.NewPinPrompt().
+			WithIO(cmd.InOrStdin(), cmd.ErrOrStderr()).
 			WithTitle("Enter New PIN").
-			WithValidation(func(s string) error {
-				if len(s) < 4 {
-					return errors.New("PIN must be at least 4 characters long")
-				}
-				return nil
-			}).Run()
+			WithValidation(validate).Run()
 		if err != nil {
 			return err
 		}
 
 		_, err = prompts.NewPinPrompt().
+			WithIO(cmd.InOrStdin(), cmd.ErrOrStderr()).
 			WithTitle("Confirm New PIN").
 			WithValidation(func(s string) error {
 				if s != newPIN {
@@ -102,10 +80,8 @@ func setHandler(cmd *cobra.Command, _ []string) error {
 		if err != nil {
 			return err
 		}
-	} else {
-		if len(newPIN) < 4 {
-			return errors.New("PIN must be at least 4 characters long")
-		}
+	} else if err := validate(newPIN); err != nil {
+		return err
 	}
 
 	err = dev.SetPIN(newPIN)
@@ -0,0 +1,42 @@
+package pin
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+)
+
+// defaultMinPINLength is the minimum PIN length of authenticators that don't report one.
+const defaultMinPINLength = 4
+
+// maxPINBytes is the maximum size of a UTF-8 encoded PIN accepted by CTAP2.
+const maxPINBytes = 63
+
+// Validator returns a function that checks a new PIN against the length policy of the authenticator.
+// Like CTAP, the minimum and the authenticator's maximum count Unicode code points, not bytes.
+func Validator(info *ctap2.AuthenticatorGetInfoResponse) func(string) error {
+	minLength := info.MinPinLength
+	if minLength == 0 {
+		minLength = defaultMinPINLength
+	}
+
+	return func(pin string) error {
+		if !utf8.ValidString(pin) {
+			return errors.New("PIN must be valid UTF-8")
+		}
+
+		length := uint(utf8.RuneCountInString(pin))
+		if length < minLength {
+			return fmt.Errorf("PIN must be at least %d characters long", minLength)
+		}
+		if info.MaxPINLength > 0 && length > info.MaxPINLength {
+			return fmt.Errorf("PIN must be at most %d characters long", info.MaxPINLength)
+		}
+		if len(pin) > maxPINBytes {
+			return fmt.Errorf("PIN must be at most %d bytes long when UTF-8 encoded", maxPINBytes)
+		}
+		return nil
+	}
+}
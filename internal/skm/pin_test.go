@@ -0,0 +1,312 @@
+package skm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/exitcode"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+)
+
+func TestPinSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		withPIN bool
+		args    []string
+		wantOut []string
+		wantErr string
+	}{
+		{
+			name:    "new PIN",
+			args:    []string{"--pin", "5678"},
+			wantOut: []string{"PIN set successfully."},
+		},
+		{
+			name:    "too short",
+			args:    []string{"--pin", "12"},
+			wantErr: "PIN must be at least 4 characters",
+		},
+		{
+			name:    "already set",
+			withPIN: true,
+			args:    []string{"--pin", "5678"},
+			wantErr: "PIN is already set, use 'skm pin change' to update it",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t, tt.withPIN)
+
+			runCommand(t, b, append([]string{"pin", "set"}, tt.args...)...).check(t, tt.wantOut, tt.wantErr)
+
+			if tt.wantErr == "" {
+				a := b.Virtual(testDevicePath)
+				if _, err := a.GetPinUvAuthTokenUsingPIN("5678", ctap2.PermissionCredentialManagement, ""); err != nil {
+					t.Errorf("new PIN rejected: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestPinChange(t *testing.T) {
+	tests := []struct {
+		name        string
+		withPIN     bool
+		args        []string
+		wantOut     []string
+		wantErr     string
+		wantRetries uint
+	}{
+		{
+			name:        "changed",
+			withPIN:     true,
+			args:        []string{"--pin", testPIN, "--new-pin", "5678"},
+			wantOut:     []string{"PIN changed successfully."},
+			wantRetries: 8,
+		},
+		{
+			name:        "wrong PIN",
+			withPIN:     true,
+			args:        []string{"--pin", "0000", "--new-pin", "5678"},
+			wantErr:     "CTAP2_ERR_PIN_INVALID",
+			wantRetries: 7,
+		},
+		{
+			name:        "new PIN too long",
+			withPIN:     true,
+			args:        []string{"--pin", testPIN, "--new-pin", strings.Repeat("1", 64)},
+			wantErr:     "PIN must be at most 63",
+			wantRetries: 8,
+		},
+		{
+			name:    "not set",
+			args:    []string{"--pin", testPIN, "--new-pin", "5678"},
+			wantErr: "PIN is not set, use 'skm pin set' to set it",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t, tt.withPIN)
+
+			runCommand(t, b, append([]string{"pin", "change"}, tt.args...)...).check(t, tt.wantOut, tt.wantErr)
+
+			if !tt.withPIN {
+				return
+			}
+			retries, _, err := b.Virtual(testDevicePath).GetPINRetries()
+			if err != nil {
+				t.Fatalf("GetPINRetries() error = %v", err)
+			}
+			if retries != tt.wantRetries {
+				t.Errorf("PIN retries = %d, want %d", retries, tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestPINInput(t *testing.T) {
+	pinFile := filepath.Join(t.TempDir(), "pin")
+	if err := os.WriteFile(pinFile, []byte(testPIN+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		env        map[string]string
+		stdin      string
+		args       []string
+		wantErr    string
+		wantStderr string
+	}{
+		{
+			name:  "stdin",
+			stdin: testPIN + "\n",
+			args:  []string{"--pin-stdin"},
+		},
+		{
+			name:  "stdin without line ending",
+			stdin: testPIN,
+			args:  []string{"--pin-stdin"},
+		},
+		{
+			name: "file",
+			args: []string{"--pin-file", pinFile},
+		},
+		{
+			name: "environment",
+			env:  map[string]string{pinflag.EnvPIN: testPIN},
+		},
+		{
+			name:       "flag",
+			args:       []string{"--pin", testPIN},
+			wantStderr: "Warning: a PIN given with --pin is visible in the shell history and the process list",
+		},
+		{
+			name:    "flag in strict mode",
+			env:     map[string]string{pinflag.EnvStrict: "true"},
+			args:    []string{"--pin", testPIN},
+			wantErr: "--pin is refused as SKM_PIN_STRICT is set",
+		},
+		{
+			name:       "prompt without a terminal",
+			env:        map[string]string{prompts.EnvPinProvider: "tty"},
+			stdin:      testPIN + "\n",
+			wantStderr: "Enter PIN: ",
+		},
+		{
+			name:    "empty stdin",
+			args:    []string{"--pin-stdin"},
+			wantErr: "failed to read the PIN from stdin: EOF",
+		},
+		{
+			name:    "empty line",
+			stdin:   "\n",
+			args:    []string{"--pin-stdin"},
+			wantErr: "the PIN read from stdin is empty",
+		},
+		{
+			name:    "missing file",
+			args:    []string{"--pin-file", filepath.Join(t.TempDir(), "missing")},
+			wantErr: "failed to read the PIN from",
+		},
+		{
+			name:    "several sources",
+			stdin:   testPIN + "\n",
+			args:    []string{"--pin", testPIN, "--pin-stdin"},
+			wantErr: "if any flags in the group [pin pin-stdin pin-file pin-fd] are set none of the others can be",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			b := newBackend(t, true)
+
+			r := runCommandInput(t, b, tt.stdin, append([]string{"creds", "list"}, tt.args...)...)
+			r.check(t, nil, tt.wantErr)
+
+			if tt.wantStderr != "" && !strings.Contains(r.stderr, tt.wantStderr) {
+				t.Errorf("stderr does not contain %q:\n%s", tt.wantStderr, r.stderr)
+			}
+			if tt.wantStderr == "" && strings.Contains(r.stderr, "Warning:") {
+				t.Errorf("unexpected warning:\n%s", r.stderr)
+			}
+		})
+	}
+}
+
+func TestPinChangeFromStdin(t *testing.T) {
+	b := newBackend(t, true)
+
+	r := runCommandInput(t, b, testPIN+"\n5678\n", "pin", "change", "--pin-stdin", "--new-pin-stdin")
+	r.check(t, []string{"PIN changed successfully."}, "")
+
+	a := b.Virtual(testDevicePath)
+	if _, err := a.GetPinUvAuthTokenUsingPIN("5678", ctap2.PermissionCredentialManagement, ""); err != nil {
+		t.Errorf("new PIN rejected: %v", err)
+	}
+}
+
+func TestPINNotKept(t *testing.T) {
+	t.Setenv(prompts.EnvPinProvider, "tty")
+	b := newBackend(t, true)
+
+	runCommand(t, b, "creds", "list", "--pin", testPIN).check(t, nil, "")
+
+	// Every execution builds its own commands, so neither the flag nor the PIN it gave is kept.
+	runCommand(t, b, "creds", "list").check(t, nil, "no PIN given on stdin")
+}
+
+// unreadableRetries is a security key whose PIN retries cannot be read.
+type unreadableRetries struct {
+	authenticator.Device
+}
+
+func (unreadableRetries) GetPINRetries() (uint, bool, error) {
+	return 0, false, errors.New("invalid response")
+}
+
+func TestPinRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		withPIN    bool
+		blocked    bool
+		unreadable bool
+		args       []string
+		wantOut    []string
+		wantErr    string
+		wantCode   int
+	}{
+		{
+			name:    "ok",
+			withPIN: true,
+			wantOut: []string{"8", "ok"},
+		},
+		{
+			name:    "not set",
+			wantOut: []string{"not set"},
+		},
+		{
+			name:     "low",
+			withPIN:  true,
+			args:     []string{"--warn-threshold", "8"},
+			wantOut:  []string{"low"},
+			wantErr:  "1 of 1 security keys are low on retries",
+			wantCode: 2,
+		},
+		{
+			name:     "blocked",
+			withPIN:  true,
+			blocked:  true,
+			wantOut:  []string{"blocked"},
+			wantErr:  "1 of 1 security keys have a blocked PIN",
+			wantCode: 3,
+		},
+		{
+			name:       "unknown",
+			withPIN:    true,
+			unreadable: true,
+			wantOut:    []string{"unknown"},
+			wantErr:    "the PIN retries of 1 of 1 security keys cannot be read",
+			wantCode:   4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t, tt.withPIN)
+			if tt.blocked {
+				a := b.Virtual(testDevicePath)
+				state := a.State()
+				state.PINRetries = 0
+				if err := state.Save(a.Path()); err != nil {
+					t.Fatalf("Save() error = %v", err)
+				}
+			}
+			if tt.unreadable {
+				b.Wrap = func(d authenticator.Device) authenticator.Device {
+					return unreadableRetries{Device: d}
+				}
+			}
+
+			r := runCommand(t, b, append([]string{"pin", "retries"}, tt.args...)...)
+			r.check(t, tt.wantOut, tt.wantErr)
+
+			var exitErr *exitcode.Error
+			if tt.wantCode != 0 && (!errors.As(r.err, &exitErr) || exitErr.Code != tt.wantCode) {
+				t.Errorf("error = %v, want exit code %d", r.err, tt.wantCode)
+			}
+		})
+	}
+}
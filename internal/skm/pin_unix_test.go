@@ -0,0 +1,26 @@
+//go:build unix
+
+package skm
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestPINFromFD(t *testing.T) {
+	pinFile := filepath.Join(t.TempDir(), "pin")
+	if err := os.WriteFile(pinFile, []byte(testPIN+"\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	// The descriptor is closed once the PIN is read, so it isn't owned by an *os.File of the test.
+	fd, err := syscall.Open(pinFile, syscall.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	runCommand(t, newBackend(t, true), "creds", "list", "--pin-fd", strconv.Itoa(fd)).check(t, nil, "")
+}
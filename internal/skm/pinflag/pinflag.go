@@ -0,0 +1,199 @@
+// Package pinflag reads the PIN a command should use from its PIN flags or from the environment, so it doesn't
+// have to be given on the command line where it leaks into the shell history and the process list.
+package pinflag
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/agent"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/exitcode"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// EnvPIN is the environment variable the PIN is read from when no --pin flag is given.
+	EnvPIN = "SKM_PIN"
+	// EnvNewPIN is the environment variable the new PIN is read from when no --new-pin flag is given.
+	EnvNewPIN = "SKM_NEW_PIN"
+	// EnvStrict is the environment variable that, when true, makes a PIN given in plain text on the command line
+	// an error instead of a warning.
+	EnvStrict = "SKM_PIN_STRICT"
+)
+
+// Source holds the flags a PIN is read from: --NAME with the PIN itself, --NAME-stdin, --NAME-file FILE and
+// --NAME-fd N, where NAME is pin or new-pin.
+type Source struct {
+	literal string
+	stdin   bool
+	file    string
+	fd      int
+
+	name  string
+	label string
+	env   string
+	cmd   *cobra.Command
+	flags *pflag.FlagSet
+
+	// pin is the PIN read or prompted for, so that it is asked only once per invocation. A Source is registered
+	// on the command tree built for a single invocation.
+	pin string
+}
+
+// RegisterFlags registers the --pin flags on cmd, whose PIN is otherwise read from SKM_PIN.
+func (s *Source) RegisterFlags(cmd *cobra.Command, usage string) {
+	s.register(cmd, "pin", "p", "PIN", EnvPIN, usage)
+}
+
+// RegisterNewFlags registers the --new-pin flags on cmd, whose PIN is otherwise read from SKM_NEW_PIN.
+func (s *Source) RegisterNewFlags(cmd *cobra.Command, usage string) {
+	s.register(cmd, "new-pin", "n", "new PIN", EnvNewPIN, usage)
+}
+
+func (s *Source) register(cmd *cobra.Command, name, shorthand, label, env, usage string) {
+	s.name = name
+	s.label = label
+	s.env = env
+	s.cmd = cmd
+	s.flags = cmd.Flags()
+
+	cmd.Flags().StringVarP(&s.literal, name, shorthand, "",
+		usage+", visible to other users, prefer --"+name+"-stdin, --"+name+"-file, --"+name+"-fd or "+env)
+	cmd.Flags().BoolVar(&s.stdin, name+"-stdin", false, "Read the "+label+" from the first line of stdin")
+	cmd.Flags().StringVar(&s.file, name+"-file", "", "Read the "+label+" from the first line of a file")
+	cmd.Flags().IntVar(&s.fd, name+"-fd", 0, "Read the "+label+" from the first line of a file descriptor")
+	cmd.MarkFlagsMutuallyExclusive(name, name+"-stdin", name+"-file", name+"-fd")
+}
+
+// Value returns the PIN given with the flags or the environment variable, or an empty string if there is none
+// and the user should be prompted for it. A PIN given in plain text on the command line prints a warning, or is
+// refused if SKM_PIN_STRICT is true.
+func (s *Source) Value() (string, error) {
+	switch {
+	case s.literal != "":
+		if strict, _ := strconv.ParseBool(os.Getenv(EnvStrict)); strict {
+			return "", exitcode.New(exitcode.Usage, fmt.Errorf(
+				"--%s is refused as %s is set, use --%s-stdin, --%s-file, --%s-fd or %s instead",
+				s.name, EnvStrict, s.name, s.name, s.name, s.env))
+		}
+		s.cmd.PrintErrf("Warning: a PIN given with --%s is visible in the shell history and the process list, "+
+			"use --%s-stdin, --%s-file, --%s-fd or %s instead\n", s.name, s.name, s.name, s.name, s.env)
+		return s.literal, nil
+	case s.stdin:
+		return s.read("stdin", func() (string, error) {
+			return prompts.ReadLine(s.cmd.InOrStdin())
+		})
+	case s.flags.Changed(s.name + "-file"):
+		return s.read(s.file, func() (string, error) {
+			f, err := os.Open(s.file) //nolint:gosec // the file is chosen by the user to hold their PIN
+			if err != nil {
+				return "", err
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+			return prompts.ReadLine(f)
+		})
+	case s.flags.Changed(s.name + "-fd"):
+		return s.read("file descriptor "+strconv.Itoa(s.fd), func() (string, error) {
+			if s.fd < 0 {
+				return "", errors.New("invalid file descriptor")
+			}
+			// The standard streams are left open, and read through their os.File, as another os.File of theirs
+			// would close them once garbage collected.
+			if std := []*os.File{os.Stdin, os.Stdout, os.Stderr}; s.fd < len(std) {
+				return prompts.ReadLine(std[s.fd])
+			}
+			f := os.NewFile(uintptr(s.fd), "fd "+strconv.Itoa(s.fd)) //nolint:gosec // s.fd is not negative
+			defer func() {
+				_ = f.Close()
+			}()
+			return prompts.ReadLine(f)
+		})
+	}
+
+	return os.Getenv(s.env), nil
+}
+
+// read reads the PIN with readLine, which must not be empty.
+func (s *Source) read(from string, readLine func() (string, error)) (string, error) {
+	pin, err := readLine()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the %s from %s: %w", s.label, from, err)
+	}
+	if strings.TrimSpace(pin) == "" {
+		return "", exitcode.New(exitcode.Usage, fmt.Errorf("the %s read from %s is empty", s.label, from))
+	}
+	return pin, nil
+}
+
+// Token returns a pinUvAuthToken of dev, the security key desc, with permissions for rpID. The token cached by
+// the agent, if one is running, is used as long as dev still accepts it. Otherwise the PIN is read with Value,
+// or prompted for, and the new token is handed to the agent.
+func (s *Source) Token(
+	dev authenticator.Device,
+	desc fido2.DeviceDescriptor,
+	permissions ctap2.Permission,
+	rpID string,
+) ([]byte, error) {
+	ctx := s.cmd.Context()
+	key := agent.Key(desc)
+	permissions = agent.Permissions(permissions, rpID)
+	cacheable := agent.Cacheable(permissions, rpID)
+
+	if cacheable {
+		if token, err := agent.Get(ctx, key, permissions, rpID); err == nil && token != nil {
+			if agent.Valid(dev, token, permissions, rpID) {
+				return token, nil
+			}
+			_ = agent.Forget(ctx, key)
+		}
+	}
+
+	pin, err := s.prompt(dev)
+	if err != nil {
+		return nil, err
+	}
+	token, err := dev.GetPinUvAuthTokenUsingPIN(pin, permissions, rpID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable {
+		_ = agent.Put(ctx, key, permissions, rpID, token)
+	}
+	return token, nil
+}
+
+// prompt returns the PIN given with Value, or prompts for it, once per invocation of the command.
+func (s *Source) prompt(dev authenticator.Device) (string, error) {
+	if s.pin != "" {
+		return s.pin, nil
+	}
+
+	pin, err := s.Value()
+	if err != nil {
+		return "", err
+	}
+	if pin == "" {
+		retries, _, _ := dev.GetPINRetries()
+		pin, err = prompts.NewPinPrompt().
+			WithIO(s.cmd.InOrStdin(), s.cmd.ErrOrStderr()).
+			WithRetries(retries).
+			Run()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	s.pin = pin
+	return pin, nil
+}
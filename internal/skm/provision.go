@@ -0,0 +1,453 @@
+package skm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/agent"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/policy"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/pin"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/output"
+	"github.com/mohammadv184/skm/internal/ui/prompts"
+	"github.com/mohammadv184/skm/internal/ui/views"
+	"github.com/spf13/cobra"
+)
+
+// provisionOptions holds the flags of the provision command.
+type provisionOptions struct {
+	device     device.Selector
+	all        bool
+	policy     string
+	pinFlag    pinflag.Source
+	newPinFlag pinflag.Source
+	dryRun     bool
+	yes        bool
+	receiptDir string
+
+	// pin and newPin are read from their flags once, and used for every security key.
+	pin    string
+	newPin string
+}
+
+// newProvisionCommand returns the provision command.
+func newProvisionCommand() *cobra.Command {
+	var opts provisionOptions
+	cmd := &cobra.Command{
+		Use:   "provision",
+		Short: "Apply a policy file to security keys",
+		Long: `Bring security keys to the state described by a policy file in one pass: the PIN (set or rotate), the minimum
+PIN length, a forced PIN change, Always UV, and enterprise attestation. Keys whose model or firmware is not allowed
+by the policy, or that cannot be reconciled with it, are left unchanged.
+
+The changes planned for each key are shown before they are applied. Settings that already match the policy are
+left alone, so provisioning can safely be run again. After applying, the key is read back to confirm the changes and
+a JSON receipt named after its serial number is written to --receipt-dir.
+
+Example policy:
+
+  version: 1
+  pin:
+    required: true
+    rotate: false
+    minLength: 8
+    forceChange: true
+    minLengthRpIds: [login.example.com]
+  alwaysUv: true
+  enterpriseAttestation: false
+  allowedAaguids: [ee882879-721c-4913-9775-3dfcce97072a]
+  minFirmwareVersion: 328966`,
+		Example: `  skm provision --policy policy.yaml --dry-run
+  skm provision --policy policy.yaml --all --new-pin 12345678 --yes
+  skm provision --policy policy.yaml --serial 12345678 --pin 123456 --receipt-dir receipts/`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return provisionHandler(cmd, &opts)
+		},
+		SilenceUsage: true,
+	}
+
+	opts.device.RegisterFlags(cmd)
+	cmd.Flags().BoolVarP(&opts.all, "all", "a", false, "Provision all matching security keys")
+	cmd.Flags().StringVar(&opts.policy, "policy", "", "Path to the policy file (YAML)")
+	opts.pinFlag.RegisterFlags(cmd, "Current PIN of the security keys")
+	opts.newPinFlag.RegisterNewFlags(cmd, "PIN to set or rotate to")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Show the plan without applying it")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Apply the plan without asking for confirmation")
+	cmd.Flags().StringVar(&opts.receiptDir, "receipt-dir", ".", "Directory to write the receipts to")
+	_ = cmd.MarkFlagRequired("policy")
+	_ = cmd.MarkFlagFilename("policy", "yaml", "yml")
+	_ = cmd.MarkFlagDirname("receipt-dir")
+	return cmd
+}
+
+func provisionHandler(cmd *cobra.Command, opts *provisionOptions) error {
+	pol, err := policy.Load(opts.policy)
+	if err != nil {
+		return err
+	}
+
+	if opts.pin, err = opts.pinFlag.Value(); err != nil {
+		return err
+	}
+	if opts.newPin, err = opts.newPinFlag.Value(); err != nil {
+		return err
+	}
+
+	selectedDevs, err := opts.device.ResolveAll(opts.all)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sd := range selectedDevs {
+		receipt, err := provisionOne(cmd, pol, &sd, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", deviceName(&sd), err))
+		}
+
+		if receipt == nil || opts.dryRun {
+			continue
+		}
+
+		path, err := writeReceipt(receipt, opts.receiptDir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to write receipt: %w", deviceName(&sd), err))
+			continue
+		}
+		cmd.Printf("Receipt written to %s.\n", path)
+	}
+
+	return errors.Join(errs...)
+}
+
+// provisionOne plans and applies the policy to a single security key. The receipt is nil if the security key
+// could not be opened or the plan was not confirmed.
+func provisionOne(
+	cmd *cobra.Command,
+	pol *policy.Policy,
+	desc *fido2.DeviceDescriptor,
+	opts *provisionOptions,
+) (*output.ProvisioningReceipt, error) {
+	dev, err := authenticator.Open(cmd.Context(), *desc)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	info := dev.Info()
+	plan := policy.NewPlan(pol, info)
+	receipt := output.NewProvisioningReceipt(desc, info, pol, plan)
+
+	cmd.Println(views.NewPlanView(desc, plan).Render())
+
+	if err := plan.Err(); err != nil {
+		receipt.Result = output.ResultSkipped
+		receipt.Error = err.Error()
+		return receipt, err
+	}
+
+	if plan.Changes() == 0 {
+		cmd.Println("Security key already matches the policy.")
+		receipt.Result = output.ResultUnchanged
+		return receipt, nil
+	}
+
+	if opts.dryRun {
+		cmd.Printf("%d changes would be applied (dry run).\n", plan.Changes())
+		return receipt, nil
+	}
+
+	if !opts.yes {
+		confirmed, err := prompts.NewConfirmPrompt(
+			fmt.Sprintf("Apply %d changes to %s?", plan.Changes(), deviceName(desc)),
+			"Raising the minimum PIN length and enabling enterprise attestation can only be undone by a factory reset.",
+		).Run()
+		if err != nil {
+			return nil, err
+		}
+		if !confirmed {
+			cmd.Println("Skipped.")
+			return nil, nil
+		}
+	}
+
+	err = applyPlan(cmd, dev, desc, pol, plan, receipt, opts)
+	if err != nil {
+		receipt.Result = output.ResultFailed
+		receipt.Error = err.Error()
+		return receipt, err
+	}
+
+	// The device caches its GetInfo response, so reopen it to confirm the changes.
+	_ = dev.Close()
+	confirmDev, err := authenticator.Open(cmd.Context(), *desc)
+	if err != nil {
+		receipt.Result = output.ResultFailed
+		receipt.Error = "failed to confirm the changes: " + err.Error()
+		return receipt, err
+	}
+	defer func() {
+		_ = confirmDev.Close()
+	}()
+
+	err = verifyPlan(pol, plan, confirmDev.Info(), receipt)
+	if err != nil {
+		receipt.Result = output.ResultFailed
+		receipt.Error = err.Error()
+		return receipt, err
+	}
+
+	receipt.Result = output.ResultProvisioned
+	cmd.Println("Security key provisioned successfully.")
+	return receipt, nil
+}
+
+// applyPlan applies the changes of plan in an order that keeps the PIN usable: the PIN first, then the
+// configuration, and the minimum PIN length last since it may force a PIN change.
+func applyPlan(
+	cmd *cobra.Command,
+	dev authenticator.Device,
+	desc *fido2.DeviceDescriptor,
+	pol *policy.Policy,
+	plan *policy.Plan,
+	receipt *output.ProvisioningReceipt,
+	opts *provisionOptions,
+) error {
+	info := dev.Info()
+	pinSet := info.Options[ctap2.OptionClientPIN]
+
+	currentPIN := opts.pin
+	if pinSet && currentPIN == "" {
+		retries, _, _ := dev.GetPINRetries()
+
+		var err error
+		currentPIN, err = prompts.NewPinPrompt().
+			WithIO(cmd.InOrStdin(), cmd.ErrOrStderr()).
+			WithTitle("Enter Current PIN").
+			WithRetries(retries).
+			Run()
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for i, s := range plan.Steps {
+		switch s.Action {
+		case policy.ActionSetPIN, policy.ActionChangePIN:
+			var newPIN string
+			newPIN, err = provisionNewPIN(cmd, info, pol, opts)
+			if err != nil {
+				break
+			}
+
+			if s.Action == policy.ActionSetPIN {
+				err = dev.SetPIN(newPIN)
+			} else {
+				err = dev.ChangePIN(currentPIN, newPIN)
+			}
+			if err == nil {
+				currentPIN = newPIN
+				// Changing the PIN invalidates the token of the key.
+				_ = agent.Forget(cmd.Context(), agent.Key(*desc))
+			}
+		default:
+			continue
+		}
+
+		if err != nil {
+			return markStep(receipt, i, err)
+		}
+		_ = markStep(receipt, i, nil)
+	}
+
+	var token []byte
+	configToken := func() ([]byte, error) {
+		if token == nil {
+			token, err = dev.GetPinUvAuthTokenUsingPIN(currentPIN, ctap2.PermissionAuthenticatorConfiguration, "")
+		}
+		return token, err
+	}
+
+	for i, s := range plan.Steps {
+		switch s.Action {
+		case policy.ActionToggleAlwaysUV:
+			if _, err = configToken(); err == nil {
+				err = dev.ToggleAlwaysUV(token)
+			}
+		case policy.ActionEnableEnterpriseAttestation:
+			if _, err = configToken(); err == nil {
+				err = dev.EnableEnterpriseAttestation(token)
+			}
+		default:
+			continue
+		}
+
+		if err != nil {
+			return markStep(receipt, i, err)
+		}
+		_ = markStep(receipt, i, nil)
+	}
+
+	if !plan.Has(policy.ActionSetMinPINLength) && !plan.Has(policy.ActionSetMinPINLengthRPIDs) &&
+		!plan.Has(policy.ActionForcePINChange) {
+		return nil
+	}
+
+	var (
+		newMinLength uint
+		rpIDs        []string
+	)
+	if plan.Has(policy.ActionSetMinPINLength) {
+		newMinLength = *pol.PIN.MinLength
+	}
+	if plan.Has(policy.ActionSetMinPINLengthRPIDs) {
+		rpIDs = pol.PIN.MinLengthRPIDs
+	}
+
+	if _, err = configToken(); err == nil {
+		err = dev.SetMinPINLength(token, newMinLength, rpIDs, plan.Has(policy.ActionForcePINChange), false)
+	}
+
+	for i, s := range plan.Steps {
+		switch s.Action {
+		case policy.ActionSetMinPINLength, policy.ActionSetMinPINLengthRPIDs, policy.ActionForcePINChange:
+			_ = markStep(receipt, i, err)
+		}
+	}
+	return err
+}
+
+// provisionNewPIN returns the PIN to set, prompting for it once if --new-pin isn't given.
+func provisionNewPIN(
+	cmd *cobra.Command,
+	info *ctap2.AuthenticatorGetInfoResponse,
+	pol *policy.Policy,
+	opts *provisionOptions,
+) (string, error) {
+	validateLength := pin.Validator(info)
+	validate := func(newPIN string) error {
+		if err := validateLength(newPIN); err != nil {
+			return err
+		}
+		// The minimum PIN length of the policy is applied after the PIN, so the key does not enforce it yet.
+		if minLength := pol.PIN.MinLength; minLength != nil && uint(utf8.RuneCountInString(newPIN)) < *minLength {
+			return fmt.Errorf("PIN must be at least %d characters long", *minLength)
+		}
+		return nil
+	}
+
+	if opts.newPin != "" {
+		return opts.newPin, validate(opts.newPin)
+	}
+
+	newPIN, err := prompts.NewPinPrompt().
+		WithIO(cmd.InOrStdin(), cmd.ErrOrStderr()).
+		WithTitle("Enter New PIN").
+		WithValidation(validate).
+		Run()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = prompts.NewPinPrompt().
+		WithIO(cmd.InOrStdin(), cmd.ErrOrStderr()).
+		WithTitle("Confirm New PIN").
+		WithValidation(func(s string) error {
+			if s != newPIN {
+				return errors.New("PINs do not match")
+			}
+			return nil
+		}).Run()
+	if err != nil {
+		return "", err
+	}
+
+	// Reuse the new PIN for the remaining security keys.
+	opts.newPin = newPIN
+	return newPIN, nil
+}
+
+// verifyPlan checks that the settings changed by plan now match the policy.
+func verifyPlan(
+	pol *policy.Policy,
+	plan *policy.Plan,
+	info *ctap2.AuthenticatorGetInfoResponse,
+	receipt *output.ProvisioningReceipt,
+) error {
+	after := policy.NewPlan(pol, info)
+
+	var errs []error
+	for i, s := range plan.Steps {
+		switch s.Action {
+		case policy.ActionNone, policy.ActionChangePIN, policy.ActionSetMinPINLengthRPIDs:
+			// Rotations and RP IDs cannot be read back.
+			continue
+		}
+
+		// A rotating policy plans another PIN change once the PIN is set.
+		confirmed := after.Steps[i].Action == policy.ActionNone || after.Steps[i].Action == policy.ActionChangePIN
+		if confirmed && after.Steps[i].Problem == "" {
+			receipt.Steps[i].Status = "verified"
+			continue
+		}
+
+		receipt.Steps[i].Status = "unverified"
+		errs = append(errs, fmt.Errorf("%s is %s after applying the policy", s.Setting, after.Steps[i].Current))
+	}
+
+	return errors.Join(errs...)
+}
+
+// markStep records the outcome of the i-th step in the receipt and returns err.
+func markStep(receipt *output.ProvisioningReceipt, i int, err error) error {
+	if err != nil {
+		receipt.Steps[i].Status = "failed"
+		receipt.Steps[i].Error = err.Error()
+		return err
+	}
+	receipt.Steps[i].Status = "applied"
+	return nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// writeReceipt writes the receipt as JSON to dir and returns its path.
+func writeReceipt(receipt *output.ProvisioningReceipt, dir string) (string, error) {
+	name := receipt.Device.SerialNumber
+	if name == "" {
+		name = receipt.AAGUID + "-" + receipt.Device.Path
+	}
+	name = "skm-receipt-" + unsafeFileChars.ReplaceAllString(name, "_") + ".json"
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(receipt, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, name)
+	return path, os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// deviceName returns a short human-readable name of a security key.
+func deviceName(desc *fido2.DeviceDescriptor) string {
+	if desc.SerialNumber != "" {
+		return desc.Product + " (" + desc.SerialNumber + ")"
+	}
+	return desc.Product + " (" + desc.Path + ")"
+}
@@ -0,0 +1,69 @@
+package skm
+
+import (
+	"testing"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+)
+
+func TestProvision(t *testing.T) {
+	alwaysUV := writePolicy(t, "version: 1\nalwaysUv: true\n")
+	pinAndAlwaysUV := writePolicy(t, "version: 1\npin:\n  required: true\nalwaysUv: true\n")
+
+	tests := []struct {
+		name        string
+		withPIN     bool
+		reopenFails bool
+		args        []string
+		wantOut     []string
+		wantErr     string
+		wantEnabled bool
+	}{
+		{
+			name:        "always UV",
+			withPIN:     true,
+			args:        []string{"--policy", alwaysUV, "--pin", testPIN, "--yes"},
+			wantOut:     []string{"Security key provisioned successfully."},
+			wantEnabled: true,
+		},
+		{
+			name:    "always UV without a PIN",
+			args:    []string{"--policy", alwaysUV, "--yes"},
+			wantErr: "alwaysUv: a PIN is required to change this setting",
+		},
+		{
+			name:        "PIN set before always UV",
+			args:        []string{"--policy", pinAndAlwaysUV, "--new-pin", "87654321", "--yes"},
+			wantOut:     []string{"Security key provisioned successfully."},
+			wantEnabled: true,
+		},
+		{
+			name:        "reopen fails",
+			withPIN:     true,
+			reopenFails: true,
+			args:        []string{"--policy", alwaysUV, "--pin", testPIN, "--yes"},
+			wantErr:     "unplugged",
+			wantEnabled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t, tt.withPIN)
+			if tt.reopenFails {
+				b.Wrap = func(d authenticator.Device) authenticator.Device {
+					return unplugOnToggle{Device: d, b: b}
+				}
+			}
+
+			args := append([]string{"provision", "--receipt-dir", t.TempDir()}, tt.args...)
+			runCommand(t, b, args...).check(t, tt.wantOut, tt.wantErr)
+
+			b.OpenErr = nil
+			if enabled := b.Virtual(testDevicePath).Info().Options[ctap2.OptionAlwaysUv]; enabled != tt.wantEnabled {
+				t.Errorf("alwaysUv = %v, want %v", enabled, tt.wantEnabled)
+			}
+		})
+	}
+}
@@ -1,78 +1,86 @@
 package skm
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"time"
 
+	"github.com/charmbracelet/x/term"
+	"github.com/google/uuid"
 	"github.com/mohammadv184/go-fido2"
-	"github.com/mohammadv184/skm/internal/skm/completion"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/skm/internal/agent"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/exitcode"
 	"github.com/mohammadv184/skm/internal/ui/prompts"
 	"github.com/spf13/cobra"
 )
 
-var resetCMD = cobra.Command{
-	Use:   "reset",
-	Short: "Factory reset a security key",
-	Long:  "Completely wipe all credentials and reset the PIN of a security key. This action is IRREVERSIBLE. Most keys require physical touch within a short window after power-on to perform a reset.",
-	Example: `  skm reset
-  skm reset --device-path /dev/hidraw0 --yes`,
-	RunE: resetHandler,
+// resetOptions holds the flags of the reset command.
+type resetOptions struct {
+	device        device.Selector
+	yes           bool
+	noReplug      bool
+	replugTimeout time.Duration
 }
 
-var (
-	resetDevicePath string
-	resetYes        bool
-)
+// newResetCommand returns the reset command.
+func newResetCommand() *cobra.Command {
+	var opts resetOptions
+	cmd := &cobra.Command{
+		Use:   "reset",
+		Short: "Factory reset a security key",
+		Long: `Completely wipe all credentials and reset the PIN of a security key. This action is IRREVERSIBLE.
+Most keys only accept a reset within about 10 seconds of being plugged in, and require a touch to confirm it. So
+after the confirmation, you are asked to unplug the key and plug it back in: the reset is sent as soon as the
+same key, recognized by its serial number or model, is detected again.
+The replug is skipped with --no-replug, for virtual authenticators, and when --wait waited for the key to be
+plugged in.`,
+		Example: `  skm reset
+  skm reset --wait --yes
+  skm reset --device-path /dev/hidraw0 --yes --no-replug`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return resetHandler(cmd, &opts)
+		},
+	}
 
-func init() {
-	resetCMD.Flags().StringVarP(&resetDevicePath, "device-path", "d", "", "Path to the security key device")
-	_ = resetCMD.RegisterFlagCompletionFunc("device-path", completion.CompleteDevicePath)
-	resetCMD.Flags().BoolVarP(&resetYes, "yes", "y", false, "Confirm reset without prompting")
-	rootCMD.AddCommand(&resetCMD)
+	opts.device.RegisterFlags(cmd)
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Confirm reset without prompting")
+	cmd.Flags().BoolVar(&opts.noReplug, "no-replug", false,
+		"Send the reset right away instead of asking to unplug and replug the key")
+	cmd.Flags().DurationVar(&opts.replugTimeout, "replug-timeout", time.Minute,
+		"How long to wait for the key to be unplugged and plugged back in")
+	return cmd
 }
 
-func resetHandler(cmd *cobra.Command, _ []string) error {
-	var selectedDev *fido2.DeviceDescriptor
-
-	if resetDevicePath != "" {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-		for _, dev := range devs {
-			if dev.Path == resetDevicePath {
-				selectedDev = &dev
-				break
-			}
-		}
-		if selectedDev == nil {
-			return fmt.Errorf("device not found at path: %s", resetDevicePath)
-		}
-	} else {
-		devs, err := fido2.Enumerate()
-		if err != nil {
-			return err
-		}
-
-		if len(devs) == 0 {
-			return errors.New("no security keys found")
-		}
+const (
+	// resetWindow is how long after being plugged in most security keys accept a reset.
+	resetWindow = 10 * time.Second
+	// replugInterval is how often the security keys are polled while waiting for the replug.
+	replugInterval = 250 * time.Millisecond
+)
 
-		selectedDev, err = prompts.NewDeviceSelectPrompt().WithDevices(devs...).Run()
-		if err != nil {
-			return err
-		}
+func resetHandler(cmd *cobra.Command, opts *resetOptions) error {
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
 	}
 
-	dev, err := fido2.Open(*selectedDev)
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = dev.Close()
+		if dev != nil {
+			_ = dev.Close()
+		}
 	}()
 
-	confirm := resetYes
+	confirm := opts.yes
 	if !confirm {
 		var err error
 		confirm, err = prompts.NewConfirmPrompt(
@@ -89,12 +97,166 @@ func resetHandler(cmd *cobra.Command, _ []string) error {
 		return nil
 	}
 
-	cmd.Println("Performing reset. Please touch your security key if it starts blinking.")
-	err = dev.Reset()
-	if err != nil {
-		return err
+	// A key that was just plugged in is still within its reset window. When it was plugged in is unknown
+	// otherwise, e.g. with --no-replug, so no countdown is shown.
+	var deadline time.Time
+	if opts.device.Waited() {
+		deadline = time.Now().Add(resetWindow)
+	}
+	if !opts.noReplug && !authenticator.IsVirtual(selectedDev.Path) && !opts.device.Waited() {
+		aaguid := dev.Info().AAGUID
+		_ = dev.Close()
+		dev = nil
+
+		replugged, err := waitForReplug(cmd, *selectedDev, aaguid, opts.replugTimeout)
+		if err != nil {
+			return err
+		}
+		deadline = time.Now().Add(resetWindow)
+
+		dev, err = authenticator.Open(cmd.Context(), *replugged)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := runReset(cmd, dev, deadline); err != nil {
+		return resetError(err)
 	}
+	_ = agent.Forget(cmd.Context(), agent.Key(*selectedDev))
 
 	cmd.Println("Security key reset successfully.")
 	return nil
 }
+
+// waitForReplug waits up to timeout for the security key old to be unplugged, then for the same key to be plugged
+// back in, and returns its new descriptor.
+func waitForReplug(
+	cmd *cobra.Command,
+	old fido2.DeviceDescriptor,
+	aaguid uuid.UUID,
+	timeout time.Duration,
+) (*fido2.DeviceDescriptor, error) {
+	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+	defer cancel()
+
+	enumerate := func() ([]fido2.DeviceDescriptor, error) {
+		return authenticator.Enumerate(ctx)
+	}
+
+	// The other keys are never taken for the replugged one, even if they are of the same model.
+	others, err := enumerate()
+	if err != nil {
+		return nil, err
+	}
+	others = slices.DeleteFunc(others, func(d fido2.DeviceDescriptor) bool {
+		return d == old
+	})
+
+	err = waitStep(ctx, cmd, "Unplug your security key now…", time.Time{}, func(ctx context.Context) error {
+		_, err := authenticator.WaitFor(ctx, enumerate, replugInterval, func(devs []fido2.DeviceDescriptor) bool {
+			return !slices.Contains(devs, old)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, replugError(err, "unplugged", timeout)
+	}
+
+	var replugged *fido2.DeviceDescriptor
+	err = waitStep(ctx, cmd, "Plug your security key back in…", time.Time{}, func(ctx context.Context) error {
+		_, err := authenticator.WaitFor(ctx, enumerate, replugInterval, func(devs []fido2.DeviceDescriptor) bool {
+			for _, d := range devs {
+				if !slices.Contains(others, d) && isSameKey(ctx, old, d, aaguid) {
+					replugged = &d
+					return true
+				}
+			}
+			return false
+		})
+		return err
+	})
+	if err != nil {
+		return nil, replugError(err, "plugged back in", timeout)
+	}
+
+	return replugged, nil
+}
+
+// isSameKey reports whether desc is the security key old, whose AAGUID is aaguid, plugged in again. Its path
+// may have changed, so it is recognized by its serial number, or by its model if it has none.
+func isSameKey(ctx context.Context, old, desc fido2.DeviceDescriptor, aaguid uuid.UUID) bool {
+	if old.SerialNumber != "" {
+		return desc.SerialNumber == old.SerialNumber
+	}
+	if desc.VendorID != old.VendorID || desc.ProductID != old.ProductID || desc.Product != old.Product {
+		return false
+	}
+
+	dev, err := authenticator.Open(ctx, desc)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	return dev.Info().AAGUID == aaguid
+}
+
+// runReset sends the reset, counting down to the end of the reset window, if deadline is set, while waiting for
+// the touch.
+func runReset(cmd *cobra.Command, dev authenticator.Device, deadline time.Time) error {
+	return waitStep(cmd.Context(), cmd, "Touch your security key to confirm the reset…", deadline,
+		func(context.Context) error {
+			return dev.Reset()
+		})
+}
+
+// waitStep runs work, showing message with a spinner, and a countdown to deadline if it is set, on a terminal.
+// Otherwise message is printed to stderr, leaving stdout to the result.
+func waitStep(
+	ctx context.Context,
+	cmd *cobra.Command,
+	message string,
+	deadline time.Time,
+	work func(ctx context.Context) error,
+) error {
+	if term.IsTerminal(os.Stdin.Fd()) && term.IsTerminal(os.Stderr.Fd()) {
+		return prompts.NewSpinnerPrompt(message).WithDeadline(deadline).Run(ctx, work)
+	}
+
+	cmd.PrintErrln(message)
+	return work(ctx)
+}
+
+// replugError explains a failure to wait for the security key to be unplugged or plugged back in.
+func replugError(err error, action string, timeout time.Duration) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("the security key was not %s within %s", action, timeout)
+	}
+	return err
+}
+
+// resetError adds guidance to the errors of a reset refused by the security key.
+func resetError(err error) error {
+	var ctapErr *ctaphid.CTAPError
+	if !errors.As(err, &ctapErr) {
+		return err
+	}
+
+	switch ctapErr.StatusCode {
+	case ctaphid.StatusCTAP2ErrNotAllowed:
+		return exitcode.New(exitcode.Denied, fmt.Errorf(
+			"%w: the security key only accepts a reset within seconds of being plugged in, "+
+				"run 'skm reset' again and replug the key when asked", err))
+	case ctaphid.StatusCTAP2ErrUserActionTimeout:
+		return exitcode.New(exitcode.Timeout, fmt.Errorf(
+			"%w: the security key was not touched in time, run 'skm reset' again and touch the key as soon as "+
+				"it blinks", err))
+	case ctaphid.StatusCTAP2ErrOperationDenied:
+		return exitcode.New(exitcode.Denied, fmt.Errorf("%w: the reset was declined on the security key", err))
+	default:
+		return err
+	}
+}
@@ -0,0 +1,141 @@
+package skm
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/authenticator/authenticatortest"
+)
+
+// failingReset is a security key whose reset is refused with a CTAP2 status.
+type failingReset struct {
+	authenticator.Device
+	status ctaphid.StatusCode
+}
+
+func (d failingReset) Reset() error {
+	return &ctaphid.CTAPError{Command: ctaphid.CmdCBOR, StatusCode: d.status}
+}
+
+func TestReset(t *testing.T) {
+	replugged := fido2.DeviceDescriptor{
+		Path:         "/dev/hidraw5",
+		Product:      "Test Key",
+		Manufacturer: "skm",
+		SerialNumber: "0001",
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		refuse  ctaphid.StatusCode
+		replug  func(b *authenticatortest.Backend)
+		wantOut []string
+		// wantPrompts are the prompts that should be printed to stderr.
+		wantPrompts []string
+		wantErr     string
+		// wantReset is the path of the security key that should be reset, if any.
+		wantReset string
+	}{
+		{
+			name:        "without replug",
+			args:        []string{"--yes", "--no-replug"},
+			wantOut:     []string{"Security key reset successfully."},
+			wantPrompts: []string{"Touch your security key to confirm the reset…"},
+			wantReset:   testDevicePath,
+		},
+		{
+			name: "replugged",
+			args: []string{"--yes"},
+			replug: func(b *authenticatortest.Backend) {
+				b.Unplug(testDevicePath)
+				time.Sleep(20 * time.Millisecond)
+				b.Replug(testDevicePath, replugged)
+			},
+			wantOut: []string{"Security key reset successfully."},
+			wantPrompts: []string{
+				"Unplug your security key now…",
+				"Plug your security key back in…",
+				"Touch your security key to confirm the reset…",
+			},
+			wantReset: replugged.Path,
+		},
+		{
+			name: "another key plugged in",
+			args: []string{"--yes", "--replug-timeout", "500ms"},
+			replug: func(b *authenticatortest.Backend) {
+				b.Unplug(testDevicePath)
+				b.Add(fido2.DeviceDescriptor{Path: "/dev/hidraw7", Product: "Test Key", SerialNumber: "0002"})
+			},
+			wantErr: "the security key was not plugged back in within 500ms",
+		},
+		{
+			name:    "not unplugged",
+			args:    []string{"--yes", "--replug-timeout", "50ms"},
+			wantErr: "the security key was not unplugged within 50ms",
+		},
+		{
+			name:    "reset window passed",
+			args:    []string{"--yes", "--no-replug"},
+			refuse:  ctaphid.StatusCTAP2ErrNotAllowed,
+			wantErr: "CTAP2_ERR_NOT_ALLOWED): the security key only accepts a reset within seconds of being plugged in",
+		},
+		{
+			name:    "not touched",
+			args:    []string{"--yes", "--no-replug"},
+			refuse:  ctaphid.StatusCTAP2ErrUserActionTimeout,
+			wantErr: "the security key was not touched in time",
+		},
+		{
+			name:    "no match",
+			args:    []string{"--yes", "--serial", "999"},
+			wantErr: "no security key matches the given selectors",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t, true)
+			if tt.refuse != 0 {
+				b.Wrap = func(d authenticator.Device) authenticator.Device {
+					return failingReset{Device: d, status: tt.refuse}
+				}
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				if tt.replug != nil {
+					time.Sleep(50 * time.Millisecond)
+					tt.replug(b)
+				}
+			}()
+
+			r := runCommand(t, b, append([]string{"reset"}, tt.args...)...)
+			r.check(t, tt.wantOut, tt.wantErr)
+			<-done
+
+			for _, prompt := range tt.wantPrompts {
+				if strings.Contains(r.stdout, prompt) || !strings.Contains(r.stderr, prompt) {
+					t.Errorf("prompt %q not on stderr only\nstdout: %s\nstderr: %s", prompt, r.stdout, r.stderr)
+				}
+			}
+
+			path := tt.wantReset
+			if path == "" {
+				path = testDevicePath
+				if tt.replug != nil {
+					b.Replug(testDevicePath, fido2.DeviceDescriptor{Path: testDevicePath})
+				}
+			}
+			if b.Virtual(path).Info().Options[ctap2.OptionClientPIN] == (tt.wantReset != "") {
+				t.Errorf("security key at %s reset = %v, want %v", path, tt.wantReset == "", tt.wantReset != "")
+			}
+		})
+	}
+}
@@ -2,42 +2,126 @@ package skm
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 
+	"github.com/mohammadv184/skm/internal/skm/bio"
+	"github.com/mohammadv184/skm/internal/skm/blob"
 	"github.com/mohammadv184/skm/internal/skm/config"
 	"github.com/mohammadv184/skm/internal/skm/creds"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/exitcode"
 	"github.com/mohammadv184/skm/internal/skm/pin"
 	"github.com/spf13/cobra"
 )
 
-var rootCMD = cobra.Command{
-	Use:   "skm",
-	Short: "SKM: Security Key Manager",
-	Long: `SKM (Security Key Manager) is a powerful CLI tool designed for managing FIDO2 security keys.
+// newRootCommand returns the skm command with all its subcommands. The tree is built for every execution, so
+// that no flag or state is kept from one execution to the next.
+func newRootCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "skm",
+		Short: "SKM: Security Key Manager",
+		Long: `SKM (Security Key Manager) is a powerful CLI tool designed for managing FIDO2 security keys.
 It provides functionalities to list connected devices, retrieve detailed device information,
-and manage resident credentials stored on the keys.`,
-	Example: `  skm list
+and manage resident credentials stored on the keys.
+Errors are reported with a hint on how to fix them, and a stable exit code: 10 for invalid flags or arguments, 11
+when no security key is found, 12 when it is busy, 13 for a wrong PIN, 14 for a blocked PIN, 15 when a PIN is
+required, 16 for an unsupported operation, 17 when the key refuses it, 18 on a timeout, 19 when its storage is full,
+130 when canceled, and 1 for any other error.`,
+		Example: `  skm list
   skm info
   skm creds list
+  skm creds list --output json
   skm pin set
-  skm config always-uv`,
+  skm pin retries --all
+  skm bio enroll
+  skm provision --policy policy.yaml --dry-run
+  skm audit --policy policy.yaml --junit audit.xml
+  skm config always-uv
+  skm reset --wait
+  sudo skm attach /tmp/key.json`,
+	}
+	cmd.CompletionOptions.HiddenDefaultCmd = true
+	cmd.DisableAutoGenTag = true
+	cmd.SetHelpCommand(&cobra.Command{Hidden: true})
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	device.RegisterWaitFlag(cmd)
+
+	cmd.AddCommand(
+		newListCommand(),
+		newInfoCommand(),
+		newResetCommand(),
+		newProvisionCommand(),
+		newAuditCommand(),
+		newVerifyCommand(),
+		newMDSCommand(),
+		newAgentCommand(),
+		newAttachCommand(),
+		newVersionCommand(),
+		newMandocCommand(),
+		creds.NewCommand(),
+		pin.NewCommand(),
+		config.NewCommand(),
+		bio.NewCommand(),
+		blob.NewCommand(),
+	)
+	return cmd
 }
 
-func init() {
-	creds.Init(&rootCMD)
-	pin.Init(&rootCMD)
-	config.Init(&rootCMD)
+// Main is the entry point of the SKM CLI. It returns the exit code the process should terminate with.
+func Main(args []string) int {
+	err := execute(context.Background(), args[1:], os.Stdin, os.Stdout, os.Stderr)
+
+	var exitErr *exitcode.Error
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	return exitcode.OK
 }
 
-// Main is the entry point of the SKM CLI.
-func Main(args []string) {
-	rootCMD.SetArgs(args[1:])
-	rootCMD.SetOut(os.Stdout)
-	rootCMD.SetErr(os.Stderr)
+// execute runs the command selected by args. The security keys are those of the authenticator.Backend of ctx.
+// An error is printed to stderr, and returned as an *exitcode.Error.
+func execute(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	root := newRootCommand()
+	root.SetArgs(args)
+	root.SetIn(stdin)
+	root.SetOut(stdout)
+	root.SetErr(stderr)
 
-	rootCMD.CompletionOptions.HiddenDefaultCmd = true
-	rootCMD.DisableAutoGenTag = true
-	rootCMD.SetHelpCommand(&cobra.Command{Hidden: true})
+	// Cobra checks the command, its flags and its arguments before the hook, so an error returned before it ran
+	// is a usage error.
+	var started bool
+	root.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		started = true
+		if err := cmd.ValidateRequiredFlags(); err != nil {
+			return usageError(cmd, err)
+		}
+		if err := cmd.ValidateFlagGroups(); err != nil {
+			return usageError(cmd, err)
+		}
+		return nil
+	}
+
+	cmd, err := root.ExecuteContextC(ctx)
+	if err == nil {
+		return nil
+	}
+	if !started {
+		err = usageError(cmd, err)
+	}
+
+	exitErr := classify(err)
+	if msg := exitErr.Error(); msg != "" {
+		_, _ = fmt.Fprintln(stderr, "Error:", msg)
+	}
+	return exitErr
+}
 
-	_ = rootCMD.ExecuteContext(context.Background())
+// usageError reports an invalid flag or argument of cmd.
+func usageError(cmd *cobra.Command, err error) error {
+	return exitcode.New(exitcode.Usage, fmt.Errorf("%w\nRun '%s --help' for usage", err, cmd.CommandPath()))
 }
@@ -0,0 +1,119 @@
+package skm
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/agent"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/authenticator/authenticatortest"
+	"github.com/mohammadv184/skm/internal/mds"
+)
+
+const (
+	testPIN        = "1234"
+	testDevicePath = "/dev/hidraw0"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the commands away from an agent the user may be running, and from the metadata they imported.
+	dir, err := os.MkdirTemp("", "skm-test")
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	_ = os.Setenv(agent.EnvSocket, filepath.Join(dir, "agent.sock"))
+	_ = os.Setenv(mds.EnvFile, filepath.Join(dir, "mds.json"))
+
+	m.Run()
+}
+
+// result is the outcome of a command run by runCommand.
+type result struct {
+	stdout string
+	stderr string
+	err    error
+}
+
+// runCommand runs skm with args against the security keys of backend.
+func runCommand(t *testing.T, backend authenticator.Backend, args ...string) result {
+	t.Helper()
+
+	return runCommandContext(t, context.Background(), backend, args...)
+}
+
+// runCommandContext is runCommand with a context, for commands that run until it is done.
+func runCommandContext(t *testing.T, ctx context.Context, backend authenticator.Backend, args ...string) result {
+	t.Helper()
+
+	return runCommandWith(t, ctx, backend, "", args...)
+}
+
+// runCommandInput is runCommand with stdin.
+func runCommandInput(t *testing.T, backend authenticator.Backend, stdin string, args ...string) result {
+	t.Helper()
+
+	return runCommandWith(t, context.Background(), backend, stdin, args...)
+}
+
+func runCommandWith(
+	t *testing.T,
+	ctx context.Context,
+	backend authenticator.Backend,
+	stdin string,
+	args ...string,
+) result {
+	t.Helper()
+
+	ctx = authenticator.NewContext(ctx, backend)
+
+	var stdout, stderr bytes.Buffer
+	err := execute(ctx, args, strings.NewReader(stdin), &stdout, &stderr)
+	return result{stdout: stdout.String(), stderr: stderr.String(), err: err}
+}
+
+// newBackend returns a backend with a single virtual security key, optionally with testPIN set.
+func newBackend(t *testing.T, withPIN bool) *authenticatortest.Backend {
+	t.Helper()
+
+	b := authenticatortest.NewBackend(t)
+	a := b.Add(fido2.DeviceDescriptor{
+		Path:         testDevicePath,
+		Product:      "Test Key",
+		Manufacturer: "skm",
+		SerialNumber: "0001",
+	})
+	if withPIN {
+		if err := a.SetPIN(testPIN); err != nil {
+			t.Fatalf("SetPIN() error = %v", err)
+		}
+	}
+	return b
+}
+
+// check compares the outcome of a command with the expected output and error.
+func (r result) check(t *testing.T, wantOut []string, wantErr string) {
+	t.Helper()
+
+	switch {
+	case wantErr == "" && r.err != nil:
+		t.Fatalf("error = %v, want nil", r.err)
+	case wantErr != "" && r.err == nil:
+		t.Fatalf("error = nil, want %q", wantErr)
+	case wantErr != "" && !strings.Contains(r.err.Error(), wantErr):
+		t.Fatalf("error = %v, want %q", r.err, wantErr)
+	}
+
+	for _, want := range wantOut {
+		if !strings.Contains(r.stdout, want) {
+			t.Errorf("output does not contain %q:\n%s", want, r.stdout)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package skm
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/uuid"
+	"github.com/ldclabs/cose/iana"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+	"github.com/mohammadv184/skm/internal/attestation"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/skm/device"
+	"github.com/mohammadv184/skm/internal/skm/exitcode"
+	"github.com/mohammadv184/skm/internal/skm/pinflag"
+	"github.com/mohammadv184/skm/internal/ui/output"
+	"github.com/mohammadv184/skm/internal/ui/views"
+	"github.com/spf13/cobra"
+)
+
+// verifyExitNotGenuine is the exit code used when the attestation of a security key does not prove it genuine.
+const verifyExitNotGenuine = 2
+
+// verifyOptions holds the flags of the verify command.
+type verifyOptions struct {
+	device  device.Selector
+	pin     pinflag.Source
+	roots   []string
+	report  string
+	signKey string
+}
+
+// newVerifyCommand returns the verify command.
+func newVerifyCommand() *cobra.Command {
+	var opts verifyOptions
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Prove that a security key is genuine from its attestation",
+		Long: `Ask the security key to create a throwaway credential for a random RP ID and challenge, and verify the
+attestation statement it signs: packed, fido-u2f, tpm or none. The attestation certificate must chain up to a
+root trusted for the model of the key, taken from the imported MDS BLOB (see 'skm mds') or given with --roots,
+and certify the AAGUID the key reports. The credential is not resident, so nothing is stored on the key, but it
+must be touched.
+
+The report, with the attestation it was made from, is shown as a table or as a document with --output, and can
+be written as a JWS signed with --sign-key to the file --report. The command exits with status 2 if the key
+could not be proven genuine, e.g. because it only makes self attestation.`,
+		Example: `  skm verify
+  skm verify --roots yubico-root.pem --output json
+  skm verify --report report.jwt --sign-key signer.pem`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return verifyHandler(cmd, &opts)
+		},
+		SilenceUsage: true,
+	}
+
+	opts.device.RegisterFlags(cmd)
+	output.RegisterFlag(cmd)
+	opts.pin.RegisterFlags(cmd,
+		"PIN of the security key, if it requires user verification to create a credential")
+	cmd.Flags().StringSliceVar(&opts.roots, "roots", nil,
+		"Trust the root certificates of these PEM files, or of the PEM files in these directories")
+	cmd.Flags().StringVar(&opts.report, "report", "",
+		"Write the report as a JWS signed with --sign-key to the file")
+	cmd.Flags().StringVar(&opts.signKey, "sign-key", "",
+		"PEM private key (ECDSA, Ed25519 or RSA) that signs the report")
+	cmd.MarkFlagsRequiredTogether("report", "sign-key")
+	_ = cmd.MarkFlagFilename("sign-key", "pem", "key")
+	return cmd
+}
+
+func verifyHandler(cmd *cobra.Command, opts *verifyOptions) error {
+	format, err := output.FromCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Load the roots and the signing key first, not to have the key touched in vain.
+	roots, err := loadTrustStore(opts.roots)
+	if err != nil {
+		return err
+	}
+	var signer crypto.Signer
+	if opts.signKey != "" {
+		if signer, err = loadSigningKey(opts.signKey); err != nil {
+			return err
+		}
+	}
+
+	selectedDev, err := opts.device.Resolve()
+	if err != nil {
+		return err
+	}
+
+	dev, err := authenticator.Open(cmd.Context(), *selectedDev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = dev.Close()
+	}()
+
+	rpID, clientData, err := newVerifyChallenge()
+	if err != nil {
+		return err
+	}
+	userID := make([]byte, 16)
+	if _, err := rand.Read(userID); err != nil {
+		return err
+	}
+
+	var token []byte
+	info := dev.Info()
+	if !info.Options[ctap2.OptionMakeCredentialUvNotRequired] && info.Options[ctap2.OptionClientPIN] {
+		if token, err = opts.pin.Token(dev, *selectedDev, ctap2.PermissionMakeCredential, rpID); err != nil {
+			return err
+		}
+	}
+
+	cmd.PrintErrln("Touch your security key to create the attestation.")
+	resp, err := dev.MakeCredential(
+		token,
+		clientData,
+		webauthn.PublicKeyCredentialRpEntity{ID: rpID, Name: "skm verify"},
+		webauthn.PublicKeyCredentialUserEntity{ID: userID, Name: "skm verify", DisplayName: "skm verify"},
+		[]webauthn.PublicKeyCredentialParameters{
+			{Type: webauthn.PublicKeyCredentialTypePublicKey, Algorithm: iana.AlgorithmES256},
+			{Type: webauthn.PublicKeyCredentialTypePublicKey, Algorithm: iana.AlgorithmEdDSA},
+			{Type: webauthn.PublicKeyCredentialTypePublicKey, Algorithm: iana.AlgorithmRS256},
+		},
+		nil,
+		&webauthn.CreateAuthenticationExtensionsClientInputs{},
+		map[ctap2.Option]bool{ctap2.OptionResidentKeys: false},
+		0,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	var aaguid uuid.UUID
+	if resp.AuthData != nil && resp.AuthData.AttestedCredentialData != nil {
+		aaguid = resp.AuthData.AttestedCredentialData.AAGUID
+	}
+	model := loadModels(cmd).Lookup(aaguid)
+	if model != nil {
+		for _, der := range model.AttestationRoots {
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				continue
+			}
+			if roots == nil {
+				roots = x509.NewCertPool()
+			}
+			roots.AddCert(cert)
+		}
+	}
+
+	clientDataHash := sha256.Sum256(clientData)
+	res := attestation.Verify(resp, clientDataHash[:], roots, time.Now())
+
+	attStmt, err := cbor.Marshal(resp.AttestationStatement)
+	if err != nil {
+		return err
+	}
+	report := output.NewVerificationReport(
+		selectedDev,
+		res,
+		output.NewAttestationEvidence(rpID, clientData, resp.AuthDataRaw, attStmt),
+	)
+	report.Device.Model = output.NewModel(model)
+
+	if format != output.FormatText {
+		err = output.Write(cmd.OutOrStdout(), format, report)
+	} else {
+		cmd.Println(views.NewVerificationView(report).Render())
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.report != "" {
+		jws, err := report.Sign(signer)
+		if err != nil {
+			return fmt.Errorf("failed to sign the report: %w", err)
+		}
+		if err := os.WriteFile(opts.report, append(jws, '\n'), 0o600); err != nil {
+			return err
+		}
+		if format == output.FormatText {
+			cmd.Printf("Signed report written to %s.\n", opts.report)
+		}
+	}
+
+	if !report.Genuine {
+		return exitcode.New(verifyExitNotGenuine, errors.New("the security key could not be proven genuine"))
+	}
+	return nil
+}
+
+// newVerifyChallenge returns a random RP ID that no relying party uses, and client data with a random challenge
+// for it.
+func newVerifyChallenge() (string, []byte, error) {
+	random := make([]byte, 40)
+	if _, err := rand.Read(random); err != nil {
+		return "", nil, err
+	}
+
+	rpID := hex.EncodeToString(random[:8]) + ".verify.skm.invalid"
+	clientData, err := json.Marshal(map[string]string{
+		"type":      "webauthn.create",
+		"challenge": base64.RawURLEncoding.EncodeToString(random[8:]),
+		"origin":    "https://" + rpID,
+	})
+	return rpID, clientData, err
+}
+
+// loadTrustStore returns the root certificates of the PEM files of paths, and of the PEM files in the directories
+// of paths. It returns nil if there are none.
+func loadTrustStore(paths []string) (*x509.CertPool, error) {
+	var files []string
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+
+	var pool *x509.CertPool
+	for _, file := range files {
+		b, err := os.ReadFile(file) //nolint:gosec // the file is chosen by the user to hold trusted roots
+		if err != nil {
+			return nil, err
+		}
+		if pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, exitcode.New(exitcode.Usage, fmt.Errorf("no PEM certificate found in %s", file))
+		}
+	}
+	return pool, nil
+}
+
+// loadSigningKey reads the PEM private key of path: PKCS #8, SEC 1 (EC) or PKCS #1 (RSA).
+func loadSigningKey(path string) (crypto.Signer, error) {
+	b, err := os.ReadFile(path) //nolint:gosec // the file is chosen by the user to hold their signing key
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, exitcode.New(exitcode.Usage, fmt.Errorf("no PEM private key found in %s", path))
+	}
+
+	var key any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, exitcode.New(exitcode.Usage, fmt.Errorf("invalid private key in %s: %w", path, err))
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, exitcode.New(exitcode.Usage, fmt.Errorf("unsupported private key %T in %s", key, path))
+	}
+	return signer, nil
+}
@@ -0,0 +1,122 @@
+package skm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mohammadv184/skm/internal/skm/exitcode"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	signKey := filepath.Join(dir, "signer.pem")
+	if err := os.WriteFile(signKey, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	notPEM := filepath.Join(dir, "roots.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	report := filepath.Join(dir, "report.jwt")
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantOut  []string
+		wantErr  string
+		wantCode int
+	}{
+		{
+			name:     "self attestation",
+			args:     []string{"verify"},
+			wantOut:  []string{"packed, self attestation", "signed by the credential", "NOT PROVEN GENUINE"},
+			wantErr:  "could not be proven genuine",
+			wantCode: verifyExitNotGenuine,
+		},
+		{
+			name:     "as json",
+			args:     []string{"verify", "-o", "json"},
+			wantOut:  []string{`"kind": "VerificationReport"`, `"genuine": false`, `"format": "packed"`, `"evidence"`},
+			wantErr:  "could not be proven genuine",
+			wantCode: verifyExitNotGenuine,
+		},
+		{
+			name:     "signed report",
+			args:     []string{"verify", "--report", report, "--sign-key", signKey},
+			wantOut:  []string{"Signed report written to " + report},
+			wantErr:  "could not be proven genuine",
+			wantCode: verifyExitNotGenuine,
+		},
+		{
+			name:    "report without signing key",
+			args:    []string{"verify", "--report", report},
+			wantErr: "sign-key",
+		},
+		{
+			name:     "roots without certificates",
+			args:     []string{"verify", "--roots", notPEM},
+			wantErr:  "no PEM certificate found",
+			wantCode: exitcode.Usage,
+		},
+		{
+			name:     "invalid signing key",
+			args:     []string{"verify", "--report", report, "--sign-key", notPEM},
+			wantErr:  "no PEM private key found",
+			wantCode: exitcode.Usage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t, true)
+			r := runCommand(t, b, tt.args...)
+			r.check(t, tt.wantOut, tt.wantErr)
+
+			if tt.wantCode != 0 {
+				var exitErr *exitcode.Error
+				if !errors.As(r.err, &exitErr) || exitErr.Code != tt.wantCode {
+					t.Errorf("error = %v, want exit code %d", r.err, tt.wantCode)
+				}
+			}
+			if creds := b.Virtual(testDevicePath).State().Credentials; len(creds) != 0 {
+				t.Errorf("credentials = %d, want none left on the key", len(creds))
+			}
+		})
+	}
+
+	b, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	parts := strings.Split(strings.TrimSpace(string(b)), ".")
+	if len(parts) != 3 {
+		t.Fatalf("report = %q, want a JWS in compact serialization", b)
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		t.Fatalf("DecodeString() error = %v", err)
+	}
+	var h struct{ Alg string }
+	if err := json.Unmarshal(header, &h); err != nil || h.Alg != "ES256" {
+		t.Errorf("header = %s, want alg ES256", header)
+	}
+}
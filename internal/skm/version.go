@@ -5,15 +5,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var versionCMD = cobra.Command{
-	Use:   "version",
-	Short: "Show SKM version information",
-	Long:  `Display the current version of SKM, along with the build date and commit hash.`,
-	RunE:  versionHandler,
-}
-
-func init() {
-	rootCMD.AddCommand(&versionCMD)
+// newVersionCommand returns the version command.
+func newVersionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Show SKM version information",
+		Long:  `Display the current version of SKM, along with the build date and commit hash.`,
+		RunE:  versionHandler,
+	}
 }
 
 func versionHandler(cmd *cobra.Command, _ []string) error {
@@ -0,0 +1,101 @@
+package skm
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/authenticator/authenticatortest"
+)
+
+func TestWait(t *testing.T) {
+	tests := []struct {
+		name       string
+		present    []fido2.DeviceDescriptor
+		plugged    []fido2.DeviceDescriptor
+		args       []string
+		wantOut    []string
+		wantStderr string
+		wantErr    string
+	}{
+		{
+			name:       "plugged in later",
+			plugged:    []fido2.DeviceDescriptor{{Product: "YubiKey 5", SerialNumber: "111"}},
+			args:       []string{"list", "--wait"},
+			wantOut:    []string{"YubiKey 5", "111"},
+			wantStderr: "Insert your security key…",
+		},
+		{
+			name:       "matching selectors",
+			present:    []fido2.DeviceDescriptor{{Product: "YubiKey 5", SerialNumber: "111"}},
+			plugged:    []fido2.DeviceDescriptor{{Product: "Solo 2", SerialNumber: "222"}},
+			args:       []string{"info", "--serial", "222", "--wait=5s"},
+			wantOut:    []string{"/dev/hidraw1"},
+			wantStderr: "Insert a security key matching the selectors…",
+		},
+		{
+			name:       "device index plugged in later",
+			present:    []fido2.DeviceDescriptor{{Product: "YubiKey 5", SerialNumber: "111"}},
+			plugged:    []fido2.DeviceDescriptor{{Product: "Solo 2", SerialNumber: "222"}},
+			args:       []string{"info", "--device-index", "1", "--wait=5s"},
+			wantOut:    []string{"Solo 2", "222"},
+			wantStderr: "Insert a security key matching the selectors…",
+		},
+		{
+			name:    "already connected",
+			present: []fido2.DeviceDescriptor{{Product: "YubiKey 5", SerialNumber: "111"}},
+			args:    []string{"list", "--wait=1ms"},
+			wantOut: []string{"YubiKey 5"},
+		},
+		{
+			name:    "timeout",
+			args:    []string{"info", "--wait=50ms"},
+			wantErr: "no security keys found after waiting 50ms",
+		},
+		{
+			name:    "timeout with selectors",
+			present: []fido2.DeviceDescriptor{{Product: "YubiKey 5", SerialNumber: "111"}},
+			args:    []string{"pin", "set", "--serial", "222", "--wait=50ms"},
+			wantErr: "no security key matches the given selectors after waiting 50ms",
+		},
+		{
+			name:    "device index without wait",
+			present: []fido2.DeviceDescriptor{{Product: "YubiKey 5", SerialNumber: "111"}},
+			args:    []string{"info", "--device-index", "3"},
+			wantErr: "device index out of range: 3, 1 security keys found",
+		},
+		{
+			name:    "without wait",
+			args:    []string{"info"},
+			wantErr: "no security keys found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := authenticatortest.NewBackend(t)
+			for _, d := range tt.present {
+				b.Add(d)
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				time.Sleep(50 * time.Millisecond)
+				for _, d := range tt.plugged {
+					b.Add(d)
+				}
+			}()
+			defer func() {
+				<-done
+			}()
+
+			r := runCommand(t, b, tt.args...)
+			r.check(t, tt.wantOut, tt.wantErr)
+			if !strings.Contains(r.stderr, tt.wantStderr) {
+				t.Errorf("stderr does not contain %q:\n%s", tt.wantStderr, r.stderr)
+			}
+		})
+	}
+}
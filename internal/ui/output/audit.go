@@ -0,0 +1,205 @@
+package output
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/policy"
+)
+
+// AuditRule is the outcome of an audit rule.
+type AuditRule struct {
+	Rule     string `json:"rule" yaml:"rule"`
+	Expected string `json:"expected" yaml:"expected"`
+	Actual   string `json:"actual" yaml:"actual"`
+	Status   string `json:"status" yaml:"status"`
+	Message  string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// AuditedDevice is the audit result of a single security key.
+type AuditedDevice struct {
+	Device          Device      `json:"device" yaml:"device"`
+	AAGUID          string      `json:"aaguid" yaml:"aaguid"`
+	FirmwareVersion uint        `json:"firmwareVersion" yaml:"firmwareVersion"`
+	Passed          bool        `json:"passed" yaml:"passed"`
+	Error           string      `json:"error,omitempty" yaml:"error,omitempty"`
+	Rules           []AuditRule `json:"rules" yaml:"rules"`
+}
+
+// AuditReport is the document rendered by 'skm audit'.
+type AuditReport struct {
+	SchemaVersion int             `json:"schemaVersion" yaml:"schemaVersion"`
+	Kind          string          `json:"kind" yaml:"kind"`
+	Timestamp     time.Time       `json:"timestamp" yaml:"timestamp"`
+	Policy        string          `json:"policy" yaml:"policy"`
+	PolicySHA256  string          `json:"policySha256" yaml:"policySha256"`
+	Passed        bool            `json:"passed" yaml:"passed"`
+	Devices       []AuditedDevice `json:"devices" yaml:"devices"`
+}
+
+// NewAuditReport creates an empty AuditReport for a policy.
+func NewAuditReport(pol *policy.Policy) *AuditReport {
+	return &AuditReport{
+		SchemaVersion: SchemaVersion,
+		Kind:          "AuditReport",
+		Timestamp:     time.Now().UTC(),
+		Policy:        pol.Path(),
+		PolicySHA256:  pol.Digest(),
+		Passed:        true,
+		Devices:       []AuditedDevice{},
+	}
+}
+
+// AddDevice adds the audit results of a security key to the report. The info may be nil if the security key
+// could not be opened, in which case err explains why.
+func (r *AuditReport) AddDevice(
+	desc *fido2.DeviceDescriptor,
+	info *ctap2.AuthenticatorGetInfoResponse,
+	results []policy.RuleResult,
+	err error,
+) {
+	d := AuditedDevice{
+		Device: NewDevice(desc),
+		Passed: err == nil && policy.Passed(results),
+		Rules:  make([]AuditRule, 0, len(results)),
+	}
+	if info != nil {
+		d.AAGUID = info.AAGUID.String()
+		d.FirmwareVersion = info.FirmwareVersion
+	}
+	if err != nil {
+		d.Error = err.Error()
+	}
+
+	for _, res := range results {
+		d.Rules = append(d.Rules, AuditRule{
+			Rule:     res.Rule,
+			Expected: res.Expected,
+			Actual:   res.Actual,
+			Status:   string(res.Status),
+			Message:  res.Message,
+		})
+	}
+
+	r.Passed = r.Passed && d.Passed
+	r.Devices = append(r.Devices, d)
+}
+
+// CSV returns the audit results as a CSV header and its records.
+func (r *AuditReport) CSV() ([]string, [][]string) {
+	var records [][]string
+	for _, d := range r.Devices {
+		if d.Error != "" {
+			records = append(records, []string{
+				d.Device.SerialNumber, d.Device.Path, "", "", "", string(policy.StatusError), d.Error,
+			})
+		}
+		for _, rule := range d.Rules {
+			records = append(records, []string{
+				d.Device.SerialNumber, d.Device.Path, rule.Rule, rule.Expected, rule.Actual, rule.Status, rule.Message,
+			})
+		}
+	}
+	return []string{"serialNumber", "path", "rule", "expected", "actual", "status", "message"}, records
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the audit report as JUnit XML, with a test suite per security key and a test case per rule.
+func (r *AuditReport) WriteJUnit(w io.Writer) error {
+	suites := junitTestSuites{Name: "skm audit " + r.Policy}
+
+	for _, d := range r.Devices {
+		name := d.Device.Product + " " + d.Device.SerialNumber
+		if d.Device.SerialNumber == "" {
+			name = d.Device.Product + " " + d.Device.Path
+		}
+
+		suite := junitTestSuite{Name: name, Timestamp: r.Timestamp.Format(time.RFC3339)}
+		className := "skm.audit." + d.Device.SerialNumber
+
+		if d.Error != "" {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      "open",
+				ClassName: className,
+				Error:     &junitMessage{Message: d.Error},
+			})
+			suite.Errors++
+		}
+
+		for _, rule := range d.Rules {
+			tc := junitTestCase{Name: rule.Rule, ClassName: className}
+			body := "expected: " + rule.Expected + "\nactual: " + rule.Actual
+
+			switch policy.Status(rule.Status) {
+			case policy.StatusFail:
+				tc.Failure = &junitMessage{Message: rule.Message, Body: body}
+				suite.Failures++
+			case policy.StatusError:
+				tc.Error = &junitMessage{Message: rule.Message, Body: body}
+				suite.Errors++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		suite.Tests = len(suite.Cases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Summary returns the number of audited security keys and how many of them passed.
+func (r *AuditReport) Summary() (total, passed int) {
+	for _, d := range r.Devices {
+		if d.Passed {
+			passed++
+		}
+	}
+	return len(r.Devices), passed
+}
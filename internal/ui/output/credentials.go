@@ -0,0 +1,174 @@
+package output
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/mohammadv184/skm/internal/credential"
+)
+
+// Credential describes a discoverable (resident) credential. Binary values are base64url encoded without padding,
+// except for the RP ID hash which is hex encoded. The public key is the CBOR encoded COSE key.
+type Credential struct {
+	RPID            string `json:"rpId" yaml:"rpId"`
+	RPName          string `json:"rpName" yaml:"rpName"`
+	RPIDHash        string `json:"rpIdHash" yaml:"rpIdHash"`
+	UserID          string `json:"userId" yaml:"userId"`
+	UserName        string `json:"userName" yaml:"userName"`
+	UserDisplayName string `json:"userDisplayName" yaml:"userDisplayName"`
+	CredentialID    string `json:"credentialId" yaml:"credentialId"`
+	CredProtect     uint   `json:"credProtect" yaml:"credProtect"`
+	CredProtectName string `json:"credProtectName" yaml:"credProtectName"`
+	PublicKeyAlg    string `json:"publicKeyAlg" yaml:"publicKeyAlg"`
+	PublicKey       string `json:"publicKey" yaml:"publicKey"`
+	HasLargeBlobKey bool   `json:"hasLargeBlobKey" yaml:"hasLargeBlobKey"`
+}
+
+// CredentialList is the document rendered by 'skm creds list'.
+type CredentialList struct {
+	SchemaVersion int          `json:"schemaVersion" yaml:"schemaVersion"`
+	Kind          string       `json:"kind" yaml:"kind"`
+	Device        Device       `json:"device" yaml:"device"`
+	Credentials   []Credential `json:"credentials" yaml:"credentials"`
+}
+
+// NewCredential creates a Credential from a credential management response.
+func NewCredential(cred *credential.Credential) Credential {
+	c := Credential{
+		RPID:            cred.RP.ID,
+		RPName:          cred.RP.Name,
+		RPIDHash:        hex.EncodeToString(cred.RPIDHash),
+		UserID:          base64.RawURLEncoding.EncodeToString(cred.User.ID),
+		UserName:        cred.User.Name,
+		UserDisplayName: cred.User.DisplayName,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(cred.CredentialID.ID),
+		CredProtect:     cred.CredProtect,
+		CredProtectName: credential.ProtectionName(cred.CredProtect),
+		PublicKeyAlg:    credential.PublicKeyAlgorithm(cred),
+		HasLargeBlobKey: len(cred.LargeBlobKey) > 0,
+	}
+
+	if cred.PublicKey != nil {
+		if b, err := cred.PublicKey.MarshalCBOR(); err == nil {
+			c.PublicKey = base64.RawURLEncoding.EncodeToString(b)
+		}
+	}
+
+	return c
+}
+
+// NewCredentialList creates a CredentialList for the credentials stored on a device.
+func NewCredentialList(
+	device Device,
+	creds ...*credential.Credential,
+) *CredentialList {
+	l := &CredentialList{
+		SchemaVersion: SchemaVersion,
+		Kind:          "CredentialList",
+		Device:        device,
+		Credentials:   make([]Credential, 0, len(creds)),
+	}
+	for _, c := range creds {
+		l.Credentials = append(l.Credentials, NewCredential(c))
+	}
+	return l
+}
+
+// CSV returns the credential list as a CSV header and its records.
+func (l *CredentialList) CSV() ([]string, [][]string) {
+	header := []string{
+		"rp_id",
+		"rp_name",
+		"rp_id_hash",
+		"user_id",
+		"user_name",
+		"user_display_name",
+		"credential_id",
+		"cred_protect",
+		"public_key_alg",
+		"public_key",
+		"has_large_blob_key",
+	}
+
+	records := make([][]string, 0, len(l.Credentials))
+	for _, c := range l.Credentials {
+		records = append(records, []string{
+			c.RPID,
+			c.RPName,
+			c.RPIDHash,
+			c.UserID,
+			c.UserName,
+			c.UserDisplayName,
+			c.CredentialID,
+			strconv.FormatUint(uint64(c.CredProtect), 10),
+			c.PublicKeyAlg,
+			c.PublicKey,
+			strconv.FormatBool(c.HasLargeBlobKey),
+		})
+	}
+
+	return header, records
+}
+
+// PublicKey is a credential public key exported as PEM and JWK.
+type PublicKey struct {
+	Algorithm string            `json:"algorithm" yaml:"algorithm"`
+	Curve     string            `json:"curve" yaml:"curve"`
+	PEM       string            `json:"pem" yaml:"pem"`
+	JWK       map[string]string `json:"jwk" yaml:"jwk"`
+}
+
+// CredentialDetail is the document rendered by 'skm creds show'. CredBlob is nil if it wasn't checked.
+type CredentialDetail struct {
+	SchemaVersion int        `json:"schemaVersion" yaml:"schemaVersion"`
+	Kind          string     `json:"kind" yaml:"kind"`
+	Device        Device     `json:"device" yaml:"device"`
+	Credential    Credential `json:"credential" yaml:"credential"`
+	UserIDHex     string     `json:"userIdHex" yaml:"userIdHex"`
+	PublicKey     *PublicKey `json:"publicKey" yaml:"publicKey"`
+	CredBlob      *bool      `json:"credBlob" yaml:"credBlob"`
+}
+
+// NewCredentialDetail creates a CredentialDetail for a credential stored on a device.
+func NewCredentialDetail(device Device, cred *credential.Credential, credBlob *bool) *CredentialDetail {
+	d := &CredentialDetail{
+		SchemaVersion: SchemaVersion,
+		Kind:          "CredentialDetail",
+		Device:        device,
+		Credential:    NewCredential(cred),
+		UserIDHex:     hex.EncodeToString(cred.User.ID),
+		CredBlob:      credBlob,
+	}
+
+	if cred.PublicKey != nil {
+		if pk, err := credential.DecodePublicKey(*cred.PublicKey); err == nil {
+			d.PublicKey = &PublicKey{
+				Algorithm: pk.Algorithm,
+				Curve:     pk.Curve,
+				PEM:       pk.PEM,
+				JWK:       pk.JWK,
+			}
+		}
+	}
+
+	return d
+}
+
+// CSV returns the credential details as a CSV header and a single record.
+func (d *CredentialDetail) CSV() ([]string, [][]string) {
+	header, records := (&CredentialList{Credentials: []Credential{d.Credential}}).CSV()
+
+	var pemKey, credBlob string
+	if d.PublicKey != nil {
+		pemKey = d.PublicKey.PEM
+	}
+	if d.CredBlob != nil {
+		credBlob = strconv.FormatBool(*d.CredBlob)
+	}
+
+	header = append(header, "user_id_hex", "public_key_pem", "cred_blob")
+	records[0] = append(records[0], d.UserIDHex, pemKey, credBlob)
+
+	return header, records
+}
@@ -0,0 +1,116 @@
+package output
+
+import (
+	"strconv"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/mds"
+)
+
+// Device describes a connected security key. Its Model is only set by the documents that look it up.
+type Device struct {
+	Path         string `json:"path" yaml:"path"`
+	VendorID     uint16 `json:"vendorId" yaml:"vendorId"`
+	ProductID    uint16 `json:"productId" yaml:"productId"`
+	SerialNumber string `json:"serialNumber" yaml:"serialNumber"`
+	Manufacturer string `json:"manufacturer" yaml:"manufacturer"`
+	Product      string `json:"product" yaml:"product"`
+	Model        *Model `json:"model,omitempty" yaml:"model,omitempty"`
+}
+
+// Model describes the model of a security key, identified by its AAGUID.
+type Model struct {
+	Name          string         `json:"name" yaml:"name"`
+	Vendor        string         `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Certification string         `json:"certification,omitempty" yaml:"certification,omitempty"`
+	StatusReports []StatusReport `json:"statusReports" yaml:"statusReports"`
+	Icon          string         `json:"icon,omitempty" yaml:"icon,omitempty"`
+}
+
+// StatusReport is a status the FIDO Alliance reported for a model.
+type StatusReport struct {
+	Status        string `json:"status" yaml:"status"`
+	EffectiveDate string `json:"effectiveDate,omitempty" yaml:"effectiveDate,omitempty"`
+	URL           string `json:"url,omitempty" yaml:"url,omitempty"`
+	SecurityIssue bool   `json:"securityIssue" yaml:"securityIssue"`
+}
+
+// DeviceList is the document rendered by 'skm list'.
+type DeviceList struct {
+	SchemaVersion int      `json:"schemaVersion" yaml:"schemaVersion"`
+	Kind          string   `json:"kind" yaml:"kind"`
+	Devices       []Device `json:"devices" yaml:"devices"`
+}
+
+// NewDevice creates a Device from a device descriptor.
+func NewDevice(desc *fido2.DeviceDescriptor) Device {
+	return Device{
+		Path:         desc.Path,
+		VendorID:     desc.VendorID,
+		ProductID:    desc.ProductID,
+		SerialNumber: desc.SerialNumber,
+		Manufacturer: desc.Manufacturer,
+		Product:      desc.Product,
+	}
+}
+
+// NewModel creates a Model from a model of the metadata, or returns nil if m is nil.
+func NewModel(m *mds.Model) *Model {
+	if m == nil {
+		return nil
+	}
+
+	model := &Model{
+		Name:          m.Name,
+		Vendor:        m.Vendor,
+		Certification: m.Certification,
+		StatusReports: make([]StatusReport, 0, len(m.StatusReports)),
+		Icon:          m.Icon,
+	}
+	for _, r := range m.StatusReports {
+		model.StatusReports = append(model.StatusReports, StatusReport{
+			Status:        r.Status,
+			EffectiveDate: r.EffectiveDate,
+			URL:           r.URL,
+			SecurityIssue: r.SecurityIssue(),
+		})
+	}
+	return model
+}
+
+// NewDeviceList creates a DeviceList from device descriptors.
+func NewDeviceList(devs ...fido2.DeviceDescriptor) *DeviceList {
+	l := &DeviceList{
+		SchemaVersion: SchemaVersion,
+		Kind:          "DeviceList",
+		Devices:       make([]Device, 0, len(devs)),
+	}
+	for i := range devs {
+		l.Devices = append(l.Devices, NewDevice(&devs[i]))
+	}
+	return l
+}
+
+// CSV returns the device list as a CSV header and its records.
+func (l *DeviceList) CSV() ([]string, [][]string) {
+	records := make([][]string, 0, len(l.Devices))
+	for _, d := range l.Devices {
+		records = append(records, d.csvRecord())
+	}
+	return deviceCSVHeader(), records
+}
+
+func deviceCSVHeader() []string {
+	return []string{"path", "vendor_id", "product_id", "serial_number", "manufacturer", "product"}
+}
+
+func (d Device) csvRecord() []string {
+	return []string{
+		d.Path,
+		strconv.FormatUint(uint64(d.VendorID), 10),
+		strconv.FormatUint(uint64(d.ProductID), 10),
+		d.SerialNumber,
+		d.Manufacturer,
+		d.Product,
+	}
+}
@@ -0,0 +1,35 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/mohammadv184/skm/internal/authenticator"
+)
+
+// DeviceEvent is a security key being attached or detached, written by 'skm list --json-events' as one line of
+// an NDJSON stream.
+type DeviceEvent struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Kind          string    `json:"kind"`
+	Type          string    `json:"type"`
+	Timestamp     time.Time `json:"timestamp"`
+	Device        Device    `json:"device"`
+}
+
+// NewDeviceEvent creates a DeviceEvent from a hot-plug event.
+func NewDeviceEvent(e *authenticator.Event) *DeviceEvent {
+	return &DeviceEvent{
+		SchemaVersion: SchemaVersion,
+		Kind:          "DeviceEvent",
+		Type:          string(e.Type),
+		Timestamp:     e.Time.UTC(),
+		Device:        NewDevice(&e.Device),
+	}
+}
+
+// WriteLine writes the event as a single line of JSON.
+func (e *DeviceEvent) WriteLine(w io.Writer) error {
+	return json.NewEncoder(w).Encode(e)
+}
@@ -0,0 +1,212 @@
+package output
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/credential"
+)
+
+// Algorithm is a public key credential algorithm supported by a security key.
+type Algorithm struct {
+	Type string `json:"type" yaml:"type"`
+	Alg  int    `json:"alg" yaml:"alg"`
+	Name string `json:"name" yaml:"name"`
+}
+
+// Retries describes the PIN and UV retry state of a security key. Counters the key doesn't report are nil.
+type Retries struct {
+	PIN                *uint `json:"pin" yaml:"pin"`
+	UV                 *uint `json:"uv" yaml:"uv"`
+	PowerCycleRequired bool  `json:"powerCycleRequired" yaml:"powerCycleRequired"`
+}
+
+// DeviceInfo describes a security key and everything it reports in authenticatorGetInfo.
+type DeviceInfo struct {
+	Device                           Device            `json:"device" yaml:"device"`
+	AAGUID                           string            `json:"aaguid" yaml:"aaguid"`
+	Versions                         []string          `json:"versions" yaml:"versions"`
+	Extensions                       []string          `json:"extensions" yaml:"extensions"`
+	Options                          map[string]bool   `json:"options" yaml:"options"`
+	MaxMsgSize                       uint              `json:"maxMsgSize" yaml:"maxMsgSize"`
+	PinUvAuthProtocols               []uint            `json:"pinUvAuthProtocols" yaml:"pinUvAuthProtocols"`
+	MaxCredentialCountInList         uint              `json:"maxCredentialCountInList" yaml:"maxCredentialCountInList"`
+	MaxCredentialLength              uint              `json:"maxCredentialLength" yaml:"maxCredentialLength"`
+	Transports                       []string          `json:"transports" yaml:"transports"`
+	Algorithms                       []Algorithm       `json:"algorithms" yaml:"algorithms"`
+	MaxSerializedLargeBlobArray      uint              `json:"maxSerializedLargeBlobArray" yaml:"maxSerializedLargeBlobArray"`
+	ForcePINChange                   bool              `json:"forcePinChange" yaml:"forcePinChange"`
+	MinPINLength                     uint              `json:"minPinLength" yaml:"minPinLength"`
+	MaxPINLength                     uint              `json:"maxPinLength" yaml:"maxPinLength"`
+	FirmwareVersion                  uint              `json:"firmwareVersion" yaml:"firmwareVersion"`
+	MaxCredBlobLength                uint              `json:"maxCredBlobLength" yaml:"maxCredBlobLength"`
+	MaxRPIDsForSetMinPINLength       uint              `json:"maxRpIdsForSetMinPinLength" yaml:"maxRpIdsForSetMinPinLength"`
+	PreferredPlatformUvAttempts      uint              `json:"preferredPlatformUvAttempts" yaml:"preferredPlatformUvAttempts"`
+	UvModality                       uint              `json:"uvModality" yaml:"uvModality"`
+	Certifications                   map[string]uint64 `json:"certifications" yaml:"certifications"`
+	RemainingDiscoverableCredentials uint              `json:"remainingDiscoverableCredentials" yaml:"remainingDiscoverableCredentials"`
+	VendorPrototypeConfigCommands    []uint            `json:"vendorPrototypeConfigCommands" yaml:"vendorPrototypeConfigCommands"`
+	AttestationFormats               []string          `json:"attestationFormats" yaml:"attestationFormats"`
+	UvCountSinceLastPinEntry         uint              `json:"uvCountSinceLastPinEntry" yaml:"uvCountSinceLastPinEntry"`
+	LongTouchForReset                bool              `json:"longTouchForReset" yaml:"longTouchForReset"`
+	EncIdentifier                    string            `json:"encIdentifier" yaml:"encIdentifier"`
+	TransportsForReset               []string          `json:"transportsForReset" yaml:"transportsForReset"`
+	PinComplexityPolicy              bool              `json:"pinComplexityPolicy" yaml:"pinComplexityPolicy"`
+	PinComplexityPolicyURL           string            `json:"pinComplexityPolicyUrl" yaml:"pinComplexityPolicyUrl"`
+	Retries                          Retries           `json:"retries" yaml:"retries"`
+}
+
+// DeviceInfoList is the document rendered by 'skm info'.
+type DeviceInfoList struct {
+	SchemaVersion int          `json:"schemaVersion" yaml:"schemaVersion"`
+	Kind          string       `json:"kind" yaml:"kind"`
+	Devices       []DeviceInfo `json:"devices" yaml:"devices"`
+}
+
+// NewDeviceInfo creates a DeviceInfo from a device descriptor, its authenticatorGetInfo response and retries.
+func NewDeviceInfo(
+	desc *fido2.DeviceDescriptor,
+	info *ctap2.AuthenticatorGetInfoResponse,
+	retries Retries,
+) DeviceInfo {
+	di := DeviceInfo{
+		Device:                           NewDevice(desc),
+		AAGUID:                           info.AAGUID.String(),
+		Versions:                         make([]string, 0, len(info.Versions)),
+		Extensions:                       make([]string, 0, len(info.Extensions)),
+		Options:                          make(map[string]bool, len(info.Options)),
+		MaxMsgSize:                       info.MaxMsgSize,
+		PinUvAuthProtocols:               make([]uint, 0, len(info.PinUvAuthProtocols)),
+		MaxCredentialCountInList:         info.MaxCredentialCountInList,
+		MaxCredentialLength:              info.MaxCredentialLength,
+		Transports:                       nonNil(info.Transports),
+		Algorithms:                       make([]Algorithm, 0, len(info.Algorithms)),
+		MaxSerializedLargeBlobArray:      info.MaxSerializedLargeBlobArray,
+		ForcePINChange:                   info.ForcePinChange,
+		MinPINLength:                     info.MinPinLength,
+		MaxPINLength:                     info.MaxPINLength,
+		FirmwareVersion:                  info.FirmwareVersion,
+		MaxCredBlobLength:                info.MaxCredBlobLength,
+		MaxRPIDsForSetMinPINLength:       info.MaxRPIDsForSetMinPINLength,
+		PreferredPlatformUvAttempts:      info.PreferredPlatformUvAttempts,
+		UvModality:                       info.UvModality,
+		Certifications:                   make(map[string]uint64, len(info.Certifications)),
+		RemainingDiscoverableCredentials: info.RemainingDiscoverableCredentials,
+		VendorPrototypeConfigCommands:    nonNil(info.VendorPrototypeConfigCommands),
+		AttestationFormats:               nonNil(info.AttestationFormats),
+		UvCountSinceLastPinEntry:         info.UvCountSinceLastPinEntry,
+		LongTouchForReset:                info.LongTouchForReset,
+		EncIdentifier:                    info.EncIdentifier,
+		TransportsForReset:               nonNil(info.TransportsForReset),
+		PinComplexityPolicy:              info.PinComplexityPolicy,
+		PinComplexityPolicyURL:           info.PinComplexityPolicyURL,
+		Retries:                          retries,
+	}
+
+	for _, v := range info.Versions {
+		di.Versions = append(di.Versions, string(v))
+	}
+	for _, ext := range info.Extensions {
+		di.Extensions = append(di.Extensions, string(ext))
+	}
+	for k, v := range info.Options {
+		di.Options[k.String()] = v
+	}
+	for _, p := range info.PinUvAuthProtocols {
+		di.PinUvAuthProtocols = append(di.PinUvAuthProtocols, uint(p))
+	}
+	for _, a := range info.Algorithms {
+		di.Algorithms = append(di.Algorithms, Algorithm{
+			Type: string(a.Type),
+			Alg:  int(a.Algorithm),
+			Name: credential.AlgorithmName(int(a.Algorithm)),
+		})
+	}
+	maps.Copy(di.Certifications, info.Certifications)
+
+	return di
+}
+
+// NewDeviceInfoList creates a DeviceInfoList from DeviceInfo entries.
+func NewDeviceInfoList(infos ...DeviceInfo) *DeviceInfoList {
+	return &DeviceInfoList{
+		SchemaVersion: SchemaVersion,
+		Kind:          "DeviceInfoList",
+		Devices:       nonNil(infos),
+	}
+}
+
+// CSV returns the device information as a CSV header and its records. List values are separated by
+// semicolons and map values are rendered as key=value pairs.
+func (l *DeviceInfoList) CSV() ([]string, [][]string) {
+	header := slices.Concat(deviceCSVHeader(), []string{
+		"aaguid",
+		"versions",
+		"extensions",
+		"options",
+		"algorithms",
+		"firmware_version",
+		"certifications",
+		"min_pin_length",
+		"force_pin_change",
+		"remaining_discoverable_credentials",
+		"pin_retries",
+		"uv_retries",
+		"power_cycle_required",
+	})
+
+	records := make([][]string, 0, len(l.Devices))
+	for _, d := range l.Devices {
+		options := make([]string, 0, len(d.Options))
+		for _, k := range slices.Sorted(maps.Keys(d.Options)) {
+			options = append(options, k+"="+strconv.FormatBool(d.Options[k]))
+		}
+
+		algorithms := make([]string, 0, len(d.Algorithms))
+		for _, a := range d.Algorithms {
+			algorithms = append(algorithms, strconv.Itoa(a.Alg))
+		}
+
+		certifications := make([]string, 0, len(d.Certifications))
+		for _, k := range slices.Sorted(maps.Keys(d.Certifications)) {
+			certifications = append(certifications, k+"="+strconv.FormatUint(d.Certifications[k], 10))
+		}
+
+		records = append(records, slices.Concat(d.Device.csvRecord(), []string{
+			d.AAGUID,
+			strings.Join(d.Versions, ";"),
+			strings.Join(d.Extensions, ";"),
+			strings.Join(options, ";"),
+			strings.Join(algorithms, ";"),
+			strconv.FormatUint(uint64(d.FirmwareVersion), 10),
+			strings.Join(certifications, ";"),
+			strconv.FormatUint(uint64(d.MinPINLength), 10),
+			strconv.FormatBool(d.ForcePINChange),
+			strconv.FormatUint(uint64(d.RemainingDiscoverableCredentials), 10),
+			optionalUint(d.Retries.PIN),
+			optionalUint(d.Retries.UV),
+			strconv.FormatBool(d.Retries.PowerCycleRequired),
+		}))
+	}
+
+	return header, records
+}
+
+func optionalUint(v *uint) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*v), 10)
+}
+
+// nonNil returns s, or an empty slice if s is nil, so that it is rendered as [] rather than null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
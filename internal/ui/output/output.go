@@ -0,0 +1,107 @@
+// Package output renders machine-readable (JSON, YAML and CSV) representations of skm data.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format.
+type Format string
+
+const (
+	// FormatText renders the human-readable views.
+	FormatText Format = "text"
+	// FormatJSON renders JSON documents.
+	FormatJSON Format = "json"
+	// FormatYAML renders YAML documents.
+	FormatYAML Format = "yaml"
+	// FormatCSV renders CSV tables.
+	FormatCSV Format = "csv"
+)
+
+// SchemaVersion is the version of the JSON, YAML and CSV schemas. It is bumped whenever a field is renamed or
+// removed; new fields may be added without a version bump.
+const SchemaVersion = 1
+
+const flagName = "output"
+
+// Formats lists all supported output formats.
+var Formats = []Format{FormatText, FormatJSON, FormatYAML, FormatCSV}
+
+// Document is a versioned, machine-readable document.
+type Document interface {
+	// CSV returns the document as a CSV header and its records.
+	CSV() ([]string, [][]string)
+}
+
+// RegisterFlag registers the --output flag on cmd. Only the commands that render documents register it, so that
+// the others reject it rather than print text where a script expects a document.
+func RegisterFlag(cmd *cobra.Command) {
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+
+	cmd.Flags().StringP(
+		flagName,
+		"o",
+		string(FormatText),
+		"Output format, one of: "+strings.Join(names, ", "),
+	)
+	_ = cmd.RegisterFlagCompletionFunc(
+		flagName,
+		cobra.FixedCompletions(names, cobra.ShellCompDirectiveNoFileComp),
+	)
+}
+
+// FromCommand returns the output format selected with the --output flag.
+func FromCommand(cmd *cobra.Command) (Format, error) {
+	flag := cmd.Flags().Lookup(flagName)
+	if flag == nil {
+		return FormatText, nil
+	}
+
+	f := Format(strings.ToLower(flag.Value.String()))
+	if !slices.Contains(Formats, f) {
+		return "", fmt.Errorf("unsupported output format %q", flag.Value.String())
+	}
+
+	return f, nil
+}
+
+// Write writes doc to w in the given format. It must not be called with FormatText.
+func Write(w io.Writer, f Format, doc Document) error {
+	switch f {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatCSV:
+		header, records := doc.CSV()
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(records); err != nil {
+			return err
+		}
+		return cw.Error()
+	default:
+		return fmt.Errorf("output format %q cannot be written as a document", f)
+	}
+}
@@ -0,0 +1,98 @@
+package output
+
+import (
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/policy"
+)
+
+// Provisioning results.
+const (
+	// ResultProvisioned means every change of the plan was applied.
+	ResultProvisioned = "provisioned"
+	// ResultUnchanged means the security key already matched the policy.
+	ResultUnchanged = "unchanged"
+	// ResultSkipped means the security key cannot be reconciled with the policy and was left unchanged.
+	ResultSkipped = "skipped"
+	// ResultFailed means applying a change failed.
+	ResultFailed = "failed"
+)
+
+// ProvisioningStep is a setting of a provisioning receipt.
+type ProvisioningStep struct {
+	Setting string `json:"setting" yaml:"setting"`
+	Before  string `json:"before" yaml:"before"`
+	Desired string `json:"desired" yaml:"desired"`
+	Action  string `json:"action" yaml:"action"`
+	Status  string `json:"status" yaml:"status"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// ProvisioningReceipt is the document written by 'skm provision' for each security key.
+type ProvisioningReceipt struct {
+	SchemaVersion   int                `json:"schemaVersion" yaml:"schemaVersion"`
+	Kind            string             `json:"kind" yaml:"kind"`
+	Timestamp       time.Time          `json:"timestamp" yaml:"timestamp"`
+	Device          Device             `json:"device" yaml:"device"`
+	AAGUID          string             `json:"aaguid" yaml:"aaguid"`
+	FirmwareVersion uint               `json:"firmwareVersion" yaml:"firmwareVersion"`
+	Policy          string             `json:"policy" yaml:"policy"`
+	PolicySHA256    string             `json:"policySha256" yaml:"policySha256"`
+	Result          string             `json:"result" yaml:"result"`
+	Error           string             `json:"error,omitempty" yaml:"error,omitempty"`
+	Steps           []ProvisioningStep `json:"steps" yaml:"steps"`
+}
+
+// NewProvisioningReceipt creates a ProvisioningReceipt for a plan. All steps are initially pending.
+func NewProvisioningReceipt(
+	desc *fido2.DeviceDescriptor,
+	info *ctap2.AuthenticatorGetInfoResponse,
+	pol *policy.Policy,
+	plan *policy.Plan,
+) *ProvisioningReceipt {
+	r := &ProvisioningReceipt{
+		SchemaVersion:   SchemaVersion,
+		Kind:            "ProvisioningReceipt",
+		Timestamp:       time.Now().UTC(),
+		Device:          NewDevice(desc),
+		AAGUID:          info.AAGUID.String(),
+		FirmwareVersion: info.FirmwareVersion,
+		Policy:          pol.Path(),
+		PolicySHA256:    pol.Digest(),
+		Steps:           make([]ProvisioningStep, 0, len(plan.Steps)),
+	}
+
+	for _, s := range plan.Steps {
+		status := "pending"
+		switch {
+		case s.Problem != "":
+			status = "blocked"
+		case s.Action == policy.ActionNone:
+			status = "ok"
+		}
+
+		r.Steps = append(r.Steps, ProvisioningStep{
+			Setting: s.Setting,
+			Before:  s.Current,
+			Desired: s.Desired,
+			Action:  string(s.Action),
+			Status:  status,
+			Error:   s.Problem,
+		})
+	}
+
+	return r
+}
+
+// CSV returns the receipt steps as a CSV header and its records.
+func (r *ProvisioningReceipt) CSV() ([]string, [][]string) {
+	records := make([][]string, 0, len(r.Steps))
+	for _, s := range r.Steps {
+		records = append(records, []string{
+			r.Device.SerialNumber, s.Setting, s.Before, s.Desired, s.Action, s.Status, s.Error,
+		})
+	}
+	return []string{"serialNumber", "setting", "before", "desired", "action", "status", "error"}, records
+}
@@ -0,0 +1,204 @@
+package output
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/attestation"
+)
+
+// Certificate describes a certificate of an attestation chain.
+type Certificate struct {
+	Subject      string    `json:"subject" yaml:"subject"`
+	Issuer       string    `json:"issuer" yaml:"issuer"`
+	SerialNumber string    `json:"serialNumber" yaml:"serialNumber"`
+	NotBefore    time.Time `json:"notBefore" yaml:"notBefore"`
+	NotAfter     time.Time `json:"notAfter" yaml:"notAfter"`
+	SHA256       string    `json:"sha256" yaml:"sha256"`
+}
+
+// VerificationCheck is the outcome of a check of an attestation.
+type VerificationCheck struct {
+	Check   string `json:"check" yaml:"check"`
+	Status  string `json:"status" yaml:"status"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// AttestationEvidence is the attestation the checks were made on, base64url encoded, so that they can be made
+// again.
+type AttestationEvidence struct {
+	RPID                 string `json:"rpId" yaml:"rpId"`
+	ClientData           string `json:"clientData" yaml:"clientData"`
+	AuthenticatorData    string `json:"authenticatorData" yaml:"authenticatorData"`
+	AttestationStatement string `json:"attestationStatement" yaml:"attestationStatement"`
+}
+
+// NewAttestationEvidence creates an AttestationEvidence from the raw client data, authenticator data and CBOR
+// encoded attestation statement.
+func NewAttestationEvidence(rpID string, clientData, authData, attStmt []byte) AttestationEvidence {
+	b64 := base64.RawURLEncoding.EncodeToString
+	return AttestationEvidence{
+		RPID:                 rpID,
+		ClientData:           b64(clientData),
+		AuthenticatorData:    b64(authData),
+		AttestationStatement: b64(attStmt),
+	}
+}
+
+// VerificationReport is the document rendered by 'skm verify'.
+type VerificationReport struct {
+	SchemaVersion     int                 `json:"schemaVersion" yaml:"schemaVersion"`
+	Kind              string              `json:"kind" yaml:"kind"`
+	Timestamp         time.Time           `json:"timestamp" yaml:"timestamp"`
+	Device            Device              `json:"device" yaml:"device"`
+	AAGUID            string              `json:"aaguid" yaml:"aaguid"`
+	CertificateAAGUID string              `json:"certificateAaguid,omitempty" yaml:"certificateAaguid,omitempty"`
+	Genuine           bool                `json:"genuine" yaml:"genuine"`
+	Format            string              `json:"format" yaml:"format"`
+	AttestationType   string              `json:"attestationType,omitempty" yaml:"attestationType,omitempty"`
+	Certificates      []Certificate       `json:"certificates" yaml:"certificates"`
+	TrustAnchor       *Certificate        `json:"trustAnchor,omitempty" yaml:"trustAnchor,omitempty"`
+	Checks            []VerificationCheck `json:"checks" yaml:"checks"`
+	Evidence          AttestationEvidence `json:"evidence" yaml:"evidence"`
+}
+
+// NewVerificationReport creates a VerificationReport from the verification of the attestation evidence of a
+// security key.
+func NewVerificationReport(
+	desc *fido2.DeviceDescriptor,
+	res *attestation.Result,
+	evidence AttestationEvidence,
+) *VerificationReport {
+	r := &VerificationReport{
+		SchemaVersion:   SchemaVersion,
+		Kind:            "VerificationReport",
+		Timestamp:       time.Now().UTC(),
+		Device:          NewDevice(desc),
+		AAGUID:          res.AAGUID.String(),
+		Genuine:         res.Genuine(),
+		Format:          res.Format,
+		AttestationType: string(res.Type),
+		Certificates:    make([]Certificate, 0, len(res.Certificates)),
+		Checks:          make([]VerificationCheck, 0, len(res.Checks)),
+		Evidence:        evidence,
+	}
+	if res.CertificateAAGUID != nil {
+		r.CertificateAAGUID = res.CertificateAAGUID.String()
+	}
+	for _, cert := range res.Certificates {
+		r.Certificates = append(r.Certificates, newCertificate(cert))
+	}
+	if res.Root != nil {
+		root := newCertificate(res.Root)
+		r.TrustAnchor = &root
+	}
+	for _, c := range res.Checks {
+		r.Checks = append(r.Checks, VerificationCheck{Check: c.Name, Status: string(c.Status), Message: c.Message})
+	}
+	return r
+}
+
+func newCertificate(cert *x509.Certificate) Certificate {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return Certificate{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.Text(16),
+		NotBefore:    cert.NotBefore.UTC(),
+		NotAfter:     cert.NotAfter.UTC(),
+		SHA256:       hex.EncodeToString(fingerprint[:]),
+	}
+}
+
+// CSV returns the checks of the report as a CSV header and its records.
+func (r *VerificationReport) CSV() ([]string, [][]string) {
+	records := make([][]string, 0, len(r.Checks))
+	for _, c := range r.Checks {
+		records = append(records, []string{r.Device.SerialNumber, r.AAGUID, c.Check, c.Status, c.Message})
+	}
+	return []string{"serialNumber", "aaguid", "check", "status", "message"}, records
+}
+
+// Sign returns the report as a JWS in compact serialization, signed with key: an ECDSA, Ed25519 or RSA private
+// key. The kid of its header is the SHA-256 fingerprint of the DER encoded public key, in hex.
+func (r *VerificationReport) Sign(key crypto.Signer) ([]byte, error) {
+	var alg string
+	var hash crypto.Hash
+	switch pub := key.Public().(type) {
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			alg, hash = "ES256", crypto.SHA256
+		case elliptic.P384():
+			alg, hash = "ES384", crypto.SHA384
+		case elliptic.P521():
+			alg, hash = "ES512", crypto.SHA512
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", pub.Curve.Params().Name)
+		}
+	case *rsa.PublicKey:
+		alg, hash = "RS256", crypto.SHA256
+	case ed25519.PublicKey:
+		alg = "EdDSA"
+	default:
+		return nil, fmt.Errorf("unsupported signing key %T", pub)
+	}
+
+	spki, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	kid := sha256.Sum256(spki)
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": hex.EncodeToString(kid[:])})
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	signed := b64(header) + "." + b64(payload)
+	digest := []byte(signed)
+	if hash != 0 {
+		h := hash.New()
+		h.Write(digest)
+		digest = h.Sum(nil)
+	}
+	sig, err := key.Sign(rand.Reader, digest, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if pub, ok := key.Public().(*ecdsa.PublicKey); ok {
+		// JWS encodes the ECDSA signature as r || s, crypto as an ASN.1 sequence.
+		if sig, err = jwsECDSASignature(pub, sig); err != nil {
+			return nil, err
+		}
+	}
+	return []byte(signed + "." + b64(sig)), nil
+}
+
+func jwsECDSASignature(pub *ecdsa.PublicKey, der []byte) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, err
+	}
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	return append(sig.R.FillBytes(make([]byte, size)), sig.S.FillBytes(make([]byte, size))...), nil
+}
@@ -0,0 +1,226 @@
+package prompts
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+)
+
+// BioSampleCapturer captures the next fingerprint sample of the enrollment of templateID, or begins a new
+// enrollment if templateID is nil. It blocks until the user touches the sensor or the capture times out.
+type BioSampleCapturer func(templateID []byte) (*ctap2.AuthenticatorBioEnrollmentResponse, error)
+
+type bioSampleMsg struct {
+	resp *ctap2.AuthenticatorBioEnrollmentResponse
+	err  error
+}
+
+// BioEnrollPrompt is a progress view that captures fingerprint samples until the enrollment is complete,
+// showing the samples remaining and the quality feedback of the last sample.
+type BioEnrollPrompt struct {
+	spinner    spinner.Model
+	capture    BioSampleCapturer
+	total      uint
+	remaining  uint
+	captured   uint
+	lastStatus *ctap2.LastEnrollSampleStatus
+	templateID []byte
+	done       bool
+	// canceling is set once the user asked to cancel: the key is busy until the capture in flight returns.
+	canceling bool
+	quitting  bool
+	err       error
+}
+
+// NewBioEnrollPrompt creates a new BioEnrollPrompt that calls capture for every sample.
+func NewBioEnrollPrompt(capture BioSampleCapturer) *BioEnrollPrompt {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#11998e", Dark: "#4ecdc4"})
+
+	return &BioEnrollPrompt{
+		spinner: s,
+		capture: capture,
+	}
+}
+
+// WithTotalSamples sets the number of samples the sensor requires for an enrollment, if known.
+func (p *BioEnrollPrompt) WithTotalSamples(total uint) *BioEnrollPrompt {
+	p.total = total
+	p.remaining = total
+	return p
+}
+
+// captureNext returns a command capturing the next sample of the enrollment of templateID.
+func (p *BioEnrollPrompt) captureNext(templateID []byte) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := p.capture(templateID)
+		return bioSampleMsg{resp: resp, err: err}
+	}
+}
+
+// Init initializes the bubbletea model.
+func (p *BioEnrollPrompt) Init() tea.Cmd {
+	return tea.Batch(p.spinner.Tick, p.captureNext(nil))
+}
+
+// Update handles message updates for the bubbletea model.
+func (p *BioEnrollPrompt) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			p.canceling = true
+		}
+	case bioSampleMsg:
+		if msg.err != nil {
+			p.err = msg.err
+			return p, tea.Quit
+		}
+
+		if msg.resp.TemplateID != nil {
+			p.templateID = msg.resp.TemplateID
+		}
+		status := msg.resp.LastEnrollSampleStatus
+		p.lastStatus = &status
+		if status == ctap2.LastEnrollSampleStatusFingerprintGood {
+			p.captured++
+		}
+
+		p.remaining = msg.resp.RemainingSamples
+		if p.captured+p.remaining > p.total {
+			p.total = p.captured + p.remaining
+		}
+
+		if p.remaining == 0 {
+			p.done = true
+			return p, tea.Quit
+		}
+		if p.canceling {
+			p.quitting = true
+			return p, tea.Quit
+		}
+		return p, p.captureNext(p.templateID)
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		p.spinner, cmd = p.spinner.Update(msg)
+		return p, cmd
+	}
+
+	return p, nil
+}
+
+// View renders the prompt view.
+func (p *BioEnrollPrompt) View() string {
+	if p.quitting {
+		return ""
+	}
+
+	accent := lipgloss.AdaptiveColor{Light: "#11998e", Dark: "#4ecdc4"}
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(accent)
+	filledStyle := lipgloss.NewStyle().Foreground(accent)
+	emptyStyle := lipgloss.NewStyle().Faint(true)
+	goodStyle := lipgloss.NewStyle().Foreground(accent)
+	badStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Enrolling fingerprint"))
+	b.WriteString("\n\n")
+
+	const barWidth = 30
+	filled := 0
+	if p.total > 0 {
+		filled = int(p.captured * barWidth / p.total)
+	}
+	b.WriteString(filledStyle.Render(strings.Repeat("█", filled)))
+	b.WriteString(emptyStyle.Render(strings.Repeat("░", barWidth-filled)))
+	b.WriteString(" " + strconv.FormatUint(uint64(p.captured), 10) + " samples captured")
+	if p.total > 0 {
+		b.WriteString(", " + strconv.FormatUint(uint64(p.remaining), 10) + " remaining")
+	}
+	b.WriteString("\n\n")
+
+	if p.lastStatus != nil {
+		if *p.lastStatus == ctap2.LastEnrollSampleStatusFingerprintGood {
+			b.WriteString(goodStyle.Render("Last sample: good"))
+		} else {
+			b.WriteString(badStyle.Render("Last sample: " + sampleFeedback(*p.lastStatus)))
+		}
+		b.WriteString("\n\n")
+	}
+
+	switch {
+	case p.err != nil:
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render(p.err.Error()))
+	case p.done:
+		b.WriteString(goodStyle.Render("Fingerprint enrolled."))
+	case p.canceling:
+		b.WriteString(p.spinner.View() + " Canceling once the current sample is captured or times out...")
+	default:
+		b.WriteString(p.spinner.View() + " Place your finger on the sensor, then lift it.  (esc to cancel)")
+	}
+
+	return lipgloss.NewStyle().Padding(1, 0, 1, 1).Render(b.String()) + "\n"
+}
+
+// sampleFeedback describes how to correct a rejected fingerprint sample.
+func sampleFeedback(status ctap2.LastEnrollSampleStatus) string {
+	switch status {
+	case ctap2.LastEnrollSampleStatusFingerprintTooHigh:
+		return "finger too high, move it down"
+	case ctap2.LastEnrollSampleStatusFingerprintTooLow:
+		return "finger too low, move it up"
+	case ctap2.LastEnrollSampleStatusFingerprintTooLeft:
+		return "finger too far left, move it right"
+	case ctap2.LastEnrollSampleStatusFingerprintTooRight:
+		return "finger too far right, move it left"
+	case ctap2.LastEnrollSampleStatusFingerprintTooFast:
+		return "finger moved too fast, hold it still"
+	case ctap2.LastEnrollSampleStatusFingerprintTooSlow:
+		return "finger moved too slowly"
+	case ctap2.LastEnrollSampleStatusFingerprintPoorQuality:
+		return "poor quality, make sure the sensor and your finger are clean and dry"
+	case ctap2.LastEnrollSampleStatusFingerprintTooSkewed:
+		return "finger too skewed, place it straight on the sensor"
+	case ctap2.LastEnrollSampleStatusFingerprintTooShort:
+		return "touch too short, hold your finger on the sensor longer"
+	case ctap2.LastEnrollSampleStatusFingerprintMergeFailure:
+		return "sample could not be merged, try again"
+	case ctap2.LastEnrollSampleStatusFingerprintExists:
+		return "this fingerprint is already enrolled"
+	case ctap2.LastEnrollSampleStatusNoUserActivity:
+		return "no finger detected"
+	case ctap2.LastEnrollSampleStatusNoUserPresenceTransition:
+		return "lift your finger between samples"
+	default:
+		return "rejected (status " + strconv.FormatUint(uint64(status), 10) + ")"
+	}
+}
+
+// Run executes the prompt until the enrollment is complete and returns the template ID of the new enrollment.
+// Canceling waits for the capture in flight to return, so the enrollment can be canceled once Run returns.
+func (p *BioEnrollPrompt) Run() ([]byte, error) {
+	tm, err := tea.NewProgram(p).Run()
+	if err != nil {
+		return nil, err
+	}
+
+	finalModel, ok := tm.(*BioEnrollPrompt)
+	if !ok {
+		return nil, errors.New("failed to get result from prompt")
+	}
+
+	if finalModel.err != nil {
+		return nil, finalModel.err
+	}
+	if finalModel.quitting || !finalModel.done {
+		return nil, ErrCanceled
+	}
+
+	return finalModel.templateID, nil
+}
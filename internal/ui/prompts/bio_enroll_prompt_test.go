@@ -0,0 +1,60 @@
+package prompts
+
+import (
+	"bytes"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+)
+
+func TestBioEnrollPromptCancelWaitsForCapture(t *testing.T) {
+	var captured [][]byte
+	p := NewBioEnrollPrompt(func(templateID []byte) (*ctap2.AuthenticatorBioEnrollmentResponse, error) {
+		captured = append(captured, templateID)
+		return &ctap2.AuthenticatorBioEnrollmentResponse{RemainingSamples: 2}, nil
+	}).WithTotalSamples(3)
+
+	// The enrollment began, and the next sample is being captured.
+	p.Update(bioSampleMsg{resp: &ctap2.AuthenticatorBioEnrollmentResponse{
+		TemplateID:       []byte{1},
+		RemainingSamples: 2,
+	}})
+
+	if _, cmd := p.Update(tea.KeyMsg{Type: tea.KeyEsc}); cmd != nil {
+		t.Fatal("esc returned a command while a capture is in flight")
+	}
+	if p.quitting {
+		t.Fatal("esc quit while a capture is in flight")
+	}
+
+	_, cmd := p.Update(bioSampleMsg{resp: &ctap2.AuthenticatorBioEnrollmentResponse{RemainingSamples: 1}})
+	if cmd == nil {
+		t.Fatal("the returned capture did not quit")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok || !p.quitting {
+		t.Error("the returned capture did not quit")
+	}
+	if len(captured) != 0 {
+		t.Errorf("captured %d samples after esc, want 0", len(captured))
+	}
+}
+
+func TestBioEnrollPromptCapturesTemplate(t *testing.T) {
+	var captured [][]byte
+	p := NewBioEnrollPrompt(func(templateID []byte) (*ctap2.AuthenticatorBioEnrollmentResponse, error) {
+		captured = append(captured, templateID)
+		return &ctap2.AuthenticatorBioEnrollmentResponse{RemainingSamples: 1}, nil
+	})
+
+	p.Update(bioSampleMsg{resp: &ctap2.AuthenticatorBioEnrollmentResponse{
+		TemplateID:       []byte{1},
+		RemainingSamples: 2,
+	}})
+	_, cmd := p.Update(bioSampleMsg{resp: &ctap2.AuthenticatorBioEnrollmentResponse{RemainingSamples: 1}})
+	cmd()
+
+	if len(captured) != 1 || !bytes.Equal(captured[0], []byte{1}) {
+		t.Errorf("captured template IDs = %v, want [[1]]", captured)
+	}
+}
@@ -85,7 +85,7 @@ func (p *ConfirmPrompt) Run() (bool, error) {
 	}
 
 	if finalModel.quitting {
-		return false, errors.New("canceled")
+		return false, ErrCanceled
 	}
 
 	return finalModel.value, nil
@@ -3,30 +3,28 @@ package prompts
 import (
 	"encoding/base64"
 	"errors"
+	"strconv"
 
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/credential"
 )
 
-// CredentialSelectPrompt is a prompt for selecting a credential from a list.
+// CredentialSelectPrompt is a prompt for selecting a credential from a list. In multi-select mode, space
+// toggles the highlighted credential and enter confirms all toggled credentials.
 type CredentialSelectPrompt struct {
 	table    table.Model
-	creds    []*ctap2.AuthenticatorCredentialManagementResponse
-	selected *ctap2.AuthenticatorCredentialManagementResponse
+	rps      []*credential.RelyingParty
+	creds    []*credential.Credential
+	multi    bool
+	marked   map[int]bool
+	selected []*credential.Credential
 	quitting bool
 }
 
 // NewCredentialSelectPrompt creates a new CredentialSelectPrompt.
 func NewCredentialSelectPrompt() *CredentialSelectPrompt {
-	columns := []table.Column{
-		{Title: "RP", Width: 20},
-		{Title: "USER", Width: 20},
-		{Title: "DISPLAY NAME", Width: 20},
-		{Title: "CREDENTIAL ID", Width: 20},
-	}
-
 	s := table.DefaultStyles()
 	s.Header = s.Header.
 		Bold(true).
@@ -42,43 +40,84 @@ func NewCredentialSelectPrompt() *CredentialSelectPrompt {
 		Bold(true)
 
 	t := table.New(
-		table.WithColumns(columns),
+		table.WithColumns(credentialSelectColumns(false)),
 		table.WithFocused(true),
 		table.WithHeight(10),
 	)
 	t.SetStyles(s)
 
 	return &CredentialSelectPrompt{
-		table: t,
+		table:  t,
+		marked: map[int]bool{},
 	}
 }
 
-// WithCredentials sets the credentials to display in the prompt.
-func (p *CredentialSelectPrompt) WithCredentials(
-	creds ...*ctap2.AuthenticatorCredentialManagementResponse,
-) *CredentialSelectPrompt {
-	p.creds = creds
-	rows := make([]table.Row, len(creds))
-	for i, cred := range creds {
-		rpName := cred.RP.Name
-		if rpName == "" {
-			rpName = cred.RP.ID
-		}
+func credentialSelectColumns(multi bool) []table.Column {
+	columns := []table.Column{
+		{Title: "RP", Width: 20},
+		{Title: "USER", Width: 20},
+		{Title: "DISPLAY NAME", Width: 20},
+		{Title: "CREDENTIAL ID", Width: 20},
+		{Title: "KEY", Width: 8},
+	}
+	if multi {
+		columns = append([]table.Column{{Title: "", Width: 3}}, columns...)
+	}
+	return columns
+}
 
-		credID := base64.RawURLEncoding.EncodeToString(cred.CredentialID.ID)
-		if len(credID) > 20 {
-			credID = credID[:17] + "..."
-		}
+// WithRelyingParties sets the credentials to display in the prompt. Credentials are grouped by relying party,
+// which is only shown on the first row of each group.
+func (p *CredentialSelectPrompt) WithRelyingParties(rps ...*credential.RelyingParty) *CredentialSelectPrompt {
+	p.rps = rps
+	p.creds = credential.All(rps)
+	p.marked = map[int]bool{}
+	p.updateRows()
+	return p
+}
+
+// WithMultiSelect enables selecting several credentials, toggled with space. Use RunMulti to run the prompt.
+func (p *CredentialSelectPrompt) WithMultiSelect() *CredentialSelectPrompt {
+	p.multi = true
+	p.table.SetRows(nil)
+	p.table.SetColumns(credentialSelectColumns(true))
+	p.updateRows()
+	return p
+}
+
+func (p *CredentialSelectPrompt) updateRows() {
+	rows := make([]table.Row, 0, len(p.creds))
+	for _, rp := range p.rps {
+		for i, cred := range rp.Credentials {
+			rpName := ""
+			if i == 0 {
+				rpName = rp.DisplayName()
+			}
 
-		rows[i] = table.Row{
-			rpName,
-			cred.User.Name,
-			cred.User.DisplayName,
-			credID,
+			credID := base64.RawURLEncoding.EncodeToString(cred.CredentialID.ID)
+			if len(credID) > 20 {
+				credID = credID[:17] + "..."
+			}
+
+			row := table.Row{
+				rpName,
+				cred.User.Name,
+				cred.User.DisplayName,
+				credID,
+				credential.PublicKeyAlgorithm(cred),
+			}
+			if p.multi {
+				mark := "[ ]"
+				if p.marked[len(rows)] {
+					mark = "[x]"
+				}
+				row = append(table.Row{mark}, row...)
+			}
+
+			rows = append(rows, row)
 		}
 	}
 	p.table.SetRows(rows)
-	return p
 }
 
 // Init initializes the bubbletea model.
@@ -95,10 +134,34 @@ func (p *CredentialSelectPrompt) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "ctrl+c", "q", "esc":
 			p.quitting = true
 			return p, tea.Quit
+		case " ":
+			if !p.multi {
+				break
+			}
+			if idx := p.table.Cursor(); idx >= 0 && idx < len(p.creds) {
+				p.marked[idx] = !p.marked[idx]
+				p.updateRows()
+			}
+			return p, nil
+		case "a":
+			if !p.multi {
+				break
+			}
+			all := p.markedCount() != len(p.creds)
+			for i := range p.creds {
+				p.marked[i] = all
+			}
+			p.updateRows()
+			return p, nil
 		case "enter":
-			idx := p.table.Cursor()
-			if idx >= 0 && idx < len(p.creds) {
-				p.selected = p.creds[idx]
+			for i, cred := range p.creds {
+				if p.marked[i] {
+					p.selected = append(p.selected, cred)
+				}
+			}
+			// With nothing toggled, enter selects the highlighted credential.
+			if idx := p.table.Cursor(); len(p.selected) == 0 && idx >= 0 && idx < len(p.creds) {
+				p.selected = append(p.selected, p.creds[idx])
 			}
 			return p, tea.Quit
 		}
@@ -116,16 +179,48 @@ func (p *CredentialSelectPrompt) View() string {
 
 	helpStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241")).MarginTop(1)
 	help := helpStyle.Render("↑/↓: move • enter: select • q/esc: quit")
+	title := "Select a credential:"
+	if p.multi {
+		help = helpStyle.Render("↑/↓: move • space: toggle • a: toggle all • enter: confirm • q/esc: quit")
+		title = "Select credentials (" + strconv.Itoa(p.markedCount()) + " selected):"
+	}
+
+	var details string
+	if idx := p.table.Cursor(); idx >= 0 && idx < len(p.creds) {
+		details = lipgloss.NewStyle().Faint(true).MarginTop(1).Render(
+			"Credential ID: " + base64.RawURLEncoding.EncodeToString(p.creds[idx].CredentialID.ID),
+		)
+	}
 
 	return lipgloss.NewStyle().Margin(1, 2).Render(
-		"Select a credential:\n\n" +
+		title + "\n\n" +
 			p.table.View() + "\n" +
+			details + "\n" +
 			help,
 	)
 }
 
+func (p *CredentialSelectPrompt) markedCount() int {
+	n := 0
+	for _, marked := range p.marked {
+		if marked {
+			n++
+		}
+	}
+	return n
+}
+
 // Run executes the prompt and returns the selected credential.
-func (p *CredentialSelectPrompt) Run() (*ctap2.AuthenticatorCredentialManagementResponse, error) {
+func (p *CredentialSelectPrompt) Run() (*credential.Credential, error) {
+	selected, err := p.RunMulti()
+	if err != nil {
+		return nil, err
+	}
+	return selected[0], nil
+}
+
+// RunMulti executes the prompt and returns the selected credentials, in the order they are listed.
+func (p *CredentialSelectPrompt) RunMulti() ([]*credential.Credential, error) {
 	tm, err := tea.NewProgram(p).Run()
 	if err != nil {
 		return nil, err
@@ -136,7 +231,7 @@ func (p *CredentialSelectPrompt) Run() (*ctap2.AuthenticatorCredentialManagement
 		return nil, errors.New("failed to get result from prompt")
 	}
 
-	if finalModel.selected == nil {
+	if len(finalModel.selected) == 0 {
 		return nil, errors.New("no credential selected")
 	}
 
@@ -0,0 +1,136 @@
+package prompts
+
+import (
+	"iter"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/authenticator"
+	"github.com/mohammadv184/skm/internal/ui/views"
+)
+
+// maxWatchEvents is the number of most recent events shown by a DeviceWatchPrompt.
+const maxWatchEvents = 10
+
+type deviceEventMsg struct {
+	event authenticator.Event
+	err   error
+}
+
+// DeviceWatchPrompt is a live view of the connected security keys and of the most recent attach and detach
+// events, updated as the events arrive until the user quits.
+type DeviceWatchPrompt struct {
+	spinner spinner.Model
+	events  iter.Seq2[authenticator.Event, error]
+	devices []fido2.DeviceDescriptor
+	log     []authenticator.Event
+	err     error
+}
+
+// NewDeviceWatchPrompt creates a new DeviceWatchPrompt showing events, typically those of authenticator.Watch.
+func NewDeviceWatchPrompt(events iter.Seq2[authenticator.Event, error]) *DeviceWatchPrompt {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#11998e", Dark: "#4ecdc4"})
+
+	return &DeviceWatchPrompt{
+		spinner: s,
+		events:  events,
+	}
+}
+
+// Init initializes the bubbletea model.
+func (p *DeviceWatchPrompt) Init() tea.Cmd {
+	return p.spinner.Tick
+}
+
+// Update handles message updates for the bubbletea model.
+func (p *DeviceWatchPrompt) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			return p, tea.Quit
+		}
+	case deviceEventMsg:
+		if msg.err != nil {
+			p.err = msg.err
+			return p, nil
+		}
+		p.err = nil
+
+		switch msg.event.Type {
+		case authenticator.EventAttach:
+			p.devices = append(p.devices, msg.event.Device)
+		case authenticator.EventDetach:
+			p.devices = slices.DeleteFunc(p.devices, func(d fido2.DeviceDescriptor) bool {
+				return d == msg.event.Device
+			})
+		}
+
+		p.log = append(p.log, msg.event)
+		if len(p.log) > maxWatchEvents {
+			p.log = p.log[len(p.log)-maxWatchEvents:]
+		}
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		p.spinner, cmd = p.spinner.Update(msg)
+		return p, cmd
+	}
+
+	return p, nil
+}
+
+// View renders the prompt view.
+func (p *DeviceWatchPrompt) View() string {
+	accent := lipgloss.AdaptiveColor{Light: "#11998e", Dark: "#4ecdc4"}
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(accent)
+	attachStyle := lipgloss.NewStyle().Foreground(accent)
+	detachStyle := lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#c0392b", Dark: "#ff6b6b"})
+	faintStyle := lipgloss.NewStyle().Faint(true)
+
+	var b strings.Builder
+	b.WriteString(p.spinner.View() + " " + titleStyle.Render("Watching for security keys") + "\n")
+
+	if len(p.devices) == 0 {
+		b.WriteString("\n" + faintStyle.Render("No security keys found.") + "\n\n")
+	} else {
+		b.WriteString(views.NewDevicesListView().WithDevices(p.devices...).Render() + "\n")
+	}
+
+	for _, e := range p.log {
+		line := e.Time.Format("15:04:05") + "  "
+		if e.Type == authenticator.EventAttach {
+			line += attachStyle.Render("+ attached")
+		} else {
+			line += detachStyle.Render("- detached")
+		}
+		line += "  " + e.Device.Product + " " + faintStyle.Render("("+e.Device.Path+")")
+		b.WriteString(line + "\n")
+	}
+
+	if p.err != nil {
+		b.WriteString("\n" + detachStyle.Render("Error: "+p.err.Error()) + "\n")
+	}
+
+	b.WriteString("\n" + faintStyle.Render("Press q to quit.") + "\n")
+	return b.String()
+}
+
+// Run executes the prompt until the user quits. The events are consumed in the background; the caller stops
+// them, e.g. by cancelling the context of authenticator.Watch, once Run returns.
+func (p *DeviceWatchPrompt) Run() error {
+	prog := tea.NewProgram(p)
+	go func() {
+		for e, err := range p.events {
+			prog.Send(deviceEventMsg{event: e, err: err})
+		}
+	}()
+
+	_, err := prog.Run()
+	return err
+}
@@ -0,0 +1,128 @@
+package prompts
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+)
+
+// EnrollmentSelectPrompt is a prompt for selecting an enrolled fingerprint from a list.
+type EnrollmentSelectPrompt struct {
+	table       table.Model
+	enrollments []ctap2.TemplateInfo
+	selected    *ctap2.TemplateInfo
+	quitting    bool
+}
+
+// NewEnrollmentSelectPrompt creates a new EnrollmentSelectPrompt.
+func NewEnrollmentSelectPrompt() *EnrollmentSelectPrompt {
+	columns := []table.Column{
+		{Title: "NAME", Width: 30},
+		{Title: "TEMPLATE ID", Width: 40},
+	}
+
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		Bold(true).
+		Padding(0, 1).
+		Faint(true).
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.AdaptiveColor{Light: "#bbbbbb", Dark: "#555555"}).
+		BorderBottom(true)
+	s.Cell = s.Cell.
+		Padding(0, 1)
+	s.Selected = s.Selected.
+		Foreground(lipgloss.AdaptiveColor{Light: "#11998e", Dark: "#4ecdc4"}).
+		Bold(true)
+
+	t := table.New(
+		table.WithColumns(columns),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+	t.SetStyles(s)
+
+	return &EnrollmentSelectPrompt{
+		table: t,
+	}
+}
+
+// WithEnrollments sets the enrollments to display in the prompt.
+func (p *EnrollmentSelectPrompt) WithEnrollments(infos ...ctap2.TemplateInfo) *EnrollmentSelectPrompt {
+	p.enrollments = infos
+	rows := make([]table.Row, len(infos))
+	for i, info := range infos {
+		rows[i] = table.Row{
+			info.TemplateFriendlyName,
+			hex.EncodeToString(info.TemplateID),
+		}
+	}
+	p.table.SetRows(rows)
+	return p
+}
+
+// Init initializes the bubbletea model.
+func (p *EnrollmentSelectPrompt) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles message updates for the bubbletea model.
+func (p *EnrollmentSelectPrompt) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			p.quitting = true
+			return p, tea.Quit
+		case "enter":
+			idx := p.table.Cursor()
+			if idx >= 0 && idx < len(p.enrollments) {
+				p.selected = &p.enrollments[idx]
+			}
+			return p, tea.Quit
+		}
+	}
+
+	p.table, cmd = p.table.Update(msg)
+	return p, cmd
+}
+
+// View renders the prompt view.
+func (p *EnrollmentSelectPrompt) View() string {
+	if p.quitting {
+		return ""
+	}
+
+	helpStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("241")).MarginTop(1)
+	help := helpStyle.Render("↑/↓: move • enter: select • q/esc: quit")
+
+	return lipgloss.NewStyle().Padding(1, 0, 1, 1).Render(
+		"Select a fingerprint:\n\n" +
+			p.table.View() + "\n" +
+			help,
+	)
+}
+
+// Run executes the prompt and returns the selected enrollment.
+func (p *EnrollmentSelectPrompt) Run() (*ctap2.TemplateInfo, error) {
+	tm, err := tea.NewProgram(p).Run()
+	if err != nil {
+		return nil, err
+	}
+
+	finalModel, ok := tm.(*EnrollmentSelectPrompt)
+	if !ok {
+		return nil, errors.New("failed to get result from prompt")
+	}
+
+	if finalModel.selected == nil {
+		return nil, errors.New("no fingerprint selected")
+	}
+
+	return finalModel.selected, nil
+}
@@ -1,12 +1,17 @@
 package prompts
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
 )
 
 // PinPrompt is a prompt for entering a security key PIN.
@@ -16,9 +21,12 @@ type PinPrompt struct {
 	submitted   bool
 	retries     uint
 	title       string
+	description string
 	placeholder string
 	validate    func(string) error
 	err         error
+	in          io.Reader
+	out         io.Writer
 }
 
 // NewPinPrompt creates a new PinPrompt.
@@ -33,15 +41,31 @@ func NewPinPrompt() *PinPrompt {
 		textInput:   ti,
 		title:       "Enter PIN",
 		placeholder: "PIN",
+		in:          os.Stdin,
+		out:         os.Stderr,
 	}
 }
 
+// WithIO sets the input the PIN is read from, and the output the prompt is written to, os.Stdin and os.Stderr by
+// default.
+func (p *PinPrompt) WithIO(in io.Reader, out io.Writer) *PinPrompt {
+	p.in = in
+	p.out = out
+	return p
+}
+
 // WithTitle sets the title of the prompt.
 func (p *PinPrompt) WithTitle(title string) *PinPrompt {
 	p.title = title
 	return p
 }
 
+// WithDescription sets a description of what the PIN is for, shown below the title.
+func (p *PinPrompt) WithDescription(description string) *PinPrompt {
+	p.description = description
+	return p
+}
+
 // WithPlaceholder sets the placeholder text for the PIN input.
 func (p *PinPrompt) WithPlaceholder(placeholder string) *PinPrompt {
 	p.placeholder = placeholder
@@ -99,12 +123,14 @@ func (p *PinPrompt) View() string {
 		return ""
 	}
 
+	var description string
+	if p.description != "" {
+		description = p.description + "\n\n"
+	}
+
 	var warning string
-	if p.retries == 1 {
-		warning = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("201")).
-			Render("Warning: This is your LAST attempt before the device is locked.") +
-			"\n\n"
+	if w := p.warning(); w != "" {
+		warning = lipgloss.NewStyle().Foreground(lipgloss.Color("201")).Render(w) + "\n\n"
 	}
 
 	var errView string
@@ -113,8 +139,9 @@ func (p *PinPrompt) View() string {
 	}
 
 	return fmt.Sprintf(
-		"%s\n\n%s%s%s\n\n%s",
+		"%s\n\n%s%s%s%s\n\n%s",
 		p.title,
+		description,
 		warning,
 		errView,
 		p.textInput.View(),
@@ -122,9 +149,32 @@ func (p *PinPrompt) View() string {
 	) + "\n"
 }
 
-// Run executes the prompt and returns the entered PIN.
+// warning returns the warning to show when the user is about to block the PIN, or an empty string.
+func (p *PinPrompt) warning() string {
+	if p.retries == 1 {
+		return "Warning: This is your LAST attempt before the device is locked."
+	}
+	return ""
+}
+
+// Run executes the prompt and returns the entered PIN. The PIN is asked with the PinProvider configured by the
+// environment, if any, see PinProviderFromEnv. Otherwise, if the input is not a terminal, e.g. when the PIN is
+// piped in from a secrets manager, the PIN is read from its next line.
 func (p *PinPrompt) Run() (string, error) {
-	tm, err := tea.NewProgram(p).Run()
+	f, ok := p.in.(*os.File)
+	interactive := ok && term.IsTerminal(f.Fd())
+	provider, err := PinProviderFromEnv(interactive)
+	if err != nil {
+		return "", err
+	}
+	if provider != nil {
+		return p.runProvider(context.Background(), provider)
+	}
+	if !interactive {
+		return p.readLine(p.in, p.out)
+	}
+
+	tm, err := tea.NewProgram(p, tea.WithInput(p.in), tea.WithOutput(p.out)).Run()
 	if err != nil {
 		return "", err
 	}
@@ -135,8 +185,92 @@ func (p *PinPrompt) Run() (string, error) {
 	}
 
 	if finalModel.quitting || !finalModel.submitted {
-		return "", errors.New("canceled")
+		return "", ErrCanceled
 	}
 
 	return finalModel.textInput.Value(), nil
 }
+
+// runProvider asks for the PIN with provider, again with the reason shown until it passes the validation.
+// A PinCommand is not asked again, as it would print the same PIN.
+func (p *PinPrompt) runProvider(ctx context.Context, provider PinProvider) (string, error) {
+	req := &PinRequest{
+		Title:       p.title,
+		Description: p.description,
+		Warning:     p.warning(),
+		Retries:     p.retries,
+	}
+
+	for {
+		pin, err := provider.GetPIN(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		if p.validate == nil {
+			return pin, nil
+		}
+
+		err = p.validate(pin)
+		if err == nil {
+			return pin, nil
+		}
+		if _, ok := provider.(*PinCommand); ok {
+			return "", err
+		}
+		req.Error = err.Error()
+	}
+}
+
+// readLine reads the PIN from the next line of r, writing the title and the warning to w.
+func (p *PinPrompt) readLine(r io.Reader, w io.Writer) (string, error) {
+	if p.description != "" {
+		_, _ = fmt.Fprintln(w, p.description)
+	}
+	if warning := p.warning(); warning != "" {
+		_, _ = fmt.Fprintln(w, warning)
+	}
+	_, _ = fmt.Fprintf(w, "%s: ", p.title)
+
+	pin, err := ReadLine(r)
+	_, _ = fmt.Fprintln(w)
+	if errors.Is(err, io.EOF) {
+		return "", errors.New("no PIN given on stdin, which is not a terminal")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if p.validate != nil {
+		if err := p.validate(pin); err != nil {
+			return "", err
+		}
+	}
+	return pin, nil
+}
+
+// ReadLine reads a line from r without reading past it, so the next line is left for the next reader, and returns
+// it without its line ending. It returns io.EOF if r has no more lines.
+func ReadLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if errors.Is(err, io.EOF) {
+			if len(line) == 0 {
+				return "", io.EOF
+			}
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
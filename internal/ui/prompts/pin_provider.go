@@ -0,0 +1,301 @@
+package prompts
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Environment variables that choose how PinPrompt asks for a PIN outside of a terminal.
+const (
+	// EnvPinProvider forces a PinProvider: tty, pinentry, askpass or command.
+	EnvPinProvider = "SKM_PIN_PROVIDER"
+	// EnvPinentry is the GnuPG pinentry program to use, "pinentry" by default.
+	EnvPinentry = "SKM_PINENTRY"
+	// EnvAskpass is the askpass program to use, SSH_ASKPASS by default.
+	EnvAskpass = "SKM_ASKPASS"
+	// EnvPinCommand is a command, split on spaces, that prints the PIN on its stdout.
+	EnvPinCommand = "SKM_PIN_COMMAND"
+)
+
+// pinentryCanceled is the GnuPG error code pinentry returns when the user cancels.
+const pinentryCanceled = 99
+
+// PinRequest is what a PinProvider shows the user when asking for a PIN.
+type PinRequest struct {
+	// Title is the title of the prompt, e.g. "Enter PIN".
+	Title string
+	// Description tells the user what the PIN is for. It may be empty.
+	Description string
+	// Warning is shown when the user is about to block the PIN. It may be empty.
+	Warning string
+	// Error is why the previous PIN was rejected, when asking again. It may be empty.
+	Error string
+	// Retries is the number of PIN retries left, or 0 if unknown.
+	Retries uint
+}
+
+// message returns the description, the retries left and the warning, shown below the title.
+func (r *PinRequest) message() string {
+	var lines []string
+	if r.Description != "" {
+		lines = append(lines, r.Description)
+	}
+	if r.Retries > 0 {
+		lines = append(lines, fmt.Sprintf("%d PIN retries left.", r.Retries))
+	}
+	if r.Warning != "" {
+		lines = append(lines, r.Warning)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PinProvider asks the user for a PIN outside of a bubbletea program.
+type PinProvider interface {
+	GetPIN(ctx context.Context, req *PinRequest) (string, error)
+}
+
+// Pinentry asks for the PIN with a GnuPG pinentry program, over the Assuan protocol.
+type Pinentry struct {
+	Program string
+	Args    []string
+}
+
+// GetPIN runs the pinentry program and returns the PIN entered, or ErrCanceled if the user cancels.
+func (p *Pinentry) GetPIN(ctx context.Context, req *PinRequest) (string, error) {
+	cmd := exec.CommandContext(ctx, p.Program, p.Args...) //nolint:gosec // the program is chosen by the user
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start pinentry: %w", err)
+	}
+	defer func() {
+		_ = stdin.Close()
+		_ = cmd.Wait()
+	}()
+
+	conn := &assuanConn{w: stdin, r: bufio.NewReader(stdout)}
+	if _, err := conn.response(); err != nil {
+		return "", fmt.Errorf("pinentry: %w", err)
+	}
+
+	commands := []string{"SETTITLE skm", "SETPROMPT " + assuanEscape(req.Title+":")}
+	if msg := req.message(); msg != "" {
+		commands = append(commands, "SETDESC "+assuanEscape(msg))
+	}
+	if req.Error != "" {
+		commands = append(commands, "SETERROR "+assuanEscape(req.Error))
+	}
+	for _, c := range commands {
+		if _, err := conn.call(c); err != nil {
+			return "", fmt.Errorf("pinentry: %s: %w", strings.Fields(c)[0], err)
+		}
+	}
+
+	pin, err := conn.call("GETPIN")
+	if err != nil {
+		return "", err
+	}
+	_, _ = conn.call("BYE")
+	return pin, nil
+}
+
+// assuanConn is the client side of an Assuan connection.
+type assuanConn struct {
+	w io.Writer
+	r *bufio.Reader
+}
+
+// call sends command and returns the data of its response.
+func (c *assuanConn) call(command string) (string, error) {
+	if _, err := io.WriteString(c.w, command+"\n"); err != nil {
+		return "", err
+	}
+	return c.response()
+}
+
+// response reads the lines of a response up to its OK or ERR line, and returns its data.
+func (c *assuanConn) response() (string, error) {
+	var data strings.Builder
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "OK" || strings.HasPrefix(line, "OK "):
+			return data.String(), nil
+		case strings.HasPrefix(line, "ERR "):
+			return "", assuanError(strings.TrimPrefix(line, "ERR "))
+		case strings.HasPrefix(line, "D "):
+			data.WriteString(assuanUnescape(strings.TrimPrefix(line, "D ")))
+		}
+		// Status (S) and comment (#) lines are ignored.
+	}
+}
+
+// assuanError turns the "CODE DESCRIPTION" of an ERR line into an error, ErrCanceled if the user canceled.
+func assuanError(s string) error {
+	code, desc, _ := strings.Cut(s, " ")
+	if n, err := strconv.ParseUint(code, 10, 32); err == nil && n&0xffff == pinentryCanceled {
+		return ErrCanceled
+	}
+	return fmt.Errorf("pinentry: %s", desc)
+}
+
+// assuanEscape percent-escapes the characters that cannot appear in an Assuan line.
+func assuanEscape(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// assuanUnescape decodes the percent-escapes of Assuan data.
+func assuanUnescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Askpass asks for the PIN with an SSH_ASKPASS style program, which shows its argument as the prompt and prints
+// the PIN on its stdout.
+type Askpass struct {
+	Program string
+}
+
+// GetPIN runs the askpass program and returns the PIN it printed, or ErrCanceled if it failed.
+func (a *Askpass) GetPIN(ctx context.Context, req *PinRequest) (string, error) {
+	prompt := req.Title + ":"
+	if msg := req.message(); msg != "" {
+		prompt = msg + "\n" + prompt
+	}
+	if req.Error != "" {
+		prompt = req.Error + "\n" + prompt
+	}
+
+	cmd := exec.CommandContext(ctx, a.Program, prompt) //nolint:gosec // the program is chosen by the user
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if exitErr := (*exec.ExitError)(nil); errors.As(err, &exitErr) {
+		return "", ErrCanceled
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to run askpass: %w", err)
+	}
+
+	return firstLine(out), nil
+}
+
+// PinCommand asks for the PIN with an external command, typically a secrets manager, which prints it on its
+// stdout. The request is passed in the SKM_PIN_TITLE, SKM_PIN_DESCRIPTION, SKM_PIN_WARNING, SKM_PIN_ERROR and
+// SKM_PIN_RETRIES environment variables.
+type PinCommand struct {
+	Args []string
+}
+
+// GetPIN runs the command and returns the first line it printed.
+func (c *PinCommand) GetPIN(ctx context.Context, req *PinRequest) (string, error) {
+	if len(c.Args) == 0 {
+		return "", fmt.Errorf("%s is empty", EnvPinCommand)
+	}
+
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...) //nolint:gosec // the command is chosen by the user
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"SKM_PIN_TITLE="+req.Title,
+		"SKM_PIN_DESCRIPTION="+req.Description,
+		"SKM_PIN_WARNING="+req.Warning,
+		"SKM_PIN_ERROR="+req.Error,
+		"SKM_PIN_RETRIES="+strconv.FormatUint(uint64(req.Retries), 10),
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", EnvPinCommand, err)
+	}
+
+	pin := firstLine(out)
+	if pin == "" {
+		return "", fmt.Errorf("%s printed no PIN", EnvPinCommand)
+	}
+	return pin, nil
+}
+
+func firstLine(b []byte) string {
+	line, _, _ := bytes.Cut(b, []byte("\n"))
+	return strings.TrimSuffix(string(line), "\r")
+}
+
+// PinProviderFromEnv returns the PinProvider configured by the environment, or nil if the PIN should be asked in
+// the terminal, or read from stdin if it isn't one. SKM_PIN_PROVIDER chooses the provider; otherwise
+// SKM_PIN_COMMAND and SKM_PINENTRY are used when set, and askpass when there is no terminal but a display.
+func PinProviderFromEnv(interactive bool) (PinProvider, error) {
+	pinentry := &Pinentry{Program: envOr(EnvPinentry, "pinentry")}
+	askpass := &Askpass{Program: envOr(EnvAskpass, os.Getenv("SSH_ASKPASS"))}
+	command := &PinCommand{Args: strings.Fields(os.Getenv(EnvPinCommand))}
+
+	switch provider := os.Getenv(EnvPinProvider); provider {
+	case "":
+	case "tty":
+		return nil, nil
+	case "pinentry":
+		return pinentry, nil
+	case "askpass":
+		if askpass.Program == "" {
+			return nil, fmt.Errorf("%s=askpass requires %s or SSH_ASKPASS", EnvPinProvider, EnvAskpass)
+		}
+		return askpass, nil
+	case "command":
+		return command, nil
+	default:
+		return nil, fmt.Errorf("unknown %s %q, use tty, pinentry, askpass or command", EnvPinProvider, provider)
+	}
+
+	switch {
+	case len(command.Args) > 0:
+		return command, nil
+	case os.Getenv(EnvPinentry) != "":
+		return pinentry, nil
+	case !interactive && askpass.Program != "" && hasDisplay():
+		return askpass, nil
+	}
+	return nil, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// hasDisplay reports whether a graphical program can show a window.
+func hasDisplay() bool {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return true
+	}
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
@@ -0,0 +1,303 @@
+package prompts
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The test binary plays the external PIN programs when fakeEnv is set: it writes what it receives to the file
+// named by fakeLogEnv, and answers with the PIN in fakePINEnv, or cancels if it is empty.
+const (
+	fakeEnv    = "SKM_TEST_FAKE"
+	fakeLogEnv = "SKM_TEST_FAKE_LOG"
+	fakePINEnv = "SKM_TEST_FAKE_PIN"
+)
+
+func TestMain(m *testing.M) {
+	if fake := os.Getenv(fakeEnv); fake != "" {
+		os.Exit(runFake(fake)) //nolint:forbidigo // the test binary plays an external program
+	}
+
+	m.Run()
+}
+
+func runFake(fake string) int {
+	log, err := os.OpenFile(os.Getenv(fakeLogEnv), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return 2
+	}
+	defer func() {
+		_ = log.Close()
+	}()
+	pin := os.Getenv(fakePINEnv)
+
+	switch fake {
+	case "pinentry":
+		_, _ = fmt.Fprintln(os.Stdout, "OK Pleased to meet you")
+		s := bufio.NewScanner(os.Stdin)
+		for s.Scan() {
+			_, _ = fmt.Fprintln(log, s.Text())
+			switch {
+			case s.Text() == "GETPIN" && pin == "":
+				_, _ = fmt.Fprintln(os.Stdout, "ERR 83886179 Operation cancelled <Pinentry>")
+			case s.Text() == "GETPIN":
+				_, _ = fmt.Fprintln(os.Stdout, "S PASSPHRASE_FROM_CACHE")
+				_, _ = fmt.Fprintf(os.Stdout, "D %s\nOK\n", assuanEscape(pin))
+			case s.Text() == "BYE":
+				_, _ = fmt.Fprintln(os.Stdout, "OK closing connection")
+				return 0
+			default:
+				_, _ = fmt.Fprintln(os.Stdout, "OK")
+			}
+		}
+	case "askpass":
+		_, _ = fmt.Fprintln(log, strings.Join(os.Args[1:], " "))
+	case "command":
+		for _, k := range []string{"SKM_PIN_TITLE", "SKM_PIN_DESCRIPTION", "SKM_PIN_WARNING", "SKM_PIN_RETRIES"} {
+			_, _ = fmt.Fprintf(log, "%s=%s\n", k, os.Getenv(k))
+		}
+	}
+
+	if pin == "" {
+		return 1
+	}
+	_, _ = fmt.Fprintln(os.Stdout, pin)
+	return 0
+}
+
+// fake makes the test binary play fake with pin, and returns the file it logs to.
+func fake(t *testing.T, fake, pin string) string {
+	t.Helper()
+
+	log := filepath.Join(t.TempDir(), "log")
+	t.Setenv(fakeEnv, fake)
+	t.Setenv(fakeLogEnv, log)
+	t.Setenv(fakePINEnv, pin)
+	return log
+}
+
+func readLog(t *testing.T, log string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	return string(b)
+}
+
+func TestPinProviders(t *testing.T) {
+	req := &PinRequest{
+		Title:       "Enter PIN",
+		Description: "PIN of the YubiKey 5",
+		Warning:     "Warning: This is your LAST attempt before the device is locked.",
+		Retries:     1,
+	}
+
+	tests := []struct {
+		name     string
+		fake     string
+		provider PinProvider
+		pin      string
+		wantLog  []string
+		wantErr  error
+	}{
+		{
+			name:     "pinentry",
+			fake:     "pinentry",
+			provider: &Pinentry{Program: os.Args[0]},
+			pin:      "12%4 56",
+			wantLog: []string{
+				"SETPROMPT Enter PIN:",
+				"SETDESC PIN of the YubiKey 5%0A1 PIN retries left.%0AWarning: This is your LAST attempt",
+				"GETPIN",
+				"BYE",
+			},
+		},
+		{
+			name:     "pinentry canceled",
+			fake:     "pinentry",
+			provider: &Pinentry{Program: os.Args[0]},
+			wantErr:  ErrCanceled,
+		},
+		{
+			name:     "askpass",
+			fake:     "askpass",
+			provider: &Askpass{Program: os.Args[0]},
+			pin:      "123456",
+			wantLog:  []string{"PIN of the YubiKey 5\n1 PIN retries left.\nWarning: This is your LAST attempt", "Enter PIN:"},
+		},
+		{
+			name:     "askpass canceled",
+			fake:     "askpass",
+			provider: &Askpass{Program: os.Args[0]},
+			wantErr:  ErrCanceled,
+		},
+		{
+			name:     "command",
+			fake:     "command",
+			provider: &PinCommand{Args: []string{os.Args[0], "-test.run=none"}},
+			pin:      "123456",
+			wantLog: []string{
+				"SKM_PIN_TITLE=Enter PIN",
+				"SKM_PIN_DESCRIPTION=PIN of the YubiKey 5",
+				"SKM_PIN_WARNING=Warning: This is your LAST attempt",
+				"SKM_PIN_RETRIES=1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := fake(t, tt.fake, tt.pin)
+
+			pin, err := tt.provider.GetPIN(context.Background(), req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetPIN() error = %v, want %v", err, tt.wantErr)
+			}
+			if pin != tt.pin {
+				t.Errorf("GetPIN() = %q, want %q", pin, tt.pin)
+			}
+
+			got := readLog(t, log)
+			for _, want := range tt.wantLog {
+				if !strings.Contains(got, want) {
+					t.Errorf("%s did not receive %q:\n%s", tt.fake, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPinProviderFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		interactive bool
+		want        string
+		wantErr     string
+	}{
+		{
+			name:        "terminal",
+			interactive: true,
+		},
+		{
+			name: "stdin",
+		},
+		{
+			name: "askpass without a terminal",
+			env:  map[string]string{"SSH_ASKPASS": "/usr/bin/ssh-askpass", "DISPLAY": ":0"},
+			want: "*prompts.Askpass",
+		},
+		{
+			name:        "askpass with a terminal",
+			env:         map[string]string{"SSH_ASKPASS": "/usr/bin/ssh-askpass", "DISPLAY": ":0"},
+			interactive: true,
+		},
+		{
+			name:        "pinentry",
+			env:         map[string]string{EnvPinentry: "pinentry-gnome3"},
+			interactive: true,
+			want:        "*prompts.Pinentry",
+		},
+		{
+			name:        "command",
+			env:         map[string]string{EnvPinCommand: "pass show security-key/pin"},
+			interactive: true,
+			want:        "*prompts.PinCommand",
+		},
+		{
+			name:        "forced",
+			env:         map[string]string{EnvPinProvider: "pinentry"},
+			interactive: true,
+			want:        "*prompts.Pinentry",
+		},
+		{
+			name: "forced terminal",
+			env:  map[string]string{EnvPinProvider: "tty", EnvPinCommand: "pass show security-key/pin"},
+		},
+		{
+			name:    "forced askpass without a program",
+			env:     map[string]string{EnvPinProvider: "askpass"},
+			wantErr: "SKM_PIN_PROVIDER=askpass requires SKM_ASKPASS or SSH_ASKPASS",
+		},
+		{
+			name:    "unknown",
+			env:     map[string]string{EnvPinProvider: "gui"},
+			wantErr: `unknown SKM_PIN_PROVIDER "gui"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{
+				EnvPinProvider, EnvPinentry, EnvAskpass, EnvPinCommand, "SSH_ASKPASS", "DISPLAY", "WAYLAND_DISPLAY",
+			} {
+				t.Setenv(k, tt.env[k])
+			}
+
+			p, err := PinProviderFromEnv(tt.interactive)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("PinProviderFromEnv() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PinProviderFromEnv() error = %v", err)
+			}
+
+			got := ""
+			if p != nil {
+				got = fmt.Sprintf("%T", p)
+			}
+			if got != tt.want {
+				t.Errorf("PinProviderFromEnv() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// pinsProvider returns its PINs one after the other, and records the requests.
+type pinsProvider struct {
+	pins []string
+	reqs []PinRequest
+}
+
+func (p *pinsProvider) GetPIN(_ context.Context, req *PinRequest) (string, error) {
+	p.reqs = append(p.reqs, *req)
+	if len(p.pins) == 0 {
+		return "", ErrCanceled
+	}
+	pin := p.pins[0]
+	p.pins = p.pins[1:]
+	return pin, nil
+}
+
+func TestPinPromptAsksAgainUntilValid(t *testing.T) {
+	provider := &pinsProvider{pins: []string{"12", "123456"}}
+	prompt := NewPinPrompt().WithTitle("Enter New PIN").WithValidation(func(s string) error {
+		if len(s) < 4 {
+			return errors.New("PIN must be at least 4 characters long")
+		}
+		return nil
+	})
+
+	pin, err := prompt.runProvider(context.Background(), provider)
+	if err != nil {
+		t.Fatalf("runProvider() error = %v", err)
+	}
+	if pin != "123456" {
+		t.Errorf("runProvider() = %q, want %q", pin, "123456")
+	}
+	if len(provider.reqs) != 2 || provider.reqs[0].Error != "" ||
+		provider.reqs[1].Error != "PIN must be at least 4 characters long" {
+		t.Errorf("requests = %+v, want a second one with the validation error", provider.reqs)
+	}
+}
@@ -0,0 +1,7 @@
+// Package prompts provides the interactive terminal prompts and live views of skm, built with bubbletea.
+package prompts
+
+import "errors"
+
+// ErrCanceled is returned by the prompts the user quits, e.g. with Ctrl+C or Esc.
+var ErrCanceled = errors.New("canceled")
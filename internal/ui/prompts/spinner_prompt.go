@@ -0,0 +1,114 @@
+package prompts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type workDoneMsg struct {
+	err error
+}
+
+// SpinnerPrompt shows a spinner and a message on stderr while some work runs in the background.
+type SpinnerPrompt struct {
+	spinner  spinner.Model
+	message  string
+	deadline time.Time
+	cancel   context.CancelFunc
+	done     bool
+	quitting bool
+	err      error
+}
+
+// NewSpinnerPrompt creates a new SpinnerPrompt showing message.
+func NewSpinnerPrompt(message string) *SpinnerPrompt {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#11998e", Dark: "#4ecdc4"})
+
+	return &SpinnerPrompt{
+		spinner: s,
+		message: message,
+	}
+}
+
+// WithDeadline shows a countdown to deadline next to the message.
+func (p *SpinnerPrompt) WithDeadline(deadline time.Time) *SpinnerPrompt {
+	p.deadline = deadline
+	return p
+}
+
+// Init initializes the bubbletea model.
+func (p *SpinnerPrompt) Init() tea.Cmd {
+	return p.spinner.Tick
+}
+
+// Update handles message updates for the bubbletea model.
+func (p *SpinnerPrompt) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			p.quitting = true
+			p.cancel()
+			return p, tea.Quit
+		}
+	case workDoneMsg:
+		p.done = true
+		p.err = msg.err
+		return p, tea.Quit
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		p.spinner, cmd = p.spinner.Update(msg)
+		return p, cmd
+	}
+
+	return p, nil
+}
+
+// View renders the prompt view.
+func (p *SpinnerPrompt) View() string {
+	if p.done || p.quitting {
+		return ""
+	}
+
+	view := p.spinner.View() + " " + p.message
+	if !p.deadline.IsZero() {
+		left := max(time.Until(p.deadline).Round(time.Second), 0)
+		view += lipgloss.NewStyle().Faint(true).Render(fmt.Sprintf(" (%s left)", left))
+	}
+	return view + "\n"
+}
+
+// Run shows the spinner until work returns, and returns its error. If the user quits first, the context of work
+// is canceled and Run returns an error once work has returned.
+func (p *SpinnerPrompt) Run(ctx context.Context, work func(ctx context.Context) error) error {
+	ctx, p.cancel = context.WithCancel(ctx)
+	defer p.cancel()
+
+	prog := tea.NewProgram(p, tea.WithOutput(os.Stderr))
+	errc := make(chan error, 1)
+	go func() {
+		err := work(ctx)
+		errc <- err
+		prog.Send(workDoneMsg{err: err})
+	}()
+
+	if _, err := prog.Run(); err != nil {
+		p.cancel()
+		<-errc
+		return err
+	}
+
+	err := <-errc
+	if p.quitting {
+		return ErrCanceled
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package prompts
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TextPrompt is a prompt for entering a single line of text.
+type TextPrompt struct {
+	textInput textinput.Model
+	quitting  bool
+	submitted bool
+	title     string
+	validate  func(string) error
+	err       error
+}
+
+// NewTextPrompt creates a new TextPrompt with the given title.
+func NewTextPrompt(title string) *TextPrompt {
+	ti := textinput.New()
+	ti.Focus()
+	ti.CharLimit = 256
+
+	return &TextPrompt{
+		textInput: ti,
+		title:     title,
+	}
+}
+
+// WithValue sets the initial value of the input.
+func (p *TextPrompt) WithValue(value string) *TextPrompt {
+	p.textInput.SetValue(value)
+	p.textInput.CursorEnd()
+	return p
+}
+
+// WithPlaceholder sets the placeholder text of the input.
+func (p *TextPrompt) WithPlaceholder(placeholder string) *TextPrompt {
+	p.textInput.Placeholder = placeholder
+	return p
+}
+
+// WithValidation sets a validation function for the entered text.
+func (p *TextPrompt) WithValidation(v func(string) error) *TextPrompt {
+	p.validate = v
+	return p
+}
+
+// Init initializes the bubbletea model.
+func (p *TextPrompt) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// Update handles message updates for the bubbletea model.
+func (p *TextPrompt) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		p.err = nil // Reset error on any key press
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			p.quitting = true
+			return p, tea.Quit
+		case "enter":
+			if p.validate != nil {
+				if err := p.validate(p.textInput.Value()); err != nil {
+					p.err = err
+					return p, nil
+				}
+			}
+			p.submitted = true
+			return p, tea.Quit
+		}
+	}
+
+	p.textInput, cmd = p.textInput.Update(msg)
+	return p, cmd
+}
+
+// View renders the prompt view.
+func (p *TextPrompt) View() string {
+	if p.quitting {
+		return ""
+	}
+
+	var errView string
+	if p.err != nil {
+		errView = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render(p.err.Error()) + "\n\n"
+	}
+
+	return fmt.Sprintf("%s\n\n%s%s\n\n%s", p.title, errView, p.textInput.View(), "(esc to quit)") + "\n"
+}
+
+// Run executes the prompt and returns the entered text.
+func (p *TextPrompt) Run() (string, error) {
+	tm, err := tea.NewProgram(p).Run()
+	if err != nil {
+		return "", err
+	}
+
+	finalModel, ok := tm.(*TextPrompt)
+	if !ok {
+		return "", errors.New("failed to get result from prompt")
+	}
+
+	if finalModel.quitting || !finalModel.submitted {
+		return "", ErrCanceled
+	}
+
+	return finalModel.textInput.Value(), nil
+}
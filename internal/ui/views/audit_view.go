@@ -0,0 +1,91 @@
+package views
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/mohammadv184/skm/internal/policy"
+	"github.com/mohammadv184/skm/internal/ui/output"
+)
+
+// AuditView is a view that displays the results of a policy audit, with a table of rules per security key.
+type AuditView struct {
+	report *output.AuditReport
+}
+
+// NewAuditView creates a new AuditView.
+func NewAuditView(report *output.AuditReport) *AuditView {
+	return &AuditView{report: report}
+}
+
+// Render renders the view.
+func (v *AuditView) Render() string {
+	titleStyle := lipgloss.NewStyle().Bold(true)
+	subtitleStyle := lipgloss.NewStyle().Faint(true)
+
+	headerStyle := lipgloss.NewStyle().
+		Bold(true).
+		Padding(0, 1).
+		Faint(true)
+
+	cellStyle := lipgloss.NewStyle().
+		Padding(0, 1)
+
+	passStyle := cellStyle.Foreground(lipgloss.AdaptiveColor{Light: "#11998e", Dark: "#4ecdc4"}).Bold(true)
+	failStyle := cellStyle.Foreground(lipgloss.Color("196")).Bold(true)
+	errorStyle := cellStyle.Foreground(lipgloss.Color("214")).Bold(true)
+
+	sections := make([]string, 0, len(v.report.Devices)+1)
+	for _, d := range v.report.Devices {
+		rules := d.Rules
+
+		t := table.New().
+			BorderStyle(lipgloss.NewStyle().Faint(true)).
+			BorderRight(false).BorderLeft(false).BorderBottom(false).BorderTop(false).
+			BorderColumn(false).
+			StyleFunc(func(row, col int) lipgloss.Style {
+				switch {
+				case row == table.HeaderRow:
+					return headerStyle
+				case col != 3:
+					return cellStyle
+				case rules[row].Status == string(policy.StatusPass):
+					return passStyle
+				case rules[row].Status == string(policy.StatusFail):
+					return failStyle
+				default:
+					return errorStyle
+				}
+			}).
+			Headers("RULE", "EXPECTED", "ACTUAL", "RESULT", "MESSAGE")
+
+		for _, r := range rules {
+			t.Row(r.Rule, r.Expected, r.Actual, strings.ToUpper(r.Status), r.Message)
+		}
+
+		title := titleStyle.Render(d.Device.Product)
+		if d.Device.SerialNumber != "" {
+			title += " " + subtitleStyle.Render("(serial "+d.Device.SerialNumber+")")
+		}
+		title += " " + subtitleStyle.Render(d.Device.Path)
+
+		body := t.Render()
+		if d.Error != "" {
+			body = failStyle.Render("ERROR") + " " + d.Error
+		}
+
+		sections = append(sections, title+"\n"+body)
+	}
+
+	total, passed := v.report.Summary()
+	summary := strconv.Itoa(passed) + " of " + strconv.Itoa(total) + " security keys comply with the policy"
+	if passed == total {
+		sections = append(sections, passStyle.UnsetPadding().Render(summary))
+	} else {
+		sections = append(sections, failStyle.UnsetPadding().Render(summary))
+	}
+
+	return lipgloss.NewStyle().Padding(1, 0, 1, 0).Render(strings.Join(sections, "\n\n"))
+}
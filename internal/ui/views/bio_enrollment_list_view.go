@@ -0,0 +1,56 @@
+package views
+
+import (
+	"encoding/hex"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+)
+
+// BioEnrollmentListView is a view that displays the fingerprints enrolled on a security key.
+type BioEnrollmentListView struct {
+	t *table.Table
+}
+
+// NewBioEnrollmentListView creates a new BioEnrollmentListView.
+func NewBioEnrollmentListView() *BioEnrollmentListView {
+	headerStyle := lipgloss.NewStyle().
+		Bold(true).
+		Padding(0, 1).
+		Faint(true)
+
+	cellStyle := lipgloss.NewStyle().
+		Padding(0, 1)
+
+	t := table.New().
+		BorderStyle(lipgloss.NewStyle().Faint(true)).
+		BorderRight(false).BorderLeft(false).BorderBottom(false).BorderTop(false).
+		BorderColumn(false).
+		StyleFunc(func(row, _ int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return headerStyle
+			}
+			return cellStyle
+		}).
+		Headers("NAME", "TEMPLATE ID")
+
+	return &BioEnrollmentListView{t: t}
+}
+
+// WithEnrollments adds enrollments to the view.
+func (v *BioEnrollmentListView) WithEnrollments(infos ...ctap2.TemplateInfo) *BioEnrollmentListView {
+	for _, info := range infos {
+		name := info.TemplateFriendlyName
+		if name == "" {
+			name = "(unnamed)"
+		}
+		v.t.Row(name, hex.EncodeToString(info.TemplateID))
+	}
+	return v
+}
+
+// Render renders the view.
+func (v *BioEnrollmentListView) Render() string {
+	return lipgloss.NewStyle().Padding(1, 0, 1, 0).Render(v.t.Render())
+}
@@ -0,0 +1,70 @@
+package views
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+)
+
+// BioSensorView is a view that displays information about the biometric sensor of a security key.
+type BioSensorView struct {
+	modality ctap2.BioModality
+	sensor   *ctap2.AuthenticatorBioEnrollmentResponse
+}
+
+// NewBioSensorView creates a new BioSensorView.
+func NewBioSensorView(modality ctap2.BioModality, sensor *ctap2.AuthenticatorBioEnrollmentResponse) *BioSensorView {
+	return &BioSensorView{
+		modality: modality,
+		sensor:   sensor,
+	}
+}
+
+// Render renders the view.
+func (v *BioSensorView) Render() string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		PaddingBottom(1)
+
+	labelStyle := lipgloss.NewStyle().
+		Bold(true).
+		Width(22)
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Biometric Sensor Information"))
+	b.WriteString("\n")
+
+	renderRow := func(label, value string) {
+		b.WriteString(labelStyle.Render(label))
+		b.WriteString(value)
+		b.WriteString("\n")
+	}
+
+	modality := v.modality.String()
+	if modality == "" {
+		modality = "unknown (" + strconv.FormatUint(uint64(v.modality), 10) + ")"
+	}
+	renderRow("Modality:", modality)
+
+	if v.sensor != nil {
+		kind := "unknown (" + strconv.FormatUint(uint64(v.sensor.FingerprintKind), 10) + ")"
+		switch v.sensor.FingerprintKind {
+		case 1:
+			kind = "touch"
+		case 2:
+			kind = "swipe"
+		}
+		renderRow("Fingerprint Kind:", kind)
+		renderRow("Max Samples:", strconv.FormatUint(uint64(v.sensor.MaxCaptureSamplesRequiredForEnroll), 10))
+		maxName := "not reported"
+		if v.sensor.MaxTemplateFriendlyName > 0 {
+			maxName = strconv.FormatUint(uint64(v.sensor.MaxTemplateFriendlyName), 10) + " bytes"
+		}
+		renderRow("Max Name Length:", maxName)
+	}
+
+	return b.String()
+}
@@ -2,19 +2,35 @@ package views
 
 import (
 	"encoding/base64"
+	"encoding/hex"
+	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
-	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/credential"
 )
 
-// CredentialListView is a view that displays a table of credentials.
+// CredentialListView is a view that displays the credentials of each relying party in its own table.
 type CredentialListView struct {
-	t *table.Table
+	rps []*credential.RelyingParty
 }
 
 // NewCredentialListView creates a new CredentialListView.
 func NewCredentialListView() *CredentialListView {
+	return &CredentialListView{}
+}
+
+// WithRelyingParties adds relying parties and their credentials to the view.
+func (d *CredentialListView) WithRelyingParties(rps ...*credential.RelyingParty) *CredentialListView {
+	d.rps = append(d.rps, rps...)
+	return d
+}
+
+// Render renders the view.
+func (d *CredentialListView) Render() string {
+	titleStyle := lipgloss.NewStyle().Bold(true)
+	subtitleStyle := lipgloss.NewStyle().Faint(true)
+
 	headerStyle := lipgloss.NewStyle().
 		Bold(true).
 		Padding(0, 1).
@@ -24,46 +40,43 @@ func NewCredentialListView() *CredentialListView {
 	cellStyle := lipgloss.NewStyle().
 		Padding(0, 1)
 
-	t := table.New().
-		Border(lipgloss.HiddenBorder()).
-		StyleFunc(func(row, _ int) lipgloss.Style {
-			if row == table.HeaderRow {
-				return headerStyle
-			}
-			return cellStyle
-		}).
-		Headers("RP", "USER", "DISPLAY NAME", "CREDENTIAL ID")
+	sections := make([]string, 0, len(d.rps))
+	for _, rp := range d.rps {
+		t := table.New().
+			Border(lipgloss.HiddenBorder()).
+			StyleFunc(func(row, _ int) lipgloss.Style {
+				if row == table.HeaderRow {
+					return headerStyle
+				}
+				return cellStyle
+			}).
+			Headers("USER", "DISPLAY NAME", "CREDENTIAL ID", "PROTECTION", "KEY", "LARGE BLOB")
 
-	return &CredentialListView{t: t}
-}
+		for _, cred := range rp.Credentials {
+			largeBlob := "no"
+			if len(cred.LargeBlobKey) > 0 {
+				largeBlob = "yes"
+			}
 
-// WithCredentials adds credentials to the view.
-func (d *CredentialListView) WithCredentials(
-	creds ...*ctap2.AuthenticatorCredentialManagementResponse,
-) *CredentialListView {
-	for _, cred := range creds {
-		rpName := cred.RP.Name
-		if rpName == "" {
-			rpName = cred.RP.ID
+			t.Row(
+				cred.User.Name,
+				cred.User.DisplayName,
+				base64.RawURLEncoding.EncodeToString(cred.CredentialID.ID),
+				credential.ProtectionName(cred.CredProtect),
+				credential.PublicKeyAlgorithm(cred),
+				largeBlob,
+			)
 		}
 
-		userName := cred.User.Name
-		displayName := cred.User.DisplayName
-
-		credID := base64.RawURLEncoding.EncodeToString(cred.CredentialID.ID)
+		title := titleStyle.Render(rp.DisplayName())
+		if rp.RP.Name != "" {
+			title += " " + subtitleStyle.Render("("+rp.RP.ID+")")
+		}
 
-		d.t.Row(
-			rpName,
-			userName,
-			displayName,
-			credID,
-		)
+		sections = append(sections, title+"\n"+
+			subtitleStyle.Render("RP ID hash: "+hex.EncodeToString(rp.RPIDHash))+"\n"+
+			t.Render())
 	}
 
-	return d
-}
-
-// Render renders the view.
-func (d *CredentialListView) Render() string {
-	return d.t.Render()
+	return strings.Join(sections, "\n\n")
 }
@@ -0,0 +1,112 @@
+package views
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mohammadv184/skm/internal/credential"
+)
+
+// CredentialView is a view that displays detailed information about a single credential.
+type CredentialView struct {
+	cred      *credential.Credential
+	publicKey *credential.PublicKey
+	keyErr    error
+	credBlob  string
+}
+
+// NewCredentialView creates a new CredentialView.
+func NewCredentialView(cred *credential.Credential) *CredentialView {
+	v := &CredentialView{
+		cred:     cred,
+		credBlob: "not checked (use --check-cred-blob)",
+	}
+	if cred.PublicKey != nil {
+		v.publicKey, v.keyErr = credential.DecodePublicKey(*cred.PublicKey)
+	}
+	return v
+}
+
+// WithCredBlob sets the credBlob state shown by the view, e.g. "present", "absent" or "unsupported".
+func (v *CredentialView) WithCredBlob(state string) *CredentialView {
+	v.credBlob = state
+	return v
+}
+
+// Render renders the view.
+func (v *CredentialView) Render() string {
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		PaddingBottom(1)
+
+	labelStyle := lipgloss.NewStyle().
+		Bold(true).
+		Width(22)
+
+	sectionStyle := lipgloss.NewStyle().Bold(true).Underline(true)
+	blockStyle := lipgloss.NewStyle().PaddingLeft(2)
+
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Credential Information"))
+	b.WriteString("\n")
+
+	renderRow := func(label, value string) {
+		b.WriteString(labelStyle.Render(label))
+		b.WriteString(value)
+		b.WriteString("\n")
+	}
+
+	renderRow("RP ID:", v.cred.RP.ID)
+	renderRow("RP Name:", v.cred.RP.Name)
+	renderRow("RP ID Hash:", hex.EncodeToString(v.cred.RPIDHash))
+	renderRow("User ID (hex):", hex.EncodeToString(v.cred.User.ID))
+	renderRow("User ID (base64):", base64.RawURLEncoding.EncodeToString(v.cred.User.ID))
+	renderRow("User Name:", v.cred.User.Name)
+	renderRow("Display Name:", v.cred.User.DisplayName)
+	renderRow("Credential ID:", base64.RawURLEncoding.EncodeToString(v.cred.CredentialID.ID))
+	renderRow(
+		"Cred Protect:",
+		credential.ProtectionName(v.cred.CredProtect)+" ("+strconv.FormatUint(uint64(v.cred.CredProtect), 10)+")",
+	)
+
+	largeBlobKey := "absent"
+	if len(v.cred.LargeBlobKey) > 0 {
+		largeBlobKey = "present"
+	}
+	renderRow("Large Blob Key:", largeBlobKey)
+	renderRow("Cred Blob:", v.credBlob)
+
+	b.WriteString("\n")
+	b.WriteString(sectionStyle.Render("Public Key:"))
+	b.WriteString("\n")
+
+	switch {
+	case v.cred.PublicKey == nil:
+		b.WriteString(blockStyle.Render("not reported by the device"))
+		b.WriteString("\n")
+	case v.keyErr != nil:
+		renderRow("  Algorithm:", credential.PublicKeyAlgorithm(v.cred))
+		b.WriteString(blockStyle.Render("cannot be exported: " + v.keyErr.Error()))
+		b.WriteString("\n")
+	default:
+		renderRow("  Algorithm:", v.publicKey.Algorithm)
+		if v.publicKey.Curve != "" {
+			renderRow("  Curve:", v.publicKey.Curve)
+		}
+
+		b.WriteString("\n")
+		b.WriteString(blockStyle.Render(strings.TrimSpace(v.publicKey.PEM)))
+		b.WriteString("\n\n")
+
+		jwk, _ := json.MarshalIndent(v.publicKey.JWK, "", "  ")
+		b.WriteString(blockStyle.Render(string(jwk)))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
@@ -10,12 +10,14 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/mohammadv184/go-fido2"
 	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/skm/internal/mds"
 )
 
 // DeviceInfoView is a view that displays detailed information about a security key.
 type DeviceInfoView struct {
 	desc       *fido2.DeviceDescriptor
 	info       *ctap2.AuthenticatorGetInfoResponse
+	model      *mds.Model
 	pinRetries uint
 	uvRetries  uint
 	hasUV      bool
@@ -37,6 +39,12 @@ func (v *DeviceInfoView) WithRetries(pinRetries uint, uvRetries uint, hasUV bool
 	return v
 }
 
+// WithModel sets the model of the security key, identified by its AAGUID, if it is known.
+func (v *DeviceInfoView) WithModel(model *mds.Model) *DeviceInfoView {
+	v.model = model
+	return v
+}
+
 // Render renders the view.
 func (v *DeviceInfoView) Render() string {
 	titleStyle := lipgloss.NewStyle().
@@ -65,11 +73,32 @@ func (v *DeviceInfoView) Render() string {
 	renderRow("Serial:", v.desc.SerialNumber)
 	renderRow("Path:", v.desc.Path)
 	renderRow("AAGUID:", v.info.AAGUID.String())
+	if v.model != nil {
+		renderRow("Model:", v.model.String())
+		if v.model.Certification != "" {
+			renderRow("Certification:", v.model.Certification)
+		}
+		issueStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
+		for _, r := range v.model.SecurityIssues() {
+			issue := r.Status
+			if r.EffectiveDate != "" {
+				issue += " since " + r.EffectiveDate
+			}
+			renderRow("Security Status:", issueStyle.Render(issue))
+		}
+	}
 
 	renderRow("PIN Retries:", strconv.FormatUint(uint64(v.pinRetries), 10))
 	if v.hasUV {
 		renderRow("UV Retries:", strconv.FormatUint(uint64(v.uvRetries), 10))
 	}
+	if enrolled, ok := v.info.Options[ctap2.OptionBioEnroll]; ok {
+		bioEnroll := "supported, no fingerprints enrolled"
+		if enrolled {
+			bioEnroll = "fingerprints enrolled"
+		}
+		renderRow("Bio Enrollment:", bioEnroll)
+	}
 
 	versions := make([]string, len(v.info.Versions))
 	for i, ver := range v.info.Versions {
@@ -4,15 +4,34 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
 	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/mds"
 )
 
 // DevicesListView is a view that displays a table of connected security keys.
 type DevicesListView struct {
-	t *table.Table
+	devs   []fido2.DeviceDescriptor
+	models map[string]*mds.Model
 }
 
 // NewDevicesListView creates a new DevicesListView.
 func NewDevicesListView() *DevicesListView {
+	return &DevicesListView{}
+}
+
+// WithDevices adds devices to the view.
+func (d *DevicesListView) WithDevices(devs ...fido2.DeviceDescriptor) *DevicesListView {
+	d.devs = append(d.devs, devs...)
+	return d
+}
+
+// WithModels sets the models of the devices by their path. A MODEL column is shown if any is known.
+func (d *DevicesListView) WithModels(models map[string]*mds.Model) *DevicesListView {
+	d.models = models
+	return d
+}
+
+// Render renders the view.
+func (d *DevicesListView) Render() string {
 	headerStyle := lipgloss.NewStyle().
 		Bold(true).
 		Padding(0, 1).
@@ -30,27 +49,26 @@ func NewDevicesListView() *DevicesListView {
 				return headerStyle
 			}
 			return cellStyle
-		}).
-		Headers("PATH", "PRODUCT", "MANUFACTURER", "SERIAL")
-
-	return &DevicesListView{t: t}
-}
+		})
 
-// WithDevices adds devices to the view.
-func (d *DevicesListView) WithDevices(devs ...fido2.DeviceDescriptor) *DevicesListView {
-	for _, dev := range devs {
-		d.t.Row(
-			dev.Path,
-			dev.Product,
-			dev.Manufacturer,
-			dev.SerialNumber,
-		)
+	withModels := len(d.models) > 0
+	if withModels {
+		t.Headers("PATH", "PRODUCT", "MANUFACTURER", "SERIAL", "MODEL")
+	} else {
+		t.Headers("PATH", "PRODUCT", "MANUFACTURER", "SERIAL")
 	}
 
-	return d
-}
+	for _, dev := range d.devs {
+		row := []string{dev.Path, dev.Product, dev.Manufacturer, dev.SerialNumber}
+		if withModels {
+			var model string
+			if m := d.models[dev.Path]; m != nil {
+				model = m.String()
+			}
+			row = append(row, model)
+		}
+		t.Row(row...)
+	}
 
-// Render renders the view.
-func (d *DevicesListView) Render() string {
-	return lipgloss.NewStyle().Padding(1, 0, 1, 0).Render(d.t.Render())
+	return lipgloss.NewStyle().Padding(1, 0, 1, 0).Render(t.Render())
 }
@@ -0,0 +1,89 @@
+package views
+
+import (
+	"encoding/base64"
+	"strconv"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/mohammadv184/skm/internal/largeblob"
+)
+
+// LargeBlobListView is a view that displays the entries of a security key's large-blob array.
+type LargeBlobListView struct {
+	t        *table.Table
+	used     uint
+	capacity uint
+	orphans  int
+}
+
+// NewLargeBlobListView creates a new LargeBlobListView.
+func NewLargeBlobListView() *LargeBlobListView {
+	headerStyle := lipgloss.NewStyle().
+		Bold(true).
+		Padding(0, 1).
+		Faint(true)
+
+	cellStyle := lipgloss.NewStyle().
+		Padding(0, 1)
+
+	t := table.New().
+		BorderStyle(lipgloss.NewStyle().Faint(true)).
+		BorderRight(false).BorderLeft(false).BorderBottom(false).BorderTop(false).
+		BorderColumn(false).
+		StyleFunc(func(row, _ int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return headerStyle
+			}
+			return cellStyle
+		}).
+		Headers("#", "RP", "USER", "CREDENTIAL ID", "SIZE", "STORED")
+
+	return &LargeBlobListView{t: t}
+}
+
+// WithEntries adds large-blob entries to the view.
+func (v *LargeBlobListView) WithEntries(entries ...*largeblob.Entry) *LargeBlobListView {
+	for _, e := range entries {
+		stored := strconv.Itoa(len(e.Blob.Ciphertext)+len(e.Blob.Nonce)) + " B"
+		size := strconv.FormatUint(uint64(e.Blob.OrigSize), 10) + " B"
+
+		if e.Orphaned() {
+			v.orphans++
+			v.t.Row(strconv.Itoa(e.Index), "(orphaned)", "", "", size, stored)
+			continue
+		}
+
+		credID := base64.RawURLEncoding.EncodeToString(e.Credential.CredentialID.ID)
+		if len(credID) > 20 {
+			credID = credID[:17] + "..."
+		}
+
+		v.t.Row(strconv.Itoa(e.Index), e.Credential.RP.ID, e.Credential.User.Name, credID, size, stored)
+	}
+	return v
+}
+
+// WithUsage sets the size of the serialized large-blob array and the maximum size supported by the device.
+func (v *LargeBlobListView) WithUsage(used, capacity uint) *LargeBlobListView {
+	v.used = used
+	v.capacity = capacity
+	return v
+}
+
+// Render renders the view.
+func (v *LargeBlobListView) Render() string {
+	faint := lipgloss.NewStyle().Faint(true)
+
+	s := v.t.Render() + "\n\n" + faint.Render(
+		"Storage: "+strconv.FormatUint(uint64(v.used), 10)+" of "+
+			strconv.FormatUint(uint64(v.capacity), 10)+" bytes used",
+	)
+	if v.orphans > 0 {
+		s += "\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Render(
+			strconv.Itoa(v.orphans)+" orphaned entries can be removed with 'skm blob delete --orphans'",
+		)
+	}
+
+	return lipgloss.NewStyle().Padding(1, 0, 1, 0).Render(s)
+}
@@ -0,0 +1,68 @@
+package views
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/mohammadv184/go-fido2"
+)
+
+// PinRetriesView is a view that displays the PIN and UV retry state of security keys.
+type PinRetriesView struct {
+	t *table.Table
+}
+
+// NewPinRetriesView creates a new PinRetriesView.
+func NewPinRetriesView() *PinRetriesView {
+	headerStyle := lipgloss.NewStyle().
+		Bold(true).
+		Padding(0, 1).
+		Faint(true)
+
+	cellStyle := lipgloss.NewStyle().
+		Padding(0, 1)
+
+	t := table.New().
+		BorderStyle(lipgloss.NewStyle().Faint(true)).
+		BorderRight(false).BorderLeft(false).BorderBottom(false).BorderTop(false).
+		BorderColumn(false).
+		StyleFunc(func(row, _ int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return headerStyle
+			}
+			return cellStyle
+		}).
+		Headers("PATH", "PRODUCT", "SERIAL", "PIN RETRIES", "UV RETRIES", "POWER CYCLE", "STATUS")
+
+	return &PinRetriesView{t: t}
+}
+
+// WithDevice adds a device and its retry state to the view.
+func (v *PinRetriesView) WithDevice(
+	desc *fido2.DeviceDescriptor,
+	pinRetries string,
+	uvRetries string,
+	powerCycle bool,
+	status string,
+) *PinRetriesView {
+	powerCycleRequired := "no"
+	if powerCycle {
+		powerCycleRequired = "required"
+	}
+
+	v.t.Row(
+		desc.Path,
+		desc.Product,
+		desc.SerialNumber,
+		pinRetries,
+		uvRetries,
+		powerCycleRequired,
+		status,
+	)
+
+	return v
+}
+
+// Render renders the view.
+func (v *PinRetriesView) Render() string {
+	return lipgloss.NewStyle().Padding(1, 0, 1, 0).Render(v.t.Render())
+}
@@ -0,0 +1,75 @@
+package views
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/skm/internal/policy"
+)
+
+// PlanView is a view that displays the changes planned to reconcile a security key with a policy.
+type PlanView struct {
+	desc *fido2.DeviceDescriptor
+	plan *policy.Plan
+}
+
+// NewPlanView creates a new PlanView.
+func NewPlanView(desc *fido2.DeviceDescriptor, plan *policy.Plan) *PlanView {
+	return &PlanView{
+		desc: desc,
+		plan: plan,
+	}
+}
+
+// Render renders the view.
+func (v *PlanView) Render() string {
+	titleStyle := lipgloss.NewStyle().Bold(true)
+	subtitleStyle := lipgloss.NewStyle().Faint(true)
+
+	headerStyle := lipgloss.NewStyle().
+		Bold(true).
+		Padding(0, 1).
+		Faint(true)
+
+	cellStyle := lipgloss.NewStyle().
+		Padding(0, 1)
+
+	changeStyle := cellStyle.Foreground(lipgloss.AdaptiveColor{Light: "#11998e", Dark: "#4ecdc4"})
+	problemStyle := cellStyle.Foreground(lipgloss.Color("196"))
+
+	steps := v.plan.Steps
+
+	t := table.New().
+		BorderStyle(lipgloss.NewStyle().Faint(true)).
+		BorderRight(false).BorderLeft(false).BorderBottom(false).BorderTop(false).
+		BorderColumn(false).
+		StyleFunc(func(row, _ int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case steps[row].Problem != "":
+				return problemStyle
+			case steps[row].Action != policy.ActionNone:
+				return changeStyle
+			default:
+				return cellStyle
+			}
+		}).
+		Headers("SETTING", "CURRENT", "DESIRED", "ACTION")
+
+	for _, s := range steps {
+		action := string(s.Action)
+		if s.Problem != "" {
+			action = "blocked: " + s.Problem
+		}
+		t.Row(s.Setting, s.Current, s.Desired, action)
+	}
+
+	title := titleStyle.Render(v.desc.Product)
+	if v.desc.SerialNumber != "" {
+		title += " " + subtitleStyle.Render("(serial "+v.desc.SerialNumber+")")
+	}
+	title += " " + subtitleStyle.Render(v.desc.Path)
+
+	return lipgloss.NewStyle().Padding(1, 0, 1, 0).Render(title + "\n" + t.Render())
+}
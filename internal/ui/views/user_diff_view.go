@@ -0,0 +1,61 @@
+package views
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+)
+
+// UserDiffView is a view that displays the changes made to the user information of a credential.
+type UserDiffView struct {
+	before webauthn.PublicKeyCredentialUserEntity
+	after  webauthn.PublicKeyCredentialUserEntity
+}
+
+// NewUserDiffView creates a new UserDiffView.
+func NewUserDiffView(before, after webauthn.PublicKeyCredentialUserEntity) *UserDiffView {
+	return &UserDiffView{
+		before: before,
+		after:  after,
+	}
+}
+
+// Render renders the view.
+func (v *UserDiffView) Render() string {
+	headerStyle := lipgloss.NewStyle().
+		Bold(true).
+		Padding(0, 1).
+		Faint(true)
+
+	cellStyle := lipgloss.NewStyle().
+		Padding(0, 1)
+
+	oldStyle := cellStyle.Foreground(lipgloss.AdaptiveColor{Light: "#c0392b", Dark: "#ff6b6b"})
+	newStyle := cellStyle.Foreground(lipgloss.AdaptiveColor{Light: "#11998e", Dark: "#4ecdc4"})
+
+	rows := [][]string{
+		{"User Name", v.before.Name, v.after.Name},
+		{"Display Name", v.before.DisplayName, v.after.DisplayName},
+	}
+
+	t := table.New().
+		BorderStyle(lipgloss.NewStyle().Faint(true)).
+		BorderRight(false).BorderLeft(false).BorderBottom(false).BorderTop(false).
+		BorderColumn(false).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case rows[row][1] == rows[row][2] || col == 0:
+				return cellStyle
+			case col == 1:
+				return oldStyle
+			default:
+				return newStyle
+			}
+		}).
+		Headers("FIELD", "BEFORE", "AFTER").
+		Rows(rows...)
+
+	return lipgloss.NewStyle().Padding(1, 0, 1, 0).Render(t.Render())
+}
@@ -0,0 +1,121 @@
+package views
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/mohammadv184/skm/internal/attestation"
+	"github.com/mohammadv184/skm/internal/ui/output"
+)
+
+// VerificationView is a view that displays the verification of the attestation of a security key, with a table
+// of its checks and the verdict.
+type VerificationView struct {
+	report *output.VerificationReport
+}
+
+// NewVerificationView creates a new VerificationView.
+func NewVerificationView(report *output.VerificationReport) *VerificationView {
+	return &VerificationView{report: report}
+}
+
+// Render renders the view.
+func (v *VerificationView) Render() string {
+	titleStyle := lipgloss.NewStyle().Bold(true)
+	subtitleStyle := lipgloss.NewStyle().Faint(true)
+
+	labelStyle := lipgloss.NewStyle().
+		Bold(true).
+		Width(20)
+
+	headerStyle := lipgloss.NewStyle().
+		Bold(true).
+		Padding(0, 1).
+		Faint(true)
+
+	cellStyle := lipgloss.NewStyle().
+		Padding(0, 1)
+
+	passStyle := cellStyle.Foreground(lipgloss.AdaptiveColor{Light: "#11998e", Dark: "#4ecdc4"}).Bold(true)
+	failStyle := cellStyle.Foreground(lipgloss.Color("196")).Bold(true)
+	skipStyle := cellStyle.Faint(true)
+
+	r := v.report
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render(r.Device.Product))
+	if r.Device.SerialNumber != "" {
+		b.WriteString(" " + subtitleStyle.Render("(serial "+r.Device.SerialNumber+")"))
+	}
+	b.WriteString(" " + subtitleStyle.Render(r.Device.Path))
+	b.WriteString("\n\n")
+
+	renderRow := func(label, value string) {
+		b.WriteString(labelStyle.Render(label))
+		b.WriteString(value)
+		b.WriteString("\n")
+	}
+
+	if r.Device.Model != nil {
+		renderRow("Model:", modelName(r))
+	}
+	renderRow("AAGUID:", r.AAGUID)
+	attestationType := r.Format
+	if r.AttestationType != "" {
+		attestationType += ", " + r.AttestationType + " attestation"
+	}
+	renderRow("Attestation:", attestationType)
+	if len(r.Certificates) > 0 {
+		renderRow("Certificate:", r.Certificates[0].Subject)
+	}
+	if r.TrustAnchor != nil {
+		renderRow("Trust Anchor:", r.TrustAnchor.Subject)
+	}
+	b.WriteString("\n")
+
+	checks := r.Checks
+	t := table.New().
+		BorderStyle(lipgloss.NewStyle().Faint(true)).
+		BorderRight(false).BorderLeft(false).BorderBottom(false).BorderTop(false).
+		BorderColumn(false).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return headerStyle
+			case col != 1:
+				return cellStyle
+			case checks[row].Status == string(attestation.StatusPass):
+				return passStyle
+			case checks[row].Status == string(attestation.StatusFail):
+				return failStyle
+			default:
+				return skipStyle
+			}
+		}).
+		Headers("CHECK", "RESULT", "MESSAGE")
+
+	for _, c := range checks {
+		t.Row(c.Check, strings.ToUpper(c.Status), c.Message)
+	}
+	b.WriteString(t.Render())
+	b.WriteString("\n\n")
+
+	if r.Genuine {
+		b.WriteString(passStyle.UnsetPadding().Render("GENUINE"))
+		b.WriteString(" the security key proved to be a genuine " + modelName(r))
+	} else {
+		b.WriteString(failStyle.UnsetPadding().Render("NOT PROVEN GENUINE"))
+		b.WriteString(" the attestation of the security key could not be verified up to a trusted root")
+	}
+
+	return lipgloss.NewStyle().Padding(1, 0, 1, 0).Render(b.String())
+}
+
+// modelName returns the name of the model of the security key of the report, or its AAGUID if it is unknown.
+func modelName(r *output.VerificationReport) string {
+	if m := r.Device.Model; m != nil {
+		return strings.TrimSpace(m.Vendor + " " + m.Name)
+	}
+	return "security key model " + r.AAGUID
+}
@@ -0,0 +1,247 @@
+package views
+
+import (
+	"crypto/sha256"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+	"github.com/mohammadv184/skm/internal/credential"
+	"github.com/mohammadv184/skm/internal/largeblob"
+	"github.com/mohammadv184/skm/internal/mds"
+	"github.com/mohammadv184/skm/internal/policy"
+	"github.com/mohammadv184/skm/internal/ui/output"
+	"github.com/muesli/termenv"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestMain(m *testing.M) {
+	// Render without colors, whatever terminal the tests run in.
+	lipgloss.SetColorProfile(termenv.Ascii)
+	lipgloss.SetHasDarkBackground(true)
+
+	m.Run()
+}
+
+var (
+	testDevice = fido2.DeviceDescriptor{
+		Path:         "/dev/hidraw0",
+		VendorID:     0x1050,
+		ProductID:    0x0407,
+		SerialNumber: "12345678",
+		Manufacturer: "Yubico",
+		Product:      "YubiKey OTP+FIDO+CCID",
+	}
+
+	testCredentials = []*credential.Credential{
+		{
+			RP:           webauthn.PublicKeyCredentialRpEntity{ID: "example.com", Name: "Example"},
+			RPIDHash:     rpIDHash("example.com"),
+			User:         webauthn.PublicKeyCredentialUserEntity{ID: []byte("alice"), Name: "alice", DisplayName: "Alice"},
+			CredentialID: webauthn.PublicKeyCredentialDescriptor{ID: []byte("credential-1")},
+			CredProtect:  2,
+			LargeBlobKey: make([]byte, 32),
+		},
+		{
+			RP:           webauthn.PublicKeyCredentialRpEntity{ID: "example.com", Name: "Example"},
+			RPIDHash:     rpIDHash("example.com"),
+			User:         webauthn.PublicKeyCredentialUserEntity{ID: []byte("bob"), Name: "bob", DisplayName: "Bob"},
+			CredentialID: webauthn.PublicKeyCredentialDescriptor{ID: []byte("credential-2")},
+			CredProtect:  3,
+		},
+		{
+			RP:           webauthn.PublicKeyCredentialRpEntity{ID: "github.com"},
+			RPIDHash:     rpIDHash("github.com"),
+			User:         webauthn.PublicKeyCredentialUserEntity{ID: []byte("carol"), Name: "carol"},
+			CredentialID: webauthn.PublicKeyCredentialDescriptor{ID: []byte("credential-3")},
+		},
+	}
+)
+
+var testModel = &mds.Model{
+	AAGUID:        uuid.MustParse("ee882879-721c-4913-9775-3dfcce97072a"),
+	Vendor:        "Yubico",
+	Name:          "YubiKey 5 Series",
+	Certification: "FIDO_CERTIFIED_L1",
+	StatusReports: []mds.StatusReport{
+		{Status: "FIDO_CERTIFIED_L1", EffectiveDate: "2020-05-12"},
+		{Status: "USER_VERIFICATION_BYPASS", EffectiveDate: "2021-03-01"},
+	},
+}
+
+func rpIDHash(rpID string) []byte {
+	h := sha256.Sum256([]byte(rpID))
+	return h[:]
+}
+
+func TestViewsGolden(t *testing.T) {
+	info := &ctap2.AuthenticatorGetInfoResponse{
+		Versions:           []ctap2.Version{"FIDO_2_0", "FIDO_2_1"},
+		Extensions:         []webauthn.ExtensionIdentifier{"credProtect", "hmac-secret", "largeBlobKey"},
+		AAGUID:             uuid.MustParse("ee882879-721c-4913-9775-3dfcce97072a"),
+		Options:            map[ctap2.Option]bool{ctap2.OptionClientPIN: true, ctap2.OptionBioEnroll: false},
+		MaxMsgSize:         1200,
+		PinUvAuthProtocols: []ctap2.PinUvAuthProtocolType{2, 1},
+		MinPinLength:       4,
+		FirmwareVersion:    328966,
+	}
+
+	tests := []struct {
+		name string
+		view interface{ Render() string }
+	}{
+		{
+			name: "devices_list",
+			view: NewDevicesListView().WithDevices(
+				testDevice,
+				fido2.DeviceDescriptor{Path: "/dev/hidraw3", Product: "Solo 2", Manufacturer: "SoloKeys"},
+			),
+		},
+		{
+			name: "devices_list_models",
+			view: NewDevicesListView().WithDevices(
+				testDevice,
+				fido2.DeviceDescriptor{Path: "/dev/hidraw3", Product: "Solo 2", Manufacturer: "SoloKeys"},
+			).WithModels(map[string]*mds.Model{testDevice.Path: testModel}),
+		},
+		{
+			name: "device_info",
+			view: NewDeviceInfoView(&testDevice, info).WithRetries(8, 0, false),
+		},
+		{
+			name: "device_info_model",
+			view: NewDeviceInfoView(&testDevice, info).WithRetries(8, 0, false).WithModel(testModel),
+		},
+		{
+			name: "credential_list",
+			view: NewCredentialListView().WithRelyingParties(credential.Group(testCredentials)...),
+		},
+		{
+			name: "credential",
+			view: NewCredentialView(testCredentials[0]).WithCredBlob("absent"),
+		},
+		{
+			name: "pin_retries",
+			view: NewPinRetriesView().
+				WithDevice(&testDevice, "8", "3", false, "ok").
+				WithDevice(&fido2.DeviceDescriptor{Path: "/dev/hidraw3", Product: "Solo 2"}, "0", "-", true, "blocked"),
+		},
+		{
+			name: "bio_enrollment_list",
+			view: NewBioEnrollmentListView().WithEnrollments(
+				ctap2.TemplateInfo{TemplateID: []byte{0x01, 0x02}, TemplateFriendlyName: "right thumb"},
+				ctap2.TemplateInfo{TemplateID: []byte{0x0a, 0x0b}},
+			),
+		},
+		{
+			name: "bio_sensor",
+			view: NewBioSensorView(ctap2.BioModalityFingerprint, &ctap2.AuthenticatorBioEnrollmentResponse{
+				FingerprintKind:                    1,
+				MaxCaptureSamplesRequiredForEnroll: 4,
+				MaxTemplateFriendlyName:            32,
+			}),
+		},
+		{
+			name: "large_blob_list",
+			view: NewLargeBlobListView().WithEntries(
+				&largeblob.Entry{
+					Index:      0,
+					Blob:       &ctap2.LargeBlob{Ciphertext: make([]byte, 40), Nonce: make([]byte, 12), OrigSize: 24},
+					Credential: testCredentials[0],
+					Data:       []byte("ssh-ed25519 AAAA comment"),
+				},
+				&largeblob.Entry{
+					Index: 1,
+					Blob:  &ctap2.LargeBlob{Ciphertext: make([]byte, 20), Nonce: make([]byte, 12), OrigSize: 4},
+				},
+			).WithUsage(120, 1024),
+		},
+		{
+			name: "user_diff",
+			view: NewUserDiffView(
+				testCredentials[0].User,
+				webauthn.PublicKeyCredentialUserEntity{ID: []byte("alice"), Name: "alice@example.com", DisplayName: "Alice"},
+			),
+		},
+		{
+			name: "plan",
+			view: NewPlanView(&testDevice, &policy.Plan{Steps: []policy.Step{
+				{Setting: "pin", Current: "set", Desired: "set", Action: policy.ActionNone},
+				{Setting: "minPinLength", Current: "4", Desired: "8", Action: policy.ActionSetMinPINLength},
+				{Setting: "alwaysUv", Current: "unsupported", Desired: "true", Problem: "not supported"},
+			}}),
+		},
+		{
+			name: "verification",
+			view: NewVerificationView(&output.VerificationReport{
+				Device:          output.Device{Path: testDevice.Path, SerialNumber: "12345678", Product: testDevice.Product},
+				AAGUID:          testModel.AAGUID.String(),
+				Format:          "packed",
+				AttestationType: "self",
+				Checks: []output.VerificationCheck{
+					{Check: "format", Status: "pass", Message: "packed statement with self attestation"},
+					{Check: "signature", Status: "pass", Message: "signed by the credential"},
+					{Check: "certificate", Status: "skip", Message: "self attestation has no certificate"},
+					{Check: "chain", Status: "fail", Message: "self attestation cannot prove the model of the key"},
+				},
+			}),
+		},
+		{
+			name: "verification_genuine",
+			view: NewVerificationView(&output.VerificationReport{
+				Device: output.Device{
+					Path:         testDevice.Path,
+					SerialNumber: "12345678",
+					Product:      testDevice.Product,
+					Model:        output.NewModel(testModel),
+				},
+				AAGUID:            testModel.AAGUID.String(),
+				CertificateAAGUID: testModel.AAGUID.String(),
+				Genuine:           true,
+				Format:            "packed",
+				AttestationType:   "basic",
+				Certificates:      []output.Certificate{{Subject: "CN=Yubico U2F EE Serial 1,OU=Authenticator Attestation"}},
+				TrustAnchor:       &output.Certificate{Subject: "CN=Yubico FIDO Root CA Serial 450203556"},
+				Checks: []output.VerificationCheck{
+					{Check: "format", Status: "pass", Message: "packed statement with basic attestation"},
+					{Check: "aaguid", Status: "pass", Message: "the attestation certificate is for " + testModel.AAGUID.String()},
+					{Check: "chain", Status: "pass", Message: "issued by CN=Yubico FIDO Root CA Serial 450203556"},
+				},
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertGolden(t, tt.name, tt.view.Render())
+		})
+	}
+}
+
+// assertGolden compares got with the golden file of name in testdata, or rewrites it with -update.
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file, run the tests with -update to create it: %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s does not match the golden file %s\ngot:\n%s\nwant:\n%s", name, path, got, want)
+	}
+}
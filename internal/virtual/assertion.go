@@ -0,0 +1,286 @@
+package virtual
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"iter"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+)
+
+// GetAssertion signs clientData with the discoverable credentials of rpID, restricted to allowList if it is
+// not empty, or with the non-discoverable credentials of allowList. Only the credBlob extension is supported.
+// A pinUvAuthToken with the getAssertion permission marks the assertions as user verified.
+func (a *Authenticator) GetAssertion(
+	pinUvAuthToken []byte,
+	rpID string,
+	clientData []byte,
+	allowList []webauthn.PublicKeyCredentialDescriptor,
+	extInputs *webauthn.GetAuthenticationExtensionsClientInputs,
+	options map[ctap2.Option]bool,
+) iter.Seq2[*ctap2.AuthenticatorGetAssertionResponse, error] {
+	return func(yield func(*ctap2.AuthenticatorGetAssertionResponse, error) bool) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if a.closed {
+			yield(nil, ErrClosed)
+			return
+		}
+		if extInputs == nil {
+			extInputs = new(webauthn.GetAuthenticationExtensionsClientInputs)
+		}
+		if extInputs.LargeBlobInputs != nil || extInputs.PRFInputs != nil || extInputs.GetHMACSecretInputs != nil {
+			yield(nil, notSupported("virtual authenticator supports only the credBlob extension in getAssertion"))
+			return
+		}
+
+		flags := ctap2.AuthDataFlagUserPresent
+		if up, ok := options[ctap2.OptionUserPresence]; ok && !up {
+			flags = 0
+		}
+
+		uv := pinUvAuthToken != nil
+		if uv {
+			if err := a.authorize(pinUvAuthToken, ctap2.PermissionGetAssertion, rpID); err != nil {
+				yield(nil, err)
+				return
+			}
+			flags |= ctap2.AuthDataFlagUserVerified
+		} else if a.state.AlwaysUV {
+			yield(nil, ctapError(ctaphid.StatusCTAP2ErrPUATRequired))
+			return
+		}
+
+		creds := a.assertionCredentials(rpID, allowList, uv)
+		if len(creds) == 0 {
+			yield(nil, ctapError(ctaphid.StatusCTAP2ErrNoCredentials))
+			return
+		}
+
+		clientDataHash := sha256.Sum256(clientData)
+		for i, c := range creds {
+			assertion, err := a.assert(c, flags, clientDataHash[:], extInputs.GetCredentialBlobInputs != nil)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if i == 0 {
+				assertion.NumberOfCredentials = uint(len(creds))
+			}
+			if !yield(assertion, nil) {
+				return
+			}
+		}
+	}
+}
+
+// assertionCredentials returns the credentials of rpID that may be asserted, honoring their credProtect level.
+// The non-discoverable credentials of allowList follow the discoverable ones.
+func (a *Authenticator) assertionCredentials(
+	rpID string,
+	allowList []webauthn.PublicKeyCredentialDescriptor,
+	uv bool,
+) []*Credential {
+	var creds []*Credential
+	for _, c := range a.state.Credentials {
+		if c.RP.ID != rpID {
+			continue
+		}
+
+		allowed := slices.ContainsFunc(allowList, func(d webauthn.PublicKeyCredentialDescriptor) bool {
+			return bytes.Equal(d.ID, c.ID)
+		})
+		switch {
+		case len(allowList) > 0 && !allowed:
+			continue
+		case c.CredProtect == 3 && !uv:
+			continue
+		case c.CredProtect == 2 && !uv && !allowed:
+			continue
+		}
+
+		creds = append(creds, c)
+	}
+
+	for _, d := range allowList {
+		if c := a.unwrapCredential(rpID, d.ID); c != nil && (c.CredProtect != 3 || uv) {
+			creds = append(creds, c)
+		}
+	}
+	return creds
+}
+
+// assert increments the signature counter of c and returns its assertion over clientDataHash.
+func (a *Authenticator) assert(
+	c *Credential,
+	flags ctap2.AuthDataFlag,
+	clientDataHash []byte,
+	credBlob bool,
+) (*ctap2.AuthenticatorGetAssertionResponse, error) {
+	priv, err := c.privateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	counter := &c.SignCount
+	if c.wrapped {
+		counter = &a.state.SignCount
+	}
+	*counter++
+	if err := a.save(); err != nil {
+		return nil, err
+	}
+
+	authData := &ctap2.GetAssertionAuthData{
+		RPIDHash:  c.rpIDHash(),
+		Flags:     flags,
+		SignCount: *counter,
+	}
+
+	raw := binary.BigEndian.AppendUint32(append(slices.Clone(authData.RPIDHash), byte(flags)), *counter)
+	if credBlob {
+		authData.Flags |= ctap2.AuthDataFlagExtensionDataIncluded
+		authData.Extensions = &ctap2.GetExtensionOutputs{
+			GetCredBlobOutput: &ctap2.GetCredBlobOutput{CredBlob: bytes.Clone(c.CredBlob)},
+		}
+
+		ext, err := cbor.Marshal(map[string][]byte{"credBlob": c.CredBlob})
+		if err != nil {
+			return nil, err
+		}
+		raw[32] = byte(authData.Flags)
+		raw = append(raw, ext...)
+	}
+
+	digest := sha256.Sum256(slices.Concat(raw, clientDataHash))
+	sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ctap2.AuthenticatorGetAssertionResponse{
+		Credential:       c.descriptor(),
+		AuthDataRaw:      raw,
+		AuthData:         authData,
+		Signature:        sig,
+		ExtensionOutputs: &webauthn.GetAuthenticationExtensionsClientOutputs{},
+	}
+	if !c.wrapped {
+		resp.User = &webauthn.PublicKeyCredentialUserEntity{ID: bytes.Clone(c.User.ID)}
+	}
+	if credBlob {
+		resp.ExtensionOutputs.GetCredentialBlobOutputs = &webauthn.GetCredentialBlobOutputs{
+			GetCredBlob: bytes.Clone(c.CredBlob),
+		}
+	}
+
+	return resp, nil
+}
+
+// getAssertionParams are the parameters of authenticatorGetAssertion.
+type getAssertionParams struct {
+	RPID              string                                   `cbor:"1,keyasint"`
+	ClientDataHash    []byte                                   `cbor:"2,keyasint"`
+	AllowList         []webauthn.PublicKeyCredentialDescriptor `cbor:"3,keyasint"`
+	Extensions        getExtensions                            `cbor:"4,keyasint"`
+	Options           map[ctap2.Option]bool                    `cbor:"5,keyasint"`
+	PinUvAuthParam    []byte                                   `cbor:"6,keyasint"`
+	PinUvAuthProtocol ctap2.PinUvAuthProtocolType              `cbor:"7,keyasint"`
+}
+
+// getExtensions are the getAssertion extension inputs the virtual authenticator supports. Others are ignored.
+type getExtensions struct {
+	CredBlob     bool `cbor:"credBlob,omitempty"`
+	LargeBlobKey bool `cbor:"largeBlobKey,omitempty"`
+}
+
+// handleGetAssertion executes authenticatorGetAssertion. With an allowList, only the first credential found is
+// asserted; otherwise the assertions of the other discoverable credentials are left for
+// authenticatorGetNextAssertion.
+func (a *Authenticator) handleGetAssertion(r *request) (any, error) {
+	var p getAssertionParams
+	if err := r.decode(&p, 0x01, 0x02); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := p.Options[ctap2.OptionResidentKeys]; ok {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrUnsupportedOption)
+	}
+
+	uv, err := a.verifyUser(
+		r,
+		0x06,
+		p.PinUvAuthParam,
+		p.PinUvAuthProtocol,
+		p.ClientDataHash,
+		p.Options,
+		ctap2.PermissionGetAssertion,
+		p.RPID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !uv && a.state.AlwaysUV {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrPUATRequired)
+	}
+
+	var flags ctap2.AuthDataFlag
+	if up, ok := p.Options[ctap2.OptionUserPresence]; !ok || up {
+		if err := r.userPresence(); err != nil {
+			return nil, err
+		}
+		flags |= ctap2.AuthDataFlagUserPresent
+	}
+	if uv {
+		flags |= ctap2.AuthDataFlagUserVerified
+	}
+
+	creds := a.assertionCredentials(p.RPID, p.AllowList, uv)
+	if len(creds) == 0 {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrNoCredentials)
+	}
+	if len(p.AllowList) > 0 {
+		creds = creds[:1]
+	}
+
+	responses := make([]any, 0, len(creds))
+	for i, c := range creds {
+		resp, err := a.assert(c, flags, p.ClientDataHash, p.Extensions.CredBlob)
+		if err != nil {
+			return nil, err
+		}
+		if uv && resp.User != nil {
+			// The user is only identified by name once verified.
+			resp.User.Name, resp.User.DisplayName = c.User.Name, c.User.DisplayName
+		}
+		if p.Extensions.LargeBlobKey {
+			resp.LargeBlobKey = bytes.Clone(c.LargeBlobKey)
+		}
+		if i == 0 && len(p.AllowList) == 0 {
+			resp.NumberOfCredentials = uint(len(creds))
+		}
+		responses = append(responses, resp)
+	}
+
+	a.next = &pendingResponses{command: ctap2.CMDAuthenticatorGetAssertion, responses: responses[1:]}
+	return responses[0], nil
+}
+
+// handleGetNextAssertion executes authenticatorGetNextAssertion.
+func (a *Authenticator) handleGetNextAssertion(r *request) (any, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.popNext(r, ctap2.CMDAuthenticatorGetAssertion, 0)
+}
@@ -0,0 +1,285 @@
+package virtual
+
+import (
+	"bytes"
+	"crypto/rand"
+	"slices"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+)
+
+// pendingEnrollment is a fingerprint enrollment that still needs samples.
+type pendingEnrollment struct {
+	templateID []byte
+	remaining  uint
+}
+
+// GetBioModality reports the fingerprint modality.
+func (a *Authenticator) GetBioModality() (*ctap2.AuthenticatorBioEnrollmentResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireBioEnrollment(); err != nil {
+		return nil, err
+	}
+
+	return &ctap2.AuthenticatorBioEnrollmentResponse{Modality: ctap2.BioModalityFingerprint}, nil
+}
+
+// GetFingerprintSensorInfo reports a touch sensor that needs four samples per enrollment.
+func (a *Authenticator) GetFingerprintSensorInfo() (*ctap2.AuthenticatorBioEnrollmentResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireBioEnrollment(); err != nil {
+		return nil, err
+	}
+
+	return &ctap2.AuthenticatorBioEnrollmentResponse{
+		FingerprintKind:                    1,
+		MaxCaptureSamplesRequiredForEnroll: samplesPerEnrollment,
+		MaxTemplateFriendlyName:            maxTemplateFriendlyName,
+	}, nil
+}
+
+// BeginEnroll starts a fingerprint enrollment and captures its first sample.
+func (a *Authenticator) BeginEnroll(
+	pinUvAuthToken []byte,
+	_ uint,
+) (*ctap2.AuthenticatorBioEnrollmentResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireBioEnrollment(); err != nil {
+		return nil, err
+	}
+	if err := a.authorize(pinUvAuthToken, ctap2.PermissionBioEnrollment, ""); err != nil {
+		return nil, err
+	}
+	if len(a.state.Enrollments) >= maxEnrollments {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrFPDatabaseFull)
+	}
+
+	templateID := make([]byte, 2)
+	for {
+		if _, err := rand.Read(templateID); err != nil {
+			return nil, err
+		}
+		if a.findEnrollment(templateID) < 0 {
+			break
+		}
+	}
+
+	a.enrollment = &pendingEnrollment{
+		templateID: templateID,
+		remaining:  samplesPerEnrollment,
+	}
+
+	return a.captureSample()
+}
+
+// EnrollCaptureNextSample captures the next sample of the enrollment in progress. The fingerprint is
+// stored once no samples remain.
+func (a *Authenticator) EnrollCaptureNextSample(
+	pinUvAuthToken []byte,
+	templateID []byte,
+	_ uint,
+) (*ctap2.AuthenticatorBioEnrollmentResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireBioEnrollment(); err != nil {
+		return nil, err
+	}
+	if err := a.authorize(pinUvAuthToken, ctap2.PermissionBioEnrollment, ""); err != nil {
+		return nil, err
+	}
+	if a.enrollment == nil || !bytes.Equal(a.enrollment.templateID, templateID) {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrInvalidOption)
+	}
+
+	return a.captureSample()
+}
+
+// CancelCurrentEnrollment discards the enrollment in progress, if any.
+func (a *Authenticator) CancelCurrentEnrollment() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireBioEnrollment(); err != nil {
+		return err
+	}
+
+	a.enrollment = nil
+	return nil
+}
+
+// EnumerateEnrollments lists the enrolled fingerprints. Like most security keys, it fails with
+// CTAP2_ERR_INVALID_OPTION if there are none.
+func (a *Authenticator) EnumerateEnrollments(pinUvAuthToken []byte) (*ctap2.AuthenticatorBioEnrollmentResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireBioEnrollment(); err != nil {
+		return nil, err
+	}
+	if err := a.authorize(pinUvAuthToken, ctap2.PermissionBioEnrollment, ""); err != nil {
+		return nil, err
+	}
+	if len(a.state.Enrollments) == 0 {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrInvalidOption)
+	}
+
+	infos := make([]ctap2.TemplateInfo, 0, len(a.state.Enrollments))
+	for _, e := range a.state.Enrollments {
+		infos = append(infos, ctap2.TemplateInfo{
+			TemplateID:           bytes.Clone(e.TemplateID),
+			TemplateFriendlyName: e.FriendlyName,
+		})
+	}
+
+	return &ctap2.AuthenticatorBioEnrollmentResponse{TemplateInfos: infos}, nil
+}
+
+// SetFriendlyName renames an enrolled fingerprint.
+func (a *Authenticator) SetFriendlyName(pinUvAuthToken []byte, templateID []byte, friendlyName string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireBioEnrollment(); err != nil {
+		return err
+	}
+	if err := a.authorize(pinUvAuthToken, ctap2.PermissionBioEnrollment, ""); err != nil {
+		return err
+	}
+	if len(friendlyName) > maxTemplateFriendlyName {
+		return ctapError(ctaphid.StatusCTAP1ErrInvalidLength)
+	}
+
+	i := a.findEnrollment(templateID)
+	if i < 0 {
+		return ctapError(ctaphid.StatusCTAP2ErrInvalidOption)
+	}
+
+	a.state.Enrollments[i].FriendlyName = friendlyName
+	return a.save()
+}
+
+// RemoveEnrollment removes an enrolled fingerprint.
+func (a *Authenticator) RemoveEnrollment(pinUvAuthToken []byte, templateID []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireBioEnrollment(); err != nil {
+		return err
+	}
+	if err := a.authorize(pinUvAuthToken, ctap2.PermissionBioEnrollment, ""); err != nil {
+		return err
+	}
+
+	i := a.findEnrollment(templateID)
+	if i < 0 {
+		return ctapError(ctaphid.StatusCTAP2ErrInvalidOption)
+	}
+
+	a.state.Enrollments = slices.Delete(a.state.Enrollments, i, i+1)
+	return a.save()
+}
+
+// captureSample records a good sample for the enrollment in progress.
+func (a *Authenticator) captureSample() (*ctap2.AuthenticatorBioEnrollmentResponse, error) {
+	a.enrollment.remaining--
+
+	resp := &ctap2.AuthenticatorBioEnrollmentResponse{
+		TemplateID:             bytes.Clone(a.enrollment.templateID),
+		LastEnrollSampleStatus: ctap2.LastEnrollSampleStatusFingerprintGood,
+		RemainingSamples:       a.enrollment.remaining,
+	}
+
+	if a.enrollment.remaining == 0 {
+		a.state.Enrollments = append(a.state.Enrollments, &Enrollment{TemplateID: a.enrollment.templateID})
+		a.enrollment = nil
+		if err := a.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+func (a *Authenticator) findEnrollment(templateID []byte) int {
+	return slices.IndexFunc(a.state.Enrollments, func(e *Enrollment) bool {
+		return bytes.Equal(e.TemplateID, templateID)
+	})
+}
+
+func (a *Authenticator) requireBioEnrollment() error {
+	switch {
+	case a.closed:
+		return ErrClosed
+	case !a.state.Features.BioEnrollment:
+		return notSupported("device doesn't support biometric enrollment")
+	}
+	return nil
+}
+
+// handleBioEnrollment executes authenticatorBioEnrollment. Capturing a sample asks for user presence, which
+// stands for the finger touching the sensor.
+func (a *Authenticator) handleBioEnrollment(r *request) (any, error) {
+	var req ctap2.AuthenticatorBioEnrollmentRequest
+	if err := r.decode(&req); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	err := a.requireBioEnrollment()
+	a.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.GetModality:
+		return a.GetBioModality()
+	case req.SubCommand == ctap2.BioEnrollmentSubCommandGetFingerprintSensorInfo:
+		return a.GetFingerprintSensorInfo()
+	case req.SubCommand == ctap2.BioEnrollmentSubCommandCancelCurrentEnrollment:
+		return nil, a.CancelCurrentEnrollment()
+	case !r.has(0x02):
+		return nil, ctapError(ctaphid.StatusCTAP2ErrMissingParameter)
+	case req.Modality != ctap2.BioModalityFingerprint:
+		return nil, ctapError(ctaphid.StatusCTAP2ErrUnsupportedOption)
+	}
+
+	token, err := a.authenticatedToken(
+		req.PinUvAuthProtocol,
+		req.PinUvAuthParam,
+		append([]byte{byte(req.Modality), byte(req.SubCommand)}, r.params[0x03]...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	params := req.SubCommandParams
+	switch req.SubCommand {
+	case ctap2.BioEnrollmentSubCommandEnrollBegin:
+		if err := r.userPresence(); err != nil {
+			return nil, err
+		}
+		return a.BeginEnroll(token, params.TimeoutMilliseconds)
+	case ctap2.BioEnrollmentSubCommandEnrollCaptureNextSample:
+		if err := r.userPresence(); err != nil {
+			return nil, err
+		}
+		return a.EnrollCaptureNextSample(token, params.TemplateID, params.TimeoutMilliseconds)
+	case ctap2.BioEnrollmentSubCommandEnumerateEnrollments:
+		return a.EnumerateEnrollments(token)
+	case ctap2.BioEnrollmentSubCommandSetFriendlyName:
+		return nil, a.SetFriendlyName(token, params.TemplateID, params.TemplateFriendlyName)
+	case ctap2.BioEnrollmentSubCommandRemoveEnrollment:
+		return nil, a.RemoveEnrollment(token, params.TemplateID)
+	default:
+		return nil, ctapError(ctaphid.StatusCTAP2ErrInvalidSubcommand)
+	}
+}
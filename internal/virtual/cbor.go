@@ -0,0 +1,304 @@
+package virtual
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+)
+
+// Presence asks the user to confirm their presence, e.g. by touching the key. It returns an error if the user
+// doesn't before ctx is done.
+type Presence func(ctx context.Context) error
+
+// test asks for user presence and returns the CTAP2 error reporting a failure. A nil Presence is granted at
+// once.
+func (p Presence) test(ctx context.Context) error {
+	err := ctx.Err()
+	if p != nil && err == nil {
+		err = p(ctx)
+	}
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.Canceled):
+		return ctapError(ctaphid.StatusCTAP2ErrKeepaliveCancel)
+	case errors.Is(err, context.DeadlineExceeded):
+		return ctapError(ctaphid.StatusCTAP2ErrUserActionTimeout)
+	default:
+		return ctapError(ctaphid.StatusCTAP2ErrOperationDenied)
+	}
+}
+
+// request is a CTAP2 command received by HandleCBOR.
+type request struct {
+	ctx      context.Context
+	presence Presence
+	// data is the CBOR parameter map and params its entries, kept raw to compute the messages that
+	// pinUvAuthParam authenticates.
+	data   []byte
+	params map[int]cbor.RawMessage
+	// next holds the responses left by the previous command, which only getNext commands continue from.
+	next *pendingResponses
+}
+
+// pendingResponses are the responses a command leaves for the getNext commands that follow it.
+type pendingResponses struct {
+	command    ctap2.Command
+	subCommand uint
+	responses  []any
+}
+
+// has reports whether the parameter with key k is present.
+func (r *request) has(k int) bool {
+	_, ok := r.params[k]
+	return ok
+}
+
+// require returns CTAP2_ERR_MISSING_PARAMETER unless the parameters with the given keys are present.
+func (r *request) require(keys ...int) error {
+	for _, k := range keys {
+		if !r.has(k) {
+			return ctapError(ctaphid.StatusCTAP2ErrMissingParameter)
+		}
+	}
+	return nil
+}
+
+// decode decodes the parameters into v, which must be a struct with integer keys, after checking that the
+// required parameters are present.
+func (r *request) decode(v any, required ...int) error {
+	if err := r.require(required...); err != nil {
+		return err
+	}
+	if r.data == nil {
+		return nil
+	}
+	if err := cbor.Unmarshal(r.data, v); err != nil {
+		return ctapError(ctaphid.StatusCTAP2ErrCBORUnexpectedType)
+	}
+	return nil
+}
+
+// userPresence asks for user presence.
+func (r *request) userPresence() error {
+	return r.presence.test(r.ctx)
+}
+
+// popNext returns the next response left by command and subCommand, or CTAP2_ERR_NOT_ALLOWED if there is none.
+// It must be called with the mutex held.
+func (a *Authenticator) popNext(r *request, command ctap2.Command, subCommand uint) (any, error) {
+	next := r.next
+	if next == nil || next.command != command || next.subCommand != subCommand || len(next.responses) == 0 {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrNotAllowed)
+	}
+
+	resp := next.responses[0]
+	next.responses = next.responses[1:]
+	a.next = next
+	return resp, nil
+}
+
+// HandleCBOR executes a CTAP2 command in the encoding carried by CTAPHID_CBOR, a command byte followed by its
+// CBOR parameters, and returns the status byte followed by the CBOR response, if any. presence is asked
+// whenever the command requires user presence; cancelling ctx makes the command fail with
+// CTAP2_ERR_KEEPALIVE_CANCEL. Commands are executed one at a time.
+func (a *Authenticator) HandleCBOR(ctx context.Context, req []byte, presence Presence) []byte {
+	a.cborMu.Lock()
+	defer a.cborMu.Unlock()
+
+	resp, err := a.handleCBOR(ctx, req, presence)
+	var data []byte
+	if err == nil && resp != nil {
+		data, err = marshal(resp)
+	}
+	if err != nil {
+		return []byte{byte(status(err))}
+	}
+	return append([]byte{byte(ctaphid.StatusCTAP2OK)}, data...)
+}
+
+func (a *Authenticator) handleCBOR(ctx context.Context, req []byte, presence Presence) (any, error) {
+	if len(req) == 0 {
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidLength)
+	}
+
+	r := &request{ctx: ctx, presence: presence}
+	if len(req) > 1 {
+		r.data = req[1:]
+		if err := cbor.Unmarshal(r.data, &r.params); err != nil {
+			return nil, ctapError(ctaphid.StatusCTAP2ErrInvalidCBOR)
+		}
+	}
+
+	a.mu.Lock()
+	r.next, a.next = a.next, nil
+	closed := a.closed
+	a.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	switch ctap2.Command(req[0]) {
+	case ctap2.CMDAuthenticatorGetInfo:
+		return a.handleGetInfo(), nil
+	case ctap2.CMDAuthenticatorMakeCredential:
+		return a.handleMakeCredential(r)
+	case ctap2.CMDAuthenticatorGetAssertion:
+		return a.handleGetAssertion(r)
+	case ctap2.CMDAuthenticatorGetNextAssertion:
+		return a.handleGetNextAssertion(r)
+	case ctap2.CMDAuthenticatorClientPIN:
+		return a.handleClientPIN(r)
+	case ctap2.CMDAuthenticatorReset:
+		if err := r.userPresence(); err != nil {
+			return nil, err
+		}
+		return nil, a.Reset()
+	case ctap2.CMDAuthenticatorSelection:
+		return nil, r.userPresence()
+	case ctap2.CMDAuthenticatorCredentialManagement:
+		return a.handleCredentialManagement(r)
+	case ctap2.CMDAuthenticatorBioEnrollment:
+		return a.handleBioEnrollment(r)
+	case ctap2.CMDAuthenticatorLargeBlobs:
+		return a.handleLargeBlobs(r)
+	case ctap2.CMDAuthenticatorConfig:
+		return a.handleConfig(r)
+	default:
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidCommand)
+	}
+}
+
+// handleGetInfo encodes the authenticatorGetInfo response, leaving out the members that don't apply.
+func (a *Authenticator) handleGetInfo() map[int]any {
+	info := a.Info()
+
+	resp := map[int]any{
+		0x01: info.Versions,
+		0x02: info.Extensions,
+		0x03: info.AAGUID[:],
+		0x04: info.Options,
+		0x05: info.MaxMsgSize,
+		0x06: info.PinUvAuthProtocols,
+		0x07: info.MaxCredentialCountInList,
+		0x08: info.MaxCredentialLength,
+		0x09: info.Transports,
+		0x0a: info.Algorithms,
+		0x0c: info.ForcePinChange,
+		0x0d: info.MinPinLength,
+		0x0e: info.FirmwareVersion,
+		0x0f: info.MaxCredBlobLength,
+		0x14: info.RemainingDiscoverableCredentials,
+		0x1d: info.MaxPINLength,
+	}
+	if info.MaxSerializedLargeBlobArray > 0 {
+		resp[0x0b] = info.MaxSerializedLargeBlobArray
+	}
+	if info.MaxRPIDsForSetMinPINLength > 0 {
+		resp[0x10] = info.MaxRPIDsForSetMinPINLength
+	}
+	if info.UvModality > 0 {
+		resp[0x12] = info.UvModality
+	}
+
+	return resp
+}
+
+// authenticatedToken checks the pinUvAuthParam of a command against message and returns the current
+// pinUvAuthToken, to be passed to the method implementing the command. A missing pinUvAuthParam is reported
+// as CTAP2_ERR_PUAT_REQUIRED.
+func (a *Authenticator) authenticatedToken(
+	protocol ctap2.PinUvAuthProtocolType,
+	param []byte,
+	message []byte,
+) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if param == nil {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrPUATRequired)
+	}
+	if err := a.checkPinUvAuthParam(protocol, param, message); err != nil {
+		return nil, err
+	}
+	return a.token, nil
+}
+
+// verifyUser verifies the user for makeCredential and getAssertion, either with a pinUvAuthParam computed over
+// clientDataHash with a pinUvAuthToken that has permission, or with the built-in user verification the uv
+// option asks for. paramKey is the key of pinUvAuthParam, which the key of pinUvAuthProtocol follows. It
+// reports whether the user was verified and must be called with the mutex held.
+func (a *Authenticator) verifyUser(
+	r *request,
+	paramKey int,
+	param []byte,
+	protocol ctap2.PinUvAuthProtocolType,
+	clientDataHash []byte,
+	options map[ctap2.Option]bool,
+	permission ctap2.Permission,
+	rpID string,
+) (bool, error) {
+	switch {
+	case r.has(paramKey) && len(param) == 0:
+		// Platforms send an empty pinUvAuthParam to have the user select an authenticator by touching it.
+		if err := r.userPresence(); err != nil {
+			return false, err
+		}
+		if len(a.state.PINHash) == 0 {
+			return false, ctapError(ctaphid.StatusCTAP2ErrPinNotSet)
+		}
+		return false, ctapError(ctaphid.StatusCTAP2ErrPinInvalid)
+	case r.has(paramKey):
+		if err := r.require(paramKey + 1); err != nil {
+			return false, err
+		}
+		if err := a.checkPinUvAuthParam(protocol, param, clientDataHash); err != nil {
+			return false, err
+		}
+		return true, a.authorize(a.token, permission, rpID)
+	case options[ctap2.OptionUserVerification]:
+		if !a.state.Features.BioEnrollment || len(a.state.Enrollments) == 0 {
+			return false, ctapError(ctaphid.StatusCTAP2ErrInvalidOption)
+		}
+		return true, r.userPresence()
+	default:
+		return false, nil
+	}
+}
+
+// status returns the CTAP2 status code that reports err.
+func status(err error) ctaphid.StatusCode {
+	var ctapErr *ctaphid.CTAPError
+	switch {
+	case errors.As(err, &ctapErr):
+		return ctapErr.StatusCode
+	case errors.Is(err, fido2.ErrNotSupported):
+		return ctaphid.StatusCTAP1ErrInvalidCommand
+	case errors.Is(err, fido2.ErrPinNotSet):
+		return ctaphid.StatusCTAP2ErrPinNotSet
+	case errors.Is(err, fido2.ErrPinAlreadySet):
+		return ctaphid.StatusCTAP2ErrPinAuthInvalid
+	case errors.Is(err, fido2.ErrUvNotConfigured):
+		return ctaphid.StatusCTAP2ErrNotAllowed
+	case errors.Is(err, fido2.ErrLargeBlobsIntegrityCheck):
+		return ctaphid.StatusCTAP2ErrIntegrityFailure
+	case errors.Is(err, fido2.ErrLargeBlobsTooBig):
+		return ctaphid.StatusCTAP2ErrLargeBlobStorageFull
+	default:
+		return ctaphid.StatusCTAP1ErrOther
+	}
+}
+
+// marshal encodes v in the CTAP2 canonical CBOR encoding.
+func marshal(v any) ([]byte, error) {
+	encMode, err := cbor.CTAP2EncOptions().EncMode()
+	if err != nil {
+		return nil, err
+	}
+	return encMode.Marshal(v)
+}
@@ -0,0 +1,478 @@
+package virtual
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ldclabs/cose/key"
+	coseecdsa "github.com/ldclabs/cose/key/ecdsa"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+)
+
+var testClientDataHash = bytes.Repeat([]byte{0xcd}, 32)
+
+// call executes command through HandleCBOR and returns its status and CBOR response.
+func call(
+	t *testing.T,
+	a *Authenticator,
+	presence Presence,
+	command ctap2.Command,
+	params any,
+) (ctaphid.StatusCode, []byte) {
+	t.Helper()
+
+	req := []byte{byte(command)}
+	if params != nil {
+		data, err := marshal(params)
+		if err != nil {
+			t.Fatalf("marshal() error = %v", err)
+		}
+		req = append(req, data...)
+	}
+
+	resp := a.HandleCBOR(context.Background(), req, presence)
+	if len(resp) == 0 {
+		t.Fatalf("HandleCBOR(%s) returned no status", command)
+	}
+	return ctaphid.StatusCode(resp[0]), resp[1:]
+}
+
+// mustCall is call for a command that must succeed, decoding its response into resp.
+func mustCall(t *testing.T, a *Authenticator, command ctap2.Command, params any, resp any) {
+	t.Helper()
+
+	status, data := call(t, a, nil, command, params)
+	if status != ctaphid.StatusCTAP2OK {
+		t.Fatalf("HandleCBOR(%s) status = %s, want %s", command, status, ctaphid.StatusCTAP2OK)
+	}
+	if resp != nil {
+		if err := cbor.Unmarshal(data, resp); err != nil {
+			t.Fatalf("decoding %s response: %v", command, err)
+		}
+	}
+}
+
+// platform is the platform side of a PIN/UV auth protocol, as a FIDO2 client implements it.
+type platform struct {
+	protocol *ctap2.PinUvAuthProtocol
+	secret   []byte
+	key      key.Key
+}
+
+func newPlatform(t *testing.T, a *Authenticator, protocol ctap2.PinUvAuthProtocolType) *platform {
+	t.Helper()
+
+	var resp ctap2.AuthenticatorClientPINResponse
+	mustCall(t, a, ctap2.CMDAuthenticatorClientPIN, &ctap2.AuthenticatorClientPINRequest{
+		PinUvAuthProtocol: protocol,
+		SubCommand:        ctap2.ClientPINSubCommandGetKeyAgreement,
+	}, &resp)
+
+	p, err := ctap2.NewPinUvAuthProtocol(protocol)
+	if err != nil {
+		t.Fatalf("NewPinUvAuthProtocol() error = %v", err)
+	}
+	platformKey, secret, err := p.Encapsulate(resp.KeyAgreement)
+	if err != nil {
+		t.Fatalf("Encapsulate() error = %v", err)
+	}
+	return &platform{protocol: p, secret: secret, key: platformKey}
+}
+
+func (p *platform) encrypt(t *testing.T, plaintext []byte) []byte {
+	t.Helper()
+
+	ciphertext, err := p.protocol.Encrypt(p.secret, plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	return ciphertext
+}
+
+// setPIN sets testPIN over the wire.
+func (p *platform) setPIN(t *testing.T, a *Authenticator) {
+	t.Helper()
+
+	padded := make([]byte, 64)
+	copy(padded, testPIN)
+	newPinEnc := p.encrypt(t, padded)
+	mustCall(t, a, ctap2.CMDAuthenticatorClientPIN, &ctap2.AuthenticatorClientPINRequest{
+		PinUvAuthProtocol: p.protocol.Type,
+		SubCommand:        ctap2.ClientPINSubCommandSetPIN,
+		KeyAgreement:      p.key,
+		NewPinEnc:         newPinEnc,
+		PinUvAuthParam:    ctap2.Authenticate(p.protocol.Type, p.secret, newPinEnc),
+	}, nil)
+}
+
+// token gets a pinUvAuthToken with testPIN over the wire.
+func (p *platform) token(t *testing.T, a *Authenticator, permissions ctap2.Permission, rpID string) []byte {
+	t.Helper()
+
+	status, data := call(t, a, nil, ctap2.CMDAuthenticatorClientPIN, &ctap2.AuthenticatorClientPINRequest{
+		PinUvAuthProtocol: p.protocol.Type,
+		SubCommand:        ctap2.ClientPINSubCommandGetPinUvAuthTokenUsingPinWithPermissions,
+		KeyAgreement:      p.key,
+		PinHashEnc:        p.encrypt(t, pinHash(testPIN)),
+		Permissions:       permissions,
+		RPID:              rpID,
+	})
+	if status != ctaphid.StatusCTAP2OK {
+		t.Fatalf("getPinUvAuthTokenUsingPinWithPermissions status = %s", status)
+	}
+
+	var resp ctap2.AuthenticatorClientPINResponse
+	if err := cbor.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("decoding clientPIN response: %v", err)
+	}
+	tok, err := p.protocol.Decrypt(p.secret, resp.PinUvAuthToken)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	return tok
+}
+
+func TestHandleCBORGetInfo(t *testing.T) {
+	a := openTest(t)
+
+	var info ctap2.AuthenticatorGetInfoResponse
+	mustCall(t, a, ctap2.CMDAuthenticatorGetInfo, nil, &info)
+
+	if info.AAGUID != a.Info().AAGUID {
+		t.Errorf("AAGUID = %s, want %s", info.AAGUID, a.Info().AAGUID)
+	}
+	if !slices.Equal(info.Versions, a.Info().Versions) {
+		t.Errorf("Versions = %v, want %v", info.Versions, a.Info().Versions)
+	}
+	if info.MaxMsgSize != maxMsgSize {
+		t.Errorf("MaxMsgSize = %d, want %d", info.MaxMsgSize, maxMsgSize)
+	}
+}
+
+func TestHandleCBORInvalid(t *testing.T) {
+	a := openTest(t)
+
+	if status, _ := call(t, a, nil, 0x7f, nil); status != ctaphid.StatusCTAP1ErrInvalidCommand {
+		t.Errorf("unknown command status = %s, want %s", status, ctaphid.StatusCTAP1ErrInvalidCommand)
+	}
+	resp := a.HandleCBOR(context.Background(), []byte{byte(ctap2.CMDAuthenticatorClientPIN), 0xa1}, nil)
+	if status := ctaphid.StatusCode(resp[0]); status != ctaphid.StatusCTAP2ErrInvalidCBOR {
+		t.Errorf("truncated CBOR status = %s, want %s", status, ctaphid.StatusCTAP2ErrInvalidCBOR)
+	}
+	status, _ := call(t, a, nil, ctap2.CMDAuthenticatorClientPIN, map[int]any{0x01: 1})
+	if status != ctaphid.StatusCTAP2ErrMissingParameter {
+		t.Errorf("clientPIN without subCommand status = %s, want %s", status, ctaphid.StatusCTAP2ErrMissingParameter)
+	}
+}
+
+func TestHandleCBORClientPIN(t *testing.T) {
+	for _, protocol := range []ctap2.PinUvAuthProtocolType{
+		ctap2.PinUvAuthProtocolTypeOne,
+		ctap2.PinUvAuthProtocolTypeTwo,
+	} {
+		t.Run(protocol.String(), func(t *testing.T) {
+			a := openTest(t)
+			p := newPlatform(t, a, protocol)
+			p.setPIN(t, a)
+
+			if err := a.verifyPIN(testPIN); err != nil {
+				t.Fatalf("PIN set over the wire doesn't verify: %v", err)
+			}
+
+			tok := p.token(t, a, ctap2.PermissionCredentialManagement, "")
+			if !bytes.Equal(tok, a.token) {
+				t.Error("decrypted pinUvAuthToken differs from the authenticator's")
+			}
+
+			status, _ := call(t, a, nil, ctap2.CMDAuthenticatorClientPIN, &ctap2.AuthenticatorClientPINRequest{
+				PinUvAuthProtocol: protocol,
+				SubCommand:        ctap2.ClientPINSubCommandGetPinUvAuthTokenUsingPinWithPermissions,
+				KeyAgreement:      p.key,
+				PinHashEnc:        p.encrypt(t, pinHash("4321")),
+				Permissions:       ctap2.PermissionCredentialManagement,
+			})
+			if status != ctaphid.StatusCTAP2ErrPinInvalid {
+				t.Errorf("wrong PIN status = %s, want %s", status, ctaphid.StatusCTAP2ErrPinInvalid)
+			}
+
+			var retries ctap2.AuthenticatorClientPINResponse
+			mustCall(t, a, ctap2.CMDAuthenticatorClientPIN, &ctap2.AuthenticatorClientPINRequest{
+				SubCommand: ctap2.ClientPINSubCommandGetPINRetries,
+			}, &retries)
+			if retries.PinRetries != maxPINRetries-1 {
+				t.Errorf("pinRetries = %d, want %d", retries.PinRetries, maxPINRetries-1)
+			}
+		})
+	}
+}
+
+func TestHandleCBORMakeCredentialAndGetAssertion(t *testing.T) {
+	a := openTest(t)
+	p := newPlatform(t, a, ctap2.PinUvAuthProtocolTypeTwo)
+	p.setPIN(t, a)
+
+	rp := webauthn.PublicKeyCredentialRpEntity{ID: "example.com", Name: "Example"}
+	params := []webauthn.PublicKeyCredentialParameters{{
+		Type:      webauthn.PublicKeyCredentialTypePublicKey,
+		Algorithm: -7,
+	}}
+
+	// A discoverable credential needs user verification once a PIN is set.
+	status, _ := call(t, a, nil, ctap2.CMDAuthenticatorMakeCredential, &ctap2.AuthenticatorMakeCredentialRequest{
+		ClientDataHash:   testClientDataHash,
+		RP:               rp,
+		User:             webauthn.PublicKeyCredentialUserEntity{ID: []byte{1}, Name: "alice"},
+		PubKeyCredParams: params,
+		Options:          map[ctap2.Option]bool{ctap2.OptionResidentKeys: true},
+	})
+	if status != ctaphid.StatusCTAP2ErrPUATRequired {
+		t.Fatalf("makeCredential without pinUvAuthParam status = %s, want %s", status, ctaphid.StatusCTAP2ErrPUATRequired)
+	}
+
+	var publicKeys []*ecdsa.PublicKey
+	for _, user := range []string{"alice", "bob"} {
+		tok := p.token(t, a, ctap2.PermissionMakeCredential, rp.ID)
+		var att ctap2.AuthenticatorMakeCredentialResponse
+		mustCall(t, a, ctap2.CMDAuthenticatorMakeCredential, &ctap2.AuthenticatorMakeCredentialRequest{
+			ClientDataHash:    testClientDataHash,
+			RP:                rp,
+			User:              webauthn.PublicKeyCredentialUserEntity{ID: []byte(user), Name: user},
+			PubKeyCredParams:  params,
+			Options:           map[ctap2.Option]bool{ctap2.OptionResidentKeys: true},
+			PinUvAuthParam:    ctap2.Authenticate(p.protocol.Type, tok, testClientDataHash),
+			PinUvAuthProtocol: p.protocol.Type,
+		}, &att)
+
+		authData, err := ctap2.ParseMakeCredentialAuthData(att.AuthDataRaw)
+		if err != nil {
+			t.Fatalf("ParseMakeCredentialAuthData() error = %v", err)
+		}
+		if authData.Flags&ctap2.AuthDataFlagUserVerified == 0 {
+			t.Error("makeCredential authData doesn't have the UV flag")
+		}
+		pub, err := coseecdsa.KeyToPublic(authData.AttestedCredentialData.CredentialPublicKey)
+		if err != nil {
+			t.Fatalf("KeyToPublic() error = %v", err)
+		}
+		sig, _ := att.AttestationStatement["sig"].([]byte)
+		if !verifySignature(pub, att.AuthDataRaw, sig) {
+			t.Error("packed self attestation signature doesn't verify")
+		}
+		publicKeys = append(publicKeys, pub)
+	}
+
+	tok := p.token(t, a, ctap2.PermissionGetAssertion, rp.ID)
+	var first ctap2.AuthenticatorGetAssertionResponse
+	mustCall(t, a, ctap2.CMDAuthenticatorGetAssertion, &ctap2.AuthenticatorGetAssertionRequest{
+		RPID:              rp.ID,
+		ClientDataHash:    testClientDataHash,
+		PinUvAuthParam:    ctap2.Authenticate(p.protocol.Type, tok, testClientDataHash),
+		PinUvAuthProtocol: p.protocol.Type,
+	}, &first)
+	if first.NumberOfCredentials != 2 {
+		t.Fatalf("numberOfCredentials = %d, want 2", first.NumberOfCredentials)
+	}
+
+	var next ctap2.AuthenticatorGetAssertionResponse
+	mustCall(t, a, ctap2.CMDAuthenticatorGetNextAssertion, nil, &next)
+	status, _ = call(t, a, nil, ctap2.CMDAuthenticatorGetNextAssertion, nil)
+	if status != ctaphid.StatusCTAP2ErrNotAllowed {
+		t.Errorf("third getNextAssertion status = %s, want %s", status, ctaphid.StatusCTAP2ErrNotAllowed)
+	}
+
+	for i, resp := range []ctap2.AuthenticatorGetAssertionResponse{first, next} {
+		if resp.User == nil || resp.User.Name == "" {
+			t.Errorf("assertion %d user = %+v, want the user with its name", i, resp.User)
+		}
+		if !slices.ContainsFunc(publicKeys, func(pub *ecdsa.PublicKey) bool {
+			return verifySignature(pub, resp.AuthDataRaw, resp.Signature)
+		}) {
+			t.Errorf("assertion %d signature doesn't verify with any created credential", i)
+		}
+	}
+}
+
+func TestHandleCBORNonDiscoverableCredential(t *testing.T) {
+	a := openTest(t)
+	rp := webauthn.PublicKeyCredentialRpEntity{ID: "example.com"}
+
+	var att ctap2.AuthenticatorMakeCredentialResponse
+	mustCall(t, a, ctap2.CMDAuthenticatorMakeCredential, &ctap2.AuthenticatorMakeCredentialRequest{
+		ClientDataHash: testClientDataHash,
+		RP:             rp,
+		User:           webauthn.PublicKeyCredentialUserEntity{ID: []byte{1}},
+		PubKeyCredParams: []webauthn.PublicKeyCredentialParameters{{
+			Type:      webauthn.PublicKeyCredentialTypePublicKey,
+			Algorithm: -7,
+		}},
+	}, &att)
+	if n := len(a.State().Credentials); n != 0 {
+		t.Fatalf("got %d stored credentials, want 0", n)
+	}
+
+	authData, err := ctap2.ParseMakeCredentialAuthData(att.AuthDataRaw)
+	if err != nil {
+		t.Fatalf("ParseMakeCredentialAuthData() error = %v", err)
+	}
+	id := authData.AttestedCredentialData.CredentialID
+	allowList := []webauthn.PublicKeyCredentialDescriptor{{Type: webauthn.PublicKeyCredentialTypePublicKey, ID: id}}
+
+	a = reopen(t, a)
+	var resp ctap2.AuthenticatorGetAssertionResponse
+	mustCall(t, a, ctap2.CMDAuthenticatorGetAssertion, &ctap2.AuthenticatorGetAssertionRequest{
+		RPID:           rp.ID,
+		ClientDataHash: testClientDataHash,
+		AllowList:      allowList,
+	}, &resp)
+	if !bytes.Equal(resp.Credential.ID, id) {
+		t.Errorf("asserted credential = %x, want %x", resp.Credential.ID, id)
+	}
+
+	// The credential ID is bound to its relying party.
+	status, _ := call(t, a, nil, ctap2.CMDAuthenticatorGetAssertion, &ctap2.AuthenticatorGetAssertionRequest{
+		RPID:           "example.org",
+		ClientDataHash: testClientDataHash,
+		AllowList:      allowList,
+	})
+	if status != ctaphid.StatusCTAP2ErrNoCredentials {
+		t.Errorf("getAssertion for another RP status = %s, want %s", status, ctaphid.StatusCTAP2ErrNoCredentials)
+	}
+}
+
+func TestHandleCBORPresence(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ctaphid.StatusCode
+	}{
+		{"granted", nil, ctaphid.StatusCTAP2OK},
+		{"cancelled", context.Canceled, ctaphid.StatusCTAP2ErrKeepaliveCancel},
+		{"timed out", context.DeadlineExceeded, ctaphid.StatusCTAP2ErrUserActionTimeout},
+		{"denied", errors.New("denied"), ctaphid.StatusCTAP2ErrOperationDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := openTest(t)
+			asked := false
+			presence := func(context.Context) error {
+				asked = true
+				return tt.err
+			}
+
+			if status, _ := call(t, a, presence, ctap2.CMDAuthenticatorSelection, nil); status != tt.want {
+				t.Errorf("selection status = %s, want %s", status, tt.want)
+			}
+			if !asked {
+				t.Error("selection didn't ask for user presence")
+			}
+		})
+	}
+}
+
+func TestHandleCBORCredentialManagement(t *testing.T) {
+	a := openTest(t)
+	p := newPlatform(t, a, ctap2.PinUvAuthProtocolTypeOne)
+	p.setPIN(t, a)
+	for _, rpID := range []string{"a.example", "b.example", "c.example"} {
+		if _, err := a.AddCredential(
+			webauthn.PublicKeyCredentialRpEntity{ID: rpID},
+			webauthn.PublicKeyCredentialUserEntity{ID: []byte{1}},
+			CredentialOptions{},
+		); err != nil {
+			t.Fatalf("AddCredential() error = %v", err)
+		}
+	}
+
+	tok := p.token(t, a, ctap2.PermissionCredentialManagement, "")
+	subCommand := ctap2.CredentialManagementSubCommandEnumerateRPsBegin
+	var first ctap2.AuthenticatorCredentialManagementResponse
+	mustCall(t, a, ctap2.CMDAuthenticatorCredentialManagement, &ctap2.AuthenticatorCredentialManagementRequest{
+		SubCommand:        subCommand,
+		PinUvAuthProtocol: p.protocol.Type,
+		PinUvAuthParam:    ctap2.Authenticate(p.protocol.Type, tok, []byte{byte(subCommand)}),
+	}, &first)
+	if first.TotalRPs != 3 {
+		t.Fatalf("totalRPs = %d, want 3", first.TotalRPs)
+	}
+
+	rpIDs := []string{first.RP.ID}
+	for range 2 {
+		var next ctap2.AuthenticatorCredentialManagementResponse
+		mustCall(t, a, ctap2.CMDAuthenticatorCredentialManagement, &ctap2.AuthenticatorCredentialManagementRequest{
+			SubCommand: ctap2.CredentialManagementSubCommandEnumerateRPsGetNextRP,
+		}, &next)
+		rpIDs = append(rpIDs, next.RP.ID)
+	}
+	if want := []string{"a.example", "b.example", "c.example"}; !slices.Equal(rpIDs, want) {
+		t.Errorf("enumerated RPs = %v, want %v", rpIDs, want)
+	}
+
+	req := &ctap2.AuthenticatorCredentialManagementRequest{
+		SubCommand:        ctap2.CredentialManagementSubCommandGetCredsMetadata,
+		PinUvAuthProtocol: p.protocol.Type,
+		PinUvAuthParam:    ctap2.Authenticate(p.protocol.Type, tok, []byte{byte(subCommand)}),
+	}
+	status, _ := call(t, a, nil, ctap2.CMDAuthenticatorCredentialManagement, req)
+	if status != ctaphid.StatusCTAP2ErrPinAuthInvalid {
+		t.Errorf("getCredsMetadata with the MAC of another subcommand status = %s, want %s",
+			status, ctaphid.StatusCTAP2ErrPinAuthInvalid)
+	}
+}
+
+func TestHandleCBORLargeBlobs(t *testing.T) {
+	a := openTest(t)
+
+	// Random data doesn't compress, so the array takes several fragments.
+	data := make([]byte, 300)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("rand.Read() error = %v", err)
+	}
+	blob, err := ctap2.EncryptLargeBlob(bytes.Repeat([]byte{1}, 32), data)
+	if err != nil {
+		t.Fatalf("EncryptLargeBlob() error = %v", err)
+	}
+	set, err := marshal([]*ctap2.LargeBlob{blob})
+	if err != nil {
+		t.Fatalf("marshal() error = %v", err)
+	}
+	set = slices.Concat(set, largeBlobTrailer(set))
+
+	// Without a PIN, the array is written without authentication, in fragments.
+	const fragment = 100
+	for offset := 0; offset < len(set); offset += fragment {
+		params := map[int]any{
+			0x02: set[offset:min(offset+fragment, len(set))],
+			0x03: offset,
+		}
+		if offset == 0 {
+			params[0x04] = len(set)
+		}
+		mustCall(t, a, ctap2.CMDAuthenticatorLargeBlobs, params, nil)
+	}
+
+	var resp ctap2.AuthenticatorLargeBlobsResponse
+	mustCall(t, a, ctap2.CMDAuthenticatorLargeBlobs, map[int]any{0x01: len(set), 0x03: 0}, &resp)
+	if !bytes.Equal(resp.Config, set) {
+		t.Errorf("read back %x, want %x", resp.Config, set)
+	}
+
+	status, _ := call(t, a, nil, ctap2.CMDAuthenticatorLargeBlobs, map[int]any{0x02: set[fragment:], 0x03: fragment})
+	if status != ctaphid.StatusCTAP1ErrInvalidSeq {
+		t.Errorf("fragment without a first one status = %s, want %s", status, ctaphid.StatusCTAP1ErrInvalidSeq)
+	}
+}
+
+func verifySignature(pub *ecdsa.PublicKey, authData, sig []byte) bool {
+	digest := sha256.Sum256(slices.Concat(authData, testClientDataHash))
+	return ecdsa.VerifyASN1(pub, digest[:], sig)
+}
@@ -0,0 +1,481 @@
+package virtual
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+)
+
+// GetPINRetries returns the number of PIN retries left and whether a power cycle, i.e. reopening the
+// authenticator, is required before the PIN can be tried again.
+func (a *Authenticator) GetPINRetries() (uint, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requirePIN(); err != nil {
+		return 0, false, err
+	}
+
+	return a.state.PINRetries, a.pinFailures >= maxConsecutivePINFailures, nil
+}
+
+// GetUVRetries returns the number of built-in user verification retries left.
+func (a *Authenticator) GetUVRetries() (uint, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireUV(); err != nil {
+		return 0, err
+	}
+
+	return a.state.UVRetries, nil
+}
+
+// SetPIN sets the first PIN of the authenticator.
+func (a *Authenticator) SetPIN(pin string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.setPIN(pin)
+}
+
+func (a *Authenticator) setPIN(pin string) error {
+	if !a.state.Features.ClientPIN {
+		return notSupported("device doesn't support clientPin option")
+	}
+	if len(a.state.PINHash) > 0 {
+		return &fido2.ErrorWithMessage{Err: fido2.ErrPinAlreadySet, Message: "pin already set, use changePin instead"}
+	}
+	if err := a.checkNewPIN(pin); err != nil {
+		return err
+	}
+
+	a.storePIN(pin)
+	return a.save()
+}
+
+// ChangePIN replaces the current PIN. A wrong current PIN counts as a failed attempt.
+func (a *Authenticator) ChangePIN(currentPin string, newPin string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.changePIN(pinHash(currentPin), newPin)
+}
+
+// changePIN replaces the PIN whose hash is currentPINHash.
+func (a *Authenticator) changePIN(currentPINHash []byte, newPin string) error {
+	if err := a.requirePIN(); err != nil {
+		return err
+	}
+	if err := a.verifyPINHash(currentPINHash); err != nil {
+		return err
+	}
+	if err := a.checkNewPIN(newPin); err != nil {
+		return err
+	}
+	if a.state.ForcePINChange && bytes.Equal(pinHash(newPin), a.state.PINHash) {
+		return ctapError(ctaphid.StatusCTAP2ErrPinPolicyViolation)
+	}
+
+	a.storePIN(newPin)
+	a.token = nil
+	return a.save()
+}
+
+// GetPinUvAuthTokenUsingPIN verifies pin and returns a new pinUvAuthToken with the given permissions,
+// optionally bound to rpID. Any previously issued token is invalidated.
+func (a *Authenticator) GetPinUvAuthTokenUsingPIN(
+	pin string,
+	permissions ctap2.Permission,
+	rpID string,
+) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.pinUvAuthTokenUsingPIN(pinHash(pin), permissions, rpID)
+}
+
+// pinUvAuthTokenUsingPIN verifies the PIN whose hash is hash and returns a new pinUvAuthToken.
+func (a *Authenticator) pinUvAuthTokenUsingPIN(
+	hash []byte,
+	permissions ctap2.Permission,
+	rpID string,
+) ([]byte, error) {
+	if !a.state.Features.ClientPIN {
+		return nil, notSupported("you cannot get a pinUvAuthToken using PIN if device hasn't clientPin option")
+	}
+	if len(a.state.PINHash) == 0 {
+		return nil, &fido2.ErrorWithMessage{Err: fido2.ErrPinNotSet, Message: "please set PIN first"}
+	}
+	if err := a.checkPermissions(permissions); err != nil {
+		return nil, err
+	}
+	if err := a.verifyPINHash(hash); err != nil {
+		return nil, err
+	}
+	if a.state.ForcePINChange {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrPinPolicyViolation)
+	}
+
+	return a.issueToken(permissions, rpID)
+}
+
+// GetPinUvAuthTokenUsingUV returns a new pinUvAuthToken after a built-in user verification, which always
+// succeeds once a fingerprint is enrolled.
+func (a *Authenticator) GetPinUvAuthTokenUsingUV(permissions ctap2.Permission, rpID string) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.pinUvAuthTokenUsingUV(context.Background(), nil, permissions, rpID)
+}
+
+// pinUvAuthTokenUsingUV returns a new pinUvAuthToken after asking presence for the fingerprint touch.
+func (a *Authenticator) pinUvAuthTokenUsingUV(
+	ctx context.Context,
+	presence Presence,
+	permissions ctap2.Permission,
+	rpID string,
+) ([]byte, error) {
+	if err := a.requireUV(); err != nil {
+		return nil, err
+	}
+	if err := a.checkPermissions(permissions); err != nil {
+		return nil, err
+	}
+	if a.state.UVRetries == 0 {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrUVBlocked)
+	}
+	if err := presence.test(ctx); err != nil {
+		return nil, err
+	}
+
+	a.state.UVRetries = maxUVRetries
+	if err := a.save(); err != nil {
+		return nil, err
+	}
+
+	return a.issueToken(permissions, rpID)
+}
+
+// requirePIN returns the error fido2.Device reports when clientPin is unsupported or no PIN is set.
+func (a *Authenticator) requirePIN() error {
+	if !a.state.Features.ClientPIN {
+		return notSupported("device doesn't support clientPin option")
+	}
+	if len(a.state.PINHash) == 0 {
+		return &fido2.ErrorWithMessage{Err: fido2.ErrPinNotSet, Message: "please set PIN first"}
+	}
+	return nil
+}
+
+// requireUV returns the error fido2.Device reports when built-in user verification is unsupported or
+// not configured.
+func (a *Authenticator) requireUV() error {
+	if !a.state.Features.BioEnrollment {
+		return notSupported("device doesn't support user verification")
+	}
+	if len(a.state.Enrollments) == 0 {
+		return &fido2.ErrorWithMessage{
+			Err:     fido2.ErrUvNotConfigured,
+			Message: "please configure UV first (e.g. enroll biometry)",
+		}
+	}
+	return nil
+}
+
+// verifyPIN checks pin against the stored PIN hash, decrementing the retries on a mismatch.
+func (a *Authenticator) verifyPIN(pin string) error {
+	return a.verifyPINHash(pinHash(pin))
+}
+
+// verifyPINHash checks hash, LEFT(SHA-256(PIN), 16), against the stored PIN hash, decrementing the retries on a
+// mismatch. A mismatch also discards the key agreement key, so the platform must start over.
+func (a *Authenticator) verifyPINHash(hash []byte) error {
+	switch {
+	case a.state.PINRetries == 0:
+		return ctapError(ctaphid.StatusCTAP2ErrPinBlocked)
+	case a.pinFailures >= maxConsecutivePINFailures:
+		return ctapError(ctaphid.StatusCTAP2ErrPinAuthBlocked)
+	}
+
+	if !bytes.Equal(hash, a.state.PINHash) {
+		a.state.PINRetries--
+		a.pinFailures++
+		a.keyAgreement = nil
+		if err := a.save(); err != nil {
+			return err
+		}
+
+		switch {
+		case a.state.PINRetries == 0:
+			return ctapError(ctaphid.StatusCTAP2ErrPinBlocked)
+		case a.pinFailures >= maxConsecutivePINFailures:
+			return ctapError(ctaphid.StatusCTAP2ErrPinAuthBlocked)
+		default:
+			return ctapError(ctaphid.StatusCTAP2ErrPinInvalid)
+		}
+	}
+
+	a.pinFailures = 0
+	if a.state.PINRetries != maxPINRetries {
+		a.state.PINRetries = maxPINRetries
+		return a.save()
+	}
+	return nil
+}
+
+// checkNewPIN enforces the PIN length limits on a new PIN.
+func (a *Authenticator) checkNewPIN(pin string) error {
+	if !utf8.ValidString(pin) || len(pin) > maxPINLength ||
+		uint(utf8.RuneCountInString(pin)) < a.state.MinPINLength {
+		return ctapError(ctaphid.StatusCTAP2ErrPinPolicyViolation)
+	}
+	return nil
+}
+
+func (a *Authenticator) storePIN(pin string) {
+	a.state.PINHash = pinHash(pin)
+	a.state.PINLength = uint(utf8.RuneCountInString(pin))
+	a.state.PINRetries = maxPINRetries
+	a.state.ForcePINChange = false
+	a.pinFailures = 0
+}
+
+// checkPermissions rejects permissions for features the authenticator doesn't support, with the errors
+// fido2.Device and a CTAP 2.1 authenticator report.
+func (a *Authenticator) checkPermissions(permissions ctap2.Permission) error {
+	f := a.state.Features
+	switch {
+	case permissions == 0:
+		return ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	case permissions&ctap2.PermissionBioEnrollment != 0 && !f.BioEnrollment:
+		return notSupported("you cannot set be BioEnrollment permission if device doesn't support bioEnroll option")
+	case permissions&ctap2.PermissionAuthenticatorConfiguration != 0 && !f.AuthenticatorConfig:
+		return notSupported(
+			"you cannot set be AuthenticatorConfiguration permission if device doesn't support uv option",
+		)
+	case permissions&ctap2.PermissionCredentialManagement != 0 && !f.CredentialManagement,
+		permissions&ctap2.PermissionLargeBlobWrite != 0 && !f.LargeBlobs:
+		return ctapError(ctaphid.StatusCTAP2ErrUnauthorizedPermission)
+	}
+	return nil
+}
+
+func (a *Authenticator) issueToken(permissions ctap2.Permission, rpID string) ([]byte, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	a.token = token
+	a.tokenPermissions = permissions
+	a.tokenRPID = rpID
+
+	return bytes.Clone(token), nil
+}
+
+// authorize checks that token is the current pinUvAuthToken, that it has the permission and, if rpID is
+// not empty and the token is bound to a relying party, that it is bound to rpID.
+func (a *Authenticator) authorize(token []byte, permission ctap2.Permission, rpID string) error {
+	switch {
+	case a.closed:
+		return ErrClosed
+	case len(token) == 0:
+		return ctapError(ctaphid.StatusCTAP2ErrPUATRequired)
+	case a.token == nil || !bytes.Equal(token, a.token):
+		return ctapError(ctaphid.StatusCTAP2ErrPinAuthInvalid)
+	case a.tokenPermissions&permission == 0:
+		return ctapError(ctaphid.StatusCTAP2ErrPinAuthInvalid)
+	case rpID != "" && a.tokenRPID != "" && rpID != a.tokenRPID:
+		return ctapError(ctaphid.StatusCTAP2ErrPinAuthInvalid)
+	}
+	return nil
+}
+
+// pinHash returns LEFT(SHA-256(pin), 16), the form in which CTAP2 authenticators store the PIN.
+func pinHash(pin string) []byte {
+	h := sha256.Sum256([]byte(pin))
+	return h[:16]
+}
+
+// handleClientPIN executes authenticatorClientPIN. The PIN and the pinUvAuthToken travel encrypted with the
+// secret shared through the key agreement of the PIN/UV auth protocol.
+func (a *Authenticator) handleClientPIN(r *request) (any, error) {
+	var req ctap2.AuthenticatorClientPINRequest
+	if err := r.decode(&req, 0x02); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch req.SubCommand {
+	case ctap2.ClientPINSubCommandGetUVRetries:
+		if !a.state.Features.BioEnrollment {
+			return nil, ctapError(ctaphid.StatusCTAP2ErrInvalidSubcommand)
+		}
+		return map[int]any{0x05: a.state.UVRetries}, nil
+	case ctap2.ClientPINSubCommandGetPinUvAuthTokenUsingUvWithPermissions:
+	default:
+		if !a.state.Features.ClientPIN {
+			return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidCommand)
+		}
+	}
+	if req.SubCommand == ctap2.ClientPINSubCommandGetPINRetries {
+		return map[int]any{
+			0x03: a.state.PINRetries,
+			0x04: a.pinFailures >= maxConsecutivePINFailures,
+		}, nil
+	}
+
+	// The other subcommands use a PIN/UV auth protocol.
+	if err := r.require(0x01); err != nil {
+		return nil, err
+	}
+	if err := checkPinUvAuthProtocol(req.PinUvAuthProtocol); err != nil {
+		return nil, err
+	}
+	if req.SubCommand == ctap2.ClientPINSubCommandGetKeyAgreement {
+		k, err := a.keyAgreementKey()
+		if err != nil {
+			return nil, err
+		}
+		return map[int]any{0x01: k}, nil
+	}
+
+	if err := r.require(0x03); err != nil {
+		return nil, err
+	}
+	secret, err := a.sharedSecret(req.PinUvAuthProtocol, req.KeyAgreement)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.SubCommand {
+	case ctap2.ClientPINSubCommandSetPIN:
+		if err := r.require(0x04, 0x05); err != nil {
+			return nil, err
+		}
+		if len(a.state.PINHash) > 0 || !verify(req.PinUvAuthProtocol, secret, req.NewPinEnc, req.PinUvAuthParam) {
+			return nil, ctapError(ctaphid.StatusCTAP2ErrPinAuthInvalid)
+		}
+		pin, err := decryptPIN(req.PinUvAuthProtocol, secret, req.NewPinEnc)
+		if err != nil {
+			return nil, err
+		}
+		return nil, a.setPIN(pin)
+	case ctap2.ClientPINSubCommandChangePIN:
+		if err := r.require(0x04, 0x05, 0x06); err != nil {
+			return nil, err
+		}
+		message := slices.Concat(req.NewPinEnc, req.PinHashEnc)
+		if !verify(req.PinUvAuthProtocol, secret, message, req.PinUvAuthParam) {
+			return nil, ctapError(ctaphid.StatusCTAP2ErrPinAuthInvalid)
+		}
+		hash, err := decryptPINHash(req.PinUvAuthProtocol, secret, req.PinHashEnc)
+		if err != nil {
+			return nil, err
+		}
+		pin, err := decryptPIN(req.PinUvAuthProtocol, secret, req.NewPinEnc)
+		if err != nil {
+			return nil, err
+		}
+		return nil, a.changePIN(hash, pin)
+	default:
+		token, err := a.handlePinUvAuthToken(r, &req, secret)
+		if err != nil {
+			return nil, err
+		}
+		enc, err := encrypt(req.PinUvAuthProtocol, secret, token)
+		if err != nil {
+			return nil, err
+		}
+		return map[int]any{0x02: enc}, nil
+	}
+}
+
+// handlePinUvAuthToken executes the authenticatorClientPIN subcommands that get a pinUvAuthToken, which is
+// returned in plaintext. It must be called with the mutex held.
+func (a *Authenticator) handlePinUvAuthToken(
+	r *request,
+	req *ctap2.AuthenticatorClientPINRequest,
+	secret []byte,
+) ([]byte, error) {
+	switch req.SubCommand {
+	case ctap2.ClientPINSubCommandGetPinToken, ctap2.ClientPINSubCommandGetPinUvAuthTokenUsingPinWithPermissions:
+		permissions, rpID := req.Permissions, req.RPID
+		if req.SubCommand == ctap2.ClientPINSubCommandGetPinToken {
+			// The legacy subcommand grants the permissions a CTAP 2.0 pinToken had.
+			permissions, rpID = ctap2.PermissionMakeCredential|ctap2.PermissionGetAssertion, ""
+		} else if err := r.require(0x09); err != nil {
+			return nil, err
+		}
+		if err := r.require(0x06); err != nil {
+			return nil, err
+		}
+		if err := a.checkTokenPermissions(permissions); err != nil {
+			return nil, err
+		}
+		hash, err := decryptPINHash(req.PinUvAuthProtocol, secret, req.PinHashEnc)
+		if err != nil {
+			return nil, err
+		}
+		return a.pinUvAuthTokenUsingPIN(hash, permissions, rpID)
+	case ctap2.ClientPINSubCommandGetPinUvAuthTokenUsingUvWithPermissions:
+		if err := r.require(0x09); err != nil {
+			return nil, err
+		}
+		if !a.state.Features.BioEnrollment {
+			return nil, ctapError(ctaphid.StatusCTAP2ErrInvalidSubcommand)
+		}
+		if err := a.checkTokenPermissions(req.Permissions); err != nil {
+			return nil, err
+		}
+		return a.pinUvAuthTokenUsingUV(r.ctx, r.presence, req.Permissions, req.RPID)
+	default:
+		return nil, ctapError(ctaphid.StatusCTAP2ErrInvalidSubcommand)
+	}
+}
+
+// checkTokenPermissions is checkPermissions with the status codes of a CTAP 2.1 authenticator only.
+func (a *Authenticator) checkTokenPermissions(permissions ctap2.Permission) error {
+	err := a.checkPermissions(permissions)
+	if errors.Is(err, fido2.ErrNotSupported) {
+		return ctapError(ctaphid.StatusCTAP2ErrUnauthorizedPermission)
+	}
+	return err
+}
+
+// decryptPIN decrypts newPinEnc, the new PIN padded with zeros to 64 bytes.
+func decryptPIN(protocol ctap2.PinUvAuthProtocolType, secret, newPinEnc []byte) (string, error) {
+	padded, err := decrypt(protocol, secret, newPinEnc)
+	if err != nil {
+		return "", err
+	}
+	if len(padded) != 64 {
+		return "", ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	}
+
+	pin, _, _ := bytes.Cut(padded, []byte{0})
+	return string(pin), nil
+}
+
+// decryptPINHash decrypts pinHashEnc, LEFT(SHA-256(PIN), 16).
+func decryptPINHash(protocol ctap2.PinUvAuthProtocolType, secret, pinHashEnc []byte) ([]byte, error) {
+	hash, err := decrypt(protocol, secret, pinHashEnc)
+	if err != nil {
+		return nil, err
+	}
+	if len(hash) != 16 {
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	}
+	return hash, nil
+}
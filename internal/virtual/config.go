@@ -0,0 +1,147 @@
+package virtual
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+)
+
+// EnableEnterpriseAttestation enables the ep option.
+func (a *Authenticator) EnableEnterpriseAttestation(pinUvAuthToken []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.state.Features.AuthenticatorConfig {
+		return notSupported("device doesn't support authnrCfg")
+	}
+	if !a.state.Features.EnterpriseAttestation {
+		return notSupported("device doesn't support ep")
+	}
+	if err := a.authorize(pinUvAuthToken, ctap2.PermissionAuthenticatorConfiguration, ""); err != nil {
+		return err
+	}
+
+	a.state.EnterpriseAttestation = true
+	return a.save()
+}
+
+// ToggleAlwaysUV toggles the alwaysUv option.
+func (a *Authenticator) ToggleAlwaysUV(pinUvAuthToken []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.state.Features.AuthenticatorConfig {
+		return notSupported("device doesn't support authnrCfg")
+	}
+	if !a.state.Features.AlwaysUV {
+		return notSupported("device doesn't support alwaysUv")
+	}
+	if err := a.authorize(pinUvAuthToken, ctap2.PermissionAuthenticatorConfiguration, ""); err != nil {
+		return err
+	}
+
+	a.state.AlwaysUV = !a.state.AlwaysUV
+	return a.save()
+}
+
+// SetMinPINLength raises the minimum PIN length, replaces the relying parties allowed to read it, and
+// optionally forces a PIN change. A zero newMinPINLength keeps the current minimum. Setting a minimum longer
+// than the current PIN forces a PIN change, as required by CTAP 2.1.
+func (a *Authenticator) SetMinPINLength(
+	pinUvAuthToken []byte,
+	newMinPINLength uint,
+	minPinLengthRPIDs []string,
+	forceChangePin bool,
+	pinComplexityPolicy bool,
+) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.state.Features.AuthenticatorConfig {
+		return notSupported("device doesn't support authnrCfg")
+	}
+	if err := a.authorize(pinUvAuthToken, ctap2.PermissionAuthenticatorConfiguration, ""); err != nil {
+		return err
+	}
+
+	if newMinPINLength == 0 {
+		newMinPINLength = a.state.MinPINLength
+	}
+	switch {
+	case newMinPINLength < a.state.MinPINLength:
+		return ctapError(ctaphid.StatusCTAP2ErrPinPolicyViolation)
+	case uint(len(minPinLengthRPIDs)) > maxRPIDsForSetMinPINLen:
+		return ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	case forceChangePin && len(a.state.PINHash) == 0:
+		return ctapError(ctaphid.StatusCTAP2ErrPinNotSet)
+	case pinComplexityPolicy:
+		return ctapError(ctaphid.StatusCTAP2ErrUnsupportedOption)
+	}
+
+	a.state.MinPINLength = newMinPINLength
+	if len(minPinLengthRPIDs) > 0 {
+		a.state.MinPINLengthRPIDs = slices.Clone(minPinLengthRPIDs)
+	}
+	if forceChangePin || (len(a.state.PINHash) > 0 && a.state.PINLength < newMinPINLength) {
+		a.state.ForcePINChange = true
+	}
+
+	return a.save()
+}
+
+// handleConfig executes authenticatorConfig.
+func (a *Authenticator) handleConfig(r *request) (any, error) {
+	var req struct {
+		SubCommand        ctap2.ConfigSubCommand      `cbor:"1,keyasint"`
+		SubCommandParams  cbor.RawMessage             `cbor:"2,keyasint"`
+		PinUvAuthProtocol ctap2.PinUvAuthProtocolType `cbor:"3,keyasint"`
+		PinUvAuthParam    []byte                      `cbor:"4,keyasint"`
+	}
+	if err := r.decode(&req, 0x01); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	supported := a.state.Features.AuthenticatorConfig
+	a.mu.Unlock()
+	if !supported {
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidCommand)
+	}
+
+	// The message is 32 bytes of 0xff followed by the command byte, the subcommand and its parameters.
+	message := slices.Concat(
+		bytes.Repeat([]byte{0xff}, 32),
+		[]byte{byte(ctap2.CMDAuthenticatorConfig), byte(req.SubCommand)},
+		req.SubCommandParams,
+	)
+	token, err := a.authenticatedToken(req.PinUvAuthProtocol, req.PinUvAuthParam, message)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.SubCommand {
+	case ctap2.ConfigSubCommandEnableEnterpriseAttestation:
+		return nil, a.EnableEnterpriseAttestation(token)
+	case ctap2.ConfigSubCommandToggleAlwaysUv:
+		return nil, a.ToggleAlwaysUV(token)
+	case ctap2.ConfigSubCommandSetMinPINLength:
+		var params ctap2.SetMinPINLengthConfigSubCommandParams
+		if req.SubCommandParams != nil {
+			if err := cbor.Unmarshal(req.SubCommandParams, &params); err != nil {
+				return nil, ctapError(ctaphid.StatusCTAP2ErrCBORUnexpectedType)
+			}
+		}
+		return nil, a.SetMinPINLength(
+			token,
+			params.NewMinPINLength,
+			params.MinPinLengthRPIDs,
+			params.ForceChangePin,
+			params.PinComplexityPolicy,
+		)
+	default:
+		return nil, ctapError(ctaphid.StatusCTAP2ErrInvalidSubcommand)
+	}
+}
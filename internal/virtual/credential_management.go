@@ -0,0 +1,446 @@
+package virtual
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"iter"
+	"slices"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+)
+
+// CredentialOptions are the optional properties of a credential created with AddCredential.
+type CredentialOptions struct {
+	// CredProtect is the credProtect level of the credential.
+	CredProtect uint
+	// CredBlob is stored with the credential.
+	CredBlob []byte
+	// NoLargeBlobKey creates the credential without a largeBlobKey.
+	NoLargeBlobKey bool
+}
+
+// AddCredential creates a discoverable ES256 credential, as makeCredential with the rk option would, and
+// returns its ID. A credential of the same relying party and user ID is replaced.
+func (a *Authenticator) AddCredential(
+	rp webauthn.PublicKeyCredentialRpEntity,
+	user webauthn.PublicKeyCredentialUserEntity,
+	opts CredentialOptions,
+) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil, ErrClosed
+	}
+	if len(opts.CredBlob) > maxCredBlobLength {
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidLength)
+	}
+
+	cred, err := a.addCredential(rp, user, opts)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Clone(cred.ID), a.save()
+}
+
+// addCredential creates a discoverable credential without saving the state.
+func (a *Authenticator) addCredential(
+	rp webauthn.PublicKeyCredentialRpEntity,
+	user webauthn.PublicKeyCredentialUserEntity,
+	opts CredentialOptions,
+) (*Credential, error) {
+	creds := slices.DeleteFunc(slices.Clone(a.state.Credentials), func(c *Credential) bool {
+		return c.RP.ID == rp.ID && bytes.Equal(c.User.ID, user.ID)
+	})
+	if len(creds) >= maxCredentials {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrKeyStoreFull)
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	cred := &Credential{
+		ID:          make([]byte, 32),
+		RP:          rp,
+		User:        user,
+		PrivateKey:  der,
+		CredProtect: opts.CredProtect,
+		CredBlob:    opts.CredBlob,
+	}
+	if _, err := rand.Read(cred.ID); err != nil {
+		return nil, err
+	}
+	if a.state.Features.LargeBlobs && !opts.NoLargeBlobKey {
+		cred.LargeBlobKey = make([]byte, 32)
+		if _, err := rand.Read(cred.LargeBlobKey); err != nil {
+			return nil, err
+		}
+	}
+
+	a.state.Credentials = append(creds, cred)
+	return cred, nil
+}
+
+// GetCredsMetadata returns the number of discoverable credentials and how many more can be stored.
+func (a *Authenticator) GetCredsMetadata(
+	pinUvAuthToken []byte,
+) (*ctap2.AuthenticatorCredentialManagementResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireCredentialManagement(pinUvAuthToken); err != nil {
+		return nil, err
+	}
+
+	return &ctap2.AuthenticatorCredentialManagementResponse{
+		ExistingResidentCredentialsCount:             uint(len(a.state.Credentials)),
+		MaxPossibleRemainingResidentCredentialsCount: maxCredentials - uint(len(a.state.Credentials)),
+	}, nil
+}
+
+// EnumerateRPs yields the relying parties that have discoverable credentials, in the order their first
+// credential was created. It yields CTAP2_ERR_NO_CREDENTIALS if there are none.
+func (a *Authenticator) EnumerateRPs(
+	pinUvAuthToken []byte,
+) iter.Seq2[*ctap2.AuthenticatorCredentialManagementResponse, error] {
+	return func(yield func(*ctap2.AuthenticatorCredentialManagementResponse, error) bool) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if err := a.requireCredentialManagement(pinUvAuthToken); err != nil {
+			yield(nil, err)
+			return
+		}
+
+		var rps []*Credential
+		for _, c := range a.state.Credentials {
+			if !slices.ContainsFunc(rps, func(rp *Credential) bool { return rp.RP.ID == c.RP.ID }) {
+				rps = append(rps, c)
+			}
+		}
+		if len(rps) == 0 {
+			yield(nil, ctapError(ctaphid.StatusCTAP2ErrNoCredentials))
+			return
+		}
+
+		for i, c := range rps {
+			resp := &ctap2.AuthenticatorCredentialManagementResponse{
+				RP:       c.RP,
+				RPIDHash: c.rpIDHash(),
+			}
+			if i == 0 {
+				resp.TotalRPs = uint(len(rps))
+			}
+			if !yield(resp, nil) {
+				return
+			}
+		}
+	}
+}
+
+// EnumerateCredentials yields the discoverable credentials of the relying party with the given ID hash.
+// It yields CTAP2_ERR_NO_CREDENTIALS if there are none.
+func (a *Authenticator) EnumerateCredentials(
+	pinUvAuthToken []byte,
+	rpIDHash []byte,
+) iter.Seq2[*ctap2.AuthenticatorCredentialManagementResponse, error] {
+	return func(yield func(*ctap2.AuthenticatorCredentialManagementResponse, error) bool) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if err := a.requireCredentialManagement(pinUvAuthToken); err != nil {
+			yield(nil, err)
+			return
+		}
+
+		var creds []*Credential
+		for _, c := range a.state.Credentials {
+			if bytes.Equal(c.rpIDHash(), rpIDHash) {
+				creds = append(creds, c)
+			}
+		}
+		if len(creds) == 0 {
+			yield(nil, ctapError(ctaphid.StatusCTAP2ErrNoCredentials))
+			return
+		}
+		if err := a.authorize(pinUvAuthToken, ctap2.PermissionCredentialManagement, creds[0].RP.ID); err != nil {
+			yield(nil, err)
+			return
+		}
+
+		for i, c := range creds {
+			pub, err := c.publicKey()
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			resp := &ctap2.AuthenticatorCredentialManagementResponse{
+				User:         c.User,
+				CredentialID: c.descriptor(),
+				PublicKey:    &pub,
+				CredProtect:  c.CredProtect,
+				LargeBlobKey: bytes.Clone(c.LargeBlobKey),
+			}
+			if i == 0 {
+				resp.TotalCredentials = uint(len(creds))
+			}
+			if !yield(resp, nil) {
+				return
+			}
+		}
+	}
+}
+
+// DeleteCredential deletes a discoverable credential. Its large blob, if any, is left in place.
+func (a *Authenticator) DeleteCredential(
+	pinUvAuthToken []byte,
+	credentialID webauthn.PublicKeyCredentialDescriptor,
+) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i, err := a.findCredential(pinUvAuthToken, credentialID)
+	if err != nil {
+		return err
+	}
+
+	a.state.Credentials = slices.Delete(a.state.Credentials, i, i+1)
+	return a.save()
+}
+
+// UpdateUserInformation replaces the user name and display name of a discoverable credential. The user ID
+// must match the one stored with the credential.
+func (a *Authenticator) UpdateUserInformation(
+	pinUvAuthToken []byte,
+	credentialID webauthn.PublicKeyCredentialDescriptor,
+	user webauthn.PublicKeyCredentialUserEntity,
+) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i, err := a.findCredential(pinUvAuthToken, credentialID)
+	if err != nil {
+		return err
+	}
+
+	cred := a.state.Credentials[i]
+	if !bytes.Equal(cred.User.ID, user.ID) {
+		return ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	}
+
+	cred.User.Name = user.Name
+	cred.User.DisplayName = user.DisplayName
+	return a.save()
+}
+
+// findCredential returns the index of the credential with the given ID after checking that the token may
+// manage it.
+func (a *Authenticator) findCredential(
+	pinUvAuthToken []byte,
+	credentialID webauthn.PublicKeyCredentialDescriptor,
+) (int, error) {
+	if err := a.requireCredentialManagement(pinUvAuthToken); err != nil {
+		return 0, err
+	}
+
+	i := slices.IndexFunc(a.state.Credentials, func(c *Credential) bool {
+		return bytes.Equal(c.ID, credentialID.ID)
+	})
+	if i < 0 {
+		return 0, ctapError(ctaphid.StatusCTAP2ErrNoCredentials)
+	}
+	if err := a.authorize(
+		pinUvAuthToken,
+		ctap2.PermissionCredentialManagement,
+		a.state.Credentials[i].RP.ID,
+	); err != nil {
+		return 0, err
+	}
+
+	return i, nil
+}
+
+func (a *Authenticator) requireCredentialManagement(pinUvAuthToken []byte) error {
+	if !a.state.Features.CredentialManagement {
+		return notSupported("device doesn't support credential management")
+	}
+	return a.authorize(pinUvAuthToken, ctap2.PermissionCredentialManagement, "")
+}
+
+func (c *Credential) descriptor() webauthn.PublicKeyCredentialDescriptor {
+	return webauthn.PublicKeyCredentialDescriptor{
+		Type: webauthn.PublicKeyCredentialTypePublicKey,
+		ID:   bytes.Clone(c.ID),
+	}
+}
+
+func (c *Credential) privateKey() (*ecdsa.PrivateKey, error) {
+	k, err := x509.ParsePKCS8PrivateKey(c.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, ok := k.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("credential private key is not an ECDSA key")
+	}
+	return priv, nil
+}
+
+// publicKey returns the COSE public key of the credential.
+func (c *Credential) publicKey() (key.Key, error) {
+	priv, err := c.privateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// The uncompressed point is 0x04 || x || y.
+	point, err := priv.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Key{
+		iana.KeyParameterKty:    iana.KeyTypeEC2,
+		iana.KeyParameterAlg:    iana.AlgorithmES256,
+		iana.EC2KeyParameterCrv: iana.EllipticCurveP_256,
+		iana.EC2KeyParameterX:   point[1:33],
+		iana.EC2KeyParameterY:   point[33:],
+	}, nil
+}
+
+// credentialManagementParams are the parameters of authenticatorCredentialManagement.
+type credentialManagementParams struct {
+	SubCommand        ctap2.CredentialManagementSubCommand       `cbor:"1,keyasint"`
+	SubCommandParams  ctap2.CredentialManagementSubCommandParams `cbor:"2,keyasint"`
+	PinUvAuthProtocol ctap2.PinUvAuthProtocolType                `cbor:"3,keyasint"`
+	PinUvAuthParam    []byte                                     `cbor:"4,keyasint"`
+}
+
+// handleCredentialManagement executes authenticatorCredentialManagement. The enumerations are computed by
+// their begin subcommand and continued from the responses it leaves.
+func (a *Authenticator) handleCredentialManagement(r *request) (any, error) {
+	var p credentialManagementParams
+	if err := r.decode(&p, 0x01); err != nil {
+		return nil, err
+	}
+
+	switch p.SubCommand {
+	case ctap2.CredentialManagementSubCommandEnumerateRPsGetNextRP,
+		ctap2.CredentialManagementSubCommandEnumerateCredentialsGetNextCredential:
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		// Each getNext subcommand follows its begin subcommand.
+		return a.popNext(r, ctap2.CMDAuthenticatorCredentialManagement, uint(p.SubCommand-1))
+	}
+
+	token, err := a.authenticatedToken(
+		p.PinUvAuthProtocol,
+		p.PinUvAuthParam,
+		append([]byte{byte(p.SubCommand)}, r.params[0x02]...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.SubCommand {
+	case ctap2.CredentialManagementSubCommandGetCredsMetadata:
+		resp, err := a.GetCredsMetadata(token)
+		if err != nil {
+			return nil, err
+		}
+		return credentialManagementResponse(resp), nil
+	case ctap2.CredentialManagementSubCommandEnumerateRPsBegin:
+		return a.beginEnumeration(p.SubCommand, a.EnumerateRPs(token))
+	case ctap2.CredentialManagementSubCommandEnumerateCredentialsBegin:
+		if p.SubCommandParams.RPIDHash == nil {
+			return nil, ctapError(ctaphid.StatusCTAP2ErrMissingParameter)
+		}
+		return a.beginEnumeration(p.SubCommand, a.EnumerateCredentials(token, p.SubCommandParams.RPIDHash))
+	case ctap2.CredentialManagementSubCommandDeleteCredential:
+		if p.SubCommandParams.CredentialID.ID == nil {
+			return nil, ctapError(ctaphid.StatusCTAP2ErrMissingParameter)
+		}
+		return nil, a.DeleteCredential(token, p.SubCommandParams.CredentialID)
+	case ctap2.CredentialManagementSubCommandUpdateUserInformation:
+		if p.SubCommandParams.CredentialID.ID == nil || p.SubCommandParams.User.ID == nil {
+			return nil, ctapError(ctaphid.StatusCTAP2ErrMissingParameter)
+		}
+		return nil, a.UpdateUserInformation(token, p.SubCommandParams.CredentialID, p.SubCommandParams.User)
+	default:
+		return nil, ctapError(ctaphid.StatusCTAP2ErrInvalidSubcommand)
+	}
+}
+
+// beginEnumeration returns the first response of an enumeration and leaves the others for the getNext
+// subcommand that follows subCommand.
+func (a *Authenticator) beginEnumeration(
+	subCommand ctap2.CredentialManagementSubCommand,
+	seq iter.Seq2[*ctap2.AuthenticatorCredentialManagementResponse, error],
+) (any, error) {
+	var responses []any
+	for resp, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, credentialManagementResponse(resp))
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.next = &pendingResponses{
+		command:    ctap2.CMDAuthenticatorCredentialManagement,
+		subCommand: uint(subCommand),
+		responses:  responses[1:],
+	}
+	return responses[0], nil
+}
+
+// credentialManagementResponse encodes resp without the members left unset, which go-fido2 would encode as
+// zero values.
+func credentialManagementResponse(resp *ctap2.AuthenticatorCredentialManagementResponse) map[int]any {
+	m := map[int]any{}
+	if resp.RP.ID == "" && resp.CredentialID.ID == nil {
+		m[0x01] = resp.ExistingResidentCredentialsCount
+		m[0x02] = resp.MaxPossibleRemainingResidentCredentialsCount
+	}
+	if resp.RP.ID != "" {
+		m[0x03] = resp.RP
+		m[0x04] = resp.RPIDHash
+	}
+	if resp.TotalRPs > 0 {
+		m[0x05] = resp.TotalRPs
+	}
+	if resp.CredentialID.ID != nil {
+		m[0x06] = resp.User
+		m[0x07] = resp.CredentialID
+		m[0x08] = resp.PublicKey
+	}
+	if resp.CredProtect > 0 {
+		m[0x0a] = resp.CredProtect
+	}
+	if resp.TotalCredentials > 0 {
+		m[0x09] = resp.TotalCredentials
+	}
+	if resp.LargeBlobKey != nil {
+		m[0x0b] = resp.LargeBlobKey
+	}
+	return m
+}
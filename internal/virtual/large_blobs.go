@@ -0,0 +1,198 @@
+package virtual
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+)
+
+// largeBlobTrailerSize is the size of the truncated SHA-256 hash that ends a serialized large-blob array.
+const largeBlobTrailerSize = 16
+
+// GetLargeBlobs returns the large-blob array after checking its hash trailer.
+func (a *Authenticator) GetLargeBlobs() ([]*ctap2.LargeBlob, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireLargeBlobs(); err != nil {
+		return nil, err
+	}
+
+	array := a.state.LargeBlobArray
+	data := array[:max(len(array)-largeBlobTrailerSize, 0)]
+	if len(array) < largeBlobTrailerSize || !bytes.Equal(largeBlobTrailer(data), array[len(data):]) {
+		return nil, &fido2.ErrorWithMessage{
+			Err:     fido2.ErrLargeBlobsIntegrityCheck,
+			Message: "for some reason calculated and actual hashes mismatch",
+		}
+	}
+
+	var blobs []*ctap2.LargeBlob
+	if err := cbor.Unmarshal(data, &blobs); err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// SetLargeBlobs replaces the large-blob array.
+func (a *Authenticator) SetLargeBlobs(pinUvAuthToken []byte, blobs []*ctap2.LargeBlob) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireLargeBlobs(); err != nil {
+		return err
+	}
+	if blobs == nil {
+		blobs = []*ctap2.LargeBlob{}
+	}
+
+	set, err := marshal(blobs)
+	if err != nil {
+		return err
+	}
+	set = slices.Concat(set, largeBlobTrailer(set))
+
+	if len(set) > maxSerializedLargeBlobs {
+		return &fido2.ErrorWithMessage{
+			Err: fido2.ErrLargeBlobsTooBig,
+			Message: fmt.Sprintf(
+				"this device max serialized large blob size is %db while you are trying to save %db",
+				maxSerializedLargeBlobs,
+				len(set),
+			),
+		}
+	}
+	if err := a.authorize(pinUvAuthToken, ctap2.PermissionLargeBlobWrite, ""); err != nil {
+		return err
+	}
+
+	a.state.LargeBlobArray = set
+	return a.save()
+}
+
+func (a *Authenticator) requireLargeBlobs() error {
+	switch {
+	case a.closed:
+		return ErrClosed
+	case !a.state.Features.LargeBlobs:
+		return notSupported("device doesn't support largeBlobs")
+	}
+	return nil
+}
+
+// largeBlobTrailer returns LEFT(SHA-256(data), 16), the trailer of the serialized large-blob array data.
+func largeBlobTrailer(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:largeBlobTrailerSize]
+}
+
+// emptyLargeBlobArray returns the serialized empty large-blob array a new authenticator is initialized with.
+func emptyLargeBlobArray() []byte {
+	empty := []byte{0x80} // CBOR empty array.
+	return slices.Concat(empty, largeBlobTrailer(empty))
+}
+
+// pendingLargeBlobs is a large-blob array being written in fragments.
+type pendingLargeBlobs struct {
+	length uint
+	data   []byte
+}
+
+// handleLargeBlobs executes authenticatorLargeBlobs, which reads and writes the serialized large-blob array
+// in fragments of at most maxMsgSize-64 bytes.
+func (a *Authenticator) handleLargeBlobs(r *request) (any, error) {
+	var req ctap2.AuthenticatorLargeBlobsRequest
+	if err := r.decode(&req, 0x03); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.requireLargeBlobs(); err != nil {
+		return nil, err
+	}
+
+	const maxFragmentLength = maxMsgSize - 64
+	switch {
+	case r.has(0x01) == r.has(0x02):
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	case r.has(0x01):
+		if req.Get > maxFragmentLength {
+			return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidLength)
+		}
+		array := a.state.LargeBlobArray
+		if req.Offset > uint(len(array)) {
+			return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+		}
+		return map[int]any{0x01: array[req.Offset:min(req.Offset+req.Get, uint(len(array)))]}, nil
+	case len(req.Set) > maxFragmentLength:
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidLength)
+	}
+
+	if req.Offset == 0 {
+		switch {
+		case !r.has(0x04):
+			return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+		case req.Length > maxSerializedLargeBlobs:
+			return nil, ctapError(ctaphid.StatusCTAP2ErrLargeBlobStorageFull)
+		case req.Length <= largeBlobTrailerSize:
+			return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+		}
+		a.largeBlobWrite = &pendingLargeBlobs{length: req.Length}
+	} else if r.has(0x04) {
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	}
+
+	write := a.largeBlobWrite
+	if write == nil || req.Offset != uint(len(write.data)) {
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidSeq)
+	}
+
+	if len(a.state.PINHash) > 0 || a.state.AlwaysUV {
+		// The message is 32 bytes of 0xff, h'0c00', the offset as a little-endian uint32, and SHA-256(set).
+		h := sha256.Sum256(req.Set)
+		message := slices.Concat(
+			bytes.Repeat([]byte{0xff}, 32),
+			[]byte{byte(ctap2.CMDAuthenticatorLargeBlobs), 0x00},
+			binary.LittleEndian.AppendUint32(nil, uint32(req.Offset)),
+			h[:],
+		)
+		switch {
+		case req.PinUvAuthParam == nil:
+			return nil, ctapError(ctaphid.StatusCTAP2ErrPUATRequired)
+		case !r.has(0x06):
+			return nil, ctapError(ctaphid.StatusCTAP2ErrMissingParameter)
+		}
+		if err := a.checkPinUvAuthParam(req.PinUvAuthProtocol, req.PinUvAuthParam, message); err != nil {
+			return nil, err
+		}
+		if err := a.authorize(a.token, ctap2.PermissionLargeBlobWrite, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Offset+uint(len(req.Set)) > write.length {
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	}
+	write.data = append(write.data, req.Set...)
+	if uint(len(write.data)) < write.length {
+		return nil, nil
+	}
+
+	a.largeBlobWrite = nil
+	data := write.data[:len(write.data)-largeBlobTrailerSize]
+	if !bytes.Equal(largeBlobTrailer(data), write.data[len(data):]) {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrIntegrityFailure)
+	}
+
+	a.state.LargeBlobArray = write.data
+	return nil, a.save()
+}
@@ -0,0 +1,451 @@
+package virtual
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"slices"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+)
+
+// attestationFormatPacked is the attestation statement format of the virtual authenticator. Having no
+// attestation certificate, it uses self attestation.
+const attestationFormatPacked = "packed"
+
+// createExtensions are the makeCredential extension inputs the virtual authenticator supports. Others are
+// ignored, as CTAP2 requires.
+type createExtensions struct {
+	CredProtect  uint   `cbor:"credProtect,omitempty"`
+	CredBlob     []byte `cbor:"credBlob,omitempty"`
+	MinPinLength bool   `cbor:"minPinLength,omitempty"`
+	LargeBlobKey bool   `cbor:"largeBlobKey,omitempty"`
+}
+
+// makeCredentialRequest holds the parameters of makeCredential once the user has been verified, if at all.
+type makeCredentialRequest struct {
+	clientDataHash   []byte
+	rp               webauthn.PublicKeyCredentialRpEntity
+	user             webauthn.PublicKeyCredentialUserEntity
+	pubKeyCredParams []webauthn.PublicKeyCredentialParameters
+	excludeList      []webauthn.PublicKeyCredentialDescriptor
+	extensions       createExtensions
+	rk               bool
+	uv               bool
+}
+
+// attestation is the outcome of makeCredential.
+type attestation struct {
+	authData     []byte
+	signature    []byte
+	largeBlobKey []byte
+}
+
+// MakeCredential creates an ES256 credential with a packed self attestation. The rk option creates a
+// discoverable credential; otherwise the private key is wrapped into the credential ID. Only the credProtect,
+// credBlob and minPinLength extensions are supported. A pinUvAuthToken with the makeCredential permission
+// marks the credential as user verified.
+func (a *Authenticator) MakeCredential(
+	pinUvAuthToken []byte,
+	clientData []byte,
+	rp webauthn.PublicKeyCredentialRpEntity,
+	user webauthn.PublicKeyCredentialUserEntity,
+	pubKeyCredParams []webauthn.PublicKeyCredentialParameters,
+	excludeList []webauthn.PublicKeyCredentialDescriptor,
+	extInputs *webauthn.CreateAuthenticationExtensionsClientInputs,
+	options map[ctap2.Option]bool,
+	_ uint,
+	_ []webauthn.AttestationStatementFormatIdentifier,
+) (*ctap2.AuthenticatorMakeCredentialResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil, ErrClosed
+	}
+	if extInputs == nil {
+		extInputs = new(webauthn.CreateAuthenticationExtensionsClientInputs)
+	}
+	if extInputs.CreateHMACSecretInputs != nil || extInputs.CreateHMACSecretMCInputs != nil ||
+		extInputs.PRFInputs != nil || extInputs.LargeBlobInputs != nil ||
+		extInputs.CreatePinComplexityPolicyInputs != nil {
+		return nil, notSupported(
+			"virtual authenticator supports only the credProtect, credBlob and minPinLength extensions",
+		)
+	}
+
+	var ext createExtensions
+	if extInputs.CreateCredentialProtectionInputs != nil {
+		switch extInputs.CredentialProtectionPolicy {
+		case webauthn.CredentialProtectionPolicyUserVerificationOptional:
+			ext.CredProtect = 1
+		case webauthn.CredentialProtectionPolicyUserVerificationOptionalWithCredentialIDList:
+			ext.CredProtect = 2
+		case webauthn.CredentialProtectionPolicyUserVerificationRequired:
+			ext.CredProtect = 3
+		default:
+			return nil, notSupported("invalid credential protection policy")
+		}
+	}
+	if extInputs.CreateCredentialBlobInputs != nil {
+		if len(extInputs.CredBlob) > maxCredBlobLength {
+			return nil, notSupported("credBlob is longer than the device supports")
+		}
+		ext.CredBlob = extInputs.CredBlob
+	}
+	if extInputs.CreateMinPinLengthInputs != nil {
+		ext.MinPinLength = extInputs.MinPinLength
+	}
+
+	uv := pinUvAuthToken != nil
+	if uv {
+		if err := a.authorize(pinUvAuthToken, ctap2.PermissionMakeCredential, rp.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	clientDataHash := sha256.Sum256(clientData)
+	att, err := a.makeCredential(context.Background(), nil, &makeCredentialRequest{
+		clientDataHash:   clientDataHash[:],
+		rp:               rp,
+		user:             user,
+		pubKeyCredParams: pubKeyCredParams,
+		excludeList:      excludeList,
+		extensions:       ext,
+		rk:               options[ctap2.OptionResidentKeys],
+		uv:               uv,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	authData, err := ctap2.ParseMakeCredentialAuthData(att.authData)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ctap2.AuthenticatorMakeCredentialResponse{
+		Format:      attestationFormatPacked,
+		AuthDataRaw: att.authData,
+		AuthData:    authData,
+		AttestationStatement: map[string]any{
+			"alg": int64(iana.AlgorithmES256),
+			"sig": att.signature,
+		},
+		ExtensionOutputs: &webauthn.CreateAuthenticationExtensionsClientOutputs{},
+	}
+	if authData.Extensions != nil && authData.Extensions.CreateCredBlobOutput != nil {
+		resp.ExtensionOutputs.CreateCredentialBlobOutputs = &webauthn.CreateCredentialBlobOutputs{
+			CredBlob: authData.Extensions.CredBlob,
+		}
+	}
+
+	return resp, nil
+}
+
+// makeCredentialParams are the parameters of authenticatorMakeCredential.
+type makeCredentialParams struct {
+	ClientDataHash    []byte                                   `cbor:"1,keyasint"`
+	RP                webauthn.PublicKeyCredentialRpEntity     `cbor:"2,keyasint"`
+	User              webauthn.PublicKeyCredentialUserEntity   `cbor:"3,keyasint"`
+	PubKeyCredParams  []webauthn.PublicKeyCredentialParameters `cbor:"4,keyasint"`
+	ExcludeList       []webauthn.PublicKeyCredentialDescriptor `cbor:"5,keyasint"`
+	Extensions        createExtensions                         `cbor:"6,keyasint"`
+	Options           map[ctap2.Option]bool                    `cbor:"7,keyasint"`
+	PinUvAuthParam    []byte                                   `cbor:"8,keyasint"`
+	PinUvAuthProtocol ctap2.PinUvAuthProtocolType              `cbor:"9,keyasint"`
+}
+
+// handleMakeCredential executes authenticatorMakeCredential.
+func (a *Authenticator) handleMakeCredential(r *request) (any, error) {
+	var p makeCredentialParams
+	if err := r.decode(&p, 0x01, 0x02, 0x03, 0x04); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if up, ok := p.Options[ctap2.OptionUserPresence]; ok && !up {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrInvalidOption)
+	}
+	if r.has(0x0a) && !a.state.EnterpriseAttestation {
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	}
+
+	uv, err := a.verifyUser(
+		r,
+		0x08,
+		p.PinUvAuthParam,
+		p.PinUvAuthProtocol,
+		p.ClientDataHash,
+		p.Options,
+		ctap2.PermissionMakeCredential,
+		p.RP.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	att, err := a.makeCredential(r.ctx, r.presence, &makeCredentialRequest{
+		clientDataHash:   p.ClientDataHash,
+		rp:               p.RP,
+		user:             p.User,
+		pubKeyCredParams: p.PubKeyCredParams,
+		excludeList:      p.ExcludeList,
+		extensions:       p.Extensions,
+		rk:               p.Options[ctap2.OptionResidentKeys],
+		uv:               uv,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := map[int]any{
+		0x01: attestationFormatPacked,
+		0x02: att.authData,
+		0x03: map[string]any{"alg": iana.AlgorithmES256, "sig": att.signature},
+	}
+	if att.largeBlobKey != nil {
+		resp[0x05] = att.largeBlobKey
+	}
+	return resp, nil
+}
+
+// makeCredential creates a credential for req after asking presence for user presence. Whether the user must
+// be verified has already been decided by the caller. It must be called with the mutex held.
+func (a *Authenticator) makeCredential(
+	ctx context.Context,
+	presence Presence,
+	req *makeCredentialRequest,
+) (*attestation, error) {
+	if !slices.ContainsFunc(req.pubKeyCredParams, func(p webauthn.PublicKeyCredentialParameters) bool {
+		return p.Type == webauthn.PublicKeyCredentialTypePublicKey && p.Algorithm == iana.AlgorithmES256
+	}) {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrUnsupportedAlgorithm)
+	}
+
+	for _, d := range req.excludeList {
+		if a.lookupCredential(req.rp.ID, d.ID) != nil {
+			if err := presence.test(ctx); err != nil {
+				return nil, err
+			}
+			return nil, ctapError(ctaphid.StatusCTAP2ErrCredentialExcluded)
+		}
+	}
+
+	protected := len(a.state.PINHash) > 0 || len(a.state.Enrollments) > 0
+	if !req.uv && (a.state.AlwaysUV || (req.rk && protected)) {
+		return nil, ctapError(ctaphid.StatusCTAP2ErrPUATRequired)
+	}
+
+	ext := req.extensions
+	if ext.CredProtect > 3 {
+		ext.CredProtect = 0
+	}
+	if err := presence.test(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		cred      *Credential
+		signCount uint32
+	)
+	if req.rk {
+		c, err := a.addCredential(req.rp, req.user, CredentialOptions{
+			CredProtect:    ext.CredProtect,
+			CredBlob:       ext.CredBlob,
+			NoLargeBlobKey: !ext.LargeBlobKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(ext.CredBlob) > maxCredBlobLength {
+			c.CredBlob = nil
+		}
+		cred = c
+	} else {
+		c, err := a.wrapCredential(req.rp.ID, ext.CredProtect)
+		if err != nil {
+			return nil, err
+		}
+		cred = c
+		signCount = a.state.SignCount
+	}
+	if err := a.save(); err != nil {
+		return nil, err
+	}
+
+	authData, err := a.makeCredentialAuthData(req, ext, cred, signCount)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, err := cred.privateKey()
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(slices.Concat(authData, req.clientDataHash))
+	sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	att := &attestation{authData: authData, signature: sig}
+	if ext.LargeBlobKey {
+		att.largeBlobKey = bytes.Clone(cred.LargeBlobKey)
+	}
+	return att, nil
+}
+
+// makeCredentialAuthData returns the authenticator data attesting cred, created for req with the extension
+// inputs ext. It must be called with the mutex held.
+func (a *Authenticator) makeCredentialAuthData(
+	req *makeCredentialRequest,
+	ext createExtensions,
+	cred *Credential,
+	signCount uint32,
+) ([]byte, error) {
+	pub, err := cred.publicKey()
+	if err != nil {
+		return nil, err
+	}
+	pubRaw, err := marshal(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := ctap2.AuthDataFlagUserPresent | ctap2.AuthDataFlagAttestedCredentialDataIncluded
+	if req.uv {
+		flags |= ctap2.AuthDataFlagUserVerified
+	}
+
+	outputs := map[string]any{}
+	if ext.CredProtect != 0 {
+		outputs["credProtect"] = ext.CredProtect
+	}
+	if ext.CredBlob != nil {
+		// Only a discoverable credential has room for a credBlob.
+		outputs["credBlob"] = req.rk && len(ext.CredBlob) <= maxCredBlobLength
+	}
+	if ext.MinPinLength && slices.Contains(a.state.MinPINLengthRPIDs, req.rp.ID) {
+		outputs["minPinLength"] = a.state.MinPINLength
+	}
+	var extRaw []byte
+	if len(outputs) > 0 {
+		flags |= ctap2.AuthDataFlagExtensionDataIncluded
+		if extRaw, err = marshal(outputs); err != nil {
+			return nil, err
+		}
+	}
+
+	authData := binary.BigEndian.AppendUint32(append(cred.rpIDHash(), byte(flags)), signCount)
+	authData = append(authData, a.state.AAGUID[:]...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(cred.ID))) //nolint:gosec // IDs are short.
+	return slices.Concat(authData, cred.ID, pubRaw, extRaw), nil
+}
+
+// lookupCredential returns the credential of rpID with the given ID, either discoverable or wrapped into the
+// ID, or nil if there is none.
+func (a *Authenticator) lookupCredential(rpID string, id []byte) *Credential {
+	for _, c := range a.state.Credentials {
+		if c.RP.ID == rpID && bytes.Equal(c.ID, id) {
+			return c
+		}
+	}
+	return a.unwrapCredential(rpID, id)
+}
+
+// wrapCredential creates a non-discoverable credential of rpID. Its ID is the private key and credProtect
+// level sealed with CredentialKey, bound to the relying party, so the credential doesn't need to be stored.
+func (a *Authenticator) wrapCredential(rpID string, credProtect uint) (*Credential, error) {
+	if len(a.state.CredentialKey) == 0 {
+		a.state.CredentialKey = make([]byte, 32)
+		if _, err := rand.Read(a.state.CredentialKey); err != nil {
+			return nil, err
+		}
+	}
+	aead, err := a.credentialAEAD()
+	if err != nil {
+		return nil, err
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	scalar, err := priv.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	rpIDHash := sha256.Sum256([]byte(rpID))
+
+	return &Credential{
+		ID:          aead.Seal(nonce, nonce, append([]byte{byte(credProtect)}, scalar...), rpIDHash[:]),
+		RP:          webauthn.PublicKeyCredentialRpEntity{ID: rpID},
+		PrivateKey:  der,
+		CredProtect: credProtect,
+		wrapped:     true,
+	}, nil
+}
+
+// unwrapCredential returns the non-discoverable credential of rpID with the given ID, or nil if the ID was not
+// created by wrapCredential for rpID.
+func (a *Authenticator) unwrapCredential(rpID string, id []byte) *Credential {
+	if len(a.state.CredentialKey) == 0 {
+		return nil
+	}
+	aead, err := a.credentialAEAD()
+	if err != nil || len(id) < aead.NonceSize() {
+		return nil
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	plaintext, err := aead.Open(nil, id[:aead.NonceSize()], id[aead.NonceSize():], rpIDHash[:])
+	if err != nil || len(plaintext) != 33 {
+		return nil
+	}
+	priv, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), plaintext[1:])
+	if err != nil {
+		return nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil
+	}
+
+	return &Credential{
+		ID:          bytes.Clone(id),
+		RP:          webauthn.PublicKeyCredentialRpEntity{ID: rpID},
+		PrivateKey:  der,
+		CredProtect: uint(plaintext[0]),
+		wrapped:     true,
+	}
+}
+
+func (a *Authenticator) credentialAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(a.state.CredentialKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,118 @@
+package virtual
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+
+	"github.com/ldclabs/cose/iana"
+	"github.com/ldclabs/cose/key"
+	coseecdh "github.com/ldclabs/cose/key/ecdh"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+)
+
+// coseAlgECDHESHKDF256 is the COSE algorithm identifier CTAP2 requires on key agreement keys, ECDH-ES+HKDF-256.
+const coseAlgECDHESHKDF256 = -25
+
+// checkPinUvAuthProtocol rejects a PIN/UV auth protocol the authenticator doesn't implement.
+func checkPinUvAuthProtocol(protocol ctap2.PinUvAuthProtocolType) error {
+	if protocol != ctap2.PinUvAuthProtocolTypeOne && protocol != ctap2.PinUvAuthProtocolTypeTwo {
+		return ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	}
+	return nil
+}
+
+// keyAgreementKey returns the public key agreement key of the PIN/UV auth protocols as a COSE key, generating
+// the key pair on first use. It must be called with the mutex held.
+func (a *Authenticator) keyAgreementKey() (key.Key, error) {
+	if a.keyAgreement == nil {
+		priv, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		a.keyAgreement = priv
+	}
+
+	k, err := coseecdh.KeyFromPublic(a.keyAgreement.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	if err := k.Set(iana.KeyParameterAlg, coseAlgECDHESHKDF256); err != nil {
+		return nil, err
+	}
+	delete(k, iana.KeyParameterKid)
+
+	return k, nil
+}
+
+// sharedSecret derives the secret shared with the platform whose key agreement key is peer. It must be called
+// with the mutex held.
+func (a *Authenticator) sharedSecret(protocol ctap2.PinUvAuthProtocolType, peer key.Key) ([]byte, error) {
+	if a.keyAgreement == nil {
+		// The platform must get the key agreement key first.
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	}
+
+	pub, err := coseecdh.KeyToPublic(peer)
+	if err != nil {
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	}
+	z, err := a.keyAgreement.ECDH(pub)
+	if err != nil {
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	}
+
+	return (&ctap2.PinUvAuthProtocol{Type: protocol}).KDF(z)
+}
+
+// encrypt encrypts plaintext, whose length is a multiple of the AES block size, with a shared secret.
+func encrypt(protocol ctap2.PinUvAuthProtocolType, secret, plaintext []byte) ([]byte, error) {
+	return (&ctap2.PinUvAuthProtocol{Type: protocol}).Encrypt(secret, plaintext)
+}
+
+// decrypt decrypts ciphertext with a shared secret. Unlike the platform side of go-fido2, it accepts
+// plaintexts of any number of blocks, such as the 64-byte padded PIN in newPinEnc.
+func decrypt(protocol ctap2.PinUvAuthProtocolType, secret, ciphertext []byte) ([]byte, error) {
+	aesKey := secret
+	iv := make([]byte, aes.BlockSize)
+	if protocol == ctap2.PinUvAuthProtocolTypeTwo {
+		if len(secret) != 64 || len(ciphertext) < aes.BlockSize {
+			return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+		}
+		aesKey = secret[32:]
+		iv, ciphertext = ciphertext[:aes.BlockSize], ciphertext[aes.BlockSize:]
+	}
+	if len(ciphertext)%aes.BlockSize != 0 {
+		return nil, ctapError(ctaphid.StatusCTAP1ErrInvalidParameter)
+	}
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	return plaintext, nil
+}
+
+// verify reports whether signature is the MAC of message computed with key, either a shared secret or a
+// pinUvAuthToken.
+func verify(protocol ctap2.PinUvAuthProtocolType, key, message, signature []byte) bool {
+	return len(key) > 0 && hmac.Equal(ctap2.Authenticate(protocol, key, message), signature)
+}
+
+// checkPinUvAuthParam checks that param is the MAC of message computed with the current pinUvAuthToken. It
+// must be called with the mutex held.
+func (a *Authenticator) checkPinUvAuthParam(protocol ctap2.PinUvAuthProtocolType, param, message []byte) error {
+	if err := checkPinUvAuthProtocol(protocol); err != nil {
+		return err
+	}
+	if a.token == nil || !verify(protocol, a.token, message, param) {
+		return ctapError(ctaphid.StatusCTAP2ErrPinAuthInvalid)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package virtual
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+)
+
+// stateVersion is the version of the state file format.
+const stateVersion = 1
+
+// State is the persistent state of a virtual authenticator, stored as JSON.
+type State struct {
+	// Version is the version of the state file format.
+	Version int `json:"version"`
+	// AAGUID is the authenticator AAGUID.
+	AAGUID uuid.UUID `json:"aaguid"`
+	// SerialNumber is the serial number reported in the device descriptor.
+	SerialNumber string `json:"serialNumber"`
+	// FirmwareVersion is the firmware version reported by getInfo.
+	FirmwareVersion uint `json:"firmwareVersion"`
+	// Features selects the optional CTAP2 features the authenticator supports.
+	Features Features `json:"features"`
+
+	// PINHash is LEFT(SHA-256(PIN), 16), or empty if no PIN is set.
+	PINHash []byte `json:"pinHash,omitempty"`
+	// PINLength is the length of the current PIN in Unicode code points.
+	PINLength uint `json:"pinLength,omitempty"`
+	// PINRetries is the number of PIN retries left.
+	PINRetries uint `json:"pinRetries"`
+	// UVRetries is the number of built-in user verification retries left.
+	UVRetries uint `json:"uvRetries"`
+	// MinPINLength is the minimum PIN length in Unicode code points.
+	MinPINLength uint `json:"minPinLength"`
+	// MinPINLengthRPIDs are the relying parties allowed to read the minimum PIN length.
+	MinPINLengthRPIDs []string `json:"minPinLengthRpIds,omitempty"`
+	// ForcePINChange requires the PIN to be changed before a pinUvAuthToken is issued.
+	ForcePINChange bool `json:"forcePinChange,omitempty"`
+	// AlwaysUV is the state of the alwaysUv option.
+	AlwaysUV bool `json:"alwaysUv,omitempty"`
+	// EnterpriseAttestation is the state of the ep option.
+	EnterpriseAttestation bool `json:"enterpriseAttestation,omitempty"`
+
+	// Credentials are the discoverable credentials, in the order they were created.
+	Credentials []*Credential `json:"credentials,omitempty"`
+	// CredentialKey is the AES-256 key that wraps the private keys of non-discoverable credentials into their
+	// credential IDs. It is generated with the first such credential.
+	CredentialKey []byte `json:"credentialKey,omitempty"`
+	// SignCount is the signature counter shared by the non-discoverable credentials.
+	SignCount uint32 `json:"signCount,omitempty"`
+	// Enrollments are the enrolled fingerprints.
+	Enrollments []*Enrollment `json:"enrollments,omitempty"`
+	// LargeBlobArray is the serialized large-blob array, including its 16-byte hash trailer.
+	LargeBlobArray []byte `json:"largeBlobArray"`
+}
+
+// Features selects the optional CTAP2 features of a virtual authenticator. A disabled feature is not
+// reported by getInfo, and the commands that need it fail as they would on a security key without it.
+type Features struct {
+	ClientPIN             bool `json:"clientPin"`
+	CredentialManagement  bool `json:"credMgmt"`
+	AuthenticatorConfig   bool `json:"authnrCfg"`
+	BioEnrollment         bool `json:"bioEnroll"`
+	LargeBlobs            bool `json:"largeBlobs"`
+	AlwaysUV              bool `json:"alwaysUv"`
+	EnterpriseAttestation bool `json:"ep"`
+}
+
+// Credential is a discoverable credential stored on a virtual authenticator.
+type Credential struct {
+	// ID is the credential ID.
+	ID []byte `json:"id"`
+	// RP is the relying party the credential belongs to.
+	RP webauthn.PublicKeyCredentialRpEntity `json:"rp"`
+	// User is the user account the credential belongs to.
+	User webauthn.PublicKeyCredentialUserEntity `json:"user"`
+	// PrivateKey is the PKCS #8 encoded ES256 private key of the credential.
+	PrivateKey []byte `json:"privateKey"`
+	// CredProtect is the credProtect level of the credential.
+	CredProtect uint `json:"credProtect,omitempty"`
+	// LargeBlobKey is the key used to encrypt the credential's large blob.
+	LargeBlobKey []byte `json:"largeBlobKey,omitempty"`
+	// CredBlob is the credBlob stored with the credential.
+	CredBlob []byte `json:"credBlob,omitempty"`
+	// SignCount is the signature counter of the credential.
+	SignCount uint32 `json:"signCount"`
+
+	// wrapped marks a non-discoverable credential unwrapped from its ID, which is not stored.
+	wrapped bool
+}
+
+// rpIDHash returns the SHA-256 hash of the credential's relying party ID.
+func (c *Credential) rpIDHash() []byte {
+	h := sha256.Sum256([]byte(c.RP.ID))
+	return h[:]
+}
+
+// Enrollment is a fingerprint enrolled on a virtual authenticator.
+type Enrollment struct {
+	// TemplateID is the ID of the fingerprint template.
+	TemplateID []byte `json:"templateId"`
+	// FriendlyName is the name of the fingerprint.
+	FriendlyName string `json:"friendlyName,omitempty"`
+}
+
+// NewState returns the state of a new virtual authenticator with every feature enabled and no PIN set.
+func NewState() (*State, error) {
+	aaguid, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	serial := make([]byte, 8)
+	if _, err := rand.Read(serial); err != nil {
+		return nil, err
+	}
+
+	s := &State{
+		Version:         stateVersion,
+		AAGUID:          aaguid,
+		SerialNumber:    hex.EncodeToString(serial),
+		FirmwareVersion: 1,
+		Features: Features{
+			ClientPIN:             true,
+			CredentialManagement:  true,
+			AuthenticatorConfig:   true,
+			BioEnrollment:         true,
+			LargeBlobs:            true,
+			AlwaysUV:              true,
+			EnterpriseAttestation: true,
+		},
+	}
+	s.reset()
+
+	return s, nil
+}
+
+// reset wipes the state as authenticatorReset does, keeping the identity and features of the authenticator.
+func (s *State) reset() {
+	*s = State{
+		Version:         s.Version,
+		AAGUID:          s.AAGUID,
+		SerialNumber:    s.SerialNumber,
+		FirmwareVersion: s.FirmwareVersion,
+		Features:        s.Features,
+		PINRetries:      maxPINRetries,
+		UVRetries:       maxUVRetries,
+		MinPINLength:    defaultMinPINLength,
+		LargeBlobArray:  emptyLargeBlobArray(),
+	}
+}
+
+// LoadState reads the state stored at path. If the file does not exist, a new state is created and saved.
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s, err := NewState()
+		if err != nil {
+			return nil, err
+		}
+		return s, s.Save(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid virtual authenticator state %s: %w", path, err)
+	}
+	if s.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported virtual authenticator state version %d in %s", s.Version, path)
+	}
+
+	return &s, nil
+}
+
+// Save atomically writes the state to path. The file is only readable by its owner since it holds the
+// PIN hash and the private keys of the credentials.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,216 @@
+package uhid
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+)
+
+// UHID event types, from linux/uhid.h.
+const (
+	uhidDestroy        = 1
+	uhidOutput         = 6
+	uhidGetReport      = 9
+	uhidGetReportReply = 10
+	uhidCreate2        = 11
+	uhidInput2         = 12
+	uhidSetReport      = 13
+	uhidSetReportReply = 14
+)
+
+const (
+	// uhidEventSize is sizeof(struct uhid_event): the event type followed by its largest member, create2_req.
+	uhidEventSize = 4 + 128 + 64 + 64 + 2 + 2 + 4 + 4 + 4 + 4 + 4096
+
+	busUSB = 0x03
+	// vendorID and productID are the pid.codes test IDs.
+	vendorID  = 0x1209
+	productID = 0x0001
+
+	// pathTimeout bounds how long Path waits for the kernel to create the hidraw node.
+	pathTimeout = 2 * time.Second
+)
+
+// reportDescriptor declares a FIDO U2F/CTAPHID interface with 64-byte input and output reports.
+var reportDescriptor = []byte{
+	0x06, 0xd0, 0xf1, // Usage Page (FIDO Alliance)
+	0x09, 0x01, // Usage (U2F Authenticator Device)
+	0xa1, 0x01, // Collection (Application)
+	0x09, 0x20, //   Usage (Input Report Data)
+	0x15, 0x00, //   Logical Minimum (0)
+	0x26, 0xff, 0x00, //   Logical Maximum (255)
+	0x75, 0x08, //   Report Size (8)
+	0x95, 0x40, //   Report Count (64)
+	0x81, 0x02, //   Input (Data, Var, Abs)
+	0x09, 0x21, //   Usage (Output Report Data)
+	0x15, 0x00, //   Logical Minimum (0)
+	0x26, 0xff, 0x00, //   Logical Maximum (255)
+	0x75, 0x08, //   Report Size (8)
+	0x95, 0x40, //   Report Count (64)
+	0x91, 0x02, //   Output (Data, Var, Abs)
+	0xc0, // End Collection
+}
+
+// errClosed is returned by the Transport of a closed Device.
+var errClosed = errors.New("uhid: device closed")
+
+// Device is a HID security key created through /dev/uhid. It lives until it is closed.
+type Device struct {
+	f    *os.File
+	uniq string
+}
+
+// Create creates a HID device named name whose reports are read and written through the returned Device.
+// It requires write access to /dev/uhid, usually root.
+func Create(name string) (*Device, error) {
+	f, err := os.OpenFile("/dev/uhid", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	uniq := make([]byte, 8)
+	if _, err := rand.Read(uniq); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	d := &Device{f: f, uniq: "skm-" + hex.EncodeToString(uniq)}
+
+	// struct uhid_create2_req: name[128] phys[64] uniq[64] rd_size bus vendor product version country rd_data.
+	ev := make([]byte, uhidEventSize)
+	binary.NativeEndian.PutUint32(ev, uhidCreate2)
+	req := ev[4:]
+	copy(req[:127], name)
+	copy(req[128:191], "skm")
+	copy(req[192:255], d.uniq)
+	binary.NativeEndian.PutUint16(req[256:], uint16(len(reportDescriptor))) //nolint:gosec // It is 34 bytes.
+	binary.NativeEndian.PutUint16(req[258:], busUSB)
+	binary.NativeEndian.PutUint32(req[260:], vendorID)
+	binary.NativeEndian.PutUint32(req[264:], productID)
+	copy(req[276:], reportDescriptor)
+
+	if _, err := f.Write(ev); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("creating the UHID device: %w", err)
+	}
+	return d, nil
+}
+
+// Path returns the hidraw node of the device, e.g. /dev/hidraw3, waiting for the kernel to create it.
+func (d *Device) Path() (string, error) {
+	deadline := time.Now().Add(pathTimeout)
+	for {
+		uevents, err := filepath.Glob("/sys/class/hidraw/hidraw*/device/uevent")
+		if err != nil {
+			return "", err
+		}
+		for _, uevent := range uevents {
+			if d.isUevent(uevent) {
+				return filepath.Join("/dev", filepath.Base(filepath.Dir(filepath.Dir(uevent)))), nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return "", errors.New("uhid: hidraw node not found")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// isUevent reports whether the uevent file belongs to the device.
+func (d *Device) isUevent(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if s.Text() == "HID_UNIQ="+d.uniq {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadReport returns the next output report written to the hidraw node. Report requests, which CTAPHID
+// doesn't use, are rejected.
+func (d *Device) ReadReport() ([]byte, error) {
+	ev := make([]byte, uhidEventSize)
+	for {
+		n, err := d.f.Read(ev)
+		if errors.Is(err, os.ErrClosed) {
+			return nil, errClosed
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n < 4 {
+			continue
+		}
+
+		switch binary.NativeEndian.Uint32(ev) {
+		case uhidOutput:
+			// struct uhid_output_req: data[4096] size rtype. The data starts with the report ID, 0.
+			size := int(binary.NativeEndian.Uint16(ev[4+4096:]))
+			data := ev[4 : 4+min(size, 4096)]
+			if len(data) == ctaphid.PacketSize+1 {
+				data = data[1:]
+			}
+			return bytes.Clone(data), nil
+		case uhidGetReport:
+			if err := d.replyReport(uhidGetReportReply, ev); err != nil {
+				return nil, err
+			}
+		case uhidSetReport:
+			if err := d.replyReport(uhidSetReportReply, ev); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// replyReport fails the GET_REPORT or SET_REPORT request ev with EIO.
+func (d *Device) replyReport(typ uint32, ev []byte) error {
+	// The replies start with the ID of the request and an error code.
+	reply := make([]byte, 4+4+2+2)
+	binary.NativeEndian.PutUint32(reply, typ)
+	copy(reply[4:8], ev[4:8])
+	binary.NativeEndian.PutUint16(reply[8:], 5) // EIO
+	_, err := d.f.Write(reply)
+	return err
+}
+
+// WriteReport sends an input report to the readers of the hidraw node.
+func (d *Device) WriteReport(report []byte) error {
+	// struct uhid_input2_req: size data[4096].
+	ev := make([]byte, 4+2+len(report))
+	binary.NativeEndian.PutUint32(ev, uhidInput2)
+	binary.NativeEndian.PutUint16(ev[4:], uint16(len(report))) //nolint:gosec // Reports are 64 bytes.
+	copy(ev[6:], report)
+
+	_, err := d.f.Write(ev)
+	if errors.Is(err, os.ErrClosed) {
+		return errClosed
+	}
+	return err
+}
+
+// Close destroys the device, removing its hidraw node.
+func (d *Device) Close() error {
+	ev := make([]byte, 4)
+	binary.NativeEndian.PutUint32(ev, uhidDestroy)
+	_, err := d.f.Write(ev)
+	return errors.Join(err, d.f.Close())
+}
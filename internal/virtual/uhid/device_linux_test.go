@@ -0,0 +1,82 @@
+package uhid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/skm/internal/virtual"
+)
+
+func TestDevice(t *testing.T) {
+	if f, err := os.OpenFile("/dev/uhid", os.O_RDWR, 0); err != nil {
+		t.Skipf("UHID is unavailable: %v", err)
+	} else {
+		_ = f.Close()
+	}
+
+	a, err := virtual.Open(filepath.Join(t.TempDir(), "key.json"))
+	if err != nil {
+		t.Fatalf("virtual.Open() error = %v", err)
+	}
+	defer func() {
+		_ = a.Close()
+	}()
+
+	d, err := Create(virtual.Product)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	path, err := d.Path()
+	if err != nil {
+		_ = d.Close()
+		t.Fatalf("Path() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- (&Server{Authenticator: a}).Serve(ctx, d)
+	}()
+	defer func() {
+		cancel()
+		_ = d.Close()
+		if err := <-done; !errors.Is(err, context.Canceled) && !errors.Is(err, errClosed) {
+			t.Errorf("Serve() error = %v", err)
+		}
+	}()
+
+	hidraw, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	defer func() {
+		_ = hidraw.Close()
+	}()
+
+	// An output report is prefixed with its report ID, 0.
+	nonce := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	report := make([]byte, 1+ctaphid.PacketSize)
+	copy(report[1:], ctaphid.BroadcastCID[:])
+	report[5] = byte(ctaphid.CmdInit) | ctaphid.InitPacketBit
+	report[7] = byte(len(nonce))
+	copy(report[8:], nonce)
+	if _, err := hidraw.Write(report); err != nil {
+		t.Fatalf("writing INIT: %v", err)
+	}
+
+	resp := make([]byte, ctaphid.PacketSize)
+	if _, err := hidraw.Read(resp); err != nil {
+		t.Fatalf("reading INIT response: %v", err)
+	}
+	if ctaphid.ChannelID(resp[:4]) != ctaphid.BroadcastCID || resp[4] != report[5] {
+		t.Fatalf("INIT response = %x, want an INIT on the broadcast channel", resp)
+	}
+	if !bytes.Equal(resp[7:15], nonce) {
+		t.Errorf("INIT response nonce = %x, want %x", resp[7:15], nonce)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !linux
+
+package uhid
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// Device is a HID security key created through /dev/uhid, which only exists on Linux.
+type Device struct{}
+
+// Create fails: UHID is only available on Linux.
+func Create(string) (*Device, error) {
+	return nil, fmt.Errorf("uhid: not supported on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+}
+
+// Path returns the hidraw node of the device.
+func (*Device) Path() (string, error) {
+	return "", errors.ErrUnsupported
+}
+
+// ReadReport returns the next output report written to the hidraw node.
+func (*Device) ReadReport() ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// WriteReport sends an input report to the readers of the hidraw node.
+func (*Device) WriteReport([]byte) error {
+	return errors.ErrUnsupported
+}
+
+// Close destroys the device.
+func (*Device) Close() error {
+	return nil
+}
@@ -0,0 +1,318 @@
+// Package uhid exposes a virtual authenticator as a HID security key. Server speaks CTAPHID, the USB HID
+// transport of CTAP2, over any Transport of 64-byte reports; on Linux, Device provides such a Transport as a
+// hidraw node created through /dev/uhid, which FIDO2 clients such as browsers and OpenSSH can use.
+package uhid
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/skm/internal/virtual"
+)
+
+const (
+	// keepAliveInterval is how often a KEEPALIVE is sent while a CBOR command is processed.
+	keepAliveInterval = 100 * time.Millisecond
+	// maxMessageSize is the largest payload CTAPHID can carry: one init packet and 128 continuation packets.
+	maxMessageSize = ctaphid.PacketSize - 7 + 128*(ctaphid.PacketSize-5)
+
+	// capabilities advertises WINK and CBOR, and no CTAP1/U2F MSG.
+	capabilities = ctaphid.CapabilityWINK | ctaphid.CapabilityCBOR | ctaphid.CapabilityNMSG
+	// protocolVersion is the CTAPHID protocol version reported by INIT.
+	protocolVersion = 2
+)
+
+// KEEPALIVE status codes.
+const (
+	statusProcessing byte = 1
+	statusUPNeeded   byte = 2
+)
+
+// Transport exchanges HID reports with the platform.
+type Transport interface {
+	// ReadReport returns the next output report sent by the platform, without a report ID.
+	ReadReport() ([]byte, error)
+	// WriteReport sends an input report of ctaphid.PacketSize bytes to the platform.
+	WriteReport(report []byte) error
+}
+
+// Server executes the CTAPHID messages received over a Transport with a virtual authenticator. Like a security
+// key, it processes one CBOR command at a time; a command is cancelled by CTAPHID_CANCEL or by INIT on its
+// channel.
+type Server struct {
+	// Authenticator executes the CBOR commands.
+	Authenticator *virtual.Authenticator
+	// Presence is asked for user presence. A nil Presence grants it at once.
+	Presence virtual.Presence
+	// Wink is called on CTAPHID_WINK, if set.
+	Wink func()
+
+	// mu serializes the writes of the responses and the keepalives sent while a command is processed.
+	mu        sync.Mutex
+	transport Transport
+
+	channels map[ctaphid.ChannelID]bool
+	message  *message
+	// busy is the CBOR command being processed, which clears it before writing its response.
+	busy atomic.Pointer[transaction]
+	wg   sync.WaitGroup
+}
+
+// message is a request being reassembled from its packets.
+type message struct {
+	cid    ctaphid.ChannelID
+	cmd    ctaphid.Command
+	length int
+	data   []byte
+	seq    byte
+}
+
+// transaction is a CBOR command being processed.
+type transaction struct {
+	cid    ctaphid.ChannelID
+	cancel context.CancelFunc
+}
+
+// Serve reads requests from t and writes their responses until ctx is done or t fails. A command being
+// processed when Serve returns is cancelled.
+func (s *Server) Serve(ctx context.Context, t Transport) error {
+	s.transport = t
+	s.channels = make(map[ctaphid.ChannelID]bool)
+
+	reports := make(chan []byte)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			report, err := t.ReadReport()
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case reports <- report:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		s.cancel(ctaphid.ChannelID{}, true)
+		s.wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errc:
+			return err
+		case report := <-reports:
+			if err := s.handleReport(ctx, report); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleReport handles a packet of a request.
+func (s *Server) handleReport(ctx context.Context, report []byte) error {
+	if len(report) < 5 {
+		return nil
+	}
+	var cid ctaphid.ChannelID
+	copy(cid[:], report)
+
+	if report[4]&ctaphid.InitPacketBit == 0 {
+		return s.handleContinuation(ctx, cid, report[4], report[5:])
+	}
+
+	cmd := ctaphid.Command(report[4] &^ ctaphid.InitPacketBit)
+	if len(report) < 7 {
+		return s.writeError(cid, ctaphid.ErrorInvalidLen)
+	}
+	length := int(binary.BigEndian.Uint16(report[5:7]))
+	data := report[7:]
+
+	switch {
+	case cmd == ctaphid.CmdInit:
+		return s.handleInit(cid, length, data)
+	case cid == ctaphid.BroadcastCID || !s.channels[cid]:
+		return s.writeError(cid, ctaphid.ErrorInvalidChannel)
+	case cmd == ctaphid.CmdCancel:
+		s.cancel(cid, false)
+		return nil
+	case s.busy.Load() != nil || (s.message != nil && s.message.cid != cid):
+		return s.writeError(cid, ctaphid.ErrorChannelBusy)
+	case s.message != nil:
+		// A new request on the channel aborts the one being reassembled.
+		s.message = nil
+		return s.writeError(cid, ctaphid.ErrorInvalidSeq)
+	case length > maxMessageSize:
+		return s.writeError(cid, ctaphid.ErrorInvalidLen)
+	}
+
+	s.message = &message{cid: cid, cmd: cmd, length: length}
+	return s.appendData(ctx, data)
+}
+
+// handleContinuation handles a continuation packet. Packets of no request being reassembled are ignored.
+func (s *Server) handleContinuation(ctx context.Context, cid ctaphid.ChannelID, seq byte, data []byte) error {
+	if s.message == nil || s.message.cid != cid {
+		return nil
+	}
+	if seq != s.message.seq {
+		s.message = nil
+		return s.writeError(cid, ctaphid.ErrorInvalidSeq)
+	}
+	s.message.seq++
+	return s.appendData(ctx, data)
+}
+
+// appendData appends the data of a packet to the request being reassembled and executes it once complete.
+func (s *Server) appendData(ctx context.Context, data []byte) error {
+	m := s.message
+	m.data = append(m.data, data[:min(len(data), m.length-len(m.data))]...)
+	if len(m.data) < m.length {
+		return nil
+	}
+
+	s.message = nil
+	return s.execute(ctx, m)
+}
+
+// handleInit allocates a channel, or resynchronizes an allocated one by aborting its request.
+func (s *Server) handleInit(cid ctaphid.ChannelID, length int, data []byte) error {
+	if length != 8 || len(data) < 8 {
+		return s.writeError(cid, ctaphid.ErrorInvalidLen)
+	}
+
+	newCID := cid
+	if cid == ctaphid.BroadcastCID {
+		for newCID == ctaphid.BroadcastCID || newCID == (ctaphid.ChannelID{}) || s.channels[newCID] {
+			if _, err := rand.Read(newCID[:]); err != nil {
+				return err
+			}
+		}
+		s.channels[newCID] = true
+	} else {
+		if !s.channels[cid] {
+			return s.writeError(cid, ctaphid.ErrorInvalidChannel)
+		}
+		if s.message != nil && s.message.cid == cid {
+			s.message = nil
+		}
+		s.cancel(cid, false)
+	}
+
+	resp := bytes.Clone(data[:8])
+	resp = append(resp, newCID[:]...)
+	resp = append(resp, protocolVersion, 0, 1, 0, byte(capabilities))
+	return s.write(cid, ctaphid.CmdInit, resp)
+}
+
+// execute executes a complete request.
+func (s *Server) execute(ctx context.Context, m *message) error {
+	switch m.cmd {
+	case ctaphid.CmdPing:
+		return s.write(m.cid, ctaphid.CmdPing, m.data)
+	case ctaphid.CmdWink:
+		if s.Wink != nil {
+			s.Wink()
+		}
+		return s.write(m.cid, ctaphid.CmdWink, nil)
+	case ctaphid.CmdCBOR:
+		if len(m.data) == 0 {
+			return s.writeError(m.cid, ctaphid.ErrorInvalidLen)
+		}
+		s.executeCBOR(ctx, m)
+		return nil
+	default:
+		return s.writeError(m.cid, ctaphid.ErrorInvalidCmd)
+	}
+}
+
+// executeCBOR starts executing a CBOR command, sending keepalives until its response is written.
+func (s *Server) executeCBOR(ctx context.Context, m *message) {
+	ctx, cancel := context.WithCancel(ctx)
+	tx := &transaction{cid: m.cid, cancel: cancel}
+	s.busy.Store(tx)
+
+	var status atomic.Uint32
+	status.Store(uint32(statusProcessing))
+	presence := func(ctx context.Context) error {
+		status.Store(uint32(statusUPNeeded))
+		defer status.Store(uint32(statusProcessing))
+
+		if s.Presence == nil {
+			return nil
+		}
+		return s.Presence(ctx)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+
+		respc := make(chan []byte, 1)
+		go func() {
+			respc <- s.Authenticator.HandleCBOR(ctx, m.data, presence)
+		}()
+
+		ticker := time.NewTicker(keepAliveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case resp := <-respc:
+				s.busy.CompareAndSwap(tx, nil)
+				_ = s.write(m.cid, ctaphid.CmdCBOR, resp)
+				return
+			case <-ticker.C:
+				_ = s.write(m.cid, ctaphid.CmdKeepAlive, []byte{byte(status.Load())})
+			}
+		}
+	}()
+}
+
+// cancel cancels the CBOR command being processed on cid, or on any channel if all is set.
+func (s *Server) cancel(cid ctaphid.ChannelID, all bool) {
+	if tx := s.busy.Load(); tx != nil && (all || tx.cid == cid) {
+		tx.cancel()
+	}
+}
+
+// writeError sends a CTAPHID_ERROR.
+func (s *Server) writeError(cid ctaphid.ChannelID, code ctaphid.Error) error {
+	return s.write(cid, ctaphid.CmdError, []byte{byte(code)})
+}
+
+// write sends a response in as many packets as it needs.
+func (s *Server) write(cid ctaphid.ChannelID, cmd ctaphid.Command, data []byte) error {
+	msg, err := ctaphid.NewMessage(cid, cmd, data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range msg {
+		buf := bytes.NewBuffer(make([]byte, 0, ctaphid.PacketSize))
+		if _, err := p.WriteTo(buf); err != nil {
+			return err
+		}
+		report := make([]byte, ctaphid.PacketSize)
+		copy(report, buf.Bytes())
+		if err := s.transport.WriteReport(report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
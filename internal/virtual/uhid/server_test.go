@@ -0,0 +1,297 @@
+package uhid
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/skm/internal/virtual"
+)
+
+// pipe is an in-memory Transport, as the hidraw node of a Device would be.
+type pipe struct {
+	out chan []byte // Output reports, from the platform.
+	in  chan []byte // Input reports, to the platform.
+}
+
+func (p *pipe) ReadReport() ([]byte, error) {
+	report, ok := <-p.out
+	if !ok {
+		return nil, io.EOF
+	}
+	return report, nil
+}
+
+func (p *pipe) WriteReport(report []byte) error {
+	p.in <- bytes.Clone(report)
+	return nil
+}
+
+// platform is the client side of a pipe.
+type platform struct {
+	t *testing.T
+	p *pipe
+}
+
+// serve starts a Server over a pipe and returns the platform side of it.
+func serve(t *testing.T, presence virtual.Presence, wink func()) *platform {
+	t.Helper()
+
+	a, err := virtual.Open(filepath.Join(t.TempDir(), "key.json"))
+	if err != nil {
+		t.Fatalf("virtual.Open() error = %v", err)
+	}
+	p := &pipe{out: make(chan []byte), in: make(chan []byte, 256)}
+	s := &Server{Authenticator: a, Presence: presence, Wink: wink}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(ctx, p)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Serve() error = %v, want %v", err, context.Canceled)
+		}
+		_ = a.Close()
+	})
+
+	return &platform{t: t, p: p}
+}
+
+// send writes a request in as many reports as it needs.
+func (pl *platform) send(cid ctaphid.ChannelID, cmd ctaphid.Command, data []byte) {
+	pl.t.Helper()
+
+	msg, err := ctaphid.NewMessage(cid, cmd, data)
+	if err != nil {
+		pl.t.Fatalf("NewMessage() error = %v", err)
+	}
+	for _, packet := range msg {
+		var buf bytes.Buffer
+		if _, err := packet.WriteTo(&buf); err != nil {
+			pl.t.Fatalf("WriteTo() error = %v", err)
+		}
+		report := make([]byte, ctaphid.PacketSize)
+		copy(report, buf.Bytes())
+		pl.p.out <- report
+	}
+}
+
+// receive reassembles the next response.
+func (pl *platform) receive() (ctaphid.ChannelID, ctaphid.Command, []byte) {
+	pl.t.Helper()
+
+	read := func() []byte {
+		select {
+		case report := <-pl.p.in:
+			return report
+		case <-time.After(5 * time.Second):
+			pl.t.Fatal("timed out waiting for a response")
+			return nil
+		}
+	}
+
+	report := read()
+	cid := ctaphid.ChannelID(report[:4])
+	if report[4]&ctaphid.InitPacketBit == 0 {
+		pl.t.Fatalf("got continuation packet %x, want an init packet", report)
+	}
+	cmd := ctaphid.Command(report[4] &^ ctaphid.InitPacketBit)
+	length := int(binary.BigEndian.Uint16(report[5:7]))
+
+	data := report[7:min(7+length, ctaphid.PacketSize)]
+	for seq := byte(0); len(data) < length; seq++ {
+		report := read()
+		if report[4] != seq {
+			pl.t.Fatalf("got sequence %d, want %d", report[4], seq)
+		}
+		data = append(data, report[5:min(5+length-len(data), ctaphid.PacketSize)]...)
+	}
+	return cid, cmd, data
+}
+
+// receiveCommand reassembles the next response, which must be cmd on cid.
+func (pl *platform) receiveCommand(cid ctaphid.ChannelID, cmd ctaphid.Command) []byte {
+	pl.t.Helper()
+
+	gotCID, gotCmd, data := pl.receive()
+	if gotCID != cid || gotCmd != cmd {
+		pl.t.Fatalf("got %s %x on %x, want %s on %x", gotCmd, data, gotCID, cmd, cid)
+	}
+	return data
+}
+
+// init allocates a channel.
+func (pl *platform) init() ctaphid.ChannelID {
+	pl.t.Helper()
+
+	nonce := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	pl.send(ctaphid.BroadcastCID, ctaphid.CmdInit, nonce)
+	resp := pl.receiveCommand(ctaphid.BroadcastCID, ctaphid.CmdInit)
+	if len(resp) < 17 || !bytes.Equal(resp[:8], nonce) {
+		pl.t.Fatalf("INIT response = %x, want one echoing nonce %x", resp, nonce)
+	}
+	return ctaphid.ChannelID(resp[8:12])
+}
+
+func TestInit(t *testing.T) {
+	pl := serve(t, nil, nil)
+
+	nonce := []byte{8, 7, 6, 5, 4, 3, 2, 1}
+	pl.send(ctaphid.BroadcastCID, ctaphid.CmdInit, nonce)
+	resp := pl.receiveCommand(ctaphid.BroadcastCID, ctaphid.CmdInit)
+
+	cid := ctaphid.ChannelID(resp[8:12])
+	if cid == ctaphid.BroadcastCID || cid == (ctaphid.ChannelID{}) {
+		t.Errorf("allocated channel %x, want a unicast one", cid)
+	}
+	if resp[12] != protocolVersion {
+		t.Errorf("protocol version = %d, want %d", resp[12], protocolVersion)
+	}
+	if caps := ctaphid.CapabilityFlag(resp[16]); caps != capabilities {
+		t.Errorf("capabilities = %#x, want %#x", caps, capabilities)
+	}
+
+	if other := pl.init(); other == cid {
+		t.Errorf("second INIT allocated the same channel %x", cid)
+	}
+
+	// INIT on an allocated channel resynchronizes it.
+	pl.send(cid, ctaphid.CmdInit, nonce)
+	resp = pl.receiveCommand(cid, ctaphid.CmdInit)
+	if got := ctaphid.ChannelID(resp[8:12]); got != cid {
+		t.Errorf("resynchronized channel = %x, want %x", got, cid)
+	}
+}
+
+func TestPing(t *testing.T) {
+	pl := serve(t, nil, nil)
+	cid := pl.init()
+
+	// The payload takes an init packet and several continuation packets.
+	data := bytes.Repeat([]byte("ping"), 100)
+	pl.send(cid, ctaphid.CmdPing, data)
+	if got := pl.receiveCommand(cid, ctaphid.CmdPing); !bytes.Equal(got, data) {
+		t.Errorf("PING echoed %x, want %x", got, data)
+	}
+}
+
+func TestWink(t *testing.T) {
+	winked := make(chan struct{}, 1)
+	pl := serve(t, nil, func() { winked <- struct{}{} })
+	cid := pl.init()
+
+	pl.send(cid, ctaphid.CmdWink, nil)
+	pl.receiveCommand(cid, ctaphid.CmdWink)
+	select {
+	case <-winked:
+	default:
+		t.Error("WINK didn't call Wink")
+	}
+}
+
+func TestCBOR(t *testing.T) {
+	pl := serve(t, nil, nil)
+	cid := pl.init()
+
+	pl.send(cid, ctaphid.CmdCBOR, []byte{byte(ctap2.CMDAuthenticatorGetInfo)})
+	resp := pl.receiveCommand(cid, ctaphid.CmdCBOR)
+	if status := ctaphid.StatusCode(resp[0]); status != ctaphid.StatusCTAP2OK {
+		t.Fatalf("getInfo status = %s, want %s", status, ctaphid.StatusCTAP2OK)
+	}
+
+	var info ctap2.AuthenticatorGetInfoResponse
+	if err := cbor.Unmarshal(resp[1:], &info); err != nil {
+		t.Fatalf("decoding getInfo: %v", err)
+	}
+	if len(info.Versions) == 0 {
+		t.Error("getInfo has no versions")
+	}
+}
+
+func TestKeepAliveAndCancel(t *testing.T) {
+	// The user never touches the key.
+	pl := serve(t, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, nil)
+	cid := pl.init()
+	other := pl.init()
+
+	pl.send(cid, ctaphid.CmdCBOR, []byte{byte(ctap2.CMDAuthenticatorSelection)})
+	status := pl.receiveCommand(cid, ctaphid.CmdKeepAlive)
+	if len(status) != 1 || status[0] != statusUPNeeded {
+		t.Errorf("KEEPALIVE status = %x, want %d", status, statusUPNeeded)
+	}
+
+	// The other channel waits for the command to complete.
+	pl.send(other, ctaphid.CmdPing, []byte("ping"))
+	for {
+		gotCID, cmd, data := pl.receive()
+		if cmd == ctaphid.CmdKeepAlive {
+			continue
+		}
+		if gotCID != other || cmd != ctaphid.CmdError || ctaphid.Error(data[0]) != ctaphid.ErrorChannelBusy {
+			t.Fatalf("got %s %x on %x, want ERR_CHANNEL_BUSY on %x", cmd, data, gotCID, other)
+		}
+		break
+	}
+
+	pl.send(cid, ctaphid.CmdCancel, nil)
+	for {
+		gotCID, cmd, data := pl.receive()
+		if cmd == ctaphid.CmdKeepAlive {
+			continue
+		}
+		if gotCID != cid || cmd != ctaphid.CmdCBOR {
+			t.Fatalf("got %s %x on %x, want CBOR on %x", cmd, data, gotCID, cid)
+		}
+		if got := ctaphid.StatusCode(data[0]); got != ctaphid.StatusCTAP2ErrKeepaliveCancel {
+			t.Errorf("cancelled selection status = %s, want %s", got, ctaphid.StatusCTAP2ErrKeepaliveCancel)
+		}
+		break
+	}
+
+	// The other channel is served again once the command is done.
+	pl.send(other, ctaphid.CmdPing, []byte("ping"))
+	pl.receiveCommand(other, ctaphid.CmdPing)
+}
+
+func TestErrors(t *testing.T) {
+	pl := serve(t, nil, nil)
+	cid := pl.init()
+
+	tests := []struct {
+		name string
+		cid  ctaphid.ChannelID
+		cmd  ctaphid.Command
+		data []byte
+		want ctaphid.Error
+	}{
+		{"unallocated channel", ctaphid.ChannelID{1, 2, 3, 4}, ctaphid.CmdPing, nil, ctaphid.ErrorInvalidChannel},
+		{"broadcast channel", ctaphid.BroadcastCID, ctaphid.CmdPing, nil, ctaphid.ErrorInvalidChannel},
+		{"U2F message", cid, ctaphid.CmdMsg, []byte{0}, ctaphid.ErrorInvalidCmd},
+		{"lock", cid, ctaphid.CmdLock, []byte{1}, ctaphid.ErrorInvalidCmd},
+		{"empty CBOR", cid, ctaphid.CmdCBOR, nil, ctaphid.ErrorInvalidLen},
+		{"INIT without nonce", ctaphid.BroadcastCID, ctaphid.CmdInit, []byte{1}, ctaphid.ErrorInvalidLen},
+	}
+
+	// The requests share the platform of t, so they don't run as subtests.
+	for _, tt := range tests {
+		pl.send(tt.cid, tt.cmd, tt.data)
+		resp := pl.receiveCommand(tt.cid, ctaphid.CmdError)
+		if got := ctaphid.Error(resp[0]); got != tt.want {
+			t.Errorf("%s: error = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
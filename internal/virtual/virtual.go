@@ -0,0 +1,271 @@
+// Package virtual implements a software CTAP2 authenticator whose state is kept in a JSON file. It implements
+// getInfo, makeCredential, getAssertion, clientPIN, credentialManagement, authenticatorConfig, reset,
+// bioEnrollment and largeBlobs with the semantics of a CTAP 2.1 security key, so commands can be exercised
+// without a physical key. HandleCBOR executes the same commands in their CTAP2 encoding, which lets a transport
+// expose the authenticator to other FIDO2 clients. User presence is granted unless a Presence asks the user for
+// it, and fingerprint samples are always good.
+package virtual
+
+import (
+	"context"
+	"crypto/ecdh"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+)
+
+const (
+	// Manufacturer is the manufacturer reported in the device descriptor of a virtual authenticator.
+	Manufacturer = "skm"
+	// Product is the product name reported in the device descriptor of a virtual authenticator.
+	Product = "Virtual Authenticator"
+
+	// ResetWindow is how long after the authenticator is opened a reset is allowed, like the 10 seconds
+	// after power-up of a physical key.
+	ResetWindow = 10 * time.Second
+
+	maxPINRetries             = 8
+	maxUVRetries              = 8
+	maxConsecutivePINFailures = 3
+	defaultMinPINLength       = 4
+	maxPINLength              = 63
+	maxCredentials            = 25
+	maxRPIDsForSetMinPINLen   = 4
+	maxEnrollments            = 5
+	samplesPerEnrollment      = 4
+	maxTemplateFriendlyName   = 32
+	maxSerializedLargeBlobs   = 2048
+	maxMsgSize                = 1200
+	maxCredBlobLength         = 32
+)
+
+// ErrClosed is returned when a closed authenticator is used.
+var ErrClosed = errors.New("virtual authenticator is closed")
+
+// Authenticator is a virtual authenticator. It mirrors the methods of fido2.Device, including the errors
+// returned for unsupported features, and persists every change to its state file.
+type Authenticator struct {
+	// cborMu serializes HandleCBOR, whose commands may take mu more than once.
+	cborMu sync.Mutex
+	mu     sync.Mutex
+	path   string
+	state  *State
+	opened time.Time
+	closed bool
+
+	// pinFailures counts consecutive wrong PINs since the authenticator was opened, which stands for the
+	// last power-up.
+	pinFailures int
+
+	token            []byte
+	tokenPermissions ctap2.Permission
+	tokenRPID        string
+
+	enrollment *pendingEnrollment
+
+	// keyAgreement is the key agreement key of the PIN/UV auth protocols, generated when a platform first
+	// asks for it.
+	keyAgreement *ecdh.PrivateKey
+	// next holds the responses left for authenticatorGetNextAssertion and the getNext subcommands of
+	// authenticatorCredentialManagement.
+	next *pendingResponses
+	// largeBlobWrite is the large-blob array being written in fragments by authenticatorLargeBlobs.
+	largeBlobWrite *pendingLargeBlobs
+}
+
+// Open opens the virtual authenticator stored at path, creating it if the file does not exist.
+func Open(path string) (*Authenticator, error) {
+	state, err := LoadState(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{
+		path:   path,
+		state:  state,
+		opened: time.Now(),
+	}, nil
+}
+
+// Describe returns the device descriptor of the virtual authenticator stored at path. The path of the
+// descriptor is path prefixed with prefix.
+func Describe(prefix, path string) (fido2.DeviceDescriptor, error) {
+	state, err := LoadState(path)
+	if err != nil {
+		return fido2.DeviceDescriptor{}, err
+	}
+
+	return fido2.DeviceDescriptor{
+		Path:         prefix + path,
+		SerialNumber: state.SerialNumber,
+		Manufacturer: Manufacturer,
+		Product:      Product,
+	}, nil
+}
+
+// Path returns the path of the state file.
+func (a *Authenticator) Path() string {
+	return a.path
+}
+
+// State returns a copy of the current state.
+func (a *Authenticator) State() State {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return *a.state
+}
+
+// Close closes the authenticator. Any pinUvAuthToken and enrollment in progress are discarded.
+func (a *Authenticator) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.closed = true
+	a.token = nil
+	a.enrollment = nil
+	a.keyAgreement = nil
+	a.next = nil
+	a.largeBlobWrite = nil
+	return nil
+}
+
+// Info returns the authenticatorGetInfo response for the current state.
+func (a *Authenticator) Info() *ctap2.AuthenticatorGetInfoResponse {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.info()
+}
+
+func (a *Authenticator) info() *ctap2.AuthenticatorGetInfoResponse {
+	s := a.state
+	f := s.Features
+
+	options := map[ctap2.Option]bool{
+		ctap2.OptionPlatformDevice:              false,
+		ctap2.OptionResidentKeys:                true,
+		ctap2.OptionUserPresence:                true,
+		ctap2.OptionPinUvAuthToken:              true,
+		ctap2.OptionMakeCredentialUvNotRequired: true,
+	}
+	if f.ClientPIN {
+		options[ctap2.OptionClientPIN] = len(s.PINHash) > 0
+	}
+	if f.CredentialManagement {
+		options[ctap2.OptionCredentialManagement] = true
+	}
+	if f.AuthenticatorConfig {
+		options[ctap2.OptionAuthenticatorConfig] = true
+		options[ctap2.OptionSetMinPINLength] = true
+	}
+	if f.BioEnrollment {
+		options[ctap2.OptionBioEnroll] = len(s.Enrollments) > 0
+		options[ctap2.OptionUserVerification] = len(s.Enrollments) > 0
+	}
+	if f.LargeBlobs {
+		options[ctap2.OptionLargeBlobs] = true
+	}
+	if f.AlwaysUV {
+		options[ctap2.OptionAlwaysUv] = s.AlwaysUV
+	}
+	if f.EnterpriseAttestation {
+		options[ctap2.OptionEnterpriseAttestation] = s.EnterpriseAttestation
+	}
+
+	extensions := []webauthn.ExtensionIdentifier{
+		webauthn.ExtensionIdentifierCredentialProtection,
+		webauthn.ExtensionIdentifierCredentialBlob,
+		webauthn.ExtensionIdentifierMinPinLength,
+	}
+	if f.LargeBlobs {
+		extensions = append(extensions, webauthn.ExtensionIdentifierLargeBlobKey)
+	}
+
+	info := &ctap2.AuthenticatorGetInfoResponse{
+		Versions:   []ctap2.Version{ctap2.Fido2_0, ctap2.Fido2_1},
+		Extensions: extensions,
+		AAGUID:     s.AAGUID,
+		Options:    options,
+		MaxMsgSize: maxMsgSize,
+		PinUvAuthProtocols: []ctap2.PinUvAuthProtocolType{
+			ctap2.PinUvAuthProtocolTypeTwo,
+			ctap2.PinUvAuthProtocolTypeOne,
+		},
+		MaxCredentialCountInList: 8,
+		MaxCredentialLength:      128,
+		Transports:               []string{"usb"},
+		Algorithms: []webauthn.PublicKeyCredentialParameters{
+			{Type: webauthn.PublicKeyCredentialTypePublicKey, Algorithm: -7},
+		},
+		ForcePinChange:                   s.ForcePINChange,
+		MinPinLength:                     s.MinPINLength,
+		FirmwareVersion:                  s.FirmwareVersion,
+		MaxCredBlobLength:                maxCredBlobLength,
+		RemainingDiscoverableCredentials: maxCredentials - uint(len(s.Credentials)),
+		MaxPINLength:                     maxPINLength,
+	}
+	if f.LargeBlobs {
+		info.MaxSerializedLargeBlobArray = maxSerializedLargeBlobs
+	}
+	if f.AuthenticatorConfig {
+		info.MaxRPIDsForSetMinPINLength = maxRPIDsForSetMinPINLen
+	}
+	if f.BioEnrollment {
+		info.UvModality = 2 // USER_VERIFY_FINGERPRINT_INTERNAL.
+	}
+
+	return info
+}
+
+// Reset performs a factory reset. Like a physical key, it is only allowed within ResetWindow of the
+// authenticator being opened.
+func (a *Authenticator) Reset() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return ErrClosed
+	}
+	if time.Since(a.opened) > ResetWindow {
+		return ctapError(ctaphid.StatusCTAP2ErrNotAllowed)
+	}
+
+	a.state.reset()
+	a.token = nil
+	a.enrollment = nil
+	a.keyAgreement = nil
+	a.next = nil
+	a.largeBlobWrite = nil
+	a.pinFailures = 0
+
+	return a.save()
+}
+
+// Selection returns immediately, since user presence is always granted.
+func (a *Authenticator) Selection(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// save persists the state. It must be called with the mutex held.
+func (a *Authenticator) save() error {
+	return a.state.Save(a.path)
+}
+
+// ctapError returns the error a CTAPHID device reports for a CBOR command that failed with status.
+func ctapError(status ctaphid.StatusCode) error {
+	return &ctaphid.CTAPError{
+		Command:    ctaphid.CmdCBOR,
+		StatusCode: status,
+	}
+}
+
+// notSupported returns the error fido2.Device reports for a feature the device doesn't support.
+func notSupported(msg string) error {
+	return &fido2.ErrorWithMessage{Err: fido2.ErrNotSupported, Message: msg}
+}
@@ -0,0 +1,505 @@
+package virtual
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mohammadv184/go-fido2"
+	"github.com/mohammadv184/go-fido2/protocol/ctap2"
+	"github.com/mohammadv184/go-fido2/protocol/ctaphid"
+	"github.com/mohammadv184/go-fido2/protocol/webauthn"
+)
+
+const testPIN = "1234"
+
+func openTest(t *testing.T) *Authenticator {
+	t.Helper()
+
+	a, err := Open(filepath.Join(t.TempDir(), "key.json"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = a.Close()
+	})
+	return a
+}
+
+// reopen closes a and opens its state file again, which stands for a power cycle.
+func reopen(t *testing.T, a *Authenticator) *Authenticator {
+	t.Helper()
+
+	_ = a.Close()
+	b, err := Open(a.Path())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.Close()
+	})
+	return b
+}
+
+func token(t *testing.T, a *Authenticator, permissions ctap2.Permission) []byte {
+	t.Helper()
+
+	tok, err := a.GetPinUvAuthTokenUsingPIN(testPIN, permissions, "")
+	if err != nil {
+		t.Fatalf("GetPinUvAuthTokenUsingPIN() error = %v", err)
+	}
+	return tok
+}
+
+func withPIN(t *testing.T) *Authenticator {
+	t.Helper()
+
+	a := openTest(t)
+	if err := a.SetPIN(testPIN); err != nil {
+		t.Fatalf("SetPIN() error = %v", err)
+	}
+	return a
+}
+
+func assertStatus(t *testing.T, err error, want ctaphid.StatusCode) {
+	t.Helper()
+
+	var ctapErr *ctaphid.CTAPError
+	if !errors.As(err, &ctapErr) || ctapErr.StatusCode != want {
+		t.Fatalf("error = %v, want %s", err, want)
+	}
+}
+
+func TestNewAuthenticatorInfo(t *testing.T) {
+	a := openTest(t)
+	info := a.Info()
+
+	if clientPIN, ok := info.Options[ctap2.OptionClientPIN]; !ok || clientPIN {
+		t.Errorf("clientPin option = %v, %v, want false, true", clientPIN, ok)
+	}
+	if info.MinPinLength != defaultMinPINLength {
+		t.Errorf("MinPinLength = %d, want %d", info.MinPinLength, defaultMinPINLength)
+	}
+	if info.MaxSerializedLargeBlobArray == 0 {
+		t.Error("MaxSerializedLargeBlobArray = 0, want a limit")
+	}
+	if _, _, err := a.GetPINRetries(); !errors.Is(err, fido2.ErrPinNotSet) {
+		t.Errorf("GetPINRetries() error = %v, want %v", err, fido2.ErrPinNotSet)
+	}
+}
+
+func TestStatePersists(t *testing.T) {
+	a := withPIN(t)
+	if _, err := a.AddCredential(
+		webauthn.PublicKeyCredentialRpEntity{ID: "example.com"},
+		webauthn.PublicKeyCredentialUserEntity{ID: []byte{1}, Name: "alice"},
+		CredentialOptions{},
+	); err != nil {
+		t.Fatalf("AddCredential() error = %v", err)
+	}
+	aaguid := a.Info().AAGUID
+
+	b := reopen(t, a)
+	if b.Info().AAGUID != aaguid {
+		t.Errorf("AAGUID = %s, want %s", b.Info().AAGUID, aaguid)
+	}
+	if !b.Info().Options[ctap2.OptionClientPIN] {
+		t.Error("clientPin option = false after reopening, want true")
+	}
+	if n := len(b.State().Credentials); n != 1 {
+		t.Errorf("got %d credentials after reopening, want 1", n)
+	}
+}
+
+func TestPINRetries(t *testing.T) {
+	a := withPIN(t)
+
+	tests := []struct {
+		attempt    int
+		wantStatus ctaphid.StatusCode
+		wantLeft   uint
+	}{
+		{1, ctaphid.StatusCTAP2ErrPinInvalid, 7},
+		{2, ctaphid.StatusCTAP2ErrPinInvalid, 6},
+		{3, ctaphid.StatusCTAP2ErrPinAuthBlocked, 5},
+	}
+	for _, tt := range tests {
+		_, err := a.GetPinUvAuthTokenUsingPIN("0000", ctap2.PermissionCredentialManagement, "")
+		assertStatus(t, err, tt.wantStatus)
+
+		left, _, err := a.GetPINRetries()
+		if err != nil {
+			t.Fatalf("GetPINRetries() error = %v", err)
+		}
+		if left != tt.wantLeft {
+			t.Errorf("attempt %d: retries = %d, want %d", tt.attempt, left, tt.wantLeft)
+		}
+	}
+
+	// The correct PIN is refused until a power cycle.
+	_, err := a.GetPinUvAuthTokenUsingPIN(testPIN, ctap2.PermissionCredentialManagement, "")
+	assertStatus(t, err, ctaphid.StatusCTAP2ErrPinAuthBlocked)
+	if _, powerCycle, _ := a.GetPINRetries(); !powerCycle {
+		t.Error("power cycle state = false, want true")
+	}
+
+	a = reopen(t, a)
+	token(t, a, ctap2.PermissionCredentialManagement)
+	if left, _, _ := a.GetPINRetries(); left != maxPINRetries {
+		t.Errorf("retries after correct PIN = %d, want %d", left, maxPINRetries)
+	}
+}
+
+func TestPINBlocked(t *testing.T) {
+	a := withPIN(t)
+
+	for i := range maxPINRetries {
+		if i > 0 && i%maxConsecutivePINFailures == 0 {
+			a = reopen(t, a)
+		}
+		_, _ = a.GetPinUvAuthTokenUsingPIN("0000", ctap2.PermissionCredentialManagement, "")
+	}
+
+	a = reopen(t, a)
+	_, err := a.GetPinUvAuthTokenUsingPIN(testPIN, ctap2.PermissionCredentialManagement, "")
+	assertStatus(t, err, ctaphid.StatusCTAP2ErrPinBlocked)
+}
+
+func TestSetAndChangePIN(t *testing.T) {
+	a := openTest(t)
+
+	assertStatus(t, a.SetPIN("123"), ctaphid.StatusCTAP2ErrPinPolicyViolation)
+	if err := a.SetPIN(testPIN); err != nil {
+		t.Fatalf("SetPIN() error = %v", err)
+	}
+	if err := a.SetPIN(testPIN); !errors.Is(err, fido2.ErrPinAlreadySet) {
+		t.Errorf("second SetPIN() error = %v, want %v", err, fido2.ErrPinAlreadySet)
+	}
+
+	assertStatus(t, a.ChangePIN("0000", "5678"), ctaphid.StatusCTAP2ErrPinInvalid)
+	assertStatus(t, a.ChangePIN(testPIN, "12"), ctaphid.StatusCTAP2ErrPinPolicyViolation)
+	if err := a.ChangePIN(testPIN, "5678"); err != nil {
+		t.Fatalf("ChangePIN() error = %v", err)
+	}
+	if _, err := a.GetPinUvAuthTokenUsingPIN("5678", ctap2.PermissionCredentialManagement, ""); err != nil {
+		t.Errorf("GetPinUvAuthTokenUsingPIN() with new PIN error = %v", err)
+	}
+}
+
+func TestTokenPermissions(t *testing.T) {
+	a := withPIN(t)
+
+	_, err := a.GetPinUvAuthTokenUsingPIN(testPIN, 0, "")
+	assertStatus(t, err, ctaphid.StatusCTAP1ErrInvalidParameter)
+
+	bioToken := token(t, a, ctap2.PermissionBioEnrollment)
+	_, err = a.GetCredsMetadata(bioToken)
+	assertStatus(t, err, ctaphid.StatusCTAP2ErrPinAuthInvalid)
+
+	credToken := token(t, a, ctap2.PermissionCredentialManagement)
+	// Issuing a new token invalidates the previous one.
+	_, err = a.EnumerateEnrollments(bioToken)
+	assertStatus(t, err, ctaphid.StatusCTAP2ErrPinAuthInvalid)
+	if _, err := a.GetCredsMetadata(credToken); err != nil {
+		t.Errorf("GetCredsMetadata() error = %v", err)
+	}
+
+	_, err = a.GetCredsMetadata(nil)
+	assertStatus(t, err, ctaphid.StatusCTAP2ErrPUATRequired)
+}
+
+func TestCredentialManagement(t *testing.T) {
+	a := withPIN(t)
+
+	tok := token(t, a, ctap2.PermissionCredentialManagement)
+	for _, err := range a.EnumerateRPs(tok) {
+		assertStatus(t, err, ctaphid.StatusCTAP2ErrNoCredentials)
+	}
+
+	users := []string{"alice", "bob"}
+	var ids [][]byte
+	for i, name := range users {
+		id, err := a.AddCredential(
+			webauthn.PublicKeyCredentialRpEntity{ID: "example.com", Name: "Example"},
+			webauthn.PublicKeyCredentialUserEntity{ID: []byte{byte(i)}, Name: name},
+			CredentialOptions{CredProtect: 2},
+		)
+		if err != nil {
+			t.Fatalf("AddCredential() error = %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	var rps []*ctap2.AuthenticatorCredentialManagementResponse
+	for rp, err := range a.EnumerateRPs(tok) {
+		if err != nil {
+			t.Fatalf("EnumerateRPs() error = %v", err)
+		}
+		rps = append(rps, rp)
+	}
+	if len(rps) != 1 || rps[0].RP.ID != "example.com" || rps[0].TotalRPs != 1 {
+		t.Fatalf("EnumerateRPs() = %+v, want example.com only", rps)
+	}
+
+	var names []string
+	for c, err := range a.EnumerateCredentials(tok, rps[0].RPIDHash) {
+		if err != nil {
+			t.Fatalf("EnumerateCredentials() error = %v", err)
+		}
+		if c.PublicKey == nil || len(c.LargeBlobKey) != 32 || c.CredProtect != 2 {
+			t.Errorf("credential %s = %+v, want a public key, a largeBlobKey and credProtect 2", c.User.Name, c)
+		}
+		names = append(names, c.User.Name)
+	}
+	if len(names) != 2 || names[0] != "alice" || names[1] != "bob" {
+		t.Errorf("credential users = %v, want %v", names, users)
+	}
+
+	descriptor := webauthn.PublicKeyCredentialDescriptor{Type: webauthn.PublicKeyCredentialTypePublicKey, ID: ids[0]}
+	if err := a.UpdateUserInformation(
+		tok, descriptor, webauthn.PublicKeyCredentialUserEntity{ID: []byte{0}, Name: "carol"},
+	); err != nil {
+		t.Fatalf("UpdateUserInformation() error = %v", err)
+	}
+	assertStatus(t, a.UpdateUserInformation(
+		tok, descriptor, webauthn.PublicKeyCredentialUserEntity{ID: []byte{9}, Name: "mallory"},
+	), ctaphid.StatusCTAP1ErrInvalidParameter)
+	if name := a.State().Credentials[0].User.Name; name != "carol" {
+		t.Errorf("user name = %q, want carol", name)
+	}
+
+	if err := a.DeleteCredential(tok, descriptor); err != nil {
+		t.Fatalf("DeleteCredential() error = %v", err)
+	}
+	assertStatus(t, a.DeleteCredential(tok, descriptor), ctaphid.StatusCTAP2ErrNoCredentials)
+
+	meta, err := a.GetCredsMetadata(tok)
+	if err != nil {
+		t.Fatalf("GetCredsMetadata() error = %v", err)
+	}
+	if meta.ExistingResidentCredentialsCount != 1 {
+		t.Errorf("ExistingResidentCredentialsCount = %d, want 1", meta.ExistingResidentCredentialsCount)
+	}
+}
+
+func TestGetAssertionCredBlob(t *testing.T) {
+	a := withPIN(t)
+
+	id, err := a.AddCredential(
+		webauthn.PublicKeyCredentialRpEntity{ID: "example.com"},
+		webauthn.PublicKeyCredentialUserEntity{ID: []byte{1}},
+		CredentialOptions{CredBlob: []byte("blob")},
+	)
+	if err != nil {
+		t.Fatalf("AddCredential() error = %v", err)
+	}
+
+	tok, err := a.GetPinUvAuthTokenUsingPIN(testPIN, ctap2.PermissionGetAssertion, "example.com")
+	if err != nil {
+		t.Fatalf("GetPinUvAuthTokenUsingPIN() error = %v", err)
+	}
+
+	for assertion, err := range a.GetAssertion(
+		tok,
+		"example.com",
+		[]byte("client data"),
+		[]webauthn.PublicKeyCredentialDescriptor{{Type: webauthn.PublicKeyCredentialTypePublicKey, ID: id}},
+		&webauthn.GetAuthenticationExtensionsClientInputs{
+			GetCredentialBlobInputs: &webauthn.GetCredentialBlobInputs{GetCredBlob: true},
+		},
+		nil,
+	) {
+		if err != nil {
+			t.Fatalf("GetAssertion() error = %v", err)
+		}
+		if got := assertion.ExtensionOutputs.GetCredBlob; !bytes.Equal(got, []byte("blob")) {
+			t.Errorf("credBlob = %q, want %q", got, "blob")
+		}
+		if assertion.AuthData.SignCount != 1 {
+			t.Errorf("sign count = %d, want 1", assertion.AuthData.SignCount)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	a := withPIN(t)
+	tok := token(t, a, ctap2.PermissionAuthenticatorConfiguration)
+
+	if err := a.ToggleAlwaysUV(tok); err != nil {
+		t.Fatalf("ToggleAlwaysUV() error = %v", err)
+	}
+	if !a.Info().Options[ctap2.OptionAlwaysUv] {
+		t.Error("alwaysUv option = false, want true")
+	}
+
+	if err := a.EnableEnterpriseAttestation(tok); err != nil {
+		t.Fatalf("EnableEnterpriseAttestation() error = %v", err)
+	}
+	if !a.Info().Options[ctap2.OptionEnterpriseAttestation] {
+		t.Error("ep option = false, want true")
+	}
+
+	tests := []struct {
+		name       string
+		length     uint
+		rpIDs      []string
+		wantStatus ctaphid.StatusCode
+	}{
+		{"lower than current", 3, nil, ctaphid.StatusCTAP2ErrPinPolicyViolation},
+		{"too many RP IDs", 0, []string{"a", "b", "c", "d", "e"}, ctaphid.StatusCTAP1ErrInvalidParameter},
+		{"longer than PIN", 6, []string{"example.com"}, ctaphid.StatusCTAP2OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.SetMinPINLength(tok, tt.length, tt.rpIDs, false, false)
+			if tt.wantStatus == ctaphid.StatusCTAP2OK {
+				if err != nil {
+					t.Fatalf("SetMinPINLength() error = %v", err)
+				}
+				return
+			}
+			assertStatus(t, err, tt.wantStatus)
+		})
+	}
+
+	info := a.Info()
+	if info.MinPinLength != 6 || !info.ForcePinChange {
+		t.Errorf("MinPinLength, ForcePinChange = %d, %v, want 6, true", info.MinPinLength, info.ForcePinChange)
+	}
+	_, err := a.GetPinUvAuthTokenUsingPIN(testPIN, ctap2.PermissionCredentialManagement, "")
+	assertStatus(t, err, ctaphid.StatusCTAP2ErrPinPolicyViolation)
+
+	if err := a.ChangePIN(testPIN, "123456"); err != nil {
+		t.Fatalf("ChangePIN() error = %v", err)
+	}
+	if a.Info().ForcePinChange {
+		t.Error("ForcePinChange = true after changing the PIN, want false")
+	}
+}
+
+func TestUnsupportedFeature(t *testing.T) {
+	a := withPIN(t)
+	a.state.Features.LargeBlobs = false
+	a.state.Features.AuthenticatorConfig = false
+
+	if _, err := a.GetLargeBlobs(); !errors.Is(err, fido2.ErrNotSupported) {
+		t.Errorf("GetLargeBlobs() error = %v, want %v", err, fido2.ErrNotSupported)
+	}
+	if _, ok := a.Info().Options[ctap2.OptionLargeBlobs]; ok {
+		t.Error("largeBlobs option reported for a disabled feature")
+	}
+	_, err := a.GetPinUvAuthTokenUsingPIN(testPIN, ctap2.PermissionAuthenticatorConfiguration, "")
+	if !errors.Is(err, fido2.ErrNotSupported) {
+		t.Errorf("GetPinUvAuthTokenUsingPIN(acfg) error = %v, want %v", err, fido2.ErrNotSupported)
+	}
+}
+
+func TestBioEnrollment(t *testing.T) {
+	a := withPIN(t)
+	tok := token(t, a, ctap2.PermissionBioEnrollment)
+
+	_, err := a.EnumerateEnrollments(tok)
+	assertStatus(t, err, ctaphid.StatusCTAP2ErrInvalidOption)
+
+	resp, err := a.BeginEnroll(tok, 0)
+	if err != nil {
+		t.Fatalf("BeginEnroll() error = %v", err)
+	}
+	samples := 1
+	for resp.RemainingSamples > 0 {
+		resp, err = a.EnrollCaptureNextSample(tok, resp.TemplateID, 0)
+		if err != nil {
+			t.Fatalf("EnrollCaptureNextSample() error = %v", err)
+		}
+		samples++
+	}
+	if samples != samplesPerEnrollment {
+		t.Errorf("captured %d samples, want %d", samples, samplesPerEnrollment)
+	}
+
+	if err := a.SetFriendlyName(tok, resp.TemplateID, "thumb"); err != nil {
+		t.Fatalf("SetFriendlyName() error = %v", err)
+	}
+	enrollments, err := a.EnumerateEnrollments(tok)
+	if err != nil {
+		t.Fatalf("EnumerateEnrollments() error = %v", err)
+	}
+	if len(enrollments.TemplateInfos) != 1 || enrollments.TemplateInfos[0].TemplateFriendlyName != "thumb" {
+		t.Errorf("TemplateInfos = %+v, want a single thumb", enrollments.TemplateInfos)
+	}
+	if !a.Info().Options[ctap2.OptionUserVerification] {
+		t.Error("uv option = false with an enrolled fingerprint, want true")
+	}
+	if _, err := a.GetPinUvAuthTokenUsingUV(ctap2.PermissionGetAssertion, "example.com"); err != nil {
+		t.Errorf("GetPinUvAuthTokenUsingUV() error = %v", err)
+	}
+
+	tok = token(t, a, ctap2.PermissionBioEnrollment)
+	if err := a.RemoveEnrollment(tok, resp.TemplateID); err != nil {
+		t.Fatalf("RemoveEnrollment() error = %v", err)
+	}
+	assertStatus(t, a.RemoveEnrollment(tok, resp.TemplateID), ctaphid.StatusCTAP2ErrInvalidOption)
+}
+
+func TestLargeBlobs(t *testing.T) {
+	a := withPIN(t)
+
+	blobs, err := a.GetLargeBlobs()
+	if err != nil {
+		t.Fatalf("GetLargeBlobs() error = %v", err)
+	}
+	if len(blobs) != 0 {
+		t.Errorf("GetLargeBlobs() = %d blobs, want none", len(blobs))
+	}
+
+	blob := &ctap2.LargeBlob{Ciphertext: []byte("ciphertext"), Nonce: make([]byte, 12), OrigSize: 4}
+	assertStatus(
+		t,
+		a.SetLargeBlobs(token(t, a, ctap2.PermissionCredentialManagement), []*ctap2.LargeBlob{blob}),
+		ctaphid.StatusCTAP2ErrPinAuthInvalid,
+	)
+
+	tok := token(t, a, ctap2.PermissionLargeBlobWrite)
+	if err := a.SetLargeBlobs(tok, []*ctap2.LargeBlob{blob}); err != nil {
+		t.Fatalf("SetLargeBlobs() error = %v", err)
+	}
+	blobs, err = a.GetLargeBlobs()
+	if err != nil {
+		t.Fatalf("GetLargeBlobs() error = %v", err)
+	}
+	if len(blobs) != 1 || !bytes.Equal(blobs[0].Ciphertext, blob.Ciphertext) {
+		t.Errorf("GetLargeBlobs() = %+v, want the stored blob", blobs)
+	}
+
+	big := &ctap2.LargeBlob{Ciphertext: make([]byte, maxSerializedLargeBlobs), Nonce: make([]byte, 12)}
+	if err := a.SetLargeBlobs(tok, []*ctap2.LargeBlob{big}); !errors.Is(err, fido2.ErrLargeBlobsTooBig) {
+		t.Errorf("SetLargeBlobs() error = %v, want %v", err, fido2.ErrLargeBlobsTooBig)
+	}
+
+	a.state.LargeBlobArray[0] ^= 0xff
+	if _, err := a.GetLargeBlobs(); !errors.Is(err, fido2.ErrLargeBlobsIntegrityCheck) {
+		t.Errorf("GetLargeBlobs() on a corrupted array error = %v, want %v", err, fido2.ErrLargeBlobsIntegrityCheck)
+	}
+}
+
+func TestReset(t *testing.T) {
+	a := withPIN(t)
+	aaguid := a.Info().AAGUID
+
+	a.opened = time.Now().Add(-ResetWindow - time.Second)
+	assertStatus(t, a.Reset(), ctaphid.StatusCTAP2ErrNotAllowed)
+
+	a = reopen(t, a)
+	if err := a.Reset(); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	info := a.Info()
+	if info.Options[ctap2.OptionClientPIN] {
+		t.Error("clientPin option = true after reset, want false")
+	}
+	if info.AAGUID != aaguid {
+		t.Errorf("AAGUID = %s after reset, want %s", info.AAGUID, aaguid)
+	}
+}